@@ -5,12 +5,24 @@ import (
 	"github.com/kcloutie/event-reactor/pkg/reactor/email"
 	"github.com/kcloutie/event-reactor/pkg/reactor/gcppublishpubsub"
 	"github.com/kcloutie/event-reactor/pkg/reactor/gcprotaterandom"
+	"github.com/kcloutie/event-reactor/pkg/reactor/httprequest"
+	"github.com/kcloutie/event-reactor/pkg/reactor/kubernetes"
+	"github.com/kcloutie/event-reactor/pkg/reactor/natspublish"
+	"github.com/kcloutie/event-reactor/pkg/reactor/pagerduty"
 	"github.com/kcloutie/event-reactor/pkg/reactor/powershell"
+	"github.com/kcloutie/event-reactor/pkg/reactor/shell"
+	"github.com/kcloutie/event-reactor/pkg/reactor/slack"
+	"github.com/kcloutie/event-reactor/pkg/reactor/teams"
 	"github.com/kcloutie/event-reactor/pkg/reactor/webex"
 	"github.com/kcloutie/event-reactor/pkg/reactor/webhook"
 
 	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/reactor/githubcheckrun"
 	"github.com/kcloutie/event-reactor/pkg/reactor/githubcomment"
+	"github.com/kcloutie/event-reactor/pkg/reactor/githubdeployment"
+	"github.com/kcloutie/event-reactor/pkg/reactor/githubdispatch"
+	"github.com/kcloutie/event-reactor/pkg/reactor/githubissue"
+	"github.com/kcloutie/event-reactor/pkg/reactor/githubstatus"
 	"go.uber.org/zap"
 )
 
@@ -25,6 +37,46 @@ func GetReactorNewFunctions(loadTestReactor bool) map[string]func(log *zap.Logge
 		return reactor
 	}
 
+	githubStatusReactor := githubstatus.New()
+	results[githubStatusReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := githubstatus.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
+	githubDeploymentReactor := githubdeployment.New()
+	results[githubDeploymentReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := githubdeployment.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
+	githubIssueReactor := githubissue.New()
+	results[githubIssueReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := githubissue.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
+	githubDispatchReactor := githubdispatch.New()
+	results[githubDispatchReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := githubdispatch.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
+	githubCheckRunReactor := githubcheckrun.New()
+	results[githubCheckRunReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := githubcheckrun.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
 	emailReactor := email.New()
 	results[emailReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
 		reactor := email.New()
@@ -41,6 +93,46 @@ func GetReactorNewFunctions(loadTestReactor bool) map[string]func(log *zap.Logge
 		return reactor
 	}
 
+	shellReactor := shell.New()
+	results[shellReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := shell.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
+	httpRequestReactor := httprequest.New()
+	results[httpRequestReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := httprequest.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
+	slackReactor := slack.New()
+	results[slackReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := slack.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
+	teamsReactor := teams.New()
+	results[teamsReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := teams.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
+	pagerDutyReactor := pagerduty.New()
+	results[pagerDutyReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := pagerduty.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
 	webhookReactor := webhook.New()
 	results[webhookReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
 		reactor := webhook.New()
@@ -73,6 +165,22 @@ func GetReactorNewFunctions(loadTestReactor bool) map[string]func(log *zap.Logge
 		return reactor
 	}
 
+	natsPublishReactor := natspublish.New()
+	results[natsPublishReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := natspublish.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
+	kubernetesReactor := kubernetes.New()
+	results[kubernetesReactor.GetName()] = func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface {
+		reactor := kubernetes.New()
+		reactor.SetLogger(log)
+		reactor.SetReactor(reactorConfig)
+		return reactor
+	}
+
 	if loadTestReactor {
 		// Add test reactor
 		testReactor := reactor.NewTestReactor()
@@ -27,6 +27,61 @@ func TestGetReactors(t *testing.T) {
 			reactorType: "github/comment",
 			wantExists:  true,
 		},
+		{
+			name:        "Reactor type is nats/publish",
+			reactorType: "nats/publish",
+			wantExists:  true,
+		},
+		{
+			name:        "Reactor type is http/request",
+			reactorType: "http/request",
+			wantExists:  true,
+		},
+		{
+			name:        "Reactor type is slack",
+			reactorType: "slack",
+			wantExists:  true,
+		},
+		{
+			name:        "Reactor type is teams",
+			reactorType: "teams",
+			wantExists:  true,
+		},
+		{
+			name:        "Reactor type is pagerduty",
+			reactorType: "pagerduty",
+			wantExists:  true,
+		},
+		{
+			name:        "Reactor type is github/status",
+			reactorType: "github/status",
+			wantExists:  true,
+		},
+		{
+			name:        "Reactor type is github/deployment",
+			reactorType: "github/deployment",
+			wantExists:  true,
+		},
+		{
+			name:        "Reactor type is github/issue",
+			reactorType: "github/issue",
+			wantExists:  true,
+		},
+		{
+			name:        "Reactor type is github/dispatch",
+			reactorType: "github/dispatch",
+			wantExists:  true,
+		},
+		{
+			name:        "Reactor type is github/checkrun",
+			reactorType: "github/checkrun",
+			wantExists:  true,
+		},
+		{
+			name:        "Reactor type is kubernetes",
+			reactorType: "kubernetes",
+			wantExists:  true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -13,6 +13,8 @@ import (
 	httper "github.com/kcloutie/event-reactor/pkg/http"
 	"github.com/kcloutie/event-reactor/pkg/listener/generic"
 	"github.com/kcloutie/event-reactor/pkg/listener/pubsub"
+	"github.com/kcloutie/event-reactor/pkg/listener/webex"
+	"github.com/kcloutie/event-reactor/pkg/params/settings"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -48,6 +50,15 @@ func CreateRouter(ctx context.Context, cacheInSeconds int) *gin.Engine {
 			ExecuteListener(ctx, c, genl)
 		})
 
+		wl := webex.New()
+		apiV1.POST(fmt.Sprintf("/%s", wl.GetApiPath()), func(c *gin.Context) {
+			ExecuteListener(ctx, c, wl)
+		})
+
+		apiV1.POST(fmt.Sprintf("/%s", settings.BatchEndpoint), func(c *gin.Context) {
+			ExecuteBatch(ctx, c)
+		})
+
 		// for _, l := range listener.GetListeners() {
 		// 	err := l.Initialize(ctx)
 		// 	if err != nil {
@@ -1 +1,15 @@
 package api
+
+import "github.com/kcloutie/event-reactor/pkg/http"
+
+// BatchEventResult is the result of processing a single event of a batch request
+type BatchEventResult struct {
+	// Index is the position of the event within the batch
+	Index int `json:"index"`
+	// ID is the id of the event. Generic events do not have an id
+	ID string `json:"id,omitempty"`
+	// MatchedReactors are the names of the reactors whose filter matched the event
+	MatchedReactors []string `json:"matchedReactors"`
+	// Errors are the errors that occurred parsing the event or running the reactors
+	Errors []http.ErrorDetail `json:"errors,omitempty"`
+}
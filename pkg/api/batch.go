@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcloutie/event-reactor/pkg/adapter"
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/http"
+	"github.com/kcloutie/event-reactor/pkg/listener/generic"
+	"github.com/kcloutie/event-reactor/pkg/listener/pubsub"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/params/settings"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"go.uber.org/zap"
+)
+
+const batchListenerName = "batch"
+
+// ExecuteBatch processes a JSON array or NDJSON body of events. Each event can either be a pub/sub push envelope or a generic
+// payload. The events are processed in parallel, bounded by the configured max parallelism, and a result is returned for
+// every event
+func ExecuteBatch(ctx context.Context, c *gin.Context) {
+	cfg := config.FromCtx(ctx)
+	var log *zap.Logger
+	log, ctx = http.SetCommonLoggingAttributes(ctx, c)
+	slog := log.Sugar()
+	batchConfig := cfg.GetBatchListenerConfig()
+
+	payload, errD := readRequestBody(log, c, batchListenerName, settings.BatchEndpoint)
+	if errD != nil {
+		WriteResponse(slog, int(errD.Status), []http.ErrorDetail{*errD}, c, cfg)
+		return
+	}
+	if cfg.LogRawPubSubPayload {
+		log.Info("raw batch Payload", zap.String("payload", string(payload)))
+	}
+
+	events, err := splitBatchPayload(payload)
+	if err == nil && batchConfig.MaxEvents > 0 && len(events) > batchConfig.MaxEvents {
+		err = fmt.Errorf("the batch contains %d events which is more than the maximum of %d", len(events), batchConfig.MaxEvents)
+	}
+	if err != nil {
+		errD := &http.ErrorDetail{
+			Type:     "unmarshal-batch-body",
+			Title:    "Unmarshal Batch Body",
+			Status:   400,
+			Detail:   err.Error(),
+			Instance: settings.BatchEndpoint,
+		}
+		log.Error(errD.Detail)
+		WriteResponse(slog, int(errD.Status), []http.ErrorDetail{*errD}, c, cfg)
+		return
+	}
+
+	if len(cfg.ReactorConfigs) == 0 {
+		slog.Warnf("no reactors configured for listener '%s'", batchListenerName)
+	}
+	reactorFunctions := adapter.GetReactorNewFunctions(cfg.LoadTestReactor)
+
+	results := make([]BatchEventResult, len(events))
+	sem := make(chan struct{}, batchConfig.GetMaxParallelism())
+	wg := new(sync.WaitGroup)
+	for i, event := range events {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, event json.RawMessage) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = processBatchEvent(ctx, cfg, log.With(zap.Int("batchIndex", i)), i, event, reactorFunctions)
+		}(i, event)
+	}
+	wg.Wait()
+
+	WriteBatchResponse(slog, results, c, cfg)
+}
+
+func processBatchEvent(ctx context.Context, cfg *config.ServerConfiguration, log *zap.Logger, index int, event json.RawMessage, reactorFunctions map[string]func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface) BatchEventResult {
+	result := BatchEventResult{
+		Index:           index,
+		MatchedReactors: []string{},
+	}
+
+	eventPayload, errD := parseBatchEvent(ctx, log, event)
+	if errD != nil {
+		result.Errors = []http.ErrorDetail{*errD}
+		return result
+	}
+	result.ID = eventPayload.ID
+	log = log.With(zap.String("message_id", eventPayload.ID))
+	if cfg.LogEventDataPayload {
+		log.Info("eventPayload Payload", zap.Any("eventPayload", eventPayload))
+	}
+
+	matched, errors := RunReactorsWithResults(ctx, cfg, log, eventPayload, batchListenerName, settings.BatchEndpoint, reactorFunctions)
+	result.MatchedReactors = matched
+	if len(errors) > 0 {
+		result.Errors = errors
+	}
+	return result
+}
+
+// parseBatchEvent uses the pub/sub listener for events that contain a message object, all other events use the generic listener
+func parseBatchEvent(ctx context.Context, log *zap.Logger, event json.RawMessage) (*message.EventData, *http.ErrorDetail) {
+	var envelope map[string]interface{}
+	if json.Unmarshal(event, &envelope) == nil {
+		if _, isPubSub := envelope["message"].(map[string]interface{}); isPubSub {
+			return pubsub.New().ParsePayload(ctx, log, event)
+		}
+	}
+	return generic.New().ParsePayload(ctx, log, event)
+}
+
+// splitBatchPayload splits the body into the individual events. Bodies starting with [ are treated as a JSON array, otherwise
+// each non empty line is treated as an event
+func splitBatchPayload(payload []byte) ([]json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(payload)
+	events := []json.RawMessage{}
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &events)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the batch body as a JSON array. Error: %v", err)
+		}
+	} else {
+		reader := bufio.NewReader(bytes.NewReader(trimmed))
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("failed to read the NDJSON batch body. Error: %v", err)
+			}
+			line = bytes.TrimSpace(line)
+			if len(line) > 0 {
+				events = append(events, json.RawMessage(line))
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+		}
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("the batch did not contain any events")
+	}
+	return events, nil
+}
+
+// WriteBatchResponse returns a 200 when every event was processed successfully, otherwise a 400 unless the server is
+// configured to always return 200
+func WriteBatchResponse(log *zap.SugaredLogger, results []BatchEventResult, c *gin.Context, cfg *config.ServerConfiguration) {
+	status := 200
+	for _, result := range results {
+		if len(result.Errors) > 0 {
+			status = 400
+			break
+		}
+	}
+	if status != 200 && cfg.AlwaysReturn200 {
+		log.Warn("at least one error occurred however the server is configured to always return 200...returning 200")
+		status = 200
+	}
+
+	c.JSON(status, results)
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	lhttp "github.com/kcloutie/event-reactor/pkg/http"
+	"github.com/kcloutie/event-reactor/pkg/params/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchListener(t *testing.T) {
+	servConf := config.ServerConfiguration{
+		LoadTestReactor: true,
+		ReactorConfigs: []config.ReactorConfig{
+			{
+				Name: "testReactor",
+				Type: "testReactor",
+				Properties: map[string]config.PropertyAndValue{
+					"test": {
+						Value: "test",
+					},
+					"message": {
+						Value: "test",
+					},
+				},
+			},
+			{
+				Name:                "missingReactor",
+				Type:                "missing",
+				CelExpressionFilter: "has(attributes.kind) && attributes.kind == 'bad'",
+			},
+		},
+		Listeners: &config.ListenerConfigs{
+			Batch: &config.BatchListenerConfig{
+				MaxParallelism: 2,
+				MaxEvents:      4,
+			},
+		},
+	}
+
+	ctx := config.WithCtx(context.Background(), &servConf)
+	router := CreateRouter(ctx, 1)
+
+	pubsubPayload, err := os.ReadFile("testdata/pubsubPayload.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compactPubSub := &bytes.Buffer{}
+	err = json.Compact(compactPubSub, pubsubPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		alwaysOk    bool
+		wantCode    int
+		wantResults []BatchEventResult
+		wantBody    string
+	}{
+		{
+			name:     "json array",
+			body:     fmt.Sprintf(`[{"attributes":{"kind":"ok"}},%s,{"attributes":{"kind":"bad"}},"dude"]`, compactPubSub.String()),
+			wantCode: 400,
+			wantResults: []BatchEventResult{
+				{Index: 0, MatchedReactors: []string{"testReactor"}},
+				{Index: 1, ID: "1122334455667788", MatchedReactors: []string{"testReactor"}},
+				{Index: 2, MatchedReactors: []string{"testReactor", "missingReactor"}, Errors: make([]lhttp.ErrorDetail, 1)},
+				{Index: 3, MatchedReactors: []string{}, Errors: make([]lhttp.ErrorDetail, 1)},
+			},
+		},
+		{
+			name:     "ndjson",
+			body:     "{\"attributes\":{\"kind\":\"ok\"}}\n\n" + compactPubSub.String() + "\n",
+			wantCode: 200,
+			wantResults: []BatchEventResult{
+				{Index: 0, MatchedReactors: []string{"testReactor"}},
+				{Index: 1, ID: "1122334455667788", MatchedReactors: []string{"testReactor"}},
+			},
+		},
+		{
+			name:     "always return 200",
+			body:     `[{"attributes":{"kind":"bad"}}]`,
+			alwaysOk: true,
+			wantCode: 200,
+			wantResults: []BatchEventResult{
+				{Index: 0, MatchedReactors: []string{"testReactor", "missingReactor"}, Errors: make([]lhttp.ErrorDetail, 1)},
+			},
+		},
+		{
+			name:     "too many events",
+			body:     `[{},{},{},{},{}]`,
+			wantCode: 400,
+			wantBody: "[{\"type\":\"unmarshal-batch-body\",\"title\":\"Unmarshal Batch Body\",\"status\":400,\"detail\":\"the batch contains 5 events which is more than the maximum of 4\",\"instance\":\"batch\"}]",
+		},
+		{
+			name:     "empty batch",
+			body:     `[]`,
+			wantCode: 400,
+			wantBody: "[{\"type\":\"unmarshal-batch-body\",\"title\":\"Unmarshal Batch Body\",\"status\":400,\"detail\":\"the batch did not contain any events\",\"instance\":\"batch\"}]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servConf.AlwaysReturn200 = tt.alwaysOk
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/v1/"+settings.BatchEndpoint, strings.NewReader(tt.body))
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+				return
+			}
+
+			got := []BatchEventResult{}
+			err := json.Unmarshal(w.Body.Bytes(), &got)
+			if err != nil {
+				t.Fatalf("failed to unmarshal the batch response - %v\n%s", err, w.Body.String())
+			}
+			assert.Equal(t, len(tt.wantResults), len(got))
+			for i, want := range tt.wantResults {
+				assert.Equal(t, want.Index, got[i].Index)
+				assert.Equal(t, want.ID, got[i].ID)
+				assert.Equal(t, want.MatchedReactors, got[i].MatchedReactors)
+				assert.Equal(t, len(want.Errors), len(got[i].Errors))
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"strings"
 	"sync"
 
 	"github.com/gin-gonic/gin"
@@ -11,6 +13,9 @@ import (
 	"github.com/kcloutie/event-reactor/pkg/config"
 	"github.com/kcloutie/event-reactor/pkg/http"
 	"github.com/kcloutie/event-reactor/pkg/listener"
+	"github.com/kcloutie/event-reactor/pkg/listener/filedrop"
+	"github.com/kcloutie/event-reactor/pkg/listener/nats"
+	"github.com/kcloutie/event-reactor/pkg/logger"
 	"github.com/kcloutie/event-reactor/pkg/matcher"
 	"github.com/kcloutie/event-reactor/pkg/message"
 	"github.com/kcloutie/event-reactor/pkg/reactor"
@@ -26,37 +31,21 @@ func ExecuteListener(ctx context.Context, c *gin.Context, listener listener.List
 
 	slog.Debugf("Executing listener '%s'", listener.GetName())
 
-	if c.Request.Body == nil {
-		errorMes := "request body was empty, request cannot be processed"
-		errD := &http.ErrorDetail{
-			Type:     listener.GetName() + "-get-request-body",
-			Title:    listener.GetName() + " Get Request Body",
-			Status:   400,
-			Detail:   errorMes,
-			Instance: listener.GetApiPath(),
-		}
-		log.Error(errorMes)
-		WriteResponse(slog, int(errD.Status), []http.ErrorDetail{*errD}, c, cfg)
-		return
-	}
-
-	payload, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		errD := &http.ErrorDetail{
-			Type:     listener.GetName() + "-get-request-body",
-			Title:    listener.GetName() + " Get Request Body",
-			Status:   400,
-			Detail:   err.Error(),
-			Instance: listener.GetApiPath(),
-		}
-		log.Error(err.Error())
+	payload, errD := readRequestBody(log, c, listener.GetName(), listener.GetApiPath())
+	if errD != nil {
 		WriteResponse(slog, int(errD.Status), []http.ErrorDetail{*errD}, c, cfg)
 		return
 	}
 	if cfg.LogRawPubSubPayload {
 		log.Info("raw Pub/Sub Payload", zap.String("payload", string(payload)))
 	}
-	eventPayload, errD := listener.ParsePayload(ctx, log, payload)
+	request := &http.ListenerRequest{
+		ContentType: c.Request.Header.Get("Content-Type"),
+		Headers:     c.Request.Header,
+		Query:       c.Request.URL.Query(),
+		Payload:     payload,
+	}
+	eventPayloads, errD := listener.ParseRequest(ctx, log, request)
 	if errD != nil {
 		log.Error(errD.Detail)
 
@@ -64,33 +53,73 @@ func ExecuteListener(ctx context.Context, c *gin.Context, listener listener.List
 		return
 	}
 
-	log = log.With(zap.String("message_id", eventPayload.ID))
-
-	if cfg.LogEventDataPayload {
-		log.Info("eventPayload Payload", zap.Any("eventPayload", eventPayload))
-	}
-
 	reactorFunctions := adapter.GetReactorNewFunctions(cfg.LoadTestReactor)
 
 	if len(cfg.ReactorConfigs) == 0 {
 		slog.Warnf("no reactors configured for listener '%s'", listener.GetName())
 	}
 
-	errors := RunReactorsAsync(ctx, cfg, log, eventPayload, listener.GetName(), listener.GetApiPath(), reactorFunctions)
+	errors := []http.ErrorDetail{}
+	for _, eventPayload := range eventPayloads {
+		log := log.With(zap.String("message_id", eventPayload.ID))
+
+		if cfg.LogEventDataPayload {
+			log.Info("eventPayload Payload", zap.Any("eventPayload", eventPayload))
+		}
+
+		errors = append(errors, RunReactorsAsync(ctx, cfg, log, eventPayload, listener.GetName(), listener.GetApiPath(), reactorFunctions)...)
+	}
 	if len(errors) > 0 {
 		WriteResponse(slog, 400, errors, c, cfg)
 		return
 	}
 }
 
+func readRequestBody(log *zap.Logger, c *gin.Context, listenerName string, listenerApiPath string) ([]byte, *http.ErrorDetail) {
+	if c.Request.Body == nil {
+		errorMes := "request body was empty, request cannot be processed"
+		errD := &http.ErrorDetail{
+			Type:     listenerName + "-get-request-body",
+			Title:    listenerName + " Get Request Body",
+			Status:   400,
+			Detail:   errorMes,
+			Instance: listenerApiPath,
+		}
+		log.Error(errorMes)
+		return nil, errD
+	}
+
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		errD := &http.ErrorDetail{
+			Type:     listenerName + "-get-request-body",
+			Title:    listenerName + " Get Request Body",
+			Status:   400,
+			Detail:   err.Error(),
+			Instance: listenerApiPath,
+		}
+		log.Error(err.Error())
+		return nil, errD
+	}
+	return payload, nil
+}
+
 func RunReactorsAsync(ctx context.Context, cfg *config.ServerConfiguration, log *zap.Logger, eventPayload *message.EventData, listenerName string, listenerApiPath string, reactorFunctions map[string]func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface) []http.ErrorDetail {
-	channels := []chan http.ErrorDetail{}
+	_, errors := RunReactorsWithResults(ctx, cfg, log, eventPayload, listenerName, listenerApiPath, reactorFunctions)
+	return errors
+}
+
+// RunReactorsWithResults runs all reactors in parallel and returns the names of the reactors that matched the event along
+// with the errors of the reactors that failed
+func RunReactorsWithResults(ctx context.Context, cfg *config.ServerConfiguration, log *zap.Logger, eventPayload *message.EventData, listenerName string, listenerApiPath string, reactorFunctions map[string]func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface) ([]string, []http.ErrorDetail) {
+	channels := []chan reactorResult{}
+	matched := []string{}
 	errors := []http.ErrorDetail{}
 	wg := new(sync.WaitGroup)
 
 	for i, reactorConfig := range cfg.ReactorConfigs {
 		wg.Add(1)
-		ch := make(chan http.ErrorDetail, 1)
+		ch := make(chan reactorResult, 1)
 		channels = append(channels, ch)
 		defer close(ch)
 		log := log.With(zap.String("reactorName", reactorConfig.Name), zap.String("reactorType", reactorConfig.Type))
@@ -98,14 +127,19 @@ func RunReactorsAsync(ctx context.Context, cfg *config.ServerConfiguration, log
 	}
 	wg.Wait()
 
-	for _, ch := range channels {
+	for i, ch := range channels {
 		select {
-		case errD := <-ch:
-			errors = append(errors, errD)
+		case result := <-ch:
+			if result.matched {
+				matched = append(matched, cfg.ReactorConfigs[i].Name)
+			}
+			if result.errD != nil {
+				errors = append(errors, *result.errD)
+			}
 		default:
 		}
 	}
-	return errors
+	return matched, errors
 }
 
 func WriteResponse(log *zap.SugaredLogger, status int, errD []http.ErrorDetail, c *gin.Context, cfg *config.ServerConfiguration) {
@@ -117,7 +151,13 @@ func WriteResponse(log *zap.SugaredLogger, status int, errD []http.ErrorDetail,
 	c.JSON(status, errD)
 }
 
-func executeReactors(wg *sync.WaitGroup, ch chan http.ErrorDetail, ctx context.Context, reactorConfig config.ReactorConfig, eventPayload *message.EventData, listenerName string, listenerApiPath string, log *zap.Logger, reactorFunctions map[string]func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface) {
+type reactorResult struct {
+	matched bool
+	errD    *http.ErrorDetail
+}
+
+func executeReactors(wg *sync.WaitGroup, ch chan reactorResult, ctx context.Context, reactorConfig config.ReactorConfig, eventPayload *message.EventData, listenerName string, listenerApiPath string, log *zap.Logger, reactorFunctions map[string]func(log *zap.Logger, reactorConfig config.ReactorConfig) reactor.ReactorInterface) {
+	defer wg.Done()
 	matches, err := matcher.Matches(ctx, reactorConfig, eventPayload)
 	if err != nil {
 		errD := http.ErrorDetail{
@@ -129,13 +169,11 @@ func executeReactors(wg *sync.WaitGroup, ch chan http.ErrorDetail, ctx context.C
 		}
 		log.Error(errD.Detail)
 
-		ch <- errD
-		wg.Done()
+		ch <- reactorResult{errD: &errD}
 		return
 	}
 	if !matches {
 		// log.Debug(fmt.Sprintf("reactor '%s' of type '%s' does not match message", reactorConfig.Name, reactorConfig.Type))
-		wg.Done()
 		return
 	}
 
@@ -150,8 +188,7 @@ func executeReactors(wg *sync.WaitGroup, ch chan http.ErrorDetail, ctx context.C
 		}
 		log.Error(errD.Detail)
 
-		ch <- errD
-		wg.Done()
+		ch <- reactorResult{matched: true, errD: &errD}
 		return
 	}
 
@@ -170,14 +207,88 @@ func executeReactors(wg *sync.WaitGroup, ch chan http.ErrorDetail, ctx context.C
 		log.Error(errD.Detail)
 
 		if !reactorConfig.GetFailOnError() {
-			wg.Done()
+			ch <- reactorResult{matched: true}
 			return
 		}
-		ch <- errD
-		wg.Done()
+		ch <- reactorResult{matched: true, errD: &errD}
 		return
 	}
 
 	log.Debug(fmt.Sprintf("execution of reactor '%s' of type '%s' has completed successfully", reactorConfig.Name, reactorObj.GetName()))
-	wg.Done()
+	ch <- reactorResult{matched: true}
+}
+
+// NewReactorEventHandler returns an event handler that runs the configured reactors for the events of listeners that are
+// not driven by http requests. An error is returned when at least one reactor failed
+func NewReactorEventHandler(listenerName string, listenerApiPath string) message.EventHandler {
+	return func(ctx context.Context, log *zap.Logger, eventPayload *message.EventData) error {
+		cfg := config.FromCtx(ctx)
+		if cfg.LogEventDataPayload {
+			log.Info("eventPayload Payload", zap.Any("eventPayload", eventPayload))
+		}
+		if len(cfg.ReactorConfigs) == 0 {
+			log.Warn(fmt.Sprintf("no reactors configured for listener '%s'", listenerName))
+		}
+
+		errors := RunReactorsAsync(ctx, cfg, log, eventPayload, listenerName, listenerApiPath, adapter.GetReactorNewFunctions(cfg.LoadTestReactor))
+		if len(errors) == 0 {
+			return nil
+		}
+		details := []string{}
+		for _, errD := range errors {
+			details = append(details, errD.Detail)
+		}
+		return fmt.Errorf("%d reactor(s) failed - %s", len(errors), strings.Join(details, "; "))
+	}
+}
+
+// StartFileDropListener starts watching the file drop directories in the background. Nothing is started when no directories
+// are configured
+func StartFileDropListener(ctx context.Context, cfg *config.ServerConfiguration) error {
+	listenerConfig := cfg.GetFileDropListenerConfig()
+	if len(listenerConfig.Directories) == 0 {
+		return nil
+	}
+	for _, dir := range listenerConfig.Directories {
+		if _, err := os.Stat(dir.Path); err != nil {
+			return fmt.Errorf("the file drop directory '%s' cannot be watched - %v", dir.Path, err)
+		}
+	}
+
+	fdl := filedrop.New(listenerConfig, NewReactorEventHandler("filedrop", "filedrop"))
+	log := logger.FromCtx(ctx).With(zap.String("listener", fdl.GetName()))
+	go func() {
+		err := fdl.Start(ctx, log)
+		if err != nil {
+			log.Error(err.Error())
+		}
+	}()
+	return nil
+}
+
+// StartNatsListener subscribes to the NATS subscriptions in the background. Nothing is started when no subscriptions are
+// configured
+func StartNatsListener(ctx context.Context, cfg *config.ServerConfiguration) error {
+	listenerConfig := cfg.GetNatsListenerConfig()
+	if len(listenerConfig.Subscriptions) == 0 {
+		return nil
+	}
+	for _, sub := range listenerConfig.Subscriptions {
+		if sub.Subject == "" {
+			return fmt.Errorf("the subject of a NATS subscription was not supplied or was empty")
+		}
+		if sub.Stream != "" && sub.Durable == "" {
+			return fmt.Errorf("the durable name must be supplied for the NATS subscription to the JetStream stream '%s'", sub.Stream)
+		}
+	}
+
+	nl := nats.New(listenerConfig, NewReactorEventHandler("nats", "nats"))
+	log := logger.FromCtx(ctx).With(zap.String("listener", nl.GetName()))
+	go func() {
+		err := nl.Start(ctx, log)
+		if err != nil {
+			log.Error(err.Error())
+		}
+	}()
+	return nil
 }
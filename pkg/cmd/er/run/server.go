@@ -92,8 +92,16 @@ func ServerCommand(run *params.Run, ioStreams *cli.IOStreams) *cobra.Command {
 			options.CliOpts = cli.NewCliOptions()
 			options.IoStreams.SetColorEnabled(!settings.RootOptions.NoColor)
 			cmd.CheckForUnknownArgsExitWhenFound(args, ioStreams)
+			err := api.StartFileDropListener(ctx, serverConfig)
+			if err != nil {
+				cmd.WriteCmdErrorToScreen(err.Error(), ioStreams, true, true)
+			}
+			err = api.StartNatsListener(ctx, serverConfig)
+			if err != nil {
+				cmd.WriteCmdErrorToScreen(err.Error(), ioStreams, true, true)
+			}
 			router := api.CreateRouter(ctx, options.CacheInSeconds)
-			err := api.Start(ctx, router, serverConfig, options.ListeningAddr)
+			err = api.Start(ctx, router, serverConfig, options.ListeningAddr)
 			if err != nil {
 				cmd.WriteCmdErrorToScreen(err.Error(), ioStreams, true, true)
 			}
@@ -35,12 +35,13 @@ func AsStringPointer(val string) *string {
 }
 
 type ServerConfiguration struct {
-	ReactorConfigs      []ReactorConfig `json:"reactorConfigs,omitempty" yaml:"reactorConfigs,omitempty"`
-	TraceHeaderKey      string          `json:"traceHeaderKey,omitempty" yaml:"traceHeaderKey,omitempty"`
-	LoadTestReactor     bool            `json:"loadTestReactor,omitempty" yaml:"loadTestReactor,omitempty"`
-	AlwaysReturn200     bool            `json:"alwaysReturn200,omitempty" yaml:"alwaysReturn200,omitempty"`
-	LogRawPubSubPayload bool            `json:"logRawPubSubPayload,omitempty" yaml:"logRawPubSubPayload,omitempty"`
-	LogEventDataPayload bool            `json:"logEventDataPayload,omitempty" yaml:"logEventDataPayload,omitempty"`
+	ReactorConfigs      []ReactorConfig  `json:"reactorConfigs,omitempty" yaml:"reactorConfigs,omitempty"`
+	TraceHeaderKey      string           `json:"traceHeaderKey,omitempty" yaml:"traceHeaderKey,omitempty"`
+	LoadTestReactor     bool             `json:"loadTestReactor,omitempty" yaml:"loadTestReactor,omitempty"`
+	AlwaysReturn200     bool             `json:"alwaysReturn200,omitempty" yaml:"alwaysReturn200,omitempty"`
+	LogRawPubSubPayload bool             `json:"logRawPubSubPayload,omitempty" yaml:"logRawPubSubPayload,omitempty"`
+	LogEventDataPayload bool             `json:"logEventDataPayload,omitempty" yaml:"logEventDataPayload,omitempty"`
+	Listeners           *ListenerConfigs `json:"listeners,omitempty" yaml:"listeners,omitempty"`
 	//X-Cloud-Trace-Context
 }
 
@@ -176,6 +177,12 @@ func (o PropertyAndValue) GetStringArrayValue(ctx context.Context, log *zap.Logg
 	switch v := val.(type) {
 	case []string:
 		return v, nil
+	case []interface{}:
+		results := []string{}
+		for _, item := range v {
+			results = append(results, fmt.Sprintf("%v", item))
+		}
+		return results, nil
 	default:
 		if v == nil {
 			return []string{}, nil
@@ -189,9 +196,14 @@ func (o PropertyAndValue) GetMapStringStringValue(ctx context.Context, log *zap.
 	if err != nil {
 		return nil, err
 	}
+	// the maps are copied so callers can render the values without modifying the reactor configuration
 	switch v := val.(type) {
 	case map[string]string:
-		return v, nil
+		results := map[string]string{}
+		for k, v := range v {
+			results[k] = v
+		}
+		return results, nil
 	case map[string]interface{}:
 		results := map[string]string{}
 		for k, v := range v {
@@ -213,7 +225,11 @@ func (o PropertyAndValue) GetMapStringInterfaceValue(ctx context.Context, log *z
 	}
 	switch v := val.(type) {
 	case map[string]interface{}:
-		return v, nil
+		newRes := map[string]interface{}{}
+		for k, v := range v {
+			newRes[k] = v
+		}
+		return newRes, nil
 	case map[string]string:
 		newRes := map[string]interface{}{}
 		for k, v := range v {
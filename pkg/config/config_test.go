@@ -364,6 +364,19 @@ func TestPropertyAndValue_GetStringArrayValue(t *testing.T) {
 			want:    []string{"test value1", "test value2"},
 			wantErr: false,
 		},
+		{
+			name: "Test with interface array value",
+			fields: fields{
+				propVal: PropertyAndValue{
+					Value: []interface{}{"test value1", 2},
+				},
+			},
+			args: args{
+				data: &message.EventData{},
+			},
+			want:    []string{"test value1", "2"},
+			wantErr: false,
+		},
 		{
 			name: "Test with non-string array value",
 			fields: fields{
@@ -520,3 +533,32 @@ func TestPropertyAndValue_GetMapStringInterfaceValue(t *testing.T) {
 		})
 	}
 }
+
+func TestPropertyAndValue_GetMapValue_DoesNotMutateConfig(t *testing.T) {
+	// reactors render the returned maps in place, the rendered values of one event must not leak into the next event
+	properties := map[string]PropertyAndValue{
+		"stringMap":    {Value: map[string]string{"app": "{{ .data.app }}"}},
+		"interfaceMap": {Value: map[string]interface{}{"app": "{{ .data.app }}"}},
+	}
+	for _, app := range []string{"app1", "app2"} {
+		event := &message.EventData{Data: map[string]interface{}{"app": app}}
+
+		stringMap, err := properties["stringMap"].GetMapStringStringValue(context.Background(), zaptest.NewLogger(t), event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stringMap["app"] != "{{ .data.app }}" {
+			t.Errorf("GetMapStringStringValue() app = %v for %s, want the configured template", stringMap["app"], app)
+		}
+		stringMap["app"] = app
+
+		interfaceMap, err := properties["interfaceMap"].GetMapStringInterfaceValue(context.Background(), zaptest.NewLogger(t), event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if interfaceMap["app"] != "{{ .data.app }}" {
+			t.Errorf("GetMapStringInterfaceValue() app = %v for %s, want the configured template", interfaceMap["app"], app)
+		}
+		interfaceMap["app"] = app
+	}
+}
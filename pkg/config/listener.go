@@ -0,0 +1,186 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/nats"
+	"github.com/kcloutie/event-reactor/pkg/params/settings"
+)
+
+// ListenerConfigs holds the configuration for each of the listeners. A listener without configuration uses its defaults
+type ListenerConfigs struct {
+	Generic  *GenericListenerConfig  `json:"generic,omitempty" yaml:"generic,omitempty"`
+	PubSub   *PubSubListenerConfig   `json:"pubsub,omitempty" yaml:"pubsub,omitempty"`
+	FileDrop *FileDropListenerConfig `json:"fileDrop,omitempty" yaml:"fileDrop,omitempty"`
+	Nats     *NatsListenerConfig     `json:"nats,omitempty" yaml:"nats,omitempty"`
+	Batch    *BatchListenerConfig    `json:"batch,omitempty" yaml:"batch,omitempty"`
+	Webex    *WebexListenerConfig    `json:"webex,omitempty" yaml:"webex,omitempty"`
+}
+
+type GenericListenerConfig struct {
+	// HeaderAttributes maps the name of a request header to the name of the attribute its value will be written to.
+	// When the attribute name is empty, the header name is used as the attribute name
+	HeaderAttributes map[string]string `json:"headerAttributes,omitempty" yaml:"headerAttributes,omitempty"`
+	// QueryAttributes maps the name of a query parameter to the name of the attribute its value will be written to.
+	// When the attribute name is empty, the query parameter name is used as the attribute name
+	QueryAttributes map[string]string `json:"queryAttributes,omitempty" yaml:"queryAttributes,omitempty"`
+}
+
+// GetGenericListenerConfig returns the generic listener configuration or an empty configuration when one was not supplied
+func (c *ServerConfiguration) GetGenericListenerConfig() *GenericListenerConfig {
+	if c.Listeners == nil || c.Listeners.Generic == nil {
+		return &GenericListenerConfig{}
+	}
+	return c.Listeners.Generic
+}
+
+type PubSubListenerConfig struct {
+	// Decoder is used to decode the data of messages that are not matched by one of the subscription decoders.
+	// When not supplied, json payloads are decoded and all other payloads are only exposed as raw bytes and text
+	Decoder *message.PayloadDecoder `json:"decoder,omitempty" yaml:"decoder,omitempty"`
+	// SubscriptionDecoders maps the full (projects/my-project/subscriptions/my-sub) or short (my-sub) name of a subscription
+	// to the decoder used for its messages
+	SubscriptionDecoders map[string]*message.PayloadDecoder `json:"subscriptionDecoders,omitempty" yaml:"subscriptionDecoders,omitempty"`
+}
+
+// GetPubSubListenerConfig returns the pub/sub listener configuration or an empty configuration when one was not supplied
+func (c *ServerConfiguration) GetPubSubListenerConfig() *PubSubListenerConfig {
+	if c.Listeners == nil || c.Listeners.PubSub == nil {
+		return &PubSubListenerConfig{}
+	}
+	return c.Listeners.PubSub
+}
+
+// GetDecoder returns the decoder for the subscription. The full subscription name is matched first, then the short name and
+// finally the default decoder is returned
+func (c *PubSubListenerConfig) GetDecoder(subscription string) *message.PayloadDecoder {
+	if subscription != "" {
+		if decoder, exists := c.SubscriptionDecoders[subscription]; exists {
+			return decoder
+		}
+		shortName := subscription[strings.LastIndex(subscription, "/")+1:]
+		if decoder, exists := c.SubscriptionDecoders[shortName]; exists {
+			return decoder
+		}
+	}
+	return c.Decoder
+}
+
+type FileDropListenerConfig struct {
+	// Directories are the directories that are watched for new files
+	Directories []FileDropDirectory `json:"directories,omitempty" yaml:"directories,omitempty"`
+	// SettleSeconds is the number of seconds the size and modification time of a file must stay the same before it is
+	// processed. Defaults to 2
+	SettleSeconds int `json:"settleSeconds,omitempty" yaml:"settleSeconds,omitempty"`
+	// IgnorePatterns are glob patterns of file names that are never processed. Writers that rename a file once it is complete
+	// should write to a name matching one of these patterns. Defaults to .*, *.tmp, *.part and *.partial
+	IgnorePatterns []string `json:"ignorePatterns,omitempty" yaml:"ignorePatterns,omitempty"`
+}
+
+type FileDropDirectory struct {
+	// Path is the directory to watch
+	Path string `json:"path" yaml:"path"`
+	// Patterns are glob patterns the file name must match to be processed. Defaults to *
+	Patterns []string `json:"patterns,omitempty" yaml:"patterns,omitempty"`
+	// ProcessedPath is the directory files are moved to once all reactors succeed. Defaults to the processed subdirectory of the path
+	ProcessedPath string `json:"processedPath,omitempty" yaml:"processedPath,omitempty"`
+	// FailedPath is the directory files are moved to when the file cannot be read or a reactor fails. Defaults to the failed
+	// subdirectory of the path
+	FailedPath string `json:"failedPath,omitempty" yaml:"failedPath,omitempty"`
+}
+
+// GetFileDropListenerConfig returns the file drop listener configuration or an empty configuration when one was not supplied
+func (c *ServerConfiguration) GetFileDropListenerConfig() *FileDropListenerConfig {
+	if c.Listeners == nil || c.Listeners.FileDrop == nil {
+		return &FileDropListenerConfig{}
+	}
+	return c.Listeners.FileDrop
+}
+
+type NatsListenerConfig struct {
+	nats.ConnectOptions `yaml:",inline"`
+	// Subscriptions are the subjects the listener subscribes to
+	Subscriptions []NatsSubscription `json:"subscriptions,omitempty" yaml:"subscriptions,omitempty"`
+}
+
+type NatsSubscription struct {
+	// Subject is the subject to subscribe to, wildcards are supported
+	Subject string `json:"subject" yaml:"subject"`
+	// Queue is the queue group used for core NATS subscriptions so only one server receives each message
+	Queue string `json:"queue,omitempty" yaml:"queue,omitempty"`
+	// Stream is the JetStream stream to consume from. When supplied, a durable JetStream consumer is used and each message is
+	// acknowledged once all reactors succeed or negatively acknowledged so it is redelivered
+	Stream string `json:"stream,omitempty" yaml:"stream,omitempty"`
+	// Durable is the name of the durable JetStream consumer. Required when the stream is supplied
+	Durable string `json:"durable,omitempty" yaml:"durable,omitempty"`
+	// AckWaitSeconds is the number of seconds JetStream waits for an acknowledgement before redelivering. Defaults to 30
+	AckWaitSeconds int `json:"ackWaitSeconds,omitempty" yaml:"ackWaitSeconds,omitempty"`
+	// MaxDeliver is the maximum number of times JetStream delivers a message. Defaults to unlimited
+	MaxDeliver int `json:"maxDeliver,omitempty" yaml:"maxDeliver,omitempty"`
+	// Decoder is used to decode the data of the messages
+	Decoder *message.PayloadDecoder `json:"decoder,omitempty" yaml:"decoder,omitempty"`
+}
+
+// GetNatsListenerConfig returns the NATS listener configuration or an empty configuration when one was not supplied
+func (c *ServerConfiguration) GetNatsListenerConfig() *NatsListenerConfig {
+	if c.Listeners == nil || c.Listeners.Nats == nil {
+		return &NatsListenerConfig{}
+	}
+	return c.Listeners.Nats
+}
+
+type BatchListenerConfig struct {
+	// MaxParallelism is the maximum number of events of a batch request that are processed at the same time. Defaults to 10
+	MaxParallelism int `json:"maxParallelism,omitempty" yaml:"maxParallelism,omitempty"`
+	// MaxEvents is the maximum number of events accepted in a single batch request. Defaults to unlimited
+	MaxEvents int `json:"maxEvents,omitempty" yaml:"maxEvents,omitempty"`
+}
+
+// GetBatchListenerConfig returns the batch listener configuration or an empty configuration when one was not supplied
+func (c *ServerConfiguration) GetBatchListenerConfig() *BatchListenerConfig {
+	if c.Listeners == nil || c.Listeners.Batch == nil {
+		return &BatchListenerConfig{}
+	}
+	return c.Listeners.Batch
+}
+
+func (c *BatchListenerConfig) GetMaxParallelism() int {
+	if c.MaxParallelism <= 0 {
+		return 10
+	}
+	return c.MaxParallelism
+}
+
+type WebexListenerConfig struct {
+	// Secret is the secret of the webex webhook, used to verify the X-Spark-Signature header of each notification
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	// Token is the bot token used to fetch the submitted inputs of the attachment action and the person who submitted them
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+	// ApiUrl is the base url of the webex api. Defaults to https://webexapis.com/v1
+	ApiUrl string `json:"apiUrl,omitempty" yaml:"apiUrl,omitempty"`
+	// MaxRetries is the maximum number of times the webex api requests are retried. Defaults to 4
+	MaxRetries *int `json:"maxRetries,omitempty" yaml:"maxRetries,omitempty"`
+}
+
+// GetWebexListenerConfig returns the webex listener configuration or an empty configuration when one was not supplied
+func (c *ServerConfiguration) GetWebexListenerConfig() *WebexListenerConfig {
+	if c.Listeners == nil || c.Listeners.Webex == nil {
+		return &WebexListenerConfig{}
+	}
+	return c.Listeners.Webex
+}
+
+func (c *WebexListenerConfig) GetApiUrl() string {
+	if c.ApiUrl == "" {
+		return settings.WebexApiBaseUrlDefault
+	}
+	return c.ApiUrl
+}
+
+func (c *WebexListenerConfig) GetMaxRetries() int {
+	if c.MaxRetries == nil {
+		return 4
+	}
+	return *c.MaxRetries
+}
@@ -0,0 +1,117 @@
+package correlation
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultTtl is how long an entry is kept when no ttl is supplied
+const DefaultTtl = 24 * time.Hour
+
+var defaultStore = NewStore()
+
+// Store is an in memory store with expiring entries. It is used by reactors to remember the messages they have posted so
+// later events with the same correlation key can update or reply to them. Entries are lost when the server restarts and
+// are not shared between replicas of the server
+type Store struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	locks   map[string]*keyLock
+	now     func() time.Time
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+type entry struct {
+	values  map[string]string
+	expires time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		entries: map[string]entry{},
+		locks:   map[string]*keyLock{},
+		now:     time.Now,
+	}
+}
+
+// Default returns the store shared by all reactors
+func Default() *Store {
+	return defaultStore
+}
+
+// Get returns the values stored for the key. False is returned when the key does not exist or has expired
+func (s *Store) Get(key string) (map[string]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, exists := s.entries[key]
+	if !exists {
+		return nil, false
+	}
+	if !s.now().Before(e.expires) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	values := map[string]string{}
+	for k, v := range e.values {
+		values[k] = v
+	}
+	return values, true
+}
+
+// Set stores the values for the key, replacing any existing values. When the ttl is zero, the DefaultTtl is used
+func (s *Store) Set(key string, values map[string]string, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultTtl
+	}
+	copied := map[string]string{}
+	for k, v := range values {
+		copied[k] = v
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = entry{
+		values:  copied,
+		expires: now.Add(ttl),
+	}
+}
+
+func (s *Store) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// Lock locks the key until the returned function is called. Reactors hold the lock while they get the values, post the
+// message and set the values, otherwise concurrent events with the same key could each post a new message
+func (s *Store) Lock(key string) func() {
+	s.mu.Lock()
+	l, exists := s.locks[key]
+	if !exists {
+		l = &keyLock{}
+		s.locks[key] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.locks, key)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package correlation
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewStore()
+	s.now = func() time.Time { return now }
+
+	s.Set("key1", map[string]string{"ts": "1"}, time.Minute)
+	s.Set("key2", map[string]string{"ts": "2"}, 0)
+
+	got, exists := s.Get("key1")
+	if !exists || !reflect.DeepEqual(got, map[string]string{"ts": "1"}) {
+		t.Errorf("Store.Get() = %v, %v, want ts 1", got, exists)
+	}
+
+	// the returned values are a copy
+	got["ts"] = "changed"
+	got, _ = s.Get("key1")
+	if got["ts"] != "1" {
+		t.Errorf("Store.Get() = %v, the stored values were modified", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, exists := s.Get("key1"); exists {
+		t.Errorf("Store.Get() expected key1 to have expired")
+	}
+	if _, exists := s.Get("key2"); !exists {
+		t.Errorf("Store.Get() expected key2 to use the default ttl")
+	}
+
+	s.Delete("key2")
+	if _, exists := s.Get("key2"); exists {
+		t.Errorf("Store.Get() expected key2 to have been deleted")
+	}
+
+	now = now.Add(DefaultTtl)
+	s.Set("key3", map[string]string{}, time.Minute)
+	if len(s.entries) != 1 {
+		t.Errorf("Store.Set() expected expired entries to be removed, got %d entries", len(s.entries))
+	}
+}
+
+func TestStore_Lock(t *testing.T) {
+	s := NewStore()
+	order := []string{}
+	mu := sync.Mutex{}
+	record := func(v string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, v)
+	}
+
+	unlock := s.Lock("key1")
+	// a different key is not blocked
+	s.Lock("key2")()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		unlock := s.Lock("key1")
+		defer unlock()
+		record("second")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	record("first")
+	unlock()
+	<-done
+
+	if !reflect.DeepEqual(order, []string{"first", "second"}) {
+		t.Errorf("Store.Lock() order = %v, want the second lock to wait for the first", order)
+	}
+	if len(s.locks) != 0 {
+		t.Errorf("Store.Lock() locks = %d, want the unused locks to be removed", len(s.locks))
+	}
+}
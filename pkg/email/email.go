@@ -3,132 +3,109 @@ package email
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"net/smtp"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-var MimeHeaders = "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
-var EmailTemplate string = `
-<!DOCTYPE html>
-<html>
-
-<head>
-  <style>
-    body {
-      box-sizing: border-box;
-      min-width: 200px;
-      max-width: 800px;
-      margin: 0 auto;
-      padding: 45px;
-      font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif, "Apple Color Emoji", "Segoe UI Emoji";
-    }
-
-    h1,
-    h2,
-    h3,
-    h4,
-    h5,
-    h6 {
-      margin-top: 24px;
-      margin-bottom: 16px;
-      font-weight: 600;
-      line-height: 1.25;
-    }
-
-    h1 {
-      margin: .67em 0;
-      font-weight: 600;
-      padding-bottom: .3em;
-      font-size: 2em;
-      border-bottom: 1px solid hsla(210, 18%, 87%, 1);
-    }
-
-    h2 {
-      font-weight: 600;
-      padding-bottom: .3em;
-      font-size: 1.5em;
-      border-bottom: 1px solid hsla(210, 18%, 87%, 1);
-    }
-
-    table {
-      border: 1px solid #1C6EA4;
-      background-color: #EEEEEE;
-
-      text-align: left;
-      border-collapse: collapse;
-    }
-
-    table td,
-    th {
-      border: 1px solid #AAAAAA;
-      padding: 3px 2px;
-      font-size: 18px;
-    }
-
-    table tbody td {
-      font-size: 16px;
-      color: #333333;
-    }
-
-    table tr:nth-child(2n) {
-      background-color: #f6f8fa;
-    }
-  </style>
-</head>
-<body>
-  {{BODY}}
-</body>
-</html>
-`
+// reservedHeaders are set when the message is built and cannot be supplied as custom headers
+var reservedHeaders = []string{"Bcc", "Cc", "Content-Transfer-Encoding", "Content-Type", "Date", "From", "Message-Id", "Mime-Version", "Reply-To", "Subject", "To"}
 
 type EmailConfiguration struct {
-	Log           *zap.Logger
-	From          string
-	Password      string
-	To            []string
-	Subject       string
-	HtmlBody      string
-	SMTPHost      string
-	SMTPPort      int
+	Log  *zap.Logger
+	From string
+	To   []string
+	Cc   []string
+	// Bcc recipients receive the email but are not included in the headers
+	Bcc     []string
+	ReplyTo []string
+	Subject string
+	// When both the html and text bodies are supplied a multipart/alternative message is sent
+	HtmlBody    string
+	TextBody    string
+	Headers     map[string]string
+	Attachments []Attachment
+
+	SMTPHost string
+	SMTPPort int
+	// TLSMode is none, opportunistic, starttls or implicit. Defaults to opportunistic
+	TLSMode string
+	// CACertificates are PEM encoded certificates trusted in addition to the system certificates
+	CACertificates     string
+	InsecureSkipVerify bool
+	// AuthMechanism is none, plain, login, cram-md5 or xoauth2. Defaults to xoauth2 when an oauth2 token is configured,
+	// plain when a password is supplied and none otherwise
+	AuthMechanism string
+	// Username defaults to the from address
+	Username    string
+	Password    string
+	OAuth2Token string
+	OAuth2      *OAuth2Config
+	// Timeout of the connection and of each email sent using it. Defaults to 30 seconds
+	Timeout time.Duration
+
 	MaxRetries    int
 	SleepInterval time.Duration
 }
 
-func New(from string, password string, to []string, subject string, htmlBody string) EmailConfiguration {
+// Attachment is a file attached to the email. The content type is detected from the file name when it is not supplied
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+func New(smtpHost string, smtpPort int, from string, password string, to []string, subject string, htmlBody string) EmailConfiguration {
 	return EmailConfiguration{
 		From:          from,
 		Password:      password,
 		To:            to,
 		Subject:       subject,
 		HtmlBody:      htmlBody,
-		SMTPHost:      "mrls.azell.com",
-		SMTPPort:      587,
+		SMTPHost:      smtpHost,
+		SMTPPort:      smtpPort,
 		MaxRetries:    30,
 		SleepInterval: time.Duration(1) * time.Second,
 	}
 }
 
+// SendEmail connects to the smtp server and sends the email, the connection and the email are retried up to MaxRetries times
 func (e *EmailConfiguration) SendEmail(ctx context.Context) error {
 	log := e.Log.Sugar()
-	auth := smtp.PlainAuth("", e.From, e.Password, e.SMTPHost)
-
-	var body bytes.Buffer
 
-	body.Write([]byte(fmt.Sprintf("Subject: %s \n%s\n\n%s", e.Subject, MimeHeaders, e.HtmlBody)))
-
-	smtpHostFullName := fmt.Sprintf("%s:%v", e.SMTPHost, e.SMTPPort)
+	msg, err := e.BuildMessage(time.Now())
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(e.From)
+	if err != nil {
+		return fmt.Errorf("the from address '%s' is not valid - %v", e.From, err)
+	}
+	recipients, err := e.Recipients()
+	if err != nil {
+		return err
+	}
 
 	var sendMailError error = nil
 	for i := 1; i < e.MaxRetries+1; i++ {
 
-		sendMailError = smtp.SendMail(smtpHostFullName, auth, e.From, e.To, body.Bytes())
+		sendMailError = e.send(ctx, from.Address, recipients, msg)
 
 		if sendMailError != nil {
 
-			log.Debugf("Attempt %v of %v failed...sleeping and trying again", i, e.MaxRetries)
+			log.Debugf("Attempt %v of %v failed...sleeping and trying again. Error: %v", i, e.MaxRetries, sendMailError)
 			time.Sleep(e.SleepInterval)
 		} else {
 			return nil
@@ -137,3 +114,254 @@ func (e *EmailConfiguration) SendEmail(ctx context.Context) error {
 
 	return fmt.Errorf("failed to send email after %v attempts. Last error was %v", e.MaxRetries, sendMailError)
 }
+
+func (e *EmailConfiguration) send(ctx context.Context, from string, recipients []string, msg []byte) error {
+	conn, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+	return conn.send(from, recipients, msg)
+}
+
+// Recipients returns the addresses of the to, cc and bcc recipients used for the smtp envelope
+func (e *EmailConfiguration) Recipients() ([]string, error) {
+	recipients := []string{}
+	for _, list := range [][]string{e.To, e.Cc, e.Bcc} {
+		addresses, err := parseAddresses(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range addresses {
+			recipients = append(recipients, address.Address)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("the email does not have any recipients")
+	}
+	return recipients, nil
+}
+
+// BuildMessage returns the RFC 5322 message. The text and html bodies are sent as multipart/alternative and the attachments
+// are added using multipart/mixed. Headers containing non-ASCII characters are encoded using RFC 2047
+func (e *EmailConfiguration) BuildMessage(now time.Time) ([]byte, error) {
+	from, err := mail.ParseAddress(e.From)
+	if err != nil {
+		return nil, fmt.Errorf("the from address '%s' is not valid - %v", e.From, err)
+	}
+	if e.HtmlBody == "" && e.TextBody == "" {
+		return nil, fmt.Errorf("either the html or the text body of the email must be supplied")
+	}
+
+	var msg bytes.Buffer
+	writeHeader := func(name string, value string) {
+		msg.WriteString(fmt.Sprintf("%s: %s\r\n", name, value))
+	}
+
+	writeHeader("From", from.String())
+	for _, header := range []struct {
+		name      string
+		addresses []string
+	}{{"To", e.To}, {"Cc", e.Cc}, {"Reply-To", e.ReplyTo}} {
+		addresses, err := parseAddresses(header.addresses)
+		if err != nil {
+			return nil, err
+		}
+		if len(addresses) == 0 {
+			continue
+		}
+		formatted := []string{}
+		for _, address := range addresses {
+			formatted = append(formatted, address.String())
+		}
+		writeHeader(header.name, strings.Join(formatted, ", "))
+	}
+	writeHeader("Subject", encodeHeaderValue(e.Subject))
+	writeHeader("Date", now.Format(time.RFC1123Z))
+	messageId, err := newMessageId(from.Address)
+	if err != nil {
+		return nil, err
+	}
+	writeHeader("Message-ID", messageId)
+	writeHeader("MIME-Version", "1.0")
+
+	headerNames := []string{}
+	for name := range e.Headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	for _, name := range headerNames {
+		if err := validateHeader(name, e.Headers[name]); err != nil {
+			return nil, err
+		}
+		writeHeader(textproto.CanonicalMIMEHeaderKey(name), encodeHeaderValue(e.Headers[name]))
+	}
+
+	if len(e.Attachments) == 0 {
+		err = e.writeBody(&msg, writeHeader)
+		return msg.Bytes(), err
+	}
+
+	mixed := multipart.NewWriter(&msg)
+	writeHeader("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mixed.Boundary()}))
+	msg.WriteString("\r\n")
+
+	var body bytes.Buffer
+	bodyHeaders := textproto.MIMEHeader{}
+	err = e.writeBody(&body, func(name string, value string) { bodyHeaders.Set(name, value) })
+	if err != nil {
+		return nil, err
+	}
+	// writeBody writes the blank line ending the headers, which is written by the multipart writer for a part
+	part, err := mixed.CreatePart(bodyHeaders)
+	if err != nil {
+		return nil, err
+	}
+	_, err = part.Write(bytes.TrimPrefix(body.Bytes(), []byte("\r\n")))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range e.Attachments {
+		err = writeAttachment(mixed, attachment)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = mixed.Close()
+	return msg.Bytes(), err
+}
+
+// writeBody writes the content type header, the blank line ending the headers and the text and/or html bodies
+func (e *EmailConfiguration) writeBody(w *bytes.Buffer, writeHeader func(name string, value string)) error {
+	if e.HtmlBody == "" || e.TextBody == "" {
+		contentType := "text/html"
+		body := e.HtmlBody
+		if e.HtmlBody == "" {
+			contentType = "text/plain"
+			body = e.TextBody
+		}
+		writeHeader("Content-Type", mime.FormatMediaType(contentType, map[string]string{"charset": "UTF-8"}))
+		writeHeader("Content-Transfer-Encoding", "quoted-printable")
+		w.WriteString("\r\n")
+		return writeQuotedPrintable(w, body)
+	}
+
+	alternative := multipart.NewWriter(w)
+	writeHeader("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": alternative.Boundary()}))
+	w.WriteString("\r\n")
+	for _, body := range []struct {
+		contentType string
+		content     string
+	}{{"text/plain", e.TextBody}, {"text/html", e.HtmlBody}} {
+		part, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(body.contentType, map[string]string{"charset": "UTF-8"})},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		var encoded bytes.Buffer
+		err = writeQuotedPrintable(&encoded, body.content)
+		if err != nil {
+			return err
+		}
+		_, err = part.Write(encoded.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	return alternative.Close()
+}
+
+func writeQuotedPrintable(w *bytes.Buffer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	_, err := qp.Write([]byte(body))
+	if err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeAttachment(mixed *multipart.Writer, attachment Attachment) error {
+	if attachment.Filename == "" {
+		return fmt.Errorf("the file name of an attachment was not supplied")
+	}
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(attachment.Filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	filename := filepath.Base(attachment.Filename)
+	part, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filename})},
+	})
+	if err != nil {
+		return err
+	}
+
+	// base64 content is wrapped at 76 characters as required by RFC 2045
+	encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+	for len(encoded) > 76 {
+		_, err = part.Write([]byte(encoded[:76] + "\r\n"))
+		if err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = part.Write([]byte(encoded + "\r\n"))
+	return err
+}
+
+func parseAddresses(addresses []string) ([]*mail.Address, error) {
+	results := []*mail.Address{}
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("the email address '%s' is not valid - %v", address, err)
+		}
+		results = append(results, parsed)
+	}
+	return results, nil
+}
+
+func validateHeader(name string, value string) error {
+	if name == "" || strings.ContainsAny(name, ": \t\r\n") {
+		return fmt.Errorf("the header name '%s' is not valid", name)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("the value of the '%s' header must not contain line breaks", name)
+	}
+	for _, reserved := range reservedHeaders {
+		if strings.EqualFold(name, reserved) {
+			return fmt.Errorf("the '%s' header is set by the email reactor and cannot be supplied as a custom header", name)
+		}
+	}
+	return nil
+}
+
+// encodeHeaderValue encodes values containing non-ASCII characters using RFC 2047, ASCII values are returned unchanged
+func encodeHeaderValue(value string) string {
+	return mime.QEncoding.Encode("UTF-8", value)
+}
+
+func newMessageId(fromAddress string) (string, error) {
+	domain := "localhost"
+	if i := strings.LastIndex(fromAddress, "@"); i != -1 && i < len(fromAddress)-1 {
+		domain = fromAddress[i+1:]
+	}
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain), nil
+}
@@ -0,0 +1,225 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	testsmtp "github.com/kcloutie/event-reactor/pkg/test/smtp"
+	"go.uber.org/zap/zaptest"
+)
+
+// part is a decoded leaf part of a message
+type part struct {
+	contentType string
+	filename    string
+	content     string
+}
+
+// readParts returns the decoded leaf parts of the message, walking nested multipart parts
+func readParts(t *testing.T, contentType string, body io.Reader) []part {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("invalid content type '%s' - %v", contentType, err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		content, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []part{{contentType: mediaType, content: string(content)}}
+	}
+
+	parts := []part{}
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the multipart reader decodes quoted-printable parts, base64 parts are decoded here
+		var partBody io.Reader = p
+		if p.Header.Get("Content-Transfer-Encoding") == "base64" {
+			decoded, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+			if err != nil {
+				t.Fatal(err)
+			}
+			partBody = bytes.NewReader(decoded)
+		}
+		for _, child := range readParts(t, p.Header.Get("Content-Type"), partBody) {
+			if child.filename == "" {
+				child.filename = p.FileName()
+			}
+			parts = append(parts, child)
+		}
+	}
+}
+
+func TestEmailConfiguration_BuildMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      EmailConfiguration
+		wantHeaders map[string]string
+		wantParts   []part
+		wantErr     string
+	}{
+		{
+			name: "html only",
+			config: EmailConfiguration{
+				From:     "Build Bot <bot@example.com>",
+				To:       []string{"a@example.com", "b@example.com"},
+				Subject:  "Build succeeded",
+				HtmlBody: "<p>done</p>",
+			},
+			wantHeaders: map[string]string{
+				"From":         `"Build Bot" <bot@example.com>`,
+				"To":           "<a@example.com>, <b@example.com>",
+				"Subject":      "Build succeeded",
+				"Mime-Version": "1.0",
+			},
+			wantParts: []part{{contentType: "text/html", content: "<p>done</p>"}},
+		},
+		{
+			name: "text, html, cc, bcc, reply-to, headers and attachments",
+			config: EmailConfiguration{
+				From:     "bot@example.com",
+				To:       []string{"a@example.com"},
+				Cc:       []string{"Zoë <c@example.com>"},
+				Bcc:      []string{"hidden@example.com"},
+				ReplyTo:  []string{"team@example.com"},
+				Subject:  "Déploiement réussi ✅",
+				TextBody: "done",
+				HtmlBody: "<p>done</p>",
+				Headers:  map[string]string{"x-priority": "1"},
+				Attachments: []Attachment{
+					{Filename: "/tmp/report.txt", Content: []byte("report")},
+					{Filename: "data.bin", Content: bytes.Repeat([]byte{0, 1, 2}, 100)},
+				},
+			},
+			wantHeaders: map[string]string{
+				"Cc":         "Zoë <c@example.com>",
+				"Reply-To":   "<team@example.com>",
+				"Subject":    "Déploiement réussi ✅",
+				"X-Priority": "1",
+				"Bcc":        "",
+			},
+			wantParts: []part{
+				{contentType: "text/plain", content: "done"},
+				{contentType: "text/html", content: "<p>done</p>"},
+				{contentType: "text/plain", filename: "report.txt", content: "report"},
+				{contentType: "application/octet-stream", filename: "data.bin", content: string(bytes.Repeat([]byte{0, 1, 2}, 100))},
+			},
+		},
+		{
+			name:    "header injection",
+			config:  EmailConfiguration{From: "bot@example.com", To: []string{"a@example.com"}, TextBody: "done", Headers: map[string]string{"X-Test": "a\r\nBcc: evil@example.com"}},
+			wantErr: "the value of the 'X-Test' header must not contain line breaks",
+		},
+		{
+			name:    "reserved header",
+			config:  EmailConfiguration{From: "bot@example.com", To: []string{"a@example.com"}, TextBody: "done", Headers: map[string]string{"subject": "other"}},
+			wantErr: "the 'subject' header is set by the email reactor and cannot be supplied as a custom header",
+		},
+		{
+			name:    "invalid address",
+			config:  EmailConfiguration{From: "bot@example.com", To: []string{"not an address"}, TextBody: "done"},
+			wantErr: "the email address 'not an address' is not valid - mail: no angle-addr",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.BuildMessage(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("EmailConfiguration.BuildMessage() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("EmailConfiguration.BuildMessage() error = nil, wantErr %v", tt.wantErr)
+			}
+
+			msg, err := mail.ReadMessage(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("the message could not be parsed - %v\n%s", err, got)
+			}
+			decoder := mime.WordDecoder{}
+			for name, want := range tt.wantHeaders {
+				value, _ := decoder.DecodeHeader(msg.Header.Get(name))
+				if value != want {
+					t.Errorf("EmailConfiguration.BuildMessage() header %s = %q, want %q", name, value, want)
+				}
+			}
+			if msg.Header.Get("Date") != "Tue, 02 Jan 2024 03:04:05 +0000" || !strings.HasSuffix(msg.Header.Get("Message-Id"), "@example.com>") {
+				t.Errorf("EmailConfiguration.BuildMessage() Date = %v, Message-ID = %v", msg.Header.Get("Date"), msg.Header.Get("Message-Id"))
+			}
+			if strings.Contains(string(got), "\n") && strings.Count(string(got), "\n") != strings.Count(string(got), "\r\n") {
+				t.Errorf("EmailConfiguration.BuildMessage() the lines of the message must end with CRLF")
+			}
+
+			var body io.Reader = msg.Body
+			if msg.Header.Get("Content-Transfer-Encoding") == "quoted-printable" {
+				body = quotedprintable.NewReader(msg.Body)
+			}
+			parts := readParts(t, msg.Header.Get("Content-Type"), body)
+			if len(parts) != len(tt.wantParts) {
+				t.Fatalf("EmailConfiguration.BuildMessage() parts = %+v, want %+v", parts, tt.wantParts)
+			}
+			for i := range parts {
+				if parts[i].contentType != tt.wantParts[i].contentType || parts[i].filename != tt.wantParts[i].filename || parts[i].content != tt.wantParts[i].content {
+					t.Errorf("EmailConfiguration.BuildMessage() part %d = %+v, want %+v", i, parts[i], tt.wantParts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEmailConfiguration_SendEmail(t *testing.T) {
+	server := testsmtp.NewServer(t)
+	e := EmailConfiguration{
+		Log:           zaptest.NewLogger(t),
+		From:          "Build Bot <bot@example.com>",
+		Password:      "secret",
+		To:            []string{"a@example.com"},
+		Cc:            []string{"c@example.com"},
+		Bcc:           []string{"hidden@example.com"},
+		Subject:       "Build succeeded",
+		TextBody:      "done",
+		SMTPHost:      server.Host,
+		SMTPPort:      server.Port,
+		TLSMode:       TLSModeNone,
+		MaxRetries:    1,
+		SleepInterval: time.Millisecond,
+	}
+	err := e.SendEmail(context.Background())
+	if err != nil {
+		t.Fatalf("EmailConfiguration.SendEmail() error = %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("EmailConfiguration.SendEmail() messages = %v, want 1", len(messages))
+	}
+	got := messages[0]
+	if got.From != "bot@example.com" || strings.Join(got.To, ",") != "a@example.com,c@example.com,hidden@example.com" {
+		t.Errorf("EmailConfiguration.SendEmail() envelope from = %v, to = %v", got.From, got.To)
+	}
+	if got.Username != "bot@example.com" || got.Password != "secret" {
+		t.Errorf("EmailConfiguration.SendEmail() authenticated as %v/%v", got.Username, got.Password)
+	}
+	if strings.Contains(string(got.Data), "hidden@example.com") {
+		t.Errorf("EmailConfiguration.SendEmail() the bcc recipient is in the message:\n%s", got.Data)
+	}
+}
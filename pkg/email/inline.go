@@ -0,0 +1,262 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	cssCommentRegex = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	// dynamicSelectorRegex matches the pseudo classes and elements that depend on the state of the element or generate
+	// content, which cannot be inlined
+	dynamicSelectorRegex = regexp.MustCompile(`(?i):(hover|active|focus|focus-within|focus-visible|visited|target|before|after|first-line|first-letter|placeholder|selection)\b`)
+)
+
+// cssDeclaration is a property of a css rule, for example color: red
+type cssDeclaration struct {
+	property  string
+	value     string
+	important bool
+}
+
+// cssMatch is a declaration of a rule matching an element, sorted by importance, specificity and order to apply the cascade
+type cssMatch struct {
+	declaration cssDeclaration
+	specificity cascadia.Specificity
+	order       int
+}
+
+// InlineCss moves the rules of the style elements of the html document to the style attributes of the elements they
+// match, as many email clients such as Outlook ignore style elements. Rules that cannot be inlined, such as @media queries
+// and rules using pseudo classes like :hover, are kept in the style elements. Existing style attributes take precedence
+// over the rules unless the rules are !important
+func InlineCss(document string) (string, error) {
+	root, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the html to inline the css - %v", err)
+	}
+
+	styles := []*html.Node{}
+	var findStyles func(n *html.Node)
+	findStyles = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Style {
+			styles = append(styles, n)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			findStyles(c)
+		}
+	}
+	findStyles(root)
+	if len(styles) == 0 {
+		return document, nil
+	}
+
+	matches := map[*html.Node][]cssMatch{}
+	order := 0
+	for _, style := range styles {
+		css := ""
+		for c := style.FirstChild; c != nil; c = c.NextSibling {
+			css += c.Data
+		}
+		kept := []string{}
+		for _, rule := range splitCssRules(css) {
+			if strings.HasPrefix(rule.prelude, "@") {
+				kept = append(kept, rule.raw)
+				continue
+			}
+			declarations := parseCssDeclarations(rule.block)
+			notInlined := []string{}
+			for _, selector := range splitOutside(rule.prelude, ',') {
+				selector = strings.TrimSpace(selector)
+				if selector == "" {
+					continue
+				}
+				sel, err := cascadia.Parse(selector)
+				if err != nil || dynamicSelectorRegex.MatchString(selector) {
+					notInlined = append(notInlined, selector)
+					continue
+				}
+				for _, n := range cascadia.QueryAll(root, sel) {
+					for _, declaration := range declarations {
+						matches[n] = append(matches[n], cssMatch{declaration: declaration, specificity: sel.Specificity(), order: order})
+						order++
+					}
+				}
+			}
+			if len(notInlined) > 0 {
+				kept = append(kept, fmt.Sprintf("%s { %s }", strings.Join(notInlined, ", "), strings.TrimSpace(rule.block)))
+			}
+		}
+
+		for c := style.FirstChild; c != nil; {
+			next := c.NextSibling
+			style.RemoveChild(c)
+			c = next
+		}
+		if len(kept) == 0 {
+			style.Parent.RemoveChild(style)
+			continue
+		}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: "\n" + strings.Join(kept, "\n") + "\n"})
+	}
+
+	for n, nodeMatches := range matches {
+		applyCssMatches(n, nodeMatches)
+	}
+
+	var rendered bytes.Buffer
+	err = html.Render(&rendered, root)
+	if err != nil {
+		return "", fmt.Errorf("failed to render the html with the inlined css - %v", err)
+	}
+	return rendered.String(), nil
+}
+
+// applyCssMatches sets the style attribute of the element to the declarations of the matching rules followed by the
+// existing style attribute
+func applyCssMatches(n *html.Node, matches []cssMatch) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].specificity != matches[j].specificity {
+			return matches[i].specificity.Less(matches[j].specificity)
+		}
+		return matches[i].order < matches[j].order
+	})
+
+	properties := []string{}
+	values := map[string]cssDeclaration{}
+	set := func(declaration cssDeclaration) {
+		current, exists := values[declaration.property]
+		if exists && current.important && !declaration.important {
+			return
+		}
+		if !exists {
+			properties = append(properties, declaration.property)
+		}
+		values[declaration.property] = declaration
+	}
+	for _, match := range matches {
+		set(match.declaration)
+	}
+	styleIndex := -1
+	for i, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, "style") {
+			styleIndex = i
+			for _, declaration := range parseCssDeclarations(attr.Val) {
+				set(declaration)
+			}
+		}
+	}
+
+	style := []string{}
+	for _, property := range properties {
+		declaration := values[property]
+		value := declaration.value
+		if declaration.important {
+			value += " !important"
+		}
+		style = append(style, fmt.Sprintf("%s: %s", property, value))
+	}
+	if styleIndex == -1 {
+		n.Attr = append(n.Attr, html.Attribute{Key: "style", Val: strings.Join(style, "; ")})
+		return
+	}
+	n.Attr[styleIndex].Val = strings.Join(style, "; ")
+}
+
+type cssRule struct {
+	prelude string
+	block   string
+	raw     string
+}
+
+// splitCssRules returns the top level rules of the stylesheet. At-rules such as @media keep their nested rules in the block
+func splitCssRules(css string) []cssRule {
+	css = cssCommentRegex.ReplaceAllString(css, "")
+	rules := []cssRule{}
+	for {
+		css = strings.TrimSpace(css)
+		if css == "" {
+			return rules
+		}
+		open := strings.Index(css, "{")
+		// at-rules without a block, for example @import, end with a semicolon
+		if semicolon := strings.Index(css, ";"); strings.HasPrefix(css, "@") && semicolon != -1 && (open == -1 || semicolon < open) {
+			rules = append(rules, cssRule{prelude: strings.TrimSpace(css[:semicolon]), raw: css[:semicolon+1]})
+			css = css[semicolon+1:]
+			continue
+		}
+		if open == -1 {
+			return rules
+		}
+		depth, end := 0, -1
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				depth--
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		if end == -1 {
+			end = len(css) - 1
+		}
+		rules = append(rules, cssRule{prelude: strings.TrimSpace(css[:open]), block: css[open+1 : end], raw: css[:end+1]})
+		css = css[end+1:]
+	}
+}
+
+func parseCssDeclarations(block string) []cssDeclaration {
+	declarations := []cssDeclaration{}
+	for _, part := range splitOutside(block, ';') {
+		property, value, found := strings.Cut(part, ":")
+		property, value = strings.ToLower(strings.TrimSpace(property)), strings.TrimSpace(value)
+		if !found || property == "" || value == "" {
+			continue
+		}
+		declaration := cssDeclaration{property: property, value: value}
+		if lower := strings.ToLower(value); strings.HasSuffix(lower, "!important") {
+			declaration.important = true
+			declaration.value = strings.TrimSpace(value[:len(value)-len("!important")])
+		}
+		declarations = append(declarations, declaration)
+	}
+	return declarations
+}
+
+// splitOutside splits the value on the separator when it is not inside quotes or parentheses, for example the semicolon
+// of url(data:image/png;base64,...)
+func splitOutside(value string, separator rune) []string {
+	parts := []string{}
+	depth := 0
+	var quote rune
+	start := 0
+	for i, r := range value {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case r == separator && depth == 0:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
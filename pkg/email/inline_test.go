@@ -0,0 +1,61 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInlineCss(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     string
+	}{
+		{
+			name:     "no style elements",
+			document: `<p>hello</p>`,
+			want:     `<p>hello</p>`,
+		},
+		{
+			name: "specificity, order and existing style attributes",
+			document: `<html><head><style>
+/* the cascade */
+p { color: red; margin: 0 }
+.note { color: blue }
+p { font-size: 12px }
+#main { color: green; }
+</style></head><body><p id="main" class="note" style="margin: 4px">a</p><p class="note">b</p><p>c</p></body></html>`,
+			want: `<html><head></head><body><p id="main" class="note" style="color: green; margin: 4px; font-size: 12px">a</p><p class="note" style="color: blue; margin: 0; font-size: 12px">b</p><p style="color: red; margin: 0; font-size: 12px">c</p></body></html>`,
+		},
+		{
+			name:     "important declarations",
+			document: `<style>td { color: red !important } table td.cell { color: blue }</style><table><tr><td class="cell" style="color: black">a</td></tr></table>`,
+			want:     `<html><head></head><body><table><tbody><tr><td class="cell" style="color: red !important">a</td></tr></tbody></table></body></html>`,
+		},
+		{
+			name:     "structural pseudo classes, data urls and grouped selectors",
+			document: `<style>h1, h2 { margin: 0 } tr:nth-child(2n) { background: url("data:image/png;base64,AAAA") }</style><h1>a</h1><table><tr><td>1</td></tr><tr><td>2</td></tr></table>`,
+			want:     `<html><head></head><body><h1 style="margin: 0">a</h1><table><tbody><tr><td>1</td></tr><tr style="background: url(&#34;data:image/png;base64,AAAA&#34;)"><td>2</td></tr></tbody></table></body></html>`,
+		},
+		{
+			name:     "media queries and dynamic pseudo classes are kept",
+			document: `<style>a { color: red } a:hover, p { color: blue } @media (max-width: 600px) { p { width: 100% } } @import url("theme.css");</style><a>x</a><p>y</p>`,
+			want: `<html><head><style>
+a:hover { color: blue }
+@media (max-width: 600px) { p { width: 100% } }
+@import url("theme.css");
+</style></head><body><a style="color: red">x</a><p style="color: blue">y</p></body></html>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InlineCss(tt.document)
+			if err != nil {
+				t.Fatalf("InlineCss() error = %v", err)
+			}
+			if strings.TrimSpace(got) != tt.want {
+				t.Errorf("InlineCss() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
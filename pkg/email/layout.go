@@ -0,0 +1,221 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kcloutie/event-reactor/pkg/template"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+const (
+	// DefaultLayoutName is the name of the built-in layout, which is used when the layout directory does not contain a
+	// layout with the same name
+	DefaultLayoutName = "default"
+	// PartialsDirectory is the sub directory of the layout directory containing the partials shared by the layouts
+	PartialsDirectory = "partials"
+	layoutExtension   = ".html"
+)
+
+// DefaultLayout is the built-in layout. The body of the email is available as .body
+var DefaultLayout string = `<!DOCTYPE html>
+<html>
+
+<head>
+  <style>
+    body {
+      box-sizing: border-box;
+      min-width: 200px;
+      max-width: 800px;
+      margin: 0 auto;
+      padding: 45px;
+      font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif, "Apple Color Emoji", "Segoe UI Emoji";
+    }
+
+    h1,
+    h2,
+    h3,
+    h4,
+    h5,
+    h6 {
+      margin-top: 24px;
+      margin-bottom: 16px;
+      font-weight: 600;
+      line-height: 1.25;
+    }
+
+    h1 {
+      margin: .67em 0;
+      font-weight: 600;
+      padding-bottom: .3em;
+      font-size: 2em;
+      border-bottom: 1px solid hsla(210, 18%, 87%, 1);
+    }
+
+    h2 {
+      font-weight: 600;
+      padding-bottom: .3em;
+      font-size: 1.5em;
+      border-bottom: 1px solid hsla(210, 18%, 87%, 1);
+    }
+
+    table {
+      border: 1px solid #1C6EA4;
+      background-color: #EEEEEE;
+
+      text-align: left;
+      border-collapse: collapse;
+    }
+
+    table td,
+    th {
+      border: 1px solid #AAAAAA;
+      padding: 3px 2px;
+      font-size: 18px;
+    }
+
+    table tbody td {
+      font-size: 16px;
+      color: #333333;
+    }
+
+    table tr:nth-child(2n) {
+      background-color: #f6f8fa;
+    }
+  </style>
+</head>
+<body>
+  {{ .body }}
+</body>
+</html>
+`
+
+// Layouts are the named html layouts the body of an email is wrapped in. Each .html file of the layout directory is a
+// layout named after the file, for example team-a.html is the team-a layout. The files of the partials sub directory,
+// for example partials/header.html, are available to every layout as {{ template "header" . }}
+type Layouts struct {
+	opts     template.RenderTemplateOptions
+	layouts  map[string]string
+	partials map[string]string
+}
+
+// LoadLayouts reads the layouts and partials of the directory. The built-in default layout is returned when the directory
+// is empty. The delimiters and functions of the go templating options are used to parse the layouts
+func LoadLayouts(dir string, opts template.RenderTemplateOptions) (*Layouts, error) {
+	l := &Layouts{
+		opts:     opts,
+		layouts:  map[string]string{DefaultLayoutName: defaultLayout(opts)},
+		partials: map[string]string{},
+	}
+	if dir == "" {
+		return l, nil
+	}
+
+	for _, source := range []struct {
+		dir     string
+		target  map[string]string
+		partial bool
+	}{{dir, l.layouts, false}, {filepath.Join(dir, PartialsDirectory), l.partials, true}} {
+		entries, err := os.ReadDir(source.dir)
+		if err != nil {
+			if source.partial && os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read the email layouts from the '%s' directory - %v", source.dir, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), layoutExtension) {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(source.dir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the email layout '%s' - %v", entry.Name(), err)
+			}
+			source.target[strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))] = string(content)
+		}
+	}
+	return l, nil
+}
+
+// defaultLayout returns the built-in layout using the delimiters of the go templating options, so the body placeholder is
+// parsed when custom delimiters are used
+func defaultLayout(opts template.RenderTemplateOptions) string {
+	return strings.Replace(DefaultLayout, "{{ .body }}", fmt.Sprintf("%s .body %s", opts.LeftDelim, opts.RightDelim), 1)
+}
+
+// Names returns the sorted names of the layouts
+func (l *Layouts) Names() []string {
+	names := []string{}
+	for name := range l.layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render executes the layout using the data, which should contain the html body of the email as .body. The body is not
+// escaped. The css of the style elements is inlined so clients that ignore them, such as Outlook, render the email
+// correctly
+func (l *Layouts) Render(name string, data map[string]interface{}, inlineCss bool) (string, error) {
+	layout, ok := l.layouts[name]
+	if !ok {
+		return "", fmt.Errorf("the email layout '%s' does not exist. Valid layouts are %v", name, l.Names())
+	}
+
+	funcMap := htmltemplate.FuncMap(template.CreateGoTemplatingFuncMap(l.opts.RemoveDangerousFuncs))
+	option := "missingkey=error"
+	if l.opts.IgnoreTemplateErrors {
+		option = "missingkey=zero"
+	}
+	tmpl := htmltemplate.New(name).Delims(l.opts.LeftDelim, l.opts.RightDelim).Funcs(funcMap).Option(option)
+	partialNames := []string{}
+	for partialName := range l.partials {
+		partialNames = append(partialNames, partialName)
+	}
+	sort.Strings(partialNames)
+	for _, partialName := range partialNames {
+		_, err := tmpl.New(partialName).Parse(l.partials[partialName])
+		if err != nil {
+			return "", fmt.Errorf("failed to parse the email layout partial '%s' - %v", partialName, err)
+		}
+	}
+	_, err := tmpl.Parse(layout)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the email layout '%s' - %v", name, err)
+	}
+
+	values := map[string]interface{}{}
+	for k, v := range data {
+		values[k] = v
+	}
+	if body, ok := values["body"].(string); ok {
+		values["body"] = htmltemplate.HTML(body) // #nosec G203 -- the body is the html rendered by the reactor
+	}
+
+	var rendered bytes.Buffer
+	err = tmpl.ExecuteTemplate(&rendered, name, values)
+	if err != nil {
+		return "", fmt.Errorf("failed to render the email layout '%s' - %v", name, err)
+	}
+	if !inlineCss {
+		return rendered.String(), nil
+	}
+	return InlineCss(rendered.String())
+}
+
+// MarkdownToHtml converts github flavored markdown to html. Raw html in the markdown is omitted
+func MarkdownToHtml(markdown string) (string, error) {
+	var rendered bytes.Buffer
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	err := md.Convert([]byte(markdown), &rendered)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert the markdown to html - %v", err)
+	}
+	return rendered.String(), nil
+}
@@ -0,0 +1,138 @@
+package email
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/template"
+)
+
+func TestLayouts_Render(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"team-a.html":           `<html><head><style>.brand { color: {{ .values.color }} }</style></head><body>{{ template "header" . }}{{ .body }}{{ template "footer" . }}</body></html>`,
+		"plain.html":            `<div>{{ .body }}</div>`,
+		"[[delims]].html":       `<div>[[ .body ]] [[ .data.app ]]</div>`,
+		"broken.html":           `{{ .body `,
+		"notes.txt":             `not a layout`,
+		"partials/header.html":  `<h1 class="brand">{{ .values.team }}: {{ .subject }}</h1>`,
+		"partials/footer.html":  `<p>Sent for {{ .data.app | upper }}</p>`,
+		"partials/ignored.yaml": `not a partial`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := map[string]interface{}{
+		"subject": "Build <succeeded>",
+		"body":    "<p>done</p>",
+		"values":  map[string]interface{}{"team": "Team A", "color": "#ff0000"},
+		"data":    map[string]interface{}{"app": "app1"},
+	}
+
+	tests := []struct {
+		name      string
+		layout    string
+		opts      template.RenderTemplateOptions
+		inlineCss bool
+		want      string
+		wantErr   string
+	}{
+		{
+			name:      "partials, values and inlined css",
+			layout:    "team-a",
+			opts:      template.NewRenderTemplateOptions(),
+			inlineCss: true,
+			want:      `<html><head></head><body><h1 class="brand" style="color: #ff0000">Team A: Build &lt;succeeded&gt;</h1><p>done</p><p>Sent for APP1</p></body></html>`,
+		},
+		{
+			name:   "without inlining",
+			layout: "plain",
+			opts:   template.NewRenderTemplateOptions(),
+			want:   `<div><p>done</p></div>`,
+		},
+		{
+			name:   "custom delimiters",
+			layout: "[[delims]]",
+			opts:   template.RenderTemplateOptions{LeftDelim: "[[", RightDelim: "]]"},
+			want:   `<div><p>done</p> app1</div>`,
+		},
+		{
+			name:      "built-in default layout",
+			layout:    DefaultLayoutName,
+			opts:      template.NewRenderTemplateOptions(),
+			inlineCss: true,
+			want:      `<p>done</p>`,
+		},
+		{
+			name:      "built-in default layout with custom delimiters",
+			layout:    DefaultLayoutName,
+			opts:      template.RenderTemplateOptions{LeftDelim: "[[", RightDelim: "]]"},
+			inlineCss: true,
+			want:      `<p>done</p>`,
+		},
+		{
+			name:    "missing layout",
+			layout:  "team-b",
+			opts:    template.NewRenderTemplateOptions(),
+			wantErr: "the email layout 'team-b' does not exist. Valid layouts are [[[delims]] broken default plain team-a]",
+		},
+		{
+			name:    "invalid layout",
+			layout:  "broken",
+			opts:    template.NewRenderTemplateOptions(),
+			wantErr: "failed to parse the email layout 'broken' - template: broken:1: unclosed action",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layouts, err := LoadLayouts(dir, tt.opts)
+			if err != nil {
+				t.Fatalf("LoadLayouts() error = %v", err)
+			}
+			got, err := layouts.Render(tt.layout, data, tt.inlineCss)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Layouts.Render() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Layouts.Render() error = nil, wantErr %v", tt.wantErr)
+			}
+			if tt.layout == DefaultLayoutName {
+				if !strings.Contains(got, tt.want) || !strings.Contains(got, `<body style="box-sizing: border-box;`) {
+					t.Errorf("Layouts.Render() = %v, want the body wrapped in the default layout", got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Layouts.Render() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	_, err := LoadLayouts(filepath.Join(dir, "missing"), template.NewRenderTemplateOptions())
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to read the email layouts from the") {
+		t.Errorf("LoadLayouts() error = %v, want the missing directory to be reported", err)
+	}
+}
+
+func TestMarkdownToHtml(t *testing.T) {
+	got, err := MarkdownToHtml("# Build\n\n| app | status |\n| --- | --- |\n| app1 | ~~failed~~ ok |\n\n<script>alert(1)</script>\n")
+	if err != nil {
+		t.Fatalf("MarkdownToHtml() error = %v", err)
+	}
+	for _, want := range []string{"<h1>Build</h1>", "<table>", "<td>app1</td>", "<del>failed</del>", "<!-- raw HTML omitted -->"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("MarkdownToHtml() = %v, want it to contain %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	// TLSModeNone sends the email unencrypted
+	TLSModeNone = "none"
+	// TLSModeOpportunistic upgrades the connection using STARTTLS when the server supports it and otherwise sends the email
+	// unencrypted, like smtp.SendMail
+	TLSModeOpportunistic = "opportunistic"
+	// TLSModeStartTLS upgrades the connection using STARTTLS and fails when the server does not support it
+	TLSModeStartTLS = "starttls"
+	// TLSModeImplicit connects using tls, usually on port 465
+	TLSModeImplicit = "implicit"
+
+	AuthNone    = "none"
+	AuthPlain   = "plain"
+	AuthLogin   = "login"
+	AuthCramMD5 = "cram-md5"
+	AuthXOAuth2 = "xoauth2"
+
+	DefaultTimeout = 30 * time.Second
+)
+
+var (
+	TLSModes       = []string{TLSModeNone, TLSModeOpportunistic, TLSModeStartTLS, TLSModeImplicit}
+	AuthMechanisms = []string{AuthNone, AuthPlain, AuthLogin, AuthCramMD5, AuthXOAuth2}
+
+	tokenSources   = map[string]oauth2.TokenSource{}
+	tokenSourcesMu sync.Mutex
+)
+
+// OAuth2Config contains the settings used to get the token of the XOAUTH2 mechanism. When a refresh token is supplied
+// the refresh token flow is used, for example for Gmail, otherwise the client credentials flow is used, for example for
+// Microsoft 365
+type OAuth2Config struct {
+	TokenUrl     string
+	ClientId     string
+	ClientSecret string
+	RefreshToken string
+	Scopes       []string
+}
+
+// connection is an authenticated connection to an smtp server
+type connection struct {
+	client *smtp.Client
+}
+
+// dial connects to the smtp server using the tls mode and authenticates using the auth mechanism of the configuration.
+// The connection must be closed by the caller
+func (e *EmailConfiguration) dial(ctx context.Context) (*connection, error) {
+	tlsMode := strings.ToLower(e.TLSMode)
+	if tlsMode == "" {
+		tlsMode = TLSModeOpportunistic
+	}
+	if !slices.Contains(TLSModes, tlsMode) {
+		return nil, fmt.Errorf("the tls mode '%s' is not valid. Valid tls modes are %v", e.TLSMode, TLSModes)
+	}
+	auth, err := e.auth()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := e.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	timeout := e.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	addr := net.JoinHostPort(e.SMTPHost, strconv.Itoa(e.SMTPPort))
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	if tlsMode == TLSModeImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the smtp server '%s' - %v", addr, err)
+	}
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client, err := smtp.NewClient(conn, e.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to the smtp server '%s' - %v", addr, err)
+	}
+	c := &connection{client: client}
+
+	if tlsMode == TLSModeStartTLS || tlsMode == TLSModeOpportunistic {
+		ok, _ := client.Extension("STARTTLS")
+		if !ok && tlsMode == TLSModeStartTLS {
+			client.Close()
+			return nil, fmt.Errorf("the smtp server '%s' does not support STARTTLS. Use the implicit tls mode for servers that only accept tls connections or the none tls mode to send the email unencrypted", addr)
+		}
+		if ok {
+			err = client.StartTLS(tlsConfig)
+			if err != nil {
+				client.Close()
+				return nil, fmt.Errorf("failed to start tls with the smtp server '%s' - %v", addr, err)
+			}
+		}
+	}
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("the smtp server '%s' does not support authentication", addr)
+		}
+		err = client.Auth(auth)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to authenticate with the smtp server '%s' - %v", addr, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *connection) send(from string, recipients []string, msg []byte) error {
+	err := c.client.Mail(from)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		err = c.client.Rcpt(recipient)
+		if err != nil {
+			return err
+		}
+	}
+	w, err := c.client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// close sends QUIT and closes the connection. The QUIT error is not returned as the server already accepted or rejected the
+// email, returning it would make the caller send the email again
+func (c *connection) close() {
+	err := c.client.Quit()
+	if err != nil {
+		c.client.Close()
+	}
+}
+
+func (e *EmailConfiguration) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         e.SMTPHost,
+		InsecureSkipVerify: e.InsecureSkipVerify, // #nosec G402 -- only enabled when requested, for example for lab servers
+	}
+	if strings.TrimSpace(e.CACertificates) != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(e.CACertificates)) {
+			return nil, fmt.Errorf("the ca certificates do not contain any valid PEM encoded certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// authMechanism returns the auth mechanism, defaulting to xoauth2 when an oauth2 token is configured, plain when a
+// password is supplied and none otherwise
+func (e *EmailConfiguration) authMechanism() string {
+	if e.AuthMechanism != "" {
+		return strings.ToLower(e.AuthMechanism)
+	}
+	if e.OAuth2Token != "" || e.OAuth2 != nil {
+		return AuthXOAuth2
+	}
+	if e.Password != "" {
+		return AuthPlain
+	}
+	return AuthNone
+}
+
+func (e *EmailConfiguration) auth() (smtp.Auth, error) {
+	mechanism := e.authMechanism()
+	if !slices.Contains(AuthMechanisms, mechanism) {
+		return nil, fmt.Errorf("the auth mechanism '%s' is not valid. Valid auth mechanisms are %v", e.AuthMechanism, AuthMechanisms)
+	}
+	username := e.Username
+	if username == "" {
+		from, err := mail.ParseAddress(e.From)
+		if err != nil {
+			return nil, fmt.Errorf("the from address '%s' is not valid - %v", e.From, err)
+		}
+		username = from.Address
+	}
+	if (mechanism == AuthPlain || mechanism == AuthLogin || mechanism == AuthCramMD5) && e.Password == "" {
+		return nil, fmt.Errorf("the password must be supplied when using the %s auth mechanism", mechanism)
+	}
+
+	switch mechanism {
+	case AuthPlain:
+		return smtp.PlainAuth("", username, e.Password, e.SMTPHost), nil
+	case AuthLogin:
+		return &loginAuth{username: username, password: e.Password, host: e.SMTPHost}, nil
+	case AuthCramMD5:
+		return smtp.CRAMMD5Auth(username, e.Password), nil
+	case AuthXOAuth2:
+		token := e.OAuth2Token
+		if token == "" {
+			if e.OAuth2 == nil {
+				return nil, fmt.Errorf("an oauth2 token or the oauth2 token url must be supplied when using the %s auth mechanism", mechanism)
+			}
+			oauthToken, err := e.OAuth2.getTokenSource().Token()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get an oauth2 token from '%s' - %v", e.OAuth2.TokenUrl, err)
+			}
+			token = oauthToken.AccessToken
+		}
+		return &xoauth2Auth{username: username, token: token}, nil
+	}
+	return nil, nil
+}
+
+// getTokenSource returns a cached token source so tokens are reused until they expire
+func (o *OAuth2Config) getTokenSource() oauth2.TokenSource {
+	key := fmt.Sprintf("%s|%s|%s|%s|%s", o.TokenUrl, o.ClientId, o.ClientSecret, o.RefreshToken, strings.Join(o.Scopes, " "))
+
+	tokenSourcesMu.Lock()
+	defer tokenSourcesMu.Unlock()
+	ts, exists := tokenSources[key]
+	if exists {
+		return ts
+	}
+	// the token source outlives the email so it must not use the context of the event
+	if o.RefreshToken != "" {
+		config := &oauth2.Config{
+			ClientID:     o.ClientId,
+			ClientSecret: o.ClientSecret,
+			Endpoint:     oauth2.Endpoint{TokenURL: o.TokenUrl},
+			Scopes:       o.Scopes,
+		}
+		ts = config.TokenSource(context.Background(), &oauth2.Token{RefreshToken: o.RefreshToken})
+	} else {
+		config := &clientcredentials.Config{
+			ClientID:     o.ClientId,
+			ClientSecret: o.ClientSecret,
+			TokenURL:     o.TokenUrl,
+			Scopes:       o.Scopes,
+		}
+		ts = config.TokenSource(context.Background())
+	}
+	tokenSources[key] = ts
+	return ts
+}
+
+// loginAuth implements the LOGIN mechanism, which like smtp.PlainAuth refuses to send the password over an
+// unencrypted connection unless the server is localhost
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:", "user name", "username":
+		return []byte(a.username), nil
+	case "password:", "password":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge '%s'", fromServer)
+}
+
+// xoauth2Auth implements the XOAUTH2 mechanism used by Gmail and Microsoft 365
+type xoauth2Auth struct {
+	username string
+	token    string
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	return "XOAUTH2", []byte(fmt.Sprintf("user=%s\x01auth=Bearer %s\x01\x01", a.username, a.token)), nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// the server sends a json error as a challenge, an empty response is sent to receive the final error
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
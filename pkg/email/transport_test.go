@@ -0,0 +1,206 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	testsmtp "github.com/kcloutie/event-reactor/pkg/test/smtp"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestEmailConfiguration_send(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token-" + r.Form.Get("grant_type"), "token_type": "Bearer", "expires_in": 3600})
+	}))
+	defer tokenServer.Close()
+
+	tests := []struct {
+		name          string
+		serverTLS     string
+		config        EmailConfiguration
+		trustServer   bool
+		wantMechanism string
+		wantUsername  string
+		wantPassword  string
+		wantToken     string
+		wantTLS       bool
+		wantErr       string
+	}{
+		{
+			name:      "no tls and no auth",
+			serverTLS: testsmtp.TLSNone,
+			config:    EmailConfiguration{TLSMode: TLSModeNone},
+		},
+		{
+			name:          "starttls with the default plain auth",
+			serverTLS:     testsmtp.TLSStartTLS,
+			config:        EmailConfiguration{Password: "secret"},
+			trustServer:   true,
+			wantMechanism: "PLAIN",
+			wantUsername:  "bot@example.com",
+			wantPassword:  "secret",
+			wantTLS:       true,
+		},
+		{
+			name:          "implicit tls with login auth and a username",
+			serverTLS:     testsmtp.TLSImplicit,
+			config:        EmailConfiguration{TLSMode: "Implicit", AuthMechanism: "LOGIN", Username: "relay-user", Password: "secret"},
+			trustServer:   true,
+			wantMechanism: "LOGIN",
+			wantUsername:  "relay-user",
+			wantPassword:  "secret",
+			wantTLS:       true,
+		},
+		{
+			name:          "insecure skip verify with cram-md5",
+			serverTLS:     testsmtp.TLSStartTLS,
+			config:        EmailConfiguration{InsecureSkipVerify: true, AuthMechanism: AuthCramMD5, Password: "cram-secret"},
+			wantMechanism: "CRAM-MD5",
+			wantUsername:  "bot@example.com",
+			wantTLS:       true,
+		},
+		{
+			name:          "xoauth2 with a token",
+			serverTLS:     testsmtp.TLSImplicit,
+			config:        EmailConfiguration{TLSMode: TLSModeImplicit, OAuth2Token: "static-token"},
+			trustServer:   true,
+			wantMechanism: "XOAUTH2",
+			wantUsername:  "bot@example.com",
+			wantToken:     "static-token",
+			wantTLS:       true,
+		},
+		{
+			name:          "xoauth2 with a refresh token",
+			serverTLS:     testsmtp.TLSStartTLS,
+			config:        EmailConfiguration{OAuth2: &OAuth2Config{TokenUrl: tokenServer.URL, ClientId: "id", ClientSecret: "secret", RefreshToken: "refresh"}},
+			trustServer:   true,
+			wantMechanism: "XOAUTH2",
+			wantUsername:  "bot@example.com",
+			wantToken:     "token-refresh_token",
+			wantTLS:       true,
+		},
+		{
+			name:          "xoauth2 with client credentials",
+			serverTLS:     testsmtp.TLSStartTLS,
+			config:        EmailConfiguration{AuthMechanism: AuthXOAuth2, Username: "bot@tenant.example.com", OAuth2: &OAuth2Config{TokenUrl: tokenServer.URL, ClientId: "id", ClientSecret: "secret"}},
+			trustServer:   true,
+			wantMechanism: "XOAUTH2",
+			wantUsername:  "bot@tenant.example.com",
+			wantToken:     "token-client_credentials",
+			wantTLS:       true,
+		},
+		{
+			name:      "untrusted certificate",
+			serverTLS: testsmtp.TLSImplicit,
+			config:    EmailConfiguration{TLSMode: TLSModeImplicit},
+			wantErr:   "failed to connect to the smtp server '{addr}' - tls: failed to verify certificate: x509: certificate signed by unknown authority",
+		},
+		{
+			name:      "opportunistic tls without starttls is unencrypted",
+			serverTLS: testsmtp.TLSNone,
+			config:    EmailConfiguration{},
+		},
+		{
+			name:      "starttls not supported",
+			serverTLS: testsmtp.TLSNone,
+			config:    EmailConfiguration{TLSMode: TLSModeStartTLS},
+			wantErr:   "the smtp server '{addr}' does not support STARTTLS. Use the implicit tls mode for servers that only accept tls connections or the none tls mode to send the email unencrypted",
+		},
+		{
+			name:      "wrong cram-md5 password",
+			serverTLS: testsmtp.TLSNone,
+			config:    EmailConfiguration{TLSMode: TLSModeNone, AuthMechanism: AuthCramMD5, Password: "wrong"},
+			wantErr:   "failed to authenticate with the smtp server '{addr}' - " + (&textproto.Error{Code: 535, Msg: "authentication failed"}).Error(),
+		},
+		{
+			name:      "missing password",
+			serverTLS: testsmtp.TLSNone,
+			config:    EmailConfiguration{TLSMode: TLSModeNone, AuthMechanism: AuthLogin},
+			wantErr:   "the password must be supplied when using the login auth mechanism",
+		},
+		{
+			name:      "invalid tls mode",
+			serverTLS: testsmtp.TLSNone,
+			config:    EmailConfiguration{TLSMode: "ssl"},
+			wantErr:   "the tls mode 'ssl' is not valid. Valid tls modes are [none opportunistic starttls implicit]",
+		},
+		{
+			name:      "invalid ca certificates",
+			serverTLS: testsmtp.TLSNone,
+			config:    EmailConfiguration{CACertificates: "not a certificate"},
+			wantErr:   "the ca certificates do not contain any valid PEM encoded certificates",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := testsmtp.NewServerWithOptions(t, testsmtp.Options{TLS: tt.serverTLS, CramMD5Secret: "cram-secret"})
+			e := tt.config
+			e.Log = zaptest.NewLogger(t)
+			e.From = "Build Bot <bot@example.com>"
+			e.To = []string{"a@example.com"}
+			e.Subject = "Build succeeded"
+			e.TextBody = "done"
+			e.SMTPHost = server.Host
+			e.SMTPPort = server.Port
+			e.Timeout = 5 * time.Second
+			if tt.trustServer {
+				e.CACertificates = string(server.Certificate)
+			}
+
+			msg, err := e.BuildMessage(time.Now())
+			if err != nil {
+				t.Fatalf("EmailConfiguration.BuildMessage() error = %v", err)
+			}
+			err = e.send(context.Background(), "bot@example.com", e.To, msg)
+			if err != nil {
+				if wantErr := strings.ReplaceAll(tt.wantErr, "{addr}", server.Addr()); err.Error() != wantErr {
+					t.Errorf("EmailConfiguration.send() error = %v, wantErr %v", err, wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("EmailConfiguration.send() error = nil, wantErr %v", tt.wantErr)
+			}
+
+			messages := server.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("EmailConfiguration.send() messages = %v, want 1", len(messages))
+			}
+			got := messages[0]
+			if got.Mechanism != tt.wantMechanism || got.Username != tt.wantUsername || got.Password != tt.wantPassword || got.Token != tt.wantToken || got.TLS != tt.wantTLS {
+				t.Errorf("EmailConfiguration.send() mechanism = %v, username = %v, password = %v, token = %v, tls = %v", got.Mechanism, got.Username, got.Password, got.Token, got.TLS)
+			}
+		})
+	}
+}
+
+func TestEmailConfiguration_SendEmail_FailedQuit(t *testing.T) {
+	server := testsmtp.NewServerWithOptions(t, testsmtp.Options{FailQuit: true})
+	e := EmailConfiguration{
+		Log:           zaptest.NewLogger(t),
+		From:          "bot@example.com",
+		To:            []string{"a@example.com"},
+		Subject:       "Build succeeded",
+		TextBody:      "done",
+		SMTPHost:      server.Host,
+		SMTPPort:      server.Port,
+		TLSMode:       TLSModeNone,
+		MaxRetries:    3,
+		SleepInterval: time.Millisecond,
+	}
+	err := e.SendEmail(context.Background())
+	if err != nil {
+		t.Fatalf("EmailConfiguration.SendEmail() error = %v", err)
+	}
+	if len(server.Messages()) != 1 {
+		t.Errorf("EmailConfiguration.SendEmail() messages = %v, want 1", len(server.Messages()))
+	}
+}
@@ -3,27 +3,255 @@ package gcp
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+const (
+	LimitExceededBehaviorBlock       = "block"
+	LimitExceededBehaviorIgnore      = "ignore"
+	LimitExceededBehaviorSignalError = "signalError"
+)
+
+var (
+	LimitExceededBehaviors = []string{LimitExceededBehaviorBlock, LimitExceededBehaviorIgnore, LimitExceededBehaviorSignalError}
+
+	defaultPublisher = NewPublisher()
+)
+
+// PubSubConnection identifies the pub/sub service a client connects to
+type PubSubConnection struct {
+	Project string
+	// Endpoint overrides the pub/sub endpoint, for example a regional endpoint such as us-east1-pubsub.googleapis.com:443
+	Endpoint string
+	// EmulatorHost is the host:port of a pub/sub emulator. The connection is not encrypted or authenticated, the same as
+	// when the PUBSUB_EMULATOR_HOST environment variable is set
+	EmulatorHost string
+}
+
+// PublishSettings are the batching and flow control settings of a topic. Zero values use the defaults of the pub/sub client
+type PublishSettings struct {
+	DelayThreshold         time.Duration
+	CountThreshold         int
+	ByteThreshold          int
+	MaxOutstandingMessages int
+	MaxOutstandingBytes    int
+	// LimitExceededBehavior is block, ignore or signalError. Defaults to block when one of the outstanding limits is supplied,
+	// otherwise flow control is disabled
+	LimitExceededBehavior string
+	// EnableMessageOrdering must be true to publish messages with an ordering key
+	EnableMessageOrdering bool
+}
+
+// PublishMessage is a message published to a topic. The topic is either the id of a topic in the project of the
+// connection or the full name of a topic, for example projects/my-project/topics/my-topic
+type PublishMessage struct {
+	PubSubConnection
+	Topic       string
+	Data        []byte
+	Attributes  map[string]string
+	OrderingKey string
+	Settings    PublishSettings
+}
+
+// Publisher caches the pub/sub clients per project and the topics per settings, so the connections are reused and the
+// messages of concurrent events are published in batches
+type Publisher struct {
+	mutex   sync.Mutex
+	clients map[PubSubConnection]*pubsub.Client
+	topics  map[string]*pubsub.Topic
+}
+
+func NewPublisher() *Publisher {
+	return &Publisher{
+		clients: map[PubSubConnection]*pubsub.Client{},
+		topics:  map[string]*pubsub.Topic{},
+	}
+}
+
+// DefaultPublisher returns the publisher shared by the reactors
+func DefaultPublisher() *Publisher {
+	return defaultPublisher
+}
+
+// PublishEvent publishes the message to the topic using the default publisher
 func PublishEvent(projectID, topicID string, data []byte, attributes map[string]string) (string, error) {
-	ctx := context.Background()
-	client, err := pubsub.NewClient(ctx, projectID)
+	return DefaultPublisher().Publish(context.Background(), PublishMessage{
+		PubSubConnection: PubSubConnection{Project: projectID},
+		Topic:            topicID,
+		Data:             data,
+		Attributes:       attributes,
+	})
+}
+
+// Publish publishes the message and waits for the pub/sub service to acknowledge it, returning the id of the message
+func (p *Publisher) Publish(ctx context.Context, msg PublishMessage) (string, error) {
+	result, topic, err := p.PublishAsync(ctx, msg)
 	if err != nil {
-		return "", fmt.Errorf("failed to create the pub/sub client - %w", err)
+		return "", err
 	}
-	defer client.Close()
+	return p.Wait(ctx, result, topic, msg.OrderingKey)
+}
 
-	t := client.Topic(topicID)
-	result := t.Publish(ctx, &pubsub.Message{
-		Data:       data,
-		Attributes: attributes,
+// PublishAsync adds the message to the batch of the topic. The result must be passed to Wait to get the id of the message
+func (p *Publisher) PublishAsync(ctx context.Context, msg PublishMessage) (*pubsub.PublishResult, *pubsub.Topic, error) {
+	if msg.OrderingKey != "" && !msg.Settings.EnableMessageOrdering {
+		return nil, nil, fmt.Errorf("message ordering must be enabled to publish a message with the ordering key '%s'", msg.OrderingKey)
+	}
+	topic, err := p.getTopic(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := topic.Publish(ctx, &pubsub.Message{
+		Data:        msg.Data,
+		Attributes:  msg.Attributes,
+		OrderingKey: msg.OrderingKey,
 	})
+	return result, topic, nil
+}
+
+// Wait waits for the result of a published message. Publishing is resumed for the ordering key when the message failed,
+// otherwise every later message with the same ordering key would fail
+func (p *Publisher) Wait(ctx context.Context, result *pubsub.PublishResult, topic *pubsub.Topic, orderingKey string) (string, error) {
 	id, err := result.Get(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to publish the message to the pub/sub topic - %w", err)
+		if orderingKey != "" {
+			topic.ResumePublish(orderingKey)
+		}
+		return "", fmt.Errorf("failed to publish the message to the pub/sub topic '%s' - %w", topic.String(), err)
 	}
-
 	return id, nil
 }
+
+// Close flushes the batches of the topics and closes the clients
+func (p *Publisher) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, topic := range p.topics {
+		topic.Stop()
+	}
+	errs := []string{}
+	for _, client := range p.clients {
+		err := client.Close()
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	p.topics = map[string]*pubsub.Topic{}
+	p.clients = map[PubSubConnection]*pubsub.Client{}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close the pub/sub clients - %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (p *Publisher) getTopic(msg PublishMessage) (*pubsub.Topic, error) {
+	conn := msg.PubSubConnection
+	topicProject, topicId, err := ParseTopicName(msg.Topic, conn.Project)
+	if err != nil {
+		return nil, err
+	}
+	conn.Project = topicProject
+	behavior, err := getLimitExceededBehavior(msg.Settings.LimitExceededBehavior)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Settings.LimitExceededBehavior == "" && msg.Settings.MaxOutstandingMessages <= 0 && msg.Settings.MaxOutstandingBytes <= 0 {
+		behavior = pubsub.DefaultPublishSettings.FlowControlSettings.LimitExceededBehavior
+	}
+	key := fmt.Sprintf("%s|%s|%s|%s|%+v", conn.Project, conn.Endpoint, conn.EmulatorHost, topicId, msg.Settings)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	topic, exists := p.topics[key]
+	if exists {
+		return topic, nil
+	}
+	client, err := p.getClient(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// the topic outlives the event, the settings are applied to the defaults so unset values keep their defaults
+	topic = client.Topic(topicId)
+	settings := pubsub.DefaultPublishSettings
+	if msg.Settings.DelayThreshold > 0 {
+		settings.DelayThreshold = msg.Settings.DelayThreshold
+	}
+	if msg.Settings.CountThreshold > 0 {
+		settings.CountThreshold = msg.Settings.CountThreshold
+	}
+	if msg.Settings.ByteThreshold > 0 {
+		settings.ByteThreshold = msg.Settings.ByteThreshold
+	}
+	if msg.Settings.MaxOutstandingMessages > 0 {
+		settings.FlowControlSettings.MaxOutstandingMessages = msg.Settings.MaxOutstandingMessages
+	}
+	if msg.Settings.MaxOutstandingBytes > 0 {
+		settings.FlowControlSettings.MaxOutstandingBytes = msg.Settings.MaxOutstandingBytes
+	}
+	settings.FlowControlSettings.LimitExceededBehavior = behavior
+	topic.PublishSettings = settings
+	topic.EnableMessageOrdering = msg.Settings.EnableMessageOrdering
+	p.topics[key] = topic
+	return topic, nil
+}
+
+// getClient must be called while holding the mutex
+func (p *Publisher) getClient(conn PubSubConnection) (*pubsub.Client, error) {
+	client, exists := p.clients[conn]
+	if exists {
+		return client, nil
+	}
+	opts := []option.ClientOption{}
+	if conn.EmulatorHost != "" {
+		opts = append(opts,
+			option.WithEndpoint(conn.EmulatorHost),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		)
+	} else if conn.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(conn.Endpoint))
+	}
+	// the client is cached so it must not use the context of the event
+	client, err := pubsub.NewClient(context.Background(), conn.Project, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the pub/sub client - %w", err)
+	}
+	p.clients[conn] = client
+	return client, nil
+}
+
+// ParseTopicName returns the project and id of the topic. The project of a full topic name such as
+// projects/my-project/topics/my-topic takes precedence over the supplied project
+func ParseTopicName(topic string, project string) (string, string, error) {
+	parts := strings.Split(topic, "/")
+	switch {
+	case len(parts) == 1 && topic != "":
+		if project == "" {
+			return "", "", fmt.Errorf("the project of the pub/sub topic '%s' was not supplied", topic)
+		}
+		return project, topic, nil
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "topics" && parts[1] != "" && parts[3] != "":
+		return parts[1], parts[3], nil
+	}
+	return "", "", fmt.Errorf("the pub/sub topic '%s' is not valid. Supply the id of the topic or the full name of the topic, for example projects/my-project/topics/my-topic", topic)
+}
+
+func getLimitExceededBehavior(behavior string) (pubsub.LimitExceededBehavior, error) {
+	switch strings.ToLower(behavior) {
+	case "", strings.ToLower(LimitExceededBehaviorBlock):
+		return pubsub.FlowControlBlock, nil
+	case strings.ToLower(LimitExceededBehaviorIgnore):
+		return pubsub.FlowControlIgnore, nil
+	case strings.ToLower(LimitExceededBehaviorSignalError):
+		return pubsub.FlowControlSignalError, nil
+	}
+	return 0, fmt.Errorf("the limit exceeded behavior '%s' is not valid. Valid behaviors are %v", behavior, LimitExceededBehaviors)
+}
@@ -0,0 +1,111 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/pubsub/pstest"
+)
+
+func TestPublisher_Publish(t *testing.T) {
+	srv := NewFakePubSubServer(t, "projects/proj1/topics/topic1", "projects/proj1/topics/topic2", "projects/proj2/topics/topic3")
+	conn := PubSubConnection{Project: "proj1", EmulatorHost: srv.Addr}
+
+	tests := []struct {
+		name    string
+		msg     PublishMessage
+		want    *pstest.Message
+		wantErr string
+	}{
+		{
+			name: "topic id",
+			msg:  PublishMessage{PubSubConnection: conn, Topic: "topic1", Data: []byte(`{"app":"app1"}`), Attributes: map[string]string{"type": "deploy"}},
+			want: &pstest.Message{Data: []byte(`{"app":"app1"}`), Attributes: map[string]string{"type": "deploy"}},
+		},
+		{
+			name: "ordering key",
+			msg:  PublishMessage{PubSubConnection: conn, Topic: "topic2", Data: []byte("data"), OrderingKey: "app1", Settings: PublishSettings{EnableMessageOrdering: true, CountThreshold: 1}},
+			want: &pstest.Message{Data: []byte("data"), OrderingKey: "app1"},
+		},
+		{
+			name: "full topic name",
+			msg:  PublishMessage{PubSubConnection: conn, Topic: "projects/proj2/topics/topic3", Data: []byte("data")},
+			want: &pstest.Message{Data: []byte("data")},
+		},
+		{
+			name:    "ordering not enabled",
+			msg:     PublishMessage{PubSubConnection: conn, Topic: "topic1", Data: []byte("data"), OrderingKey: "app1"},
+			wantErr: "message ordering must be enabled to publish a message with the ordering key 'app1'",
+		},
+		{
+			name:    "invalid topic",
+			msg:     PublishMessage{PubSubConnection: conn, Topic: "projects/proj1/subscriptions/sub1", Data: []byte("data")},
+			wantErr: "the pub/sub topic 'projects/proj1/subscriptions/sub1' is not valid. Supply the id of the topic or the full name of the topic, for example projects/my-project/topics/my-topic",
+		},
+		{
+			name:    "invalid limit exceeded behavior",
+			msg:     PublishMessage{PubSubConnection: conn, Topic: "topic1", Data: []byte("data"), Settings: PublishSettings{LimitExceededBehavior: "dude"}},
+			wantErr: "the limit exceeded behavior 'dude' is not valid. Valid behaviors are [block ignore signalError]",
+		},
+		{
+			name:    "topic not found",
+			msg:     PublishMessage{PubSubConnection: conn, Topic: "dude", Data: []byte("data")},
+			wantErr: "failed to publish the message to the pub/sub topic 'projects/proj1/topics/dude' - rpc error: code = NotFound desc = topic \"projects/proj1/topics/dude\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPublisher()
+			defer p.Close()
+			srv.ClearMessages()
+
+			id, err := p.Publish(context.Background(), tt.msg)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Publisher.Publish() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Publisher.Publish() error = nil, wantErr %v", tt.wantErr)
+			}
+			got := srv.Message(id)
+			if got == nil {
+				t.Fatalf("Publisher.Publish() message '%s' was not received", id)
+			}
+			if string(got.Data) != string(tt.want.Data) || got.OrderingKey != tt.want.OrderingKey || (len(tt.want.Attributes) > 0 && !reflect.DeepEqual(got.Attributes, tt.want.Attributes)) {
+				t.Errorf("Publisher.Publish() message = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublisher_ReusesClients(t *testing.T) {
+	srv := NewFakePubSubServer(t, "projects/proj1/topics/topic1", "projects/proj1/topics/topic2", "projects/proj2/topics/topic1")
+	p := NewPublisher()
+	defer p.Close()
+
+	for i := 0; i < 3; i++ {
+		for _, topic := range []string{"topic1", "topic2", "projects/proj2/topics/topic1"} {
+			_, err := p.Publish(context.Background(), PublishMessage{
+				PubSubConnection: PubSubConnection{Project: "proj1", EmulatorHost: srv.Addr},
+				Topic:            topic,
+				Data:             []byte(fmt.Sprintf("message %d", i)),
+			})
+			if err != nil {
+				t.Fatalf("Publisher.Publish() error = %v", err)
+			}
+		}
+	}
+	if len(p.clients) != 2 {
+		t.Errorf("Publisher clients = %d, want one client per project", len(p.clients))
+	}
+	if len(p.topics) != 3 {
+		t.Errorf("Publisher topics = %d, want 3", len(p.topics))
+	}
+	if got := len(srv.Messages()); got != 9 {
+		t.Errorf("messages = %d, want 9", got)
+	}
+}
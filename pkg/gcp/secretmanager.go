@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"hash/crc32"
-
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	"github.com/kcloutie/event-reactor/pkg/logger"
+	"google.golang.org/api/iterator"
 )
 
 var (
@@ -62,6 +65,23 @@ func GetSecret(ctx context.Context, client *secretmanager.Client, project, name,
 	}
 	log.Info("GCP secret did not exist in cache or was expired...getting the value and caching it")
 
+	fullName := getSecretPath(project, name, version)
+	value, err := AccessVersion(ctx, client, project, name, version)
+	if err != nil {
+		return "", err
+	}
+
+	secretCache[fullName] = secretValueCache{
+		TimeToLive:  time.Duration(time.Duration(GcpSecretsCacheTTLInMinutes) * time.Minute),
+		CachedValue: value,
+		CachedTime:  time.Now(),
+	}
+	return secretCache[fullName].CachedValue, nil
+}
+
+// AccessVersion gets the value of the secret version without using the cache, for example to read the latest version
+// of a secret that is being rotated
+func AccessVersion(ctx context.Context, client *secretmanager.Client, project, name, version string) (string, error) {
 	if client == nil {
 		var err error
 		client, err = secretmanager.NewClient(ctx)
@@ -88,13 +108,7 @@ func GetSecret(ctx context.Context, client *secretmanager.Client, project, name,
 			return "", fmt.Errorf("data corruption detected on the value of secret version '%v': %v", req.Name, err)
 		}
 	}
-
-	secretCache[fullName] = secretValueCache{
-		TimeToLive:  time.Duration(time.Duration(GcpSecretsCacheTTLInMinutes) * time.Minute),
-		CachedValue: string(result.Payload.Data),
-		CachedTime:  time.Now(),
-	}
-	return secretCache[fullName].CachedValue, nil
+	return string(result.Payload.Data), nil
 }
 
 func AddVersion(ctx context.Context, client *secretmanager.Client, project, name, secretData string) (string, error) {
@@ -132,6 +146,88 @@ func AddVersion(ctx context.Context, client *secretmanager.Client, project, name
 	return result.Name, nil
 }
 
+// ListVersions lists the versions of the secret that have not been destroyed, the newest version is first
+func ListVersions(ctx context.Context, client *secretmanager.Client, project, name string) ([]*secretmanagerpb.SecretVersion, error) {
+	if client == nil {
+		var err error
+		client, err = secretmanager.NewClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup secret manager client: %v", err)
+		}
+		defer client.Close()
+	}
+
+	req := &secretmanagerpb.ListSecretVersionsRequest{
+		Parent: getSecretPath(project, name, ""),
+	}
+	versions := []*secretmanagerpb.SecretVersion{}
+	it := client.ListSecretVersions(ctx, req)
+	for {
+		version, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the versions of secret '%v': %v", req.Parent, err)
+		}
+		if version.State == secretmanagerpb.SecretVersion_DESTROYED {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return VersionNumber(versions[i].Name) > VersionNumber(versions[j].Name)
+	})
+	return versions, nil
+}
+
+// VersionNumber returns the number at the end of the full name of a secret version, or 0 when the name does not end
+// with a number
+func VersionNumber(versionName string) int {
+	number, err := strconv.Atoi(versionName[strings.LastIndex(versionName, "/")+1:])
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+// DisableVersion disables the secret version, the full name of the version is supplied i.e
+// projects/<PROJECT_ID>/secrets/<SECRET_NAME>/versions/<VERSION>
+func DisableVersion(ctx context.Context, client *secretmanager.Client, versionName string) error {
+	if client == nil {
+		var err error
+		client, err = secretmanager.NewClient(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to setup secret manager client: %v", err)
+		}
+		defer client.Close()
+	}
+
+	_, err := client.DisableSecretVersion(ctx, &secretmanagerpb.DisableSecretVersionRequest{Name: versionName})
+	if err != nil {
+		return fmt.Errorf("failed to disable secret version '%v': %v", versionName, err)
+	}
+	return nil
+}
+
+// DestroyVersion destroys the secret version, the value of a destroyed version cannot be recovered
+func DestroyVersion(ctx context.Context, client *secretmanager.Client, versionName string) error {
+	if client == nil {
+		var err error
+		client, err = secretmanager.NewClient(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to setup secret manager client: %v", err)
+		}
+		defer client.Close()
+	}
+
+	_, err := client.DestroySecretVersion(ctx, &secretmanagerpb.DestroySecretVersionRequest{Name: versionName})
+	if err != nil {
+		return fmt.Errorf("failed to destroy secret version '%v': %v", versionName, err)
+	}
+	return nil
+}
+
 var secretManagerClient *secretmanager.Client
 
 type ctxSecManClientKey struct{}
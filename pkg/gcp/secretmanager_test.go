@@ -292,3 +292,66 @@ func TestGetSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestVersionLifecycle(t *testing.T) {
+	ctx := context.Background()
+	testServer, client := NewFakeServerAndClient(ctx, t)
+	for i := 1; i <= 3; i++ {
+		_, err := AddVersion(ctx, client, "test-project", "test-name", fmt.Sprintf("value%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret := "projects/test-project/secrets/test-name"
+
+	if err := DisableVersion(ctx, client, secret+"/versions/2"); err != nil {
+		t.Fatalf("DisableVersion() error = %v", err)
+	}
+	if err := DestroyVersion(ctx, client, secret+"/versions/1"); err != nil {
+		t.Fatalf("DestroyVersion() error = %v", err)
+	}
+	wantErr := "failed to destroy secret version 'projects/test-project/secrets/test-name/versions/1': rpc error: code = FailedPrecondition desc = secret version 'projects/test-project/secrets/test-name/versions/1' is destroyed"
+	if err := DestroyVersion(ctx, client, secret+"/versions/1"); err == nil || err.Error() != wantErr {
+		t.Errorf("DestroyVersion() error = %v, wantErr %v", err, wantErr)
+	}
+
+	versions, err := ListVersions(ctx, client, "test-project", "test-name")
+	if err != nil {
+		t.Fatalf("ListVersions() error = %v", err)
+	}
+	got := []string{}
+	for _, version := range versions {
+		got = append(got, fmt.Sprintf("%d:%s", VersionNumber(version.Name), version.State))
+	}
+	want := []string{"3:ENABLED", "2:DISABLED"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ListVersions() = %v, want %v", got, want)
+	}
+
+	value, err := AccessVersion(ctx, client, "test-project", "test-name", "latest")
+	if err != nil || value != "value3" {
+		t.Errorf("AccessVersion() = %v, %v, want value3", value, err)
+	}
+	if _, exists := testServer.Responses[secret+"/versions/1"]; exists {
+		t.Errorf("the value of the destroyed version was not removed")
+	}
+}
+
+func TestVersionNumber(t *testing.T) {
+	tests := []struct {
+		name        string
+		versionName string
+		want        int
+	}{
+		{name: "full name", versionName: "projects/p/secrets/s/versions/12", want: 12},
+		{name: "number", versionName: "3", want: 3},
+		{name: "alias", versionName: "projects/p/secrets/s/versions/latest", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VersionNumber(tt.versionName); got != tt.want {
+				t.Errorf("VersionNumber() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,13 +4,20 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sync"
 	"testing"
 
+	"cloud.google.com/go/pubsub/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/pstest"
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type FakeSecretManagerServerResponse struct {
@@ -18,12 +25,18 @@ type FakeSecretManagerServerResponse struct {
 	Err      error
 }
 
+// FakeSecretManagerServer returns the Responses when accessing a secret version. Adding a version stores the version in
+// Versions, keyed by the full name of the secret, and adds the responses of the version and of the latest version
 type FakeSecretManagerServer struct {
 	secretmanagerpb.UnimplementedSecretManagerServiceServer
+	mutex     sync.Mutex
 	Responses map[string]FakeSecretManagerServerResponse
+	Versions  map[string][]*secretmanagerpb.SecretVersion
 }
 
 func (s *FakeSecretManagerServer) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
 	resp, exists := s.Responses[req.Name]
 	if !exists {
@@ -38,9 +51,83 @@ func (s *FakeSecretManagerServer) AccessSecretVersion(ctx context.Context, req *
 	//	}, nil
 }
 
+func (s *FakeSecretManagerServer) AddSecretVersion(ctx context.Context, req *secretmanagerpb.AddSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	version := &secretmanagerpb.SecretVersion{
+		Name:       fmt.Sprintf("%s/versions/%d", req.Parent, len(s.Versions[req.Parent])+1),
+		CreateTime: timestamppb.Now(),
+		State:      secretmanagerpb.SecretVersion_ENABLED,
+	}
+	s.Versions[req.Parent] = append(s.Versions[req.Parent], version)
+	response := FakeSecretManagerServerResponse{
+		Response: &secretmanagerpb.AccessSecretVersionResponse{Name: version.Name, Payload: req.Payload},
+	}
+	s.Responses[version.Name] = response
+	s.Responses[req.Parent+"/versions/latest"] = response
+	return version, nil
+}
+
+// ListSecretVersions returns the versions in a single page, the newest version is first the same as secret manager
+func (s *FakeSecretManagerServer) ListSecretVersions(ctx context.Context, req *secretmanagerpb.ListSecretVersionsRequest) (*secretmanagerpb.ListSecretVersionsResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	versions := s.Versions[req.Parent]
+	resp := &secretmanagerpb.ListSecretVersionsResponse{TotalSize: int32(len(versions))}
+	for i := len(versions) - 1; i >= 0; i-- {
+		resp.Versions = append(resp.Versions, proto.Clone(versions[i]).(*secretmanagerpb.SecretVersion))
+	}
+	return resp, nil
+}
+
+func (s *FakeSecretManagerServer) DisableSecretVersion(ctx context.Context, req *secretmanagerpb.DisableSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return s.setVersionState(req.Name, secretmanagerpb.SecretVersion_DISABLED)
+}
+
+func (s *FakeSecretManagerServer) DestroySecretVersion(ctx context.Context, req *secretmanagerpb.DestroySecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return s.setVersionState(req.Name, secretmanagerpb.SecretVersion_DESTROYED)
+}
+
+// VersionStates returns the state of each version of the secret, keyed by the version number
+func (s *FakeSecretManagerServer) VersionStates(secret string) map[int]secretmanagerpb.SecretVersion_State {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	states := map[int]secretmanagerpb.SecretVersion_State{}
+	for _, version := range s.Versions[secret] {
+		states[VersionNumber(version.Name)] = version.State
+	}
+	return states
+}
+
+func (s *FakeSecretManagerServer) setVersionState(name string, state secretmanagerpb.SecretVersion_State) (*secretmanagerpb.SecretVersion, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, versions := range s.Versions {
+		for _, version := range versions {
+			if version.Name != name {
+				continue
+			}
+			if version.State == secretmanagerpb.SecretVersion_DESTROYED {
+				return nil, status.Errorf(codes.FailedPrecondition, "secret version '%s' is destroyed", name)
+			}
+			version.State = state
+			if state == secretmanagerpb.SecretVersion_DESTROYED {
+				delete(s.Responses, name)
+			}
+			return proto.Clone(version).(*secretmanagerpb.SecretVersion), nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "secret version '%s' not found", name)
+}
+
 func NewFakeServerAndClient(ctx context.Context, t *testing.T) (*FakeSecretManagerServer, *secretmanager.Client) {
 	server := &FakeSecretManagerServer{
 		Responses: map[string]FakeSecretManagerServerResponse{},
+		Versions:  map[string][]*secretmanagerpb.SecretVersion{},
 	}
 
 	l, err := net.Listen("tcp", "localhost:0")
@@ -68,3 +155,18 @@ func NewFakeServerAndClient(ctx context.Context, t *testing.T) (*FakeSecretManag
 
 	return server, client
 }
+
+// NewFakePubSubServer starts a pstest pub/sub server and creates the topics, which are full topic names such as
+// projects/my-project/topics/my-topic. The server is closed when the test completes
+func NewFakePubSubServer(t *testing.T, topics ...string) *pstest.Server {
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+
+	for _, topic := range topics {
+		_, err := srv.GServer.CreateTopic(context.Background(), &pubsubpb.Topic{Name: topic})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return srv
+}
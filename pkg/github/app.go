@@ -0,0 +1,175 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// installation tokens are refreshed this long before they expire
+	InstallationTokenEarlyExpiry = 5 * time.Minute
+
+	appJwtLifetime = 9 * time.Minute
+	// the issued time is backdated to allow for clock drift between this server and github
+	appJwtClockDrift = 60 * time.Second
+)
+
+var (
+	installationTokenSources = map[string]oauth2.TokenSource{}
+	installationTokenMutex   sync.Mutex
+	// the requests made to create an installation token are cancelled after this long
+	installationTokenTimeout = 30 * time.Second
+)
+
+// AppConfiguration is used to authenticate as a GitHub App installation instead of using an access token
+type AppConfiguration struct {
+	AppId int64
+	// When the installation id is 0 the installation of the app on the org/repo is looked up
+	InstallationId int64
+	// The PEM encoded private key of the app
+	PrivateKey []byte
+}
+
+// appTokenSource returns the cached installation token source of the app, the token source refreshes the token shortly before it expires
+func (c *GitHubConfiguration) appTokenSource() (oauth2.TokenSource, error) {
+	key, err := parseAppPrivateKey(c.App.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// the private key is part of the key so a rotated key is not answered with a token source that signs with the old key
+	keyHash := sha256.Sum256(c.App.PrivateKey)
+	cacheKey := fmt.Sprintf("%s|%d|%d|%x", c.EnterpriseUrl, c.App.AppId, c.App.InstallationId, keyHash)
+	if c.App.InstallationId == 0 {
+		cacheKey = fmt.Sprintf("%s|%s/%s", cacheKey, c.Org, c.Repo)
+	}
+
+	installationTokenMutex.Lock()
+	defer installationTokenMutex.Unlock()
+	if ts, ok := installationTokenSources[cacheKey]; ok {
+		return ts, nil
+	}
+
+	src := &installationTokenSource{
+		appId:          c.App.AppId,
+		installationId: c.App.InstallationId,
+		org:            c.Org,
+		repo:           c.Repo,
+		key:            key,
+		newClient:      c.newClientWithHttpClient,
+	}
+	ts := oauth2.ReuseTokenSourceWithExpiry(nil, src, InstallationTokenEarlyExpiry)
+	installationTokenSources[cacheKey] = ts
+	return ts, nil
+}
+
+type installationTokenSource struct {
+	appId          int64
+	installationId int64
+	org            string
+	repo           string
+	key            *rsa.PrivateKey
+	newClient      func(httpClient *http.Client) (*github.Client, error)
+}
+
+// Token exchanges a JWT signed by the app for an installation token
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	// the token is cached across events, so it must not be bound to the context of the event that created it
+	ctx, cancel := context.WithTimeout(context.Background(), installationTokenTimeout)
+	defer cancel()
+	client, err := s.newClient(&http.Client{Transport: &appJwtTransport{appId: s.appId, key: s.key}, Timeout: installationTokenTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.installationId == 0 {
+		installation, _, err := client.Apps.FindRepositoryInstallation(ctx, s.org, s.repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the installation of github app %v on the '%v/%v' repository - %v", s.appId, s.org, s.repo, err)
+		}
+		s.installationId = installation.GetID()
+	}
+
+	token, _, err := client.Apps.CreateInstallationToken(ctx, s.installationId, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create an installation token for installation %v of github app %v - %v", s.installationId, s.appId, err)
+	}
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		Expiry:      token.GetExpiresAt().Time,
+	}, nil
+}
+
+// appJwtTransport authenticates the requests as the app, which is only used to create installation tokens
+type appJwtTransport struct {
+	appId int64
+	key   *rsa.PrivateKey
+}
+
+func (t *appJwtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := NewAppJwt(t.appId, t.key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// NewAppJwt returns a JWT signed with the private key of the app using RS256
+func NewAppJwt(appId int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-appJwtClockDrift).Unix(),
+		"exp": now.Add(appJwtLifetime).Unix(),
+		"iss": strconv.FormatInt(appId, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the github app jwt - %v", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func parseAppPrivateKey(privateKey []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, fmt.Errorf("the github app private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the github app private key - %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the github app private key is not an RSA key")
+	}
+	return key, nil
+}
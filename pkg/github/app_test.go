@@ -0,0 +1,232 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNewClient_App(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	// verifyJwt checks the request is authenticated with a JWT signed by the app private key
+	verifyJwt := func(t *testing.T, req *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			t.Fatalf("Invalid jwt: '%v'", req.Header.Get("Authorization"))
+		}
+		hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature); err != nil {
+			t.Fatalf("Invalid jwt signature: %v", err)
+		}
+		claimsJson, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		claims := map[string]interface{}{}
+		_ = json.Unmarshal(claimsJson, &claims)
+		if claims["iss"] != "123" {
+			t.Fatalf("Invalid jwt issuer: %v", claims["iss"])
+		}
+	}
+
+	tests := []struct {
+		name              string
+		installationId    int64
+		tokenLifetime     time.Duration
+		wantLookups       int
+		wantTokenRequests int
+	}{
+		{
+			name:              "installation id, the token is cached",
+			installationId:    42,
+			tokenLifetime:     time.Hour,
+			wantLookups:       0,
+			wantTokenRequests: 1,
+		},
+		{
+			name:              "installation lookup by org/repo",
+			installationId:    0,
+			tokenLifetime:     time.Hour,
+			wantLookups:       1,
+			wantTokenRequests: 1,
+		},
+		{
+			name:              "token close to expiry is refreshed",
+			installationId:    42,
+			tokenLifetime:     time.Minute,
+			wantLookups:       0,
+			wantTokenRequests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups := 0
+			tokenRequests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				switch {
+				case req.URL.Path == "/api/v3/repos/org/repo/installation" && req.Method == "GET":
+					verifyJwt(t, req)
+					lookups++
+					rw.Write([]byte(`{"id":42}`))
+				case req.URL.Path == "/api/v3/app/installations/42/access_tokens" && req.Method == "POST":
+					verifyJwt(t, req)
+					tokenRequests++
+					rw.WriteHeader(http.StatusCreated)
+					_ = json.NewEncoder(rw).Encode(map[string]string{
+						"token":      fmt.Sprintf("ghs_%d", tokenRequests),
+						"expires_at": time.Now().Add(tt.tokenLifetime).UTC().Format(time.RFC3339),
+					})
+				case req.URL.Path == "/api/v3/repos/org/repo/commits/sha/statuses" && req.Method == "GET":
+					if req.Header.Get("Authorization") != fmt.Sprintf("Bearer ghs_%d", tokenRequests) {
+						t.Fatalf("Invalid token: '%v'", req.Header.Get("Authorization"))
+					}
+					rw.Write([]byte(`[]`))
+				default:
+					t.Fatalf("Unexpected path: %v, method: %s", req.URL.Path, req.Method)
+				}
+			}))
+			defer server.Close()
+
+			for i := 0; i < 2; i++ {
+				c := New(context.Background(), zaptest.NewLogger(t), "org", "repo", "sha", "", 0, server.URL, true)
+				c.App = &AppConfiguration{AppId: 123, InstallationId: tt.installationId, PrivateKey: privateKey}
+				_, err := c.GetCommitCheckStatus("", "", "", "")
+				if err != nil {
+					t.Fatalf("GetCommitCheckStatus() error = %v", err)
+				}
+			}
+			if lookups != tt.wantLookups {
+				t.Errorf("installation lookups = %v, want %v", lookups, tt.wantLookups)
+			}
+			if tokenRequests != tt.wantTokenRequests {
+				t.Errorf("installation token requests = %v, want %v", tokenRequests, tt.wantTokenRequests)
+			}
+		})
+	}
+}
+
+func TestParseAppPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	tests := []struct {
+		name       string
+		privateKey []byte
+		wantErr    string
+	}{
+		{
+			name:       "pkcs1",
+			privateKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+		{
+			name:       "pkcs8",
+			privateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		},
+		{
+			name:       "not pem",
+			privateKey: []byte("not a key"),
+			wantErr:    "the github app private key is not PEM encoded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAppPrivateKey(tt.privateKey)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("parseAppPrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("parseAppPrivateKey() error = nil, wantErr %v", tt.wantErr)
+			}
+			if !got.Equal(key) {
+				t.Errorf("parseAppPrivateKey() returned a different key")
+			}
+		})
+	}
+}
+
+func TestAppTokenSource_PrivateKey(t *testing.T) {
+	newPrivateKey := func() []byte {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	}
+	oldKey := newPrivateKey()
+	newKey := newPrivateKey()
+
+	tokenSource := func(privateKey []byte) interface{} {
+		c := New(context.Background(), zaptest.NewLogger(t), "org", "repo", "sha", "", 0, "https://github.example.com/api/v3/", true)
+		c.App = &AppConfiguration{AppId: 456, InstallationId: 42, PrivateKey: privateKey}
+		ts, err := c.appTokenSource()
+		if err != nil {
+			t.Fatalf("appTokenSource() error = %v", err)
+		}
+		return ts
+	}
+
+	if tokenSource(oldKey) != tokenSource(oldKey) {
+		t.Errorf("appTokenSource() did not reuse the token source of the same private key")
+	}
+	if tokenSource(oldKey) == tokenSource(newKey) {
+		t.Errorf("appTokenSource() reused the token source of a different private key")
+	}
+}
+
+func TestAppTokenSource_Timeout(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	defer func(timeout time.Duration) { installationTokenTimeout = timeout }(installationTokenTimeout)
+	installationTokenTimeout = 100 * time.Millisecond
+
+	c := New(context.Background(), zaptest.NewLogger(t), "org", "repo", "sha", "", 0, server.URL, true)
+	c.App = &AppConfiguration{AppId: 789, InstallationId: 42, PrivateKey: privateKey}
+	ts, err := c.appTokenSource()
+	if err != nil {
+		t.Fatalf("appTokenSource() error = %v", err)
+	}
+	start := time.Now()
+	if _, err := ts.Token(); err == nil {
+		t.Fatalf("Token() error = nil, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Token() took %v, want it to time out", elapsed)
+	}
+}
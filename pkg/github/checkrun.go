@@ -0,0 +1,131 @@
+package github
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// MaxCheckRunAnnotations is the maximum number of annotations github accepts in a single check run request
+const MaxCheckRunAnnotations = 50
+
+// CheckRunStatusCompleted is the status of a check run that has a conclusion
+const CheckRunStatusCompleted = "completed"
+
+// CheckRunOptions are the values written to a check run
+type CheckRunOptions struct {
+	Name       string
+	Status     string
+	Conclusion string
+	DetailsUrl string
+	ExternalId string
+	Title      string
+	Summary    string
+	Text       string
+}
+
+// CreateOrUpdateCheckRun updates the latest check run with the same name on the commit sha that was created by the github app while
+// it is not completed, so a check run can be reported as queued, in progress and then completed. Once the check run is completed a new
+// check run is created instead, because github appends the annotations of each request and a re-run would otherwise keep the
+// annotations of the previous run. Annotations are sent 50 at a time. Check runs can only be written using github app authentication.
+// The returned boolean is true when an existing check run was updated
+func (c *GitHubConfiguration) CreateOrUpdateCheckRun(opts CheckRunOptions, annotations []*github.CheckRunAnnotation) (*github.CheckRun, bool, error) {
+	if c.App == nil {
+		return nil, false, fmt.Errorf("check runs can only be written using github app authentication, supply the appId and appPrivateKey properties instead of a token")
+	}
+	client, err := c.NewClient()
+	if err != nil {
+		return nil, false, err
+	}
+
+	existing, resp, err := client.Checks.ListCheckRunsForRef(c.context, c.Org, c.Repo, c.CommitSha, &github.ListCheckRunsOptions{
+		CheckName: &opts.Name,
+		AppID:     &c.App.AppId,
+	})
+	_, err = c.checkHttpResponse(existing, resp, err)
+	if err != nil {
+		return nil, false, fmt.Errorf("an error occurred attempting to list the check runs on commit '%v'. Error: %v", c.CommitSha, err)
+	}
+
+	batch := annotations
+	if len(batch) > MaxCheckRunAnnotations {
+		batch = batch[:MaxCheckRunAnnotations]
+	}
+
+	var checkRun *github.CheckRun
+	updated := len(existing.CheckRuns) > 0 && existing.CheckRuns[0].GetStatus() != CheckRunStatusCompleted
+	if updated {
+		checkRun, resp, err = client.Checks.UpdateCheckRun(c.context, c.Org, c.Repo, existing.CheckRuns[0].GetID(), newUpdateCheckRunOptions(opts, batch))
+	} else {
+		checkRun, resp, err = client.Checks.CreateCheckRun(c.context, c.Org, c.Repo, newCreateCheckRunOptions(opts, c.CommitSha, batch))
+	}
+	_, err = c.checkHttpResponse(checkRun, resp, err)
+	if err != nil {
+		return nil, updated, err
+	}
+
+	for start := MaxCheckRunAnnotations; start < len(annotations); start += MaxCheckRunAnnotations {
+		end := start + MaxCheckRunAnnotations
+		if end > len(annotations) {
+			end = len(annotations)
+		}
+		checkRun, resp, err = client.Checks.UpdateCheckRun(c.context, c.Org, c.Repo, checkRun.GetID(), github.UpdateCheckRunOptions{
+			Name:   opts.Name,
+			Output: newCheckRunOutput(opts, annotations[start:end]),
+		})
+		_, err = c.checkHttpResponse(checkRun, resp, err)
+		if err != nil {
+			return nil, updated, fmt.Errorf("failed to add annotations %d to %d to check run %v. Error: %v", start+1, end, checkRun.GetID(), err)
+		}
+	}
+	return checkRun, updated, nil
+}
+
+func newCreateCheckRunOptions(opts CheckRunOptions, headSha string, annotations []*github.CheckRunAnnotation) github.CreateCheckRunOptions {
+	update := newUpdateCheckRunOptions(opts, annotations)
+	return github.CreateCheckRunOptions{
+		Name:        opts.Name,
+		HeadSHA:     headSha,
+		DetailsURL:  update.DetailsURL,
+		ExternalID:  update.ExternalID,
+		Status:      update.Status,
+		Conclusion:  update.Conclusion,
+		CompletedAt: update.CompletedAt,
+		Output:      update.Output,
+	}
+}
+
+func newUpdateCheckRunOptions(opts CheckRunOptions, annotations []*github.CheckRunAnnotation) github.UpdateCheckRunOptions {
+	result := github.UpdateCheckRunOptions{
+		Name:       opts.Name,
+		DetailsURL: optionalString(opts.DetailsUrl),
+		ExternalID: optionalString(opts.ExternalId),
+		Status:     optionalString(opts.Status),
+		Conclusion: optionalString(opts.Conclusion),
+		Output:     newCheckRunOutput(opts, annotations),
+	}
+	if opts.Conclusion != "" {
+		result.CompletedAt = &github.Timestamp{Time: time.Now()}
+	}
+	return result
+}
+
+func newCheckRunOutput(opts CheckRunOptions, annotations []*github.CheckRunAnnotation) *github.CheckRunOutput {
+	if opts.Title == "" && opts.Summary == "" {
+		return nil
+	}
+	return &github.CheckRunOutput{
+		Title:       &opts.Title,
+		Summary:     &opts.Summary,
+		Text:        optionalString(opts.Text),
+		Annotations: annotations,
+	}
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,138 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCreateOrUpdateCheckRun(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	type request struct {
+		method      string
+		conclusion  string
+		annotations int
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body := github.UpdateCheckRunOptions{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		switch {
+		case req.URL.Path == "/api/v3/app/installations/42/access_tokens" && req.Method == "POST":
+			rw.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(rw).Encode(map[string]string{"token": "ghs_1", "expires_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339)})
+		case req.URL.Path == "/api/v3/repos/org/repo/commits/sha/check-runs" && req.Method == "GET":
+			if req.URL.Query().Get("app_id") != "123" {
+				t.Fatalf("Invalid app_id: %v", req.URL.Query().Get("app_id"))
+			}
+			if req.URL.Query().Get("check_name") == "lint" {
+				rw.Write([]byte(`{"total_count":1,"check_runs":[{"id":7,"name":"lint","status":"in_progress"}]}`))
+				return
+			}
+			if req.URL.Query().Get("check_name") == "test" {
+				rw.Write([]byte(`{"total_count":1,"check_runs":[{"id":7,"name":"test","status":"completed","conclusion":"failure"}]}`))
+				return
+			}
+			rw.Write([]byte(`{"total_count":0,"check_runs":[]}`))
+		case req.URL.Path == "/api/v3/repos/org/repo/check-runs" && req.Method == "POST":
+			requests = append(requests, request{method: "create", conclusion: body.GetConclusion(), annotations: len(body.Output.Annotations)})
+			rw.WriteHeader(http.StatusCreated)
+			rw.Write([]byte(`{"id":8}`))
+		case req.URL.Path == "/api/v3/repos/org/repo/check-runs/7" && req.Method == "PATCH":
+			requests = append(requests, request{method: "update 7", conclusion: body.GetConclusion(), annotations: len(body.Output.Annotations)})
+			rw.Write([]byte(`{"id":7}`))
+		case req.URL.Path == "/api/v3/repos/org/repo/check-runs/8" && req.Method == "PATCH":
+			requests = append(requests, request{method: "update 8", conclusion: body.GetConclusion(), annotations: len(body.Output.Annotations)})
+			rw.Write([]byte(`{"id":8}`))
+		default:
+			t.Fatalf("Unexpected path: %v, method: %s", req.URL.Path, req.Method)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		checkName    string
+		annotations  int
+		wantUpdated  bool
+		wantRequests []request
+	}{
+		{
+			name:         "created with batched annotations",
+			checkName:    "build",
+			annotations:  120,
+			wantUpdated:  false,
+			wantRequests: []request{{"create", "failure", 50}, {"update 8", "", 50}, {"update 8", "", 20}},
+		},
+		{
+			name:         "completed check run is not updated on a re-run",
+			checkName:    "test",
+			annotations:  3,
+			wantUpdated:  false,
+			wantRequests: []request{{"create", "failure", 3}},
+		},
+		{
+			name:         "existing check run is updated",
+			checkName:    "lint",
+			annotations:  3,
+			wantUpdated:  true,
+			wantRequests: []request{{"update 7", "failure", 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			annotations := []*github.CheckRunAnnotation{}
+			for i := 0; i < tt.annotations; i++ {
+				annotations = append(annotations, &github.CheckRunAnnotation{
+					Path:            github.String("main.go"),
+					StartLine:       github.Int(i + 1),
+					EndLine:         github.Int(i + 1),
+					AnnotationLevel: github.String("failure"),
+					Message:         github.String(fmt.Sprintf("finding %d", i)),
+				})
+			}
+			c := New(context.Background(), zaptest.NewLogger(t), "org", "repo", "sha", "", 0, server.URL, true)
+			c.App = &AppConfiguration{AppId: 123, InstallationId: 42, PrivateKey: privateKey}
+			_, updated, err := c.CreateOrUpdateCheckRun(CheckRunOptions{
+				Name:       tt.checkName,
+				Conclusion: "failure",
+				Title:      "title",
+				Summary:    "summary",
+			}, annotations)
+			if err != nil {
+				t.Fatalf("CreateOrUpdateCheckRun() error = %v", err)
+			}
+			if updated != tt.wantUpdated {
+				t.Errorf("CreateOrUpdateCheckRun() updated = %v, want %v", updated, tt.wantUpdated)
+			}
+			if fmt.Sprint(requests) != fmt.Sprint(tt.wantRequests) {
+				t.Errorf("CreateOrUpdateCheckRun() requests = %v, want %v", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestCreateOrUpdateCheckRun_Token(t *testing.T) {
+	c := New(context.Background(), zaptest.NewLogger(t), "org", "repo", "sha", "token", 0, "", false)
+	_, _, err := c.CreateOrUpdateCheckRun(CheckRunOptions{Name: "build"}, nil)
+	if err == nil {
+		t.Fatalf("CreateOrUpdateCheckRun() error = nil, want an error when the github app is not configured")
+	}
+}
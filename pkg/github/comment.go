@@ -0,0 +1,153 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+)
+
+const (
+	// MaxCommentLength is the maximum number of characters github accepts in the body of a comment
+	MaxCommentLength = 65536
+
+	historyStart     = "<!-- event-reactor:history-start -->"
+	historyEnd       = "<!-- event-reactor:history-end -->"
+	historySeparator = "<!-- event-reactor:history-entry -->"
+)
+
+var historyEntrySeparator = fmt.Sprintf("\n\n---\n%s\n\n", historySeparator)
+
+// CommentMarker returns the hidden html comment added to the body of a comment so the comment can be found and edited by later events
+func CommentMarker(marker string) string {
+	return fmt.Sprintf("<!-- event-reactor:comment-marker=%s -->", strings.TrimSpace(marker))
+}
+
+// UpsertCommitComment edits the comment on the commit containing the marker, or creates the comment when it does not exist.
+// When previousResultsCount is greater than 0, the body of the edited comment is kept in a collapsed previous results section.
+// The returned boolean is true when an existing comment was edited
+func (c *GitHubConfiguration) UpsertCommitComment(marker string, body string, previousResultsCount int) (*github.RepositoryComment, bool, error) {
+	client, err := c.NewClient()
+	if err != nil {
+		return nil, false, err
+	}
+
+	comments, err := c.listCommitComments(client, c.CommitSha)
+	if err != nil {
+		return nil, false, fmt.Errorf("an error occurred attempting to list the comments on commit '%v'. Error: %v", c.CommitSha, err)
+	}
+	markerComment := CommentMarker(marker)
+	for _, comment := range comments {
+		if !strings.Contains(comment.GetBody(), markerComment) {
+			continue
+		}
+		newBody := BuildUpsertCommentBody(body, marker, comment.GetBody(), previousResultsCount)
+		updated, resp, err := client.Repositories.UpdateComment(c.context, c.Org, c.Repo, comment.GetID(), &github.RepositoryComment{Body: &newBody})
+		_, err = c.checkHttpResponse(updated, resp, err)
+		return updated, true, err
+	}
+
+	newBody := BuildUpsertCommentBody(body, marker, "", previousResultsCount)
+	newComment, resp, err := client.Repositories.CreateComment(c.context, c.Org, c.Repo, c.CommitSha, &github.RepositoryComment{Body: &newBody})
+	_, err = c.checkHttpResponse(newComment, resp, err)
+	return newComment, false, err
+}
+
+// UpsertPullRequestComment edits the comment on the pull request containing the marker, or creates the comment when it does not exist.
+// When previousResultsCount is greater than 0, the body of the edited comment is kept in a collapsed previous results section.
+// The returned boolean is true when an existing comment was edited
+func (c *GitHubConfiguration) UpsertPullRequestComment(marker string, body string, previousResultsCount int) (*github.IssueComment, bool, error) {
+	client, err := c.NewClient()
+	if err != nil {
+		return nil, false, err
+	}
+
+	comments, err := c.listIssueComments(client, c.PrNumber)
+	if err != nil {
+		return nil, false, fmt.Errorf("an error occurred attempting to list the comments on pr '%v'. Error: %v", c.PrNumber, err)
+	}
+	markerComment := CommentMarker(marker)
+	for _, comment := range comments {
+		if !strings.Contains(comment.GetBody(), markerComment) {
+			continue
+		}
+		newBody := BuildUpsertCommentBody(body, marker, comment.GetBody(), previousResultsCount)
+		updated, resp, err := client.Issues.EditComment(c.context, c.Org, c.Repo, comment.GetID(), &github.IssueComment{Body: &newBody})
+		_, err = c.checkHttpResponse(updated, resp, err)
+		return updated, true, err
+	}
+
+	newBody := BuildUpsertCommentBody(body, marker, "", previousResultsCount)
+	newComment, resp, err := client.Issues.CreateComment(c.context, c.Org, c.Repo, c.PrNumber, &github.IssueComment{Body: &newBody})
+	_, err = c.checkHttpResponse(newComment, resp, err)
+	return newComment, false, err
+}
+
+// BuildUpsertCommentBody returns the body of an upserted comment. The current result of the existing comment is moved into the
+// previous results section, which keeps at most previousResultsCount results and drops the oldest results when the comment is too long
+func BuildUpsertCommentBody(body string, marker string, existingBody string, previousResultsCount int) string {
+	markerComment := CommentMarker(marker)
+	history := []string{}
+	if previousResultsCount > 0 && existingBody != "" {
+		current, previous := parseUpsertCommentBody(existingBody, markerComment)
+		history = append([]string{current}, previous...)
+		if len(history) > previousResultsCount {
+			history = history[:previousResultsCount]
+		}
+	}
+
+	for {
+		result := renderUpsertCommentBody(body, markerComment, history)
+		if len(result) <= MaxCommentLength || len(history) == 0 {
+			return result
+		}
+		history = history[:len(history)-1]
+	}
+}
+
+func renderUpsertCommentBody(body string, markerComment string, history []string) string {
+	sb := strings.Builder{}
+	sb.WriteString(body)
+	if len(history) > 0 {
+		sb.WriteString("\n\n")
+		sb.WriteString(historyStart)
+		sb.WriteString(fmt.Sprintf("\n<details>\n<summary>Previous results (%d)</summary>\n\n", len(history)))
+		for i, entry := range history {
+			if i > 0 {
+				sb.WriteString(historyEntrySeparator)
+			}
+			sb.WriteString(entry)
+		}
+		sb.WriteString("\n\n</details>\n")
+		sb.WriteString(historyEnd)
+	}
+	sb.WriteString("\n\n")
+	sb.WriteString(markerComment)
+	return sb.String()
+}
+
+// parseUpsertCommentBody splits the body of an upserted comment into the current result and the previous results
+func parseUpsertCommentBody(existingBody string, markerComment string) (string, []string) {
+	body := strings.TrimSpace(strings.Replace(existingBody, markerComment, "", 1))
+	start := strings.Index(body, historyStart)
+	end := strings.Index(body, historyEnd)
+	if start == -1 || end < start {
+		return body, []string{}
+	}
+
+	current := strings.TrimSpace(body[:start])
+	section := body[start+len(historyStart) : end]
+	section = strings.TrimSpace(section)
+	if i := strings.Index(section, "</summary>"); i != -1 {
+		section = section[i+len("</summary>"):]
+	}
+	section = strings.TrimSuffix(strings.TrimSpace(section), "</details>")
+
+	previous := []string{}
+	for _, entry := range strings.Split(section, historyEntrySeparator) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			previous = append(previous, entry)
+		}
+	}
+	return current, previous
+}
@@ -0,0 +1,129 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestBuildUpsertCommentBody(t *testing.T) {
+	marker := CommentMarker("build")
+	tests := []struct {
+		name                 string
+		bodies               []string
+		previousResultsCount int
+		want                 string
+	}{
+		{
+			name:                 "no history",
+			bodies:               []string{"result 1", "result 2"},
+			previousResultsCount: 0,
+			want:                 "result 2\n\n" + marker,
+		},
+		{
+			name:                 "history",
+			bodies:               []string{"result 1", "result 2", "result 3"},
+			previousResultsCount: 5,
+			want:                 "result 3\n\n" + historyStart + "\n<details>\n<summary>Previous results (2)</summary>\n\nresult 2" + historyEntrySeparator + "result 1\n\n</details>\n" + historyEnd + "\n\n" + marker,
+		},
+		{
+			name:                 "history is limited to the previous results count",
+			bodies:               []string{"result 1", "result 2", "result 3", "result 4"},
+			previousResultsCount: 2,
+			want:                 "result 4\n\n" + historyStart + "\n<details>\n<summary>Previous results (2)</summary>\n\nresult 3" + historyEntrySeparator + "result 2\n\n</details>\n" + historyEnd + "\n\n" + marker,
+		},
+		{
+			name:                 "oldest history is dropped when the comment is too long",
+			bodies:               []string{strings.Repeat("a", MaxCommentLength/2), strings.Repeat("b", MaxCommentLength/2), "result 3"},
+			previousResultsCount: 5,
+			want:                 "result 3\n\n" + historyStart + "\n<details>\n<summary>Previous results (1)</summary>\n\n" + strings.Repeat("b", MaxCommentLength/2) + "\n\n</details>\n" + historyEnd + "\n\n" + marker,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			for _, body := range tt.bodies {
+				got = BuildUpsertCommentBody(body, "build", got, tt.previousResultsCount)
+			}
+			if got != tt.want {
+				t.Errorf("BuildUpsertCommentBody() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpsertCommitComment(t *testing.T) {
+	var serverUrl string
+	edited := map[string]string{}
+	created := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body := map[string]string{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		switch {
+		case req.URL.Path == "/api/v3/repos/org/repo/commits/sha/comments" && req.Method == "GET":
+			if req.URL.Query().Get("page") == "2" {
+				rw.Write([]byte(fmt.Sprintf(`[{"id":2,"body":"old\n\n%s"}]`, CommentMarker("build"))))
+				return
+			}
+			rw.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/repos/org/repo/commits/sha/comments?page=2>; rel="next"`, serverUrl))
+			rw.Write([]byte(`[{"id":1,"body":"other comment"}]`))
+		case req.URL.Path == "/api/v3/repos/org/repo/comments/2" && req.Method == "PATCH":
+			edited["2"] = body["body"]
+			rw.Write([]byte(`{"id":2}`))
+		case req.URL.Path == "/api/v3/repos/org/repo/commits/sha/comments" && req.Method == "POST":
+			created = append(created, body["body"])
+			rw.WriteHeader(http.StatusCreated)
+			rw.Write([]byte(`{"id":3}`))
+		default:
+			t.Fatalf("Unexpected path: %v, method: %s", req.URL.Path, req.Method)
+		}
+	}))
+	defer server.Close()
+	serverUrl = server.URL
+
+	tests := []struct {
+		name        string
+		marker      string
+		wantUpdated bool
+		wantEdited  map[string]string
+		wantCreated []string
+	}{
+		{
+			name:        "the comment on the second page is edited",
+			marker:      "build",
+			wantUpdated: true,
+			wantEdited:  map[string]string{"2": "new\n\n" + CommentMarker("build")},
+			wantCreated: []string{},
+		},
+		{
+			name:        "the comment is created",
+			marker:      "lint",
+			wantUpdated: false,
+			wantEdited:  map[string]string{},
+			wantCreated: []string{"new\n\n" + CommentMarker("lint")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edited = map[string]string{}
+			created = []string{}
+			c := New(context.Background(), zaptest.NewLogger(t), "org", "repo", "sha", "token", 0, server.URL, true)
+			_, updated, err := c.UpsertCommitComment(tt.marker, "new", 0)
+			if err != nil {
+				t.Fatalf("UpsertCommitComment() error = %v", err)
+			}
+			if updated != tt.wantUpdated {
+				t.Errorf("UpsertCommitComment() updated = %v, want %v", updated, tt.wantUpdated)
+			}
+			if fmt.Sprint(edited) != fmt.Sprint(tt.wantEdited) || fmt.Sprint(created) != fmt.Sprint(tt.wantCreated) {
+				t.Errorf("UpsertCommitComment() edited = %q, created = %q, want %q, %q", edited, created, tt.wantEdited, tt.wantCreated)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+const (
+	EventRepositoryDispatch = "repository_dispatch"
+	EventWorkflowDispatch   = "workflow_dispatch"
+)
+
+// SendRepositoryDispatch sends a repository_dispatch event which triggers the workflows listening for the event type
+func (c *GitHubConfiguration) SendRepositoryDispatch(eventType string, clientPayload map[string]interface{}) error {
+	client, err := c.NewClient()
+	if err != nil {
+		return err
+	}
+
+	opts := github.DispatchRequestOptions{
+		EventType: eventType,
+	}
+	if len(clientPayload) > 0 {
+		payloadBytes, err := json.Marshal(clientPayload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal the client payload into json - %v", err)
+		}
+		payload := json.RawMessage(payloadBytes)
+		opts.ClientPayload = &payload
+	}
+
+	repo, resp, err := client.Repositories.Dispatch(c.context, c.Org, c.Repo, opts)
+	_, err = c.checkHttpResponse(repo, resp, err)
+	return err
+}
+
+// SendWorkflowDispatch triggers the workflow file on the ref (a branch or tag) using a workflow_dispatch event
+func (c *GitHubConfiguration) SendWorkflowDispatch(workflowFileName string, ref string, inputs map[string]interface{}) error {
+	client, err := c.NewClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Actions.CreateWorkflowDispatchEventByFileName(c.context, c.Org, c.Repo, workflowFileName, github.CreateWorkflowDispatchEventRequest{
+		Ref:    ref,
+		Inputs: inputs,
+	})
+	_, err = c.checkHttpResponse(nil, resp, err)
+	return err
+}
+
+// WaitForWorkflowRun polls the workflow runs until a run triggered by the event and created after since appears.
+// When the workflow file name is empty the runs of all the workflows of the repository are searched, when the branch is not empty only the runs of the branch are searched.
+// GitHub does not return the run created by a dispatch, so the most recent matching run is assumed to be the triggered run
+func (c *GitHubConfiguration) WaitForWorkflowRun(workflowFileName string, event string, branch string, since time.Time, timeout time.Duration, interval time.Duration) (*github.WorkflowRun, error) {
+	client, err := c.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &github.ListWorkflowRunsOptions{
+		Event:       event,
+		Branch:      branch,
+		ListOptions: github.ListOptions{PerPage: 20},
+	}
+	// the created time of a run only has a precision of seconds
+	since = since.Truncate(time.Second)
+	deadline := time.Now().Add(timeout)
+	for {
+		var runs *github.WorkflowRuns
+		var resp *github.Response
+		if workflowFileName != "" {
+			runs, resp, err = client.Actions.ListWorkflowRunsByFileName(c.context, c.Org, c.Repo, workflowFileName, opts)
+		} else {
+			runs, resp, err = client.Actions.ListRepositoryWorkflowRuns(c.context, c.Org, c.Repo, opts)
+		}
+		_, err = c.checkHttpResponse(runs, resp, err)
+		if err != nil {
+			return nil, fmt.Errorf("an error occurred attempting to list the workflow runs of the '%v/%v' repository. Error: %v", c.Org, c.Repo, err)
+		}
+
+		var latest *github.WorkflowRun
+		for _, run := range runs.WorkflowRuns {
+			if run.GetCreatedAt().Before(since) {
+				continue
+			}
+			if latest == nil || run.GetCreatedAt().After(latest.GetCreatedAt().Time) {
+				latest = run
+			}
+		}
+		if latest != nil {
+			return latest, nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return nil, fmt.Errorf("the triggered workflow run did not appear within %v", timeout)
+		}
+		select {
+		case <-c.context.Done():
+			return nil, c.context.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -29,10 +29,13 @@ type GitHubConfiguration struct {
 	EnterpriseUrl string
 	PrNumber      int
 	IsEnterprise  bool
+
+	// When set, the client authenticates as the github app installation instead of using the access token
+	App *AppConfiguration
 }
 
 func New(context context.Context, log *zap.Logger, org, repo, commitSha, accessToken string, prNumber int, enterpriseUrl string, isEnterprise bool) *GitHubConfiguration {
-	logger := log.With(zap.String("reactor", "github/comment"), zap.String("org", org), zap.String("repo", repo), zap.String("commitSha", commitSha))
+	logger := log.With(zap.String("org", org), zap.String("repo", repo), zap.String("commitSha", commitSha))
 	return &GitHubConfiguration{
 		context:       context,
 		logger:        logger,
@@ -74,26 +77,30 @@ func (c *GitHubConfiguration) WriteCommitComment(body string, commentHeading str
 }
 
 func (c *GitHubConfiguration) NewClient() (*github.Client, error) {
-	var client *github.Client
 	var httpClient *http.Client
-	var err error
 
-	if c.accessToken != "" {
+	if c.App != nil {
+		ts, err := c.appTokenSource()
+		if err != nil {
+			return nil, err
+		}
+		httpClient = oauth2.NewClient(c.context, ts)
+	} else if c.accessToken != "" {
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: c.accessToken},
 		)
 		httpClient = oauth2.NewClient(c.context, ts)
 	}
-	client = github.NewClient(httpClient)
 
+	return c.newClientWithHttpClient(httpClient)
+}
+
+func (c *GitHubConfiguration) newClientWithHttpClient(httpClient *http.Client) (*github.Client, error) {
+	client := github.NewClient(httpClient)
 	if c.IsEnterprise {
-		client, err = client.WithEnterpriseURLs(c.EnterpriseUrl, c.EnterpriseUrl)
-		if err != nil {
-			return nil, err
-		}
+		return client.WithEnterpriseURLs(c.EnterpriseUrl, c.EnterpriseUrl)
 	}
-
-	return client, err
+	return client, nil
 }
 
 func (c *GitHubConfiguration) WritePullRequestComment(body string) (*github.IssueComment, error) {
@@ -143,7 +150,7 @@ func (c *GitHubConfiguration) CleanExistingCommentsOnAllPullRequestCommits(comme
 	r, _ := regexp.Compile(fmt.Sprintf("^%v", strings.Trim(commentHeading, " ")))
 
 	if c.PrNumber == -1 || c.PrNumber == 0 {
-		comments, _, err := client.Repositories.ListCommitComments(c.context, c.Org, c.Repo, c.CommitSha, nil)
+		comments, err := c.listCommitComments(client, c.CommitSha)
 
 		if err != nil {
 			return fmt.Errorf("an error occurred attempting to list the commits on comment '%v'. Error: %v", c.CommitSha, err)
@@ -154,7 +161,7 @@ func (c *GitHubConfiguration) CleanExistingCommentsOnAllPullRequestCommits(comme
 		}
 
 	} else {
-		commits, _, err := client.PullRequests.ListCommits(c.context, c.Org, c.Repo, c.PrNumber, nil)
+		commits, err := c.listPullRequestCommits(client)
 
 		if err != nil {
 			return fmt.Errorf("an error occurred attempting to list commits from pull request '%v'. Error: %v", c.PrNumber, err)
@@ -178,7 +185,7 @@ func (c *GitHubConfiguration) CleanExistingCommentsOnPullRequest(commentHeading
 
 	r, _ := regexp.Compile(fmt.Sprintf("^%v", strings.Trim(commentHeading, " ")))
 
-	comments, _, _ := client.Issues.ListComments(c.context, c.Org, c.Repo, c.PrNumber, nil)
+	comments, err := c.listIssueComments(client, c.PrNumber)
 
 	if err != nil {
 		return fmt.Errorf("an error occurred attempting to list the commits on pr '%v'. Error: %v", c.PrNumber, err)
@@ -216,7 +223,7 @@ func (c *GitHubConfiguration) CleanExistingCommentsOnCommit(commentHeading strin
 }
 
 func (c *GitHubConfiguration) removeCommitComments(client *github.Client, r *regexp.Regexp, sha string) error {
-	comments, _, err := client.Repositories.ListCommitComments(c.context, c.Org, c.Repo, sha, nil)
+	comments, err := c.listCommitComments(client, sha)
 
 	if err != nil {
 		return fmt.Errorf("an error occurred attempting to list the comments on commit '%v'. Error: %v", c.CommitSha, err)
@@ -228,6 +235,57 @@ func (c *GitHubConfiguration) removeCommitComments(client *github.Client, r *reg
 	return nil
 }
 
+// listCommitComments returns the comments on every page of the commit comments
+func (c *GitHubConfiguration) listCommitComments(client *github.Client, sha string) ([]*github.RepositoryComment, error) {
+	results := []*github.RepositoryComment{}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		comments, resp, err := client.Repositories.ListCommitComments(c.context, c.Org, c.Repo, sha, opts)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, comments...)
+		if resp.NextPage == 0 {
+			return results, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// listPullRequestCommits returns the commits on every page of the pull request commits
+func (c *GitHubConfiguration) listPullRequestCommits(client *github.Client) ([]*github.RepositoryCommit, error) {
+	results := []*github.RepositoryCommit{}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		commits, resp, err := client.PullRequests.ListCommits(c.context, c.Org, c.Repo, c.PrNumber, opts)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, commits...)
+		if resp.NextPage == 0 {
+			return results, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// listIssueComments returns the comments on every page of the issue or pull request comments
+func (c *GitHubConfiguration) listIssueComments(client *github.Client, number int) ([]*github.IssueComment, error) {
+	results := []*github.IssueComment{}
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := client.Issues.ListComments(c.context, c.Org, c.Repo, number, opts)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, comments...)
+		if resp.NextPage == 0 {
+			return results, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
 func (c *GitHubConfiguration) removeCommitComment(body string, commentId int64, r *regexp.Regexp, client *github.Client) {
 	// body := *comment.Body
 	if r.MatchString(body) {
@@ -267,28 +325,60 @@ func (c *GitHubConfiguration) GetCommitCheckStatus(state string, context string,
 		return nil, err
 	}
 
-	statuses, resp, err := client.Repositories.ListStatuses(c.context, c.Org, c.Repo, c.CommitSha, &github.ListOptions{})
+	statuses, resp, err := client.Repositories.ListStatuses(c.context, c.Org, c.Repo, c.CommitSha, &github.ListOptions{PerPage: 100})
 	_, err = c.checkHttpResponse(statuses, resp, err)
 	return statuses, err
 
 }
 
+// WriteCommitCheckStatusIfFailedDoesNotExist writes the commit status unless the latest status of the same context is a failure or
+// error. A failure or error status is always written. When the write is skipped, nil is returned for both the status and the error
 func (c *GitHubConfiguration) WriteCommitCheckStatusIfFailedDoesNotExist(state string, context string, targetUrl string, description string) (*github.RepoStatus, error) {
-	status, err := c.GetCommitCheckStatus(state, context, targetUrl, description)
+	if isFailedState(state) {
+		return c.WriteCommitCheckStatus(state, context, targetUrl, description)
+	}
+
+	latest, err := c.GetLatestCommitCheckStatus(context)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && isFailedState(latest.GetState()) {
+		c.logger.Info(fmt.Sprintf("the latest status of the '%s' context is %s, skipping writing the %s status", context, latest.GetState(), state))
+		return nil, nil
+	}
+
+	return c.WriteCommitCheckStatus(state, context, targetUrl, description)
+}
 
+// GetLatestCommitCheckStatus returns the most recent status of the context, or nil when the context does not have a status.
+// The statuses are listed newest first, so an older failure that was replaced by a later status is ignored
+func (c *GitHubConfiguration) GetLatestCommitCheckStatus(context string) (*github.RepoStatus, error) {
+	client, err := c.NewClient()
 	if err != nil {
 		return nil, err
 	}
-	if state == "success" {
-		for _, val := range status {
-			state := *val.State
-			if *val.Context == context && (state == "failure" || state == "error") {
-				return nil, nil
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		statuses, resp, err := client.Repositories.ListStatuses(c.context, c.Org, c.Repo, c.CommitSha, opts)
+		_, err = c.checkHttpResponse(statuses, resp, err)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range statuses {
+			if status.GetContext() == context {
+				return status, nil
 			}
 		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
 	}
+}
 
-	return c.WriteCommitCheckStatus(state, context, targetUrl, description)
+func isFailedState(state string) bool {
+	return state == "failure" || state == "error"
 }
 
 func (c *GitHubConfiguration) CreateDeploymentAndStatus(environment string, envIsProd bool, autoMerge bool, description string, requiredContexts []string, state string, autoInactive bool, logsUrl *string, environmentUrl *string) (*github.Deployment, *github.DeploymentStatus, error) {
@@ -301,13 +391,58 @@ func (c *GitHubConfiguration) CreateDeploymentAndStatus(environment string, envI
 	deploymentStatus, err := c.CreateDeploymentStatus(*deployment.ID, environment, state, autoInactive, description, logsUrl, environmentUrl)
 	if err != nil {
 		deploymentIdString := strconv.FormatInt(*deployment.ID, 10)
-		return deployment, nil, fmt.Errorf("failed to create the deployment status on deployment ID %v for environment '%v/%v' in the '%v' repository on commit %v. - %v", deploymentIdString, environment, c.Org, c.Repo, c.CommitSha, err)
+		return deployment, nil, fmt.Errorf("failed to create the deployment status on deployment ID %v for environment '%v' in the '%v/%v' repository on commit %v. - %v", deploymentIdString, environment, c.Org, c.Repo, c.CommitSha, err)
 	}
 
 	return deployment, deploymentStatus, nil
 
 }
 
+// FindDeployment returns the most recent deployment of the commit sha (or ref) to the environment, or nil when no deployment exists
+func (c *GitHubConfiguration) FindDeployment(environment string) (*github.Deployment, error) {
+	client, err := c.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, resp, err := client.Repositories.ListDeployments(c.context, c.Org, c.Repo, &github.DeploymentsListOptions{
+		Ref:         c.CommitSha,
+		Environment: environment,
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	_, err = c.checkHttpResponse(deployments, resp, err)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *github.Deployment
+	for _, deployment := range deployments {
+		if latest == nil || deployment.GetCreatedAt().After(latest.GetCreatedAt().Time) {
+			latest = deployment
+		}
+	}
+	return latest, nil
+}
+
+// CreateOrUpdateDeploymentStatus adds a status to the existing deployment of the commit sha (or ref) to the environment.
+// The deployment is created when it does not exist
+func (c *GitHubConfiguration) CreateOrUpdateDeploymentStatus(environment string, envIsProd bool, autoMerge bool, description string, requiredContexts []string, state string, autoInactive bool, logsUrl *string, environmentUrl *string) (*github.Deployment, *github.DeploymentStatus, bool, error) {
+	deployment, err := c.FindDeployment(environment)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to find the existing deployment for environment '%v' in the '%v/%v' repository on commit %v. - %v", environment, c.Org, c.Repo, c.CommitSha, err)
+	}
+	if deployment == nil {
+		deployment, deploymentStatus, err := c.CreateDeploymentAndStatus(environment, envIsProd, autoMerge, description, requiredContexts, state, autoInactive, logsUrl, environmentUrl)
+		return deployment, deploymentStatus, true, err
+	}
+
+	deploymentStatus, err := c.CreateDeploymentStatus(deployment.GetID(), environment, state, autoInactive, description, logsUrl, environmentUrl)
+	if err != nil {
+		return deployment, nil, false, fmt.Errorf("failed to create the deployment status on deployment ID %v for environment '%v' in the '%v/%v' repository on commit %v. - %v", deployment.GetID(), environment, c.Org, c.Repo, c.CommitSha, err)
+	}
+	return deployment, deploymentStatus, false, nil
+}
+
 func (c *GitHubConfiguration) CreateDeployment(environment string, envIsProd bool, autoMerge bool, description string, requiredContexts []string) (*github.Deployment, error) {
 	client, err := c.NewClient()
 	if err != nil {
@@ -324,17 +459,24 @@ func (c *GitHubConfiguration) CreateDeployment(environment string, envIsProd boo
 
 	req := &github.DeploymentRequest{
 		Ref:                   &c.CommitSha,
-		RequiredContexts:      &requiredContexts,
 		Environment:           &environment,
 		Description:           &description,
 		TransientEnvironment:  &isTrans,
 		ProductionEnvironment: &isProd,
 		AutoMerge:             &autoMerge,
 	}
+	// when the required contexts are not supplied github verifies all the commit statuses, an empty list skips the verification
+	if requiredContexts != nil {
+		req.RequiredContexts = &requiredContexts
+	}
 
 	deployment, resp, err := client.Repositories.CreateDeployment(c.context, c.Org, c.Repo, req)
 
 	_, err = c.checkHttpResponse(deployment, resp, err)
+	if err == nil && deployment.ID == nil {
+		// github returns a 202 without a deployment when the default branch was auto merged into the ref
+		return nil, fmt.Errorf("github did not create the deployment, the default branch may have been merged into '%v'. Create the deployment again or set autoMerge to false", c.CommitSha)
+	}
 	return deployment, err
 
 }
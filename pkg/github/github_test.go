@@ -3,6 +3,7 @@ package github
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
@@ -213,3 +214,89 @@ func TestCleanExistingCommentsOnCommit(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteCommitCheckStatusIfFailedDoesNotExist(t *testing.T) {
+	written := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/api/v3/repos/org/repo/commits/sha/statuses" && req.Method == "GET" {
+			// the statuses are newest first, the failure of the deploy context was replaced by a success and the build failure is on the second page
+			if req.URL.Query().Get("page") == "2" {
+				rw.Write([]byte(`[{"id":2,"state":"failure","context":"build"},{"id":1,"state":"failure","context":"deploy"}]`))
+				return
+			}
+			rw.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/repos/org/repo/commits/sha/statuses?page=2>; rel="next"`, "http://"+req.Host))
+			rw.Write([]byte(`[{"id":4,"state":"success","context":"lint"},{"id":3,"state":"success","context":"deploy"}]`))
+			return
+		}
+		if req.URL.Path == "/api/v3/repos/org/repo/statuses/sha" && req.Method == "POST" {
+			body, _ := io.ReadAll(req.Body)
+			written = append(written, string(body))
+			rw.WriteHeader(http.StatusCreated)
+			rw.Write(body)
+			return
+		}
+		t.Fatalf("Unexpected path: %v, method: %s", req.URL.Path, req.Method)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		state       string
+		context     string
+		wantWritten bool
+	}{
+		{
+			name:        "success does not overwrite a failure",
+			state:       "success",
+			context:     "build",
+			wantWritten: false,
+		},
+		{
+			name:        "pending does not overwrite a failure",
+			state:       "pending",
+			context:     "build",
+			wantWritten: false,
+		},
+		{
+			name:        "failure is always written",
+			state:       "failure",
+			context:     "build",
+			wantWritten: true,
+		},
+		{
+			name:        "success is written when a failure was replaced by a later success",
+			state:       "success",
+			context:     "deploy",
+			wantWritten: true,
+		},
+		{
+			name:        "success is written for a context without a status",
+			state:       "success",
+			context:     "test",
+			wantWritten: true,
+		},
+		{
+			name:        "success is written for a context without a failure",
+			state:       "success",
+			context:     "lint",
+			wantWritten: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			written = []string{}
+			c := New(context.Background(), zaptest.NewLogger(t), "org", "repo", "sha", "token", 0, server.URL, true)
+			got, err := c.WriteCommitCheckStatusIfFailedDoesNotExist(tt.state, tt.context, "https://example.com", "description")
+			if err != nil {
+				t.Fatalf("WriteCommitCheckStatusIfFailedDoesNotExist() error = %v", err)
+			}
+			if (got != nil) != tt.wantWritten || (len(written) == 1) != tt.wantWritten {
+				t.Fatalf("WriteCommitCheckStatusIfFailedDoesNotExist() written = %v, wantWritten %v", written, tt.wantWritten)
+			}
+			if tt.wantWritten && got.GetState() != tt.state {
+				t.Errorf("WriteCommitCheckStatusIfFailedDoesNotExist() state = %v, want %v", got.GetState(), tt.state)
+			}
+		})
+	}
+}
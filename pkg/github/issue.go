@@ -0,0 +1,160 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// IssueMarker returns the hidden html comment added to the body of an issue so the issue can be found by later events
+func IssueMarker(marker string) string {
+	return fmt.Sprintf("<!-- event-reactor:issue-marker=%s -->", strings.TrimSpace(marker))
+}
+
+// AddIssueMarker appends the hidden marker to the body of an issue
+func AddIssueMarker(body string, marker string) string {
+	return fmt.Sprintf("%s\n\n%s", body, IssueMarker(marker))
+}
+
+// FindOpenIssue returns the open issue whose body contains the marker, or nil when no open issue contains the marker.
+// Pull requests are ignored
+func (c *GitHubConfiguration) FindOpenIssue(marker string) (*github.Issue, error) {
+	client, err := c.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	markerComment := IssueMarker(marker)
+	opts := &github.IssueListByRepoOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		issues, resp, err := client.Issues.ListByRepo(c.context, c.Org, c.Repo, opts)
+		_, err = c.checkHttpResponse(issues, resp, err)
+		if err != nil {
+			return nil, fmt.Errorf("an error occurred attempting to list the open issues of the '%v/%v' repository. Error: %v", c.Org, c.Repo, err)
+		}
+		for _, issue := range issues {
+			if issue.IsPullRequest() {
+				continue
+			}
+			if strings.Contains(issue.GetBody(), markerComment) {
+				return issue, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// CreateIssue creates an issue, the marker is added to the body of the issue
+func (c *GitHubConfiguration) CreateIssue(marker string, title string, body string, labels []string, assignees []string) (*github.Issue, error) {
+	client, err := c.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	body = AddIssueMarker(body, marker)
+	req := &github.IssueRequest{
+		Title: &title,
+		Body:  &body,
+	}
+	if len(labels) > 0 {
+		req.Labels = &labels
+	}
+	if len(assignees) > 0 {
+		req.Assignees = &assignees
+	}
+
+	issue, resp, err := client.Issues.Create(c.context, c.Org, c.Repo, req)
+	_, err = c.checkHttpResponse(issue, resp, err)
+	return issue, err
+}
+
+// UpdateIssue replaces the title and body of an issue, the marker is added to the body of the issue.
+// The title is not changed when it is empty
+func (c *GitHubConfiguration) UpdateIssue(number int, marker string, title string, body string) (*github.Issue, error) {
+	client, err := c.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	body = AddIssueMarker(body, marker)
+	req := &github.IssueRequest{
+		Body: &body,
+	}
+	if title != "" {
+		req.Title = &title
+	}
+
+	issue, resp, err := client.Issues.Edit(c.context, c.Org, c.Repo, number, req)
+	_, err = c.checkHttpResponse(issue, resp, err)
+	return issue, err
+}
+
+// AddIssueLabelsAndAssignees adds labels and assignees to an issue without removing the existing ones
+func (c *GitHubConfiguration) AddIssueLabelsAndAssignees(number int, labels []string, assignees []string) error {
+	client, err := c.NewClient()
+	if err != nil {
+		return err
+	}
+
+	if len(labels) > 0 {
+		newLabels, resp, err := client.Issues.AddLabelsToIssue(c.context, c.Org, c.Repo, number, labels)
+		_, err = c.checkHttpResponse(newLabels, resp, err)
+		if err != nil {
+			return fmt.Errorf("failed to add the labels %v to issue %v. Error: %v", labels, number, err)
+		}
+	}
+	if len(assignees) > 0 {
+		issue, resp, err := client.Issues.AddAssignees(c.context, c.Org, c.Repo, number, assignees)
+		_, err = c.checkHttpResponse(issue, resp, err)
+		if err != nil {
+			return fmt.Errorf("failed to add the assignees %v to issue %v. Error: %v", assignees, number, err)
+		}
+	}
+	return nil
+}
+
+// WriteIssueComment writes a comment on an issue or pull request
+func (c *GitHubConfiguration) WriteIssueComment(number int, body string) (*github.IssueComment, error) {
+	client, err := c.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &github.IssueComment{
+		Body: &body,
+	}
+	newComment, resp, err := client.Issues.CreateComment(c.context, c.Org, c.Repo, number, comment)
+	_, err = c.checkHttpResponse(newComment, resp, err)
+	return newComment, err
+}
+
+// CloseIssue closes an issue as completed. When the comment is not empty, it is written on the issue before the issue is closed
+func (c *GitHubConfiguration) CloseIssue(number int, comment string) (*github.Issue, error) {
+	if comment != "" {
+		_, err := c.WriteIssueComment(number, comment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write the closing comment on issue %v. Error: %v", number, err)
+		}
+	}
+
+	client, err := c.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	state := "closed"
+	stateReason := "completed"
+	issue, resp, err := client.Issues.Edit(c.context, c.Org, c.Repo, number, &github.IssueRequest{
+		State:       &state,
+		StateReason: &stateReason,
+	})
+	_, err = c.checkHttpResponse(issue, resp, err)
+	return issue, err
+}
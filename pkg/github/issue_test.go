@@ -0,0 +1,65 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestFindOpenIssue(t *testing.T) {
+	var serverUrl string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v3/repos/org/repo/issues" || req.Method != "GET" {
+			t.Fatalf("Unexpected path: %v, method: %s", req.URL.Path, req.Method)
+		}
+		if req.URL.Query().Get("state") != "open" {
+			t.Fatalf("Unexpected state: %v", req.URL.Query().Get("state"))
+		}
+		if req.URL.Query().Get("page") == "2" {
+			rw.Write([]byte(fmt.Sprintf(`[{"number":3,"body":"other\n\n%s"}]`, IssueMarker("deploy/app2"))))
+			return
+		}
+		rw.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/repos/org/repo/issues?page=2>; rel="next"`, serverUrl))
+		rw.Write([]byte(fmt.Sprintf(`[{"number":1,"body":"pr\n\n%[1]s","pull_request":{"url":"url"}},{"number":2,"body":"issue\n\n%[1]s"}]`, IssueMarker("deploy/app1"))))
+	}))
+	defer server.Close()
+	serverUrl = server.URL
+
+	tests := []struct {
+		name       string
+		marker     string
+		wantNumber int
+	}{
+		{
+			name:       "found on the first page, pull requests are ignored",
+			marker:     "deploy/app1",
+			wantNumber: 2,
+		},
+		{
+			name:       "found on the second page",
+			marker:     "deploy/app2",
+			wantNumber: 3,
+		},
+		{
+			name:       "not found",
+			marker:     "deploy/app3",
+			wantNumber: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(context.Background(), zaptest.NewLogger(t), "org", "repo", "", "token", 0, server.URL, true)
+			got, err := c.FindOpenIssue(tt.marker)
+			if err != nil {
+				t.Fatalf("FindOpenIssue() error = %v", err)
+			}
+			if got.GetNumber() != tt.wantNumber {
+				t.Errorf("FindOpenIssue() number = %v, want %v", got.GetNumber(), tt.wantNumber)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package http
 
 import (
 	"context"
+	"net/http"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kcloutie/event-reactor/pkg/logger"
@@ -43,3 +45,11 @@ func SetCommonLoggingAttributes(ctx context.Context, c *gin.Context) (*zap.Logge
 
 	return logger.FromCtxWithCtx(ctx, fields...)
 }
+
+// ListenerRequest contains the parts of an incoming http request that a listener needs in order to convert the request into events
+type ListenerRequest struct {
+	ContentType string
+	Headers     http.Header
+	Query       url.Values
+	Payload     []byte
+}
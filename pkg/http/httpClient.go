@@ -58,6 +58,19 @@ func NewHttpRetryClient(log *zap.Logger, maxRetries int) *retryablehttp.Client {
 	return retryClient
 }
 
+// RateLimitRetryPolicy only retries 429 Too Many Requests responses, the retry client waits for the Retry-After header.
+// It is used for requests that are not idempotent, such as posting a message, where retrying a 5xx or a network error
+// could post the message twice
+func RateLimitRetryPolicy(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if err != nil {
+		return false, err
+	}
+	return resp.StatusCode == http.StatusTooManyRequests, nil
+}
+
 // ErrorPropagatedRetryPolicy is the same as DefaultRetryPolicy, except it
 // propagates errors back instead of returning nil. This allows you to inspect
 // why it decided to retry or not.
@@ -0,0 +1,307 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/hashicorp/go-retryablehttp"
+	lcel "github.com/kcloutie/event-reactor/pkg/cel"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+var (
+	tokenSources   = map[string]oauth2.TokenSource{}
+	tokenSourcesMu sync.Mutex
+)
+
+// OAuth2Config contains the settings used to get a token using the OAuth2 client credentials flow
+type OAuth2Config struct {
+	TokenUrl     string
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
+}
+
+type RequestConfig struct {
+	Log             *zap.Logger
+	MaxRetries      int
+	Method          string
+	Url             string
+	QueryParameters map[string]string
+	Headers         map[string]string
+	Body            string
+
+	BasicAuthUser     string
+	BasicAuthPassword string
+	Token             string
+	TokenType         string
+	OAuth2            *OAuth2Config
+
+	// ExpectedStatusCodes are the status codes that are considered a success. When empty, any 2xx or 3xx status code is
+	// considered a success
+	ExpectedStatusCodes []int
+	// ResponseAssertion is a CEL expression that must evaluate to true for the request to be considered a success. The
+	// response is available as response.status, response.headers and response.body, along with the event data, attributes
+	// and id
+	ResponseAssertion string
+	// EventData is made available to the response assertion
+	EventData map[string]interface{}
+}
+
+type Response struct {
+	StatusCode int
+	Headers    map[string]string
+	RawBody    []byte
+	// Body is the decoded json body of the response, or the body as a string when the response is not json
+	Body interface{}
+}
+
+// LogFields returns the response as structured log fields
+func (r *Response) LogFields() []zap.Field {
+	return []zap.Field{
+		zap.Int("statusCode", r.StatusCode),
+		zap.Any("responseHeaders", r.Headers),
+		zap.Any("responseBody", r.Body),
+	}
+}
+
+// String returns the status, headers and body of the response so they can be included in error messages
+func (r *Response) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("STATUS: %d\n", r.StatusCode))
+	sb.WriteString("HEADERS:\n")
+	keys := make([]string, 0, len(r.Headers))
+	for k := range r.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("  %s: %s\n", k, r.Headers[k]))
+	}
+	sb.WriteString(fmt.Sprintf("BODY:\n%s\n", string(r.RawBody)))
+	return sb.String()
+}
+
+func (r *Response) asMap() map[string]interface{} {
+	return map[string]interface{}{
+		"status":  r.StatusCode,
+		"headers": r.Headers,
+		"body":    r.Body,
+	}
+}
+
+// Send makes the request and validates the response. The response is returned whenever one was received, even when the
+// status code was not expected or the assertion failed
+func (c *RequestConfig) Send(ctx context.Context) (*Response, error) {
+	method := strings.ToUpper(c.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	reqUrl, err := url.Parse(c.Url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the url '%s' - %v", c.Url, err)
+	}
+	if len(c.QueryParameters) > 0 {
+		query := reqUrl.Query()
+		for k, v := range c.QueryParameters {
+			query.Set(k, v)
+		}
+		reqUrl.RawQuery = query.Encode()
+	}
+
+	var body io.Reader
+	if c.Body != "" {
+		body = bytes.NewBufferString(c.Body)
+	}
+	req, err := retryablehttp.NewRequestWithContext(ctx, method, reqUrl.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range c.Headers {
+		req.Header.Set(key, value)
+	}
+
+	err = c.setAuthorization(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// the response of the last attempt is returned once the retries are exhausted, so a 5xx can still be an expected status
+	// code and its status, headers and body can be asserted on
+	retryClient := NewHttpRetryClient(c.Log, c.MaxRetries)
+	retryClient.CheckRetry = c.retryPolicy(method)
+	retryClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
+	httpResp, err := retryClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make the request: %v", err)
+	}
+	defer httpResp.Body.Close()
+
+	rawBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response body: %v", err)
+	}
+
+	resp := &Response{
+		StatusCode: httpResp.StatusCode,
+		Headers:    map[string]string{},
+		RawBody:    rawBody,
+		Body:       string(rawBody),
+	}
+	for k, v := range httpResp.Header {
+		resp.Headers[k] = strings.Join(v, ",")
+	}
+	var decoded interface{}
+	if len(rawBody) > 0 && json.Unmarshal(rawBody, &decoded) == nil {
+		resp.Body = decoded
+	}
+
+	if !c.isExpectedStatusCode(resp.StatusCode) {
+		if len(c.ExpectedStatusCodes) == 0 {
+			return resp, fmt.Errorf("the request to '%s' returned the status code %d which is not a 2xx or 3xx status code", reqUrl.String(), resp.StatusCode)
+		}
+		return resp, fmt.Errorf("the request to '%s' returned the status code %d which is not one of the expected status codes %v", reqUrl.String(), resp.StatusCode, c.ExpectedStatusCodes)
+	}
+
+	if c.ResponseAssertion != "" {
+		err = c.assertResponse(ctx, resp)
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	return resp, nil
+}
+
+// retryPolicy returns the retry policy of the request. A response with an expected status code is not retried. A POST or PATCH
+// is not idempotent and the server may have acted on it before failing with a 5xx or a network error, so it is only retried
+// when it was rate limited
+func (c *RequestConfig) retryPolicy(method string) retryablehttp.CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if err == nil && ctx.Err() == nil && c.isExpectedStatusCode(resp.StatusCode) {
+			return false, nil
+		}
+		if method == http.MethodPost || method == http.MethodPatch {
+			return RateLimitRetryPolicy(ctx, resp, err)
+		}
+		return requestRetryPolicy(ctx, resp, err)
+	}
+}
+
+// requestRetryPolicy retries the same responses as HttpErrorPropagatedRetryPolicy, without reading the body of the response
+func requestRetryPolicy(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if err != nil {
+		return baseRetryPolicy(nil, err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == 0 || (resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (c *RequestConfig) setAuthorization(req *retryablehttp.Request) error {
+	if c.BasicAuthUser != "" {
+		req.SetBasicAuth(c.BasicAuthUser, c.BasicAuthPassword)
+		return nil
+	}
+
+	token := c.Token
+	tokenType := c.TokenType
+	if c.OAuth2 != nil && c.OAuth2.TokenUrl != "" {
+		oauthToken, err := c.OAuth2.getTokenSource().Token()
+		if err != nil {
+			return fmt.Errorf("failed to get an oauth2 token from '%s' - %v", c.OAuth2.TokenUrl, err)
+		}
+		token = oauthToken.AccessToken
+		tokenType = oauthToken.Type()
+	}
+	if token != "" {
+		if tokenType == "" {
+			tokenType = "Bearer"
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("%s %s", tokenType, token))
+	}
+	return nil
+}
+
+// getTokenSource returns a cached token source so tokens are reused until they expire
+func (o *OAuth2Config) getTokenSource() oauth2.TokenSource {
+	scopes := append([]string{}, o.Scopes...)
+	sort.Strings(scopes)
+	key := fmt.Sprintf("%s|%s|%s|%s", o.TokenUrl, o.ClientId, o.ClientSecret, strings.Join(scopes, " "))
+
+	tokenSourcesMu.Lock()
+	defer tokenSourcesMu.Unlock()
+	ts, exists := tokenSources[key]
+	if !exists {
+		ccConfig := clientcredentials.Config{
+			ClientID:     o.ClientId,
+			ClientSecret: o.ClientSecret,
+			TokenURL:     o.TokenUrl,
+			Scopes:       o.Scopes,
+		}
+		// the token source outlives the request so it must not use the request context
+		ts = ccConfig.TokenSource(context.Background())
+		tokenSources[key] = ts
+	}
+	return ts
+}
+
+func (c *RequestConfig) isExpectedStatusCode(statusCode int) bool {
+	if len(c.ExpectedStatusCodes) == 0 {
+		return statusCode >= 200 && statusCode < 400
+	}
+	for _, code := range c.ExpectedStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *RequestConfig) assertResponse(ctx context.Context, resp *Response) error {
+	declarations := cel.Declarations(
+		decls.NewVar("response", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("data", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("attributes", decls.NewMapType(decls.String, decls.String)),
+		decls.NewVar("id", decls.String),
+	)
+	vars := map[string]interface{}{
+		"response":   resp.asMap(),
+		"data":       map[string]interface{}{},
+		"attributes": map[string]string{},
+		"id":         "",
+	}
+	for k, v := range c.EventData {
+		vars[k] = v
+	}
+
+	val, err := lcel.CelEvaluate(ctx, c.ResponseAssertion, declarations, vars)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate the response assertion - %v", err)
+	}
+	passed, ok := val.Value().(bool)
+	if !ok {
+		return fmt.Errorf("the response assertion '%s' did not return a boolean, it returned '%v'", c.ResponseAssertion, val.Value())
+	}
+	if !passed {
+		return fmt.Errorf("the response assertion '%s' failed", c.ResponseAssertion)
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRequestConfig_Send(t *testing.T) {
+	tokenRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/oauth/token":
+			tokenRequests++
+			user, pass, _ := req.BasicAuth()
+			if user != "client" || pass != "secret" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			_, _ = rw.Write([]byte(`{"access_token":"oauth-token","token_type":"Bearer","expires_in":3600}`))
+		case "/echo":
+			body, _ := io.ReadAll(req.Body)
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Header().Set("X-Test", "yes")
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+				"method":        req.Method,
+				"query":         req.URL.Query().Get("q"),
+				"header":        req.Header.Get("X-My-Header"),
+				"authorization": req.Header.Get("Authorization"),
+				"body":          string(body),
+			})
+		case "/text":
+			_, _ = rw.Write([]byte("plain text"))
+		case "/unavailable":
+			rw.Header().Set("Retry-After", "120")
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = rw.Write([]byte(`{"error":"maintenance"}`))
+		case "/notfound":
+			rw.WriteHeader(http.StatusNotFound)
+			_, _ = rw.Write([]byte(`{"error":"missing"}`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		c          *RequestConfig
+		wantStatus int
+		wantBody   interface{}
+		wantErr    string
+	}{
+		{
+			name: "put with query headers and body",
+			c: &RequestConfig{
+				Method:          "put",
+				Url:             server.URL + "/echo",
+				QueryParameters: map[string]string{"q": "search"},
+				Headers:         map[string]string{"X-My-Header": "value"},
+				Body:            `{"a":1}`,
+				Token:           "token123",
+			},
+			wantStatus: 200,
+			wantBody: map[string]interface{}{
+				"method":        "PUT",
+				"query":         "search",
+				"header":        "value",
+				"authorization": "Bearer token123",
+				"body":          `{"a":1}`,
+			},
+		},
+		{
+			name: "basic auth",
+			c: &RequestConfig{
+				Url:               server.URL + "/echo",
+				BasicAuthUser:     "user",
+				BasicAuthPassword: "pass",
+			},
+			wantStatus: 200,
+			wantBody: map[string]interface{}{
+				"method":        "GET",
+				"query":         "",
+				"header":        "",
+				"authorization": "Basic dXNlcjpwYXNz",
+				"body":          "",
+			},
+		},
+		{
+			name: "oauth2",
+			c: &RequestConfig{
+				Url: server.URL + "/echo",
+				OAuth2: &OAuth2Config{
+					TokenUrl:     server.URL + "/oauth/token",
+					ClientId:     "client",
+					ClientSecret: "secret",
+				},
+			},
+			wantStatus: 200,
+			wantBody: map[string]interface{}{
+				"method":        "GET",
+				"query":         "",
+				"header":        "",
+				"authorization": "Bearer oauth-token",
+				"body":          "",
+			},
+		},
+		{
+			name: "text body",
+			c: &RequestConfig{
+				Url: server.URL + "/text",
+			},
+			wantStatus: 200,
+			wantBody:   "plain text",
+		},
+		{
+			name: "unexpected status",
+			c: &RequestConfig{
+				Url: server.URL + "/notfound",
+			},
+			wantStatus: 404,
+			wantBody:   map[string]interface{}{"error": "missing"},
+			wantErr:    "the request to '" + server.URL + "/notfound' returned the status code 404 which is not a 2xx or 3xx status code",
+		},
+		{
+			name: "expected status",
+			c: &RequestConfig{
+				Url:                 server.URL + "/notfound",
+				ExpectedStatusCodes: []int{404},
+			},
+			wantStatus: 404,
+			wantBody:   map[string]interface{}{"error": "missing"},
+		},
+		{
+			name: "unexpected 5xx status",
+			c: &RequestConfig{
+				Url: server.URL + "/unavailable",
+			},
+			wantStatus: 503,
+			wantBody:   map[string]interface{}{"error": "maintenance"},
+			wantErr:    "the request to '" + server.URL + "/unavailable' returned the status code 503 which is not a 2xx or 3xx status code",
+		},
+		{
+			name: "expected 5xx status",
+			c: &RequestConfig{
+				Url:                 server.URL + "/unavailable",
+				ExpectedStatusCodes: []int{503},
+				ResponseAssertion:   "response.headers['Retry-After'] == '120' && response.body.error == 'maintenance'",
+			},
+			wantStatus: 503,
+			wantBody:   map[string]interface{}{"error": "maintenance"},
+		},
+		{
+			name: "status not in expected list",
+			c: &RequestConfig{
+				Url:                 server.URL + "/text",
+				ExpectedStatusCodes: []int{201, 202},
+			},
+			wantStatus: 200,
+			wantBody:   "plain text",
+			wantErr:    "the request to '" + server.URL + "/text' returned the status code 200 which is not one of the expected status codes [201 202]",
+		},
+		{
+			name: "assertion passes",
+			c: &RequestConfig{
+				Method:            "POST",
+				Url:               server.URL + "/echo",
+				ResponseAssertion: "response.status == 200 && response.body.method == data.method && response.headers['X-Test'] == 'yes'",
+				EventData:         map[string]interface{}{"data": map[string]interface{}{"method": "POST"}},
+			},
+			wantStatus: 200,
+			wantBody: map[string]interface{}{
+				"method":        "POST",
+				"query":         "",
+				"header":        "",
+				"authorization": "",
+				"body":          "",
+			},
+		},
+		{
+			name: "assertion fails",
+			c: &RequestConfig{
+				Url:               server.URL + "/text",
+				ResponseAssertion: "response.body == 'other'",
+			},
+			wantStatus: 200,
+			wantBody:   "plain text",
+			wantErr:    "the response assertion 'response.body == 'other'' failed",
+		},
+		{
+			name: "assertion not boolean",
+			c: &RequestConfig{
+				Url:               server.URL + "/text",
+				ResponseAssertion: "response.body",
+			},
+			wantStatus: 200,
+			wantBody:   "plain text",
+			wantErr:    "the response assertion 'response.body' did not return a boolean, it returned 'plain text'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.c.Log = zaptest.NewLogger(t)
+			got, err := tt.c.Send(context.Background())
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("RequestConfig.Send() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			} else if tt.wantErr != "" {
+				t.Errorf("RequestConfig.Send() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got == nil {
+				t.Fatalf("RequestConfig.Send() response = nil")
+			}
+			if got.StatusCode != tt.wantStatus {
+				t.Errorf("RequestConfig.Send() status = %v, want %v", got.StatusCode, tt.wantStatus)
+			}
+			gotBody, _ := json.Marshal(got.Body)
+			wantBody, _ := json.Marshal(tt.wantBody)
+			if string(gotBody) != string(wantBody) {
+				t.Errorf("RequestConfig.Send() body = %s, want %s", gotBody, wantBody)
+			}
+		})
+	}
+
+	// the oauth2 token is cached so a second request does not fetch a new token
+	c := &RequestConfig{
+		Log: zaptest.NewLogger(t),
+		Url: server.URL + "/echo",
+		OAuth2: &OAuth2Config{
+			TokenUrl:     server.URL + "/oauth/token",
+			ClientId:     "client",
+			ClientSecret: "secret",
+		},
+	}
+	_, err := c.Send(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("RequestConfig.Send() token requests = %v, want 1", tokenRequests)
+	}
+}
+
+func TestResponse_String(t *testing.T) {
+	r := &Response{
+		StatusCode: 500,
+		Headers:    map[string]string{"B": "2", "A": "1"},
+		RawBody:    []byte("oops"),
+	}
+	want := "STATUS: 500\nHEADERS:\n  A: 1\n  B: 2\nBODY:\noops\n"
+	if got := r.String(); got != want {
+		t.Errorf("Response.String() = %q, want %q", got, want)
+	}
+}
+
+func TestRequestConfig_Send_Retries(t *testing.T) {
+	attempts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		attempts[req.Method+" "+req.URL.Path]++
+		rw.Header().Set("Retry-After", "0")
+		switch req.URL.Path {
+		case "/unavailable":
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		case "/ratelimited":
+			rw.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name                string
+		method              string
+		path                string
+		expectedStatusCodes []int
+		wantAttempts        int
+	}{
+		{name: "unexpected 5xx of a GET is retried", method: "GET", path: "/unavailable", wantAttempts: 2},
+		{name: "expected 5xx is not retried", method: "GET", path: "/unavailable", expectedStatusCodes: []int{503}, wantAttempts: 1},
+		{name: "5xx of a POST is not retried", method: "POST", path: "/unavailable", wantAttempts: 1},
+		{name: "5xx of a PATCH is not retried", method: "PATCH", path: "/unavailable", wantAttempts: 1},
+		{name: "rate limited POST is retried", method: "POST", path: "/ratelimited", wantAttempts: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts = map[string]int{}
+			c := &RequestConfig{
+				Log:                 zaptest.NewLogger(t),
+				Method:              tt.method,
+				Url:                 server.URL + tt.path,
+				ExpectedStatusCodes: tt.expectedStatusCodes,
+				MaxRetries:          1,
+			}
+			_, _ = c.Send(context.Background())
+			if got := attempts[tt.method+" "+tt.path]; got != tt.wantAttempts {
+				t.Errorf("RequestConfig.Send() attempts = %v, want %v", got, tt.wantAttempts)
+			}
+		})
+	}
+}
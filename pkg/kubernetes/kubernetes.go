@@ -0,0 +1,329 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// ModeApply uses server-side apply, which creates the object or updates the fields owned by the field manager
+	ModeApply = "apply"
+	// ModeCreate only creates the object, an object that already exists is left unchanged
+	ModeCreate = "create"
+	// ModePatch patches an existing object with the manifest, the object must already exist
+	ModePatch = "patch"
+
+	PatchTypeMerge     = "merge"
+	PatchTypeStrategic = "strategic"
+
+	DefaultFieldManager = "event-reactor"
+	DefaultNamespace    = "default"
+	DefaultLogTailLines = 100
+)
+
+var (
+	ValidModes      = []string{ModeApply, ModeCreate, ModePatch}
+	ValidPatchTypes = []string{PatchTypeMerge, PatchTypeStrategic}
+)
+
+// Client wraps the dynamic client used to apply manifests of any kind and the typed clientset used to wait for jobs
+type Client struct {
+	Dynamic   dynamic.Interface
+	Clientset clientset.Interface
+	Mapper    meta.RESTMapper
+}
+
+// ApplyOptions control how the objects of a manifest are written to the cluster
+type ApplyOptions struct {
+	Mode string
+	// Namespace is used for namespaced objects that do not set a namespace
+	Namespace    string
+	FieldManager string
+	// Force takes ownership of fields owned by other field managers when using server-side apply
+	Force     bool
+	PatchType string
+}
+
+// ApplyResult is the object returned by the cluster after it was written
+type ApplyResult struct {
+	Object *unstructured.Unstructured
+	// Skipped is true when the object already existed and the mode is create
+	Skipped bool
+}
+
+// JobResult is the final state of a job and the logs of its pods
+type JobResult struct {
+	Job       *batchv1.Job
+	Succeeded bool
+	// PodLogs are keyed by the pod name, followed by the container name when the pod has more than one container
+	PodLogs map[string]string
+}
+
+// NewClient creates a client using the kubeconfig file when supplied, otherwise the in-cluster credentials are used.
+// The kube context is only used with a kubeconfig file
+func NewClient(kubeconfig string, kubeContext string) (*Client, error) {
+	var restConfig *rest.Config
+	var err error
+	if kubeconfig == "" {
+		restConfig, err = rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the in-cluster kubernetes credentials, supply a kubeconfig when running outside of a cluster - %v", err)
+		}
+	} else {
+		restConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+			&clientcmd.ConfigOverrides{CurrentContext: kubeContext},
+		).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the kubeconfig '%s' - %v", kubeconfig, err)
+		}
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	typedClient, err := clientset.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Dynamic:   dynamicClient,
+		Clientset: typedClient,
+		Mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(typedClient.Discovery())),
+	}, nil
+}
+
+// ParseManifest decodes the yaml or json documents of a manifest, empty documents are ignored
+func ParseManifest(manifest string) ([]*unstructured.Unstructured, error) {
+	objects := []*unstructured.Unstructured{}
+	decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+	for i := 0; ; i++ {
+		content := map[string]interface{}{}
+		err := decoder.Decode(&content)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode document %d of the manifest - %v", i, err)
+		}
+		if len(content) == 0 {
+			continue
+		}
+		obj := &unstructured.Unstructured{Object: content}
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("document %d of the manifest does not have an apiVersion and kind", i)
+		}
+		if obj.GetName() == "" && obj.GetGenerateName() == "" {
+			return nil, fmt.Errorf("the %s in document %d of the manifest does not have a name or generateName", obj.GetKind(), i)
+		}
+		objects = append(objects, obj)
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("the manifest does not contain any objects")
+	}
+	return objects, nil
+}
+
+// Apply writes the objects to the cluster in order using the mode of the options
+func (c *Client) Apply(ctx context.Context, log *zap.Logger, objects []*unstructured.Unstructured, opts ApplyOptions) ([]ApplyResult, error) {
+	results := []ApplyResult{}
+	for _, obj := range objects {
+		result, err := c.applyObject(ctx, obj, opts)
+		if err != nil {
+			return results, fmt.Errorf("failed to %s the %s '%s' - %v", opts.Mode, obj.GetKind(), objectName(obj), err)
+		}
+		if result.Skipped {
+			log.Info(fmt.Sprintf("the %s '%s' already exists and was not changed", obj.GetKind(), objectName(obj)), zap.String("namespace", obj.GetNamespace()))
+		} else {
+			log.Info(fmt.Sprintf("the %s '%s' has been written", result.Object.GetKind(), result.Object.GetName()), zap.String("namespace", result.Object.GetNamespace()), zap.String("mode", opts.Mode))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (c *Client) applyObject(ctx context.Context, obj *unstructured.Unstructured, opts ApplyOptions) (ApplyResult, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+
+	var resource dynamic.ResourceInterface = c.Dynamic.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if obj.GetNamespace() == "" {
+			namespace := opts.Namespace
+			if namespace == "" {
+				namespace = DefaultNamespace
+			}
+			obj.SetNamespace(namespace)
+		}
+		resource = c.Dynamic.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+	}
+
+	fieldManager := opts.FieldManager
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
+
+	switch opts.Mode {
+	case ModeCreate:
+		created, err := resource.Create(ctx, obj, metav1.CreateOptions{FieldManager: fieldManager})
+		if apierrors.IsAlreadyExists(err) {
+			return ApplyResult{Object: obj, Skipped: true}, nil
+		}
+		return ApplyResult{Object: created}, err
+	case ModePatch:
+		body, err := json.Marshal(obj.Object)
+		if err != nil {
+			return ApplyResult{}, err
+		}
+		patchType := types.MergePatchType
+		if opts.PatchType == PatchTypeStrategic {
+			patchType = types.StrategicMergePatchType
+		}
+		patched, err := resource.Patch(ctx, obj.GetName(), patchType, body, metav1.PatchOptions{FieldManager: fieldManager})
+		return ApplyResult{Object: patched}, err
+	case ModeApply, "":
+		if obj.GetName() == "" {
+			return ApplyResult{}, fmt.Errorf("server-side apply requires a name, use the create mode for objects with a generateName")
+		}
+		body, err := json.Marshal(obj.Object)
+		if err != nil {
+			return ApplyResult{}, err
+		}
+		force := opts.Force
+		applied, err := resource.Patch(ctx, obj.GetName(), types.ApplyPatchType, body, metav1.PatchOptions{FieldManager: fieldManager, Force: &force})
+		return ApplyResult{Object: applied}, err
+	default:
+		return ApplyResult{}, fmt.Errorf("the mode '%s' is not valid. Valid modes are %v", opts.Mode, ValidModes)
+	}
+}
+
+// WaitForJob polls the job until it has completed or failed. The logs of the pods of the job are returned when logTailLines
+// is greater than 0
+func (c *Client) WaitForJob(ctx context.Context, namespace string, name string, timeout time.Duration, interval time.Duration, logTailLines int64) (*JobResult, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		job, err := c.Clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the job '%s/%s' - %v", namespace, name, err)
+		}
+
+		finished, succeeded := jobFinished(job)
+		if finished {
+			result := &JobResult{Job: job, Succeeded: succeeded, PodLogs: map[string]string{}}
+			if logTailLines > 0 {
+				result.PodLogs, err = c.getJobPodLogs(ctx, job, logTailLines)
+				if err != nil {
+					return result, err
+				}
+			}
+			return result, nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return &JobResult{Job: job}, fmt.Errorf("the job '%s/%s' did not complete within %v", namespace, name, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return &JobResult{Job: job}, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func jobFinished(job *batchv1.Job) (bool, bool) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, true
+		case batchv1.JobFailed:
+			return true, false
+		}
+	}
+	return false, false
+}
+
+func (c *Client) getJobPodLogs(ctx context.Context, job *batchv1.Job, logTailLines int64) (map[string]string, error) {
+	if job.Spec.Selector == nil {
+		return map[string]string{}, nil
+	}
+	pods, err := c.Clientset.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(job.Spec.Selector)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the pods of the job '%s/%s' - %v", job.Namespace, job.Name, err)
+	}
+
+	logs := map[string]string{}
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			key := pod.Name
+			if len(pod.Spec.Containers) > 1 {
+				key = fmt.Sprintf("%s/%s", pod.Name, container.Name)
+			}
+			tailLines := logTailLines
+			stream, err := c.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: container.Name, TailLines: &tailLines}).Stream(ctx)
+			if err != nil {
+				logs[key] = fmt.Sprintf("failed to get the logs - %v", err)
+				continue
+			}
+			buf := bytes.Buffer{}
+			_, err = io.Copy(&buf, stream)
+			stream.Close()
+			if err != nil {
+				logs[key] = fmt.Sprintf("failed to read the logs - %v", err)
+				continue
+			}
+			logs[key] = buf.String()
+		}
+	}
+	return logs, nil
+}
+
+// FormatPodLogs returns the pod logs sorted by pod name so they can be included in an error
+func FormatPodLogs(logs map[string]string) string {
+	keys := []string{}
+	for k := range logs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sb := strings.Builder{}
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("POD %s:\n%s\n", k, strings.TrimRight(logs[k], "\n")))
+	}
+	return sb.String()
+}
+
+func objectName(obj *unstructured.Unstructured) string {
+	if obj.GetName() != "" {
+		return obj.GetName()
+	}
+	return obj.GetGenerateName()
+}
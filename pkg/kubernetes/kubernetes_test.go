@@ -0,0 +1,259 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	return mapper
+}
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+		wantErr  string
+	}{
+		{
+			name: "multiple documents",
+			manifest: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: migrate-
+`,
+			want: []string{"ConfigMap/settings", "Job/migrate-"},
+		},
+		{
+			name:     "json",
+			manifest: `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"team-a"}}`,
+			want:     []string{"Namespace/team-a"},
+		},
+		{
+			name:     "missing kind",
+			manifest: "apiVersion: v1\nmetadata:\n  name: settings\n",
+			wantErr:  "document 0 of the manifest does not have an apiVersion and kind",
+		},
+		{
+			name:     "missing name",
+			manifest: "apiVersion: v1\nkind: ConfigMap\n",
+			wantErr:  "the ConfigMap in document 0 of the manifest does not have a name or generateName",
+		},
+		{
+			name:     "empty",
+			manifest: "---\n",
+			wantErr:  "the manifest does not contain any objects",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseManifest(tt.manifest)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("ParseManifest() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("ParseManifest() error = nil, wantErr %v", tt.wantErr)
+			}
+			names := []string{}
+			for _, obj := range got {
+				names = append(names, fmt.Sprintf("%s/%s", obj.GetKind(), objectName(obj)))
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ParseManifest() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Apply(t *testing.T) {
+	existing := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "settings", "namespace": "apps"},
+		"data":       map[string]interface{}{"a": "1"},
+	}}
+	configMap := func(data map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "settings"},
+			"data":       data,
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		object      *unstructured.Unstructured
+		opts        ApplyOptions
+		wantAction  string
+		wantSkipped bool
+		wantData    map[string]interface{}
+		wantErr     string
+	}{
+		{
+			name:        "create skips an existing object",
+			object:      configMap(map[string]interface{}{"a": "2"}),
+			opts:        ApplyOptions{Mode: ModeCreate, Namespace: "apps"},
+			wantAction:  "create",
+			wantSkipped: true,
+			wantData:    map[string]interface{}{"a": "2"},
+		},
+		{
+			name:       "merge patch",
+			object:     configMap(map[string]interface{}{"b": "2"}),
+			opts:       ApplyOptions{Mode: ModePatch, Namespace: "apps"},
+			wantAction: "patch application/merge-patch+json",
+			wantData:   map[string]interface{}{"a": "1", "b": "2"},
+		},
+		{
+			name:       "server-side apply",
+			object:     configMap(map[string]interface{}{"a": "3"}),
+			opts:       ApplyOptions{Mode: ModeApply, Namespace: "apps"},
+			wantAction: "patch application/apply-patch+yaml",
+			wantData:   map[string]interface{}{"a": "3"},
+		},
+		{
+			name:    "patch a missing object",
+			object:  configMap(map[string]interface{}{"b": "2"}),
+			opts:    ApplyOptions{Mode: ModePatch, Namespace: "other"},
+			wantErr: "failed to patch the ConfigMap 'settings' - configmaps \"settings\" not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), existing.DeepCopy())
+			// the fake object tracker cannot server-side apply unstructured objects, so the applied object is returned as is
+			dynamicClient.PrependReactor("patch", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				patch := action.(k8stesting.PatchAction)
+				if patch.GetPatchType() != types.ApplyPatchType {
+					return false, nil, nil
+				}
+				obj := &unstructured.Unstructured{}
+				err := obj.UnmarshalJSON(patch.GetPatch())
+				return true, obj, err
+			})
+			c := &Client{Dynamic: dynamicClient, Mapper: newTestMapper()}
+			results, err := c.Apply(context.Background(), zaptest.NewLogger(t), []*unstructured.Unstructured{tt.object}, tt.opts)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Client.Apply() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Client.Apply() error = nil, wantErr %v", tt.wantErr)
+			}
+
+			action := dynamicClient.Actions()[0]
+			gotAction := action.GetVerb()
+			if patch, ok := action.(k8stesting.PatchAction); ok {
+				gotAction = fmt.Sprintf("%s %s", gotAction, patch.GetPatchType())
+				if patch.GetPatchType() == types.ApplyPatchType && !strings.Contains(string(patch.GetPatch()), `"namespace":"apps"`) {
+					t.Errorf("Client.Apply() patch = %s, want the namespace to be set", patch.GetPatch())
+				}
+			}
+			if gotAction != tt.wantAction {
+				t.Errorf("Client.Apply() action = %v, want %v", gotAction, tt.wantAction)
+			}
+			if results[0].Skipped != tt.wantSkipped {
+				t.Errorf("Client.Apply() skipped = %v, want %v", results[0].Skipped, tt.wantSkipped)
+			}
+			data, _, _ := unstructured.NestedMap(results[0].Object.Object, "data")
+			if fmt.Sprint(data) != fmt.Sprint(tt.wantData) {
+				t.Errorf("Client.Apply() data = %v, want %v", data, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestClient_WaitForJob(t *testing.T) {
+	newJob := func(conditions ...batchv1.JobCondition) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "apps"},
+			Spec:       batchv1.JobSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "migrate"}}},
+			Status:     batchv1.JobStatus{Conditions: conditions},
+		}
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "migrate-abcde", Namespace: "apps", Labels: map[string]string{"job-name": "migrate"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "main"}}},
+	}
+
+	tests := []struct {
+		name          string
+		job           *batchv1.Job
+		wantSucceeded bool
+		wantLogs      map[string]string
+		wantErr       string
+	}{
+		{
+			name:          "completed",
+			job:           newJob(batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}),
+			wantSucceeded: true,
+			wantLogs:      map[string]string{"migrate-abcde": "fake logs"},
+		},
+		{
+			name:          "failed",
+			job:           newJob(batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}),
+			wantSucceeded: false,
+			wantLogs:      map[string]string{"migrate-abcde": "fake logs"},
+		},
+		{
+			name:    "timed out",
+			job:     newJob(),
+			wantErr: "the job 'apps/migrate' did not complete within 30ms",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{Clientset: fake.NewSimpleClientset(tt.job, pod.DeepCopy())}
+			got, err := c.WaitForJob(context.Background(), "apps", "migrate", 30*time.Millisecond, 10*time.Millisecond, DefaultLogTailLines)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Client.WaitForJob() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Client.WaitForJob() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.Succeeded != tt.wantSucceeded {
+				t.Errorf("Client.WaitForJob() succeeded = %v, want %v", got.Succeeded, tt.wantSucceeded)
+			}
+			if fmt.Sprint(got.PodLogs) != fmt.Sprint(tt.wantLogs) {
+				t.Errorf("Client.WaitForJob() logs = %v, want %v", got.PodLogs, tt.wantLogs)
+			}
+		})
+	}
+}
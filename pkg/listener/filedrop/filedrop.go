@@ -0,0 +1,329 @@
+package filedrop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	uuid "github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ProcessedDirectoryName = "processed"
+	FailedDirectoryName    = "failed"
+
+	FileNameAttribute    = "fileName"
+	FilePathAttribute    = "filePath"
+	FileSizeAttribute    = "fileSize"
+	FileModTimeAttribute = "fileModTime"
+
+	DefaultSettleSeconds = 2
+)
+
+var DefaultIgnorePatterns = []string{".*", "*.tmp", "*.part", "*.partial"}
+
+type Listener struct {
+	Name           string
+	Directories    []config.FileDropDirectory
+	IgnorePatterns []string
+	// SettleDuration is how long the size and modification time of a file must stay the same before it is processed
+	SettleDuration time.Duration
+	// PollInterval is how often pending files are checked to see if they have settled
+	PollInterval time.Duration
+	Handler      message.EventHandler
+
+	pending map[string]*pendingFile
+}
+
+type pendingFile struct {
+	directory  config.FileDropDirectory
+	size       int64
+	modTime    time.Time
+	lastChange time.Time
+}
+
+func New(listenerConfig *config.FileDropListenerConfig, handler message.EventHandler) *Listener {
+	settleSeconds := listenerConfig.SettleSeconds
+	if settleSeconds <= 0 {
+		settleSeconds = DefaultSettleSeconds
+	}
+	ignorePatterns := listenerConfig.IgnorePatterns
+	if len(ignorePatterns) == 0 {
+		ignorePatterns = DefaultIgnorePatterns
+	}
+	settle := time.Duration(settleSeconds) * time.Second
+	return &Listener{
+		Name:           "file drop",
+		Directories:    listenerConfig.Directories,
+		IgnorePatterns: ignorePatterns,
+		SettleDuration: settle,
+		PollInterval:   settle / 4,
+		Handler:        handler,
+		pending:        map[string]*pendingFile{},
+	}
+}
+
+func (v *Listener) GetName() string {
+	return v.Name
+}
+
+// Start watches the configured directories until the context is done. Files that already exist in the directories are
+// processed as well. Each file is only processed once its size and modification time have not changed for the settle duration
+func (v *Listener) Start(ctx context.Context, log *zap.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create the file drop watcher - %v", err)
+	}
+	defer watcher.Close()
+
+	for _, dir := range v.Directories {
+		err = watcher.Add(dir.Path)
+		if err != nil {
+			return fmt.Errorf("failed to watch the file drop directory '%s' - %v", dir.Path, err)
+		}
+		entries, err := os.ReadDir(dir.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read the file drop directory '%s' - %v", dir.Path, err)
+		}
+		for _, entry := range entries {
+			v.track(log, filepath.Join(dir.Path, entry.Name()))
+		}
+		log.Info("watching file drop directory", zap.String("directory", dir.Path), zap.Strings("patterns", dir.Patterns))
+	}
+
+	ticker := time.NewTicker(v.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				v.track(log, event.Name)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error("error watching the file drop directories", zap.Error(err))
+		case <-ticker.C:
+			v.processSettled(ctx, log)
+		}
+	}
+}
+
+// track adds the file to the pending files when it is in one of the watched directories and matches its patterns
+func (v *Listener) track(log *zap.Logger, path string) {
+	if _, exists := v.pending[path]; exists {
+		v.pending[path].lastChange = time.Now()
+		return
+	}
+	dir, matches := v.match(path)
+	if !matches {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return
+	}
+	log.Debug("tracking dropped file", zap.String("file", path))
+	v.pending[path] = &pendingFile{
+		directory:  dir,
+		size:       info.Size(),
+		modTime:    info.ModTime(),
+		lastChange: time.Now(),
+	}
+}
+
+func (v *Listener) match(path string) (config.FileDropDirectory, bool) {
+	name := filepath.Base(path)
+	for _, pattern := range v.IgnorePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return config.FileDropDirectory{}, false
+		}
+	}
+	for _, dir := range v.Directories {
+		if filepath.Clean(filepath.Dir(path)) != filepath.Clean(dir.Path) {
+			continue
+		}
+		patterns := dir.Patterns
+		if len(patterns) == 0 {
+			patterns = []string{"*"}
+		}
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return dir, true
+			}
+		}
+	}
+	return config.FileDropDirectory{}, false
+}
+
+func (v *Listener) processSettled(ctx context.Context, log *zap.Logger) {
+	now := time.Now()
+	for path, file := range v.pending {
+		info, err := os.Stat(path)
+		if err != nil {
+			// the file was removed or renamed before it settled
+			delete(v.pending, path)
+			continue
+		}
+		if info.Size() != file.size || !info.ModTime().Equal(file.modTime) {
+			file.size = info.Size()
+			file.modTime = info.ModTime()
+			file.lastChange = now
+			continue
+		}
+		if now.Sub(file.lastChange) < v.SettleDuration {
+			continue
+		}
+		delete(v.pending, path)
+		v.ProcessFile(ctx, log, path, file.directory)
+	}
+}
+
+// ProcessFile converts the file into event data, passes it to the handler and moves the file to the processed or failed
+// directory depending on the result
+func (v *Listener) ProcessFile(ctx context.Context, log *zap.Logger, path string, dir config.FileDropDirectory) {
+	log = log.With(zap.String("file", path))
+	destination := getProcessedPath(dir)
+
+	eventData, err := FileToEventData(path)
+	if err == nil {
+		log = log.With(zap.String("message_id", eventData.ID))
+		err = v.Handler(ctx, log, eventData)
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to process the dropped file - %v", err))
+		destination = getFailedPath(dir)
+	}
+
+	movedTo, err := moveFile(path, destination)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to move the dropped file to '%s' - %v", destination, err))
+		return
+	}
+	log.Debug("moved dropped file", zap.String("destination", movedTo))
+}
+
+// FileToEventData reads the file and converts it into event data. Files with a json, yaml or yml extension must contain valid
+// json or yaml, all other files are decoded as json when possible otherwise only the raw bytes and text are exposed
+func FileToEventData(path string) (*message.EventData, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the details of the file '%s' - %v", path, err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the file '%s' - %v", path, err)
+	}
+
+	var eventData message.EventData
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var payload interface{}
+		err = json.Unmarshal(content, &payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the json file '%s' - %v", path, err)
+		}
+		eventData, err = message.GenericPayloadToEventData(payload)
+	case ".yaml", ".yml":
+		var payload interface{}
+		err = yaml.Unmarshal(content, &payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the yaml file '%s' - %v", path, err)
+		}
+		eventData, err = message.GenericPayloadToEventData(payload)
+	default:
+		eventData.Attributes = map[string]string{}
+		eventData.Data, err = message.PayloadToData(content, eventData.Attributes, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the file '%s' to event data - %v", path, err)
+	}
+
+	eventData.ID = uuid.NewV4().String()
+	eventData.Attributes[FileNameAttribute] = info.Name()
+	eventData.Attributes[FilePathAttribute] = path
+	eventData.Attributes[FileSizeAttribute] = strconv.FormatInt(info.Size(), 10)
+	eventData.Attributes[FileModTimeAttribute] = info.ModTime().UTC().Format(time.RFC3339)
+	return &eventData, nil
+}
+
+func getProcessedPath(dir config.FileDropDirectory) string {
+	if dir.ProcessedPath != "" {
+		return dir.ProcessedPath
+	}
+	return filepath.Join(dir.Path, ProcessedDirectoryName)
+}
+
+func getFailedPath(dir config.FileDropDirectory) string {
+	if dir.FailedPath != "" {
+		return dir.FailedPath
+	}
+	return filepath.Join(dir.Path, FailedDirectoryName)
+}
+
+// moveFile moves the file into the destination directory. A timestamp is added to the name when a file with the same name
+// already exists in the destination
+func moveFile(path string, destinationDir string) (string, error) {
+	err := os.MkdirAll(destinationDir, 0755)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(path)
+	destination := filepath.Join(destinationDir, name)
+	if _, err := os.Stat(destination); err == nil {
+		ext := filepath.Ext(name)
+		destination = filepath.Join(destinationDir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), time.Now().UnixNano(), ext))
+	}
+
+	err = os.Rename(path, destination)
+	if err == nil {
+		return destination, nil
+	}
+	// files cannot be renamed across devices, only then fall back to copying the file so other errors are not hidden
+	if !errors.Is(err, syscall.EXDEV) {
+		return "", err
+	}
+	err = copyFile(path, destination)
+	if err != nil {
+		os.Remove(destination)
+		return "", err
+	}
+	return destination, os.Remove(path)
+}
+
+func copyFile(source string, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
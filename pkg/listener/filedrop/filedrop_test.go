@@ -0,0 +1,194 @@
+package filedrop
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestFileToEventData(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		fileName string
+		content  string
+		wantData map[string]interface{}
+		wantAtt  map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "json",
+			fileName: "event.json",
+			content:  `{"test":"123","attributes":{"att1":"att1Val"}}`,
+			wantData: map[string]interface{}{"test": "123", "attributes": map[string]interface{}{"att1": "att1Val"}},
+			wantAtt:  map[string]string{"att1": "att1Val"},
+		},
+		{
+			name:     "yaml",
+			fileName: "event.yml",
+			content:  "test: \"123\"\n",
+			wantData: map[string]interface{}{"test": "123"},
+			wantAtt:  map[string]string{},
+		},
+		{
+			name:     "text",
+			fileName: "event.txt",
+			content:  "hello",
+			wantData: map[string]interface{}{"raw": []byte("hello"), "text": "hello"},
+			wantAtt:  map[string]string{},
+		},
+		{
+			name:     "invalid json",
+			fileName: "bad.json",
+			content:  "dude",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.fileName)
+			err := os.WriteFile(path, []byte(tt.content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := FileToEventData(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FileToEventData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Data, tt.wantData) {
+				t.Errorf("FileToEventData() data = %v, want %v", got.Data, tt.wantData)
+			}
+			tt.wantAtt[FileNameAttribute] = tt.fileName
+			tt.wantAtt[FilePathAttribute] = path
+			tt.wantAtt[FileSizeAttribute] = fmt.Sprintf("%d", len(tt.content))
+			delete(got.Attributes, FileModTimeAttribute)
+			if !reflect.DeepEqual(got.Attributes, tt.wantAtt) {
+				t.Errorf("FileToEventData() attributes = %v, want %v", got.Attributes, tt.wantAtt)
+			}
+			if got.ID == "" {
+				t.Errorf("FileToEventData() id is empty")
+			}
+		})
+	}
+}
+
+func TestListener_Start(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "existing.json"), []byte(`{"test":"existing"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan *message.EventData, 10)
+	handler := func(ctx context.Context, log *zap.Logger, data *message.EventData) error {
+		received <- data
+		if data.Data["test"] == "fail" {
+			return fmt.Errorf("reactor failed")
+		}
+		return nil
+	}
+	l := New(&config.FileDropListenerConfig{
+		Directories: []config.FileDropDirectory{{Path: dir, Patterns: []string{"*.json"}}},
+	}, handler)
+	l.SettleDuration = 100 * time.Millisecond
+	l.PollInterval = 20 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = l.Start(ctx, zaptest.NewLogger(t))
+	}()
+
+	waitFor := func(want string) {
+		t.Helper()
+		select {
+		case data := <-received:
+			if data.Data["test"] != want {
+				t.Errorf("Listener.Start() received %v, want %v", data.Data["test"], want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Listener.Start() timed out waiting for %s", want)
+		}
+	}
+	waitFor("existing")
+
+	// rename on complete, the partial file must be ignored
+	partial := filepath.Join(dir, "new.json.part")
+	err = os.WriteFile(partial, []byte(`{"test":"new"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Rename(partial, filepath.Join(dir, "new.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor("new")
+
+	err = os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte(`{"test":"ignored"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "failing.json"), []byte(`{"test":"fail"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor("fail")
+
+	assertExists := func(path string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, err := os.Stat(path); err == nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Errorf("Listener.Start() expected file '%s' to exist", path)
+	}
+	assertExists(filepath.Join(dir, ProcessedDirectoryName, "existing.json"))
+	assertExists(filepath.Join(dir, ProcessedDirectoryName, "new.json"))
+	assertExists(filepath.Join(dir, FailedDirectoryName, "failing.json"))
+	assertExists(filepath.Join(dir, "ignored.txt"))
+}
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "event.json")
+	if err := os.WriteFile(source, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	destinationDir := filepath.Join(dir, ProcessedDirectoryName)
+
+	got, err := moveFile(source, destinationDir)
+	if err != nil {
+		t.Fatalf("moveFile() error = %v", err)
+	}
+	if got != filepath.Join(destinationDir, "event.json") {
+		t.Errorf("moveFile() = %v, want the file in the processed directory", got)
+	}
+	if _, err := os.Stat(source); !os.IsNotExist(err) {
+		t.Errorf("moveFile() the source file still exists")
+	}
+
+	// a rename error other than a cross device error is returned without trying to copy the file
+	_, err = moveFile(source, destinationDir)
+	if !os.IsNotExist(err) {
+		t.Errorf("moveFile() error = %v, want the rename error of the missing file", err)
+	}
+	entries, _ := os.ReadDir(destinationDir)
+	if len(entries) != 1 {
+		t.Errorf("moveFile() destination files = %d, want 1", len(entries))
+	}
+}
@@ -1,13 +1,28 @@
 package generic
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
 
+	"github.com/kcloutie/event-reactor/pkg/config"
 	"github.com/kcloutie/event-reactor/pkg/http"
 	"github.com/kcloutie/event-reactor/pkg/message"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MaxMultipartMemory = 32 << 20
+	TextBodyKey        = "body"
 )
 
 type Listener struct {
@@ -52,6 +67,59 @@ func (v *Listener) ParsePayload(ctx context.Context, log *zap.Logger, payload []
 		return nil, errD
 	}
 
+	return v.toEventData(log, request)
+}
+
+// ParseRequest uses the content type of the request to determine how the payload is converted into events. JSON is used when
+// the content type is empty or unknown. NDJSON payloads create one event per line. Any headers or query parameters configured
+// on the generic listener are added to the attributes of every event
+func (v *Listener) ParseRequest(ctx context.Context, log *zap.Logger, request *http.ListenerRequest) ([]*message.EventData, *http.ErrorDetail) {
+	mediaType, params, err := mime.ParseMediaType(request.ContentType)
+	if err != nil {
+		mediaType = ""
+	}
+
+	events := []*message.EventData{}
+	var errD *http.ErrorDetail
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		events, errD = v.parseNdJson(log, request.Payload)
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		var eventData *message.EventData
+		eventData, errD = v.parseYaml(log, request.Payload)
+		events = append(events, eventData)
+	case "application/x-www-form-urlencoded":
+		var eventData *message.EventData
+		eventData, errD = v.parseForm(log, request.Payload)
+		events = append(events, eventData)
+	case "multipart/form-data":
+		var eventData *message.EventData
+		eventData, errD = v.parseMultipartForm(log, request.Payload, params["boundary"])
+		events = append(events, eventData)
+	case "text/plain":
+		events = append(events, &message.EventData{
+			Attributes: map[string]string{},
+			Data: map[string]interface{}{
+				TextBodyKey: string(request.Payload),
+			},
+		})
+	default:
+		var eventData *message.EventData
+		eventData, errD = v.ParsePayload(ctx, log, request.Payload)
+		events = append(events, eventData)
+	}
+	if errD != nil {
+		return nil, errD
+	}
+
+	listenerConfig := config.FromCtx(ctx).GetGenericListenerConfig()
+	for _, eventData := range events {
+		addRequestAttributes(eventData, listenerConfig, request)
+	}
+	return events, nil
+}
+
+func (v *Listener) toEventData(log *zap.Logger, request interface{}) (*message.EventData, *http.ErrorDetail) {
 	notifyData, err := message.GenericPayloadToEventData(request)
 	if err != nil {
 		errD := &http.ErrorDetail{
@@ -66,3 +134,123 @@ func (v *Listener) ParsePayload(ctx context.Context, log *zap.Logger, payload []
 	}
 	return &notifyData, nil
 }
+
+func (v *Listener) parseNdJson(log *zap.Logger, payload []byte) ([]*message.EventData, *http.ErrorDetail) {
+	events := []*message.EventData{}
+	reader := bufio.NewReader(bytes.NewReader(payload))
+	lineNumber := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, v.bodyError(log, "unmarshal-body-data", "Unmarshal Body Data", fmt.Sprintf("Failed to read the NDJSON body. Error: %v", err))
+		}
+		lineNumber++
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 {
+			var request interface{}
+			jsonErr := json.Unmarshal(trimmed, &request)
+			if jsonErr != nil {
+				return nil, v.bodyError(log, "unmarshal-body-data", "Unmarshal Body Data", fmt.Sprintf("Failed to unmarshal line %d of the NDJSON body. Error: %v", lineNumber, jsonErr))
+			}
+			eventData, errD := v.toEventData(log, request)
+			if errD != nil {
+				return nil, errD
+			}
+			events = append(events, eventData)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	if len(events) == 0 {
+		return nil, v.bodyError(log, "unmarshal-body-data", "Unmarshal Body Data", "the NDJSON body did not contain any events")
+	}
+	return events, nil
+}
+
+func (v *Listener) parseYaml(log *zap.Logger, payload []byte) (*message.EventData, *http.ErrorDetail) {
+	var request interface{}
+	err := yaml.Unmarshal(payload, &request)
+	if err != nil {
+		return nil, v.bodyError(log, "unmarshal-body-data", "Unmarshal Body Data", fmt.Sprintf("Failed to unmarshal the yaml body. Error: %v", err))
+	}
+	return v.toEventData(log, request)
+}
+
+func (v *Listener) parseForm(log *zap.Logger, payload []byte) (*message.EventData, *http.ErrorDetail) {
+	values, err := url.ParseQuery(string(payload))
+	if err != nil {
+		return nil, v.bodyError(log, "parse-form-body", "Parse Form Body", fmt.Sprintf("Failed to parse the form body. Error: %v", err))
+	}
+	return v.toEventData(log, formValuesToMap(values))
+}
+
+func (v *Listener) parseMultipartForm(log *zap.Logger, payload []byte, boundary string) (*message.EventData, *http.ErrorDetail) {
+	if boundary == "" {
+		return nil, v.bodyError(log, "parse-multipart-body", "Parse Multipart Body", "the multipart/form-data content type did not include a boundary")
+	}
+	form, err := multipart.NewReader(bytes.NewReader(payload), boundary).ReadForm(MaxMultipartMemory)
+	if err != nil {
+		return nil, v.bodyError(log, "parse-multipart-body", "Parse Multipart Body", fmt.Sprintf("Failed to parse the multipart body. Error: %v", err))
+	}
+	defer form.RemoveAll()
+	for name := range form.File {
+		log.Debug("skipping file part of the multipart body, only form fields are converted to event data", zap.String("field", name))
+	}
+	return v.toEventData(log, formValuesToMap(form.Value))
+}
+
+func (v *Listener) bodyError(log *zap.Logger, errType string, title string, mess string) *http.ErrorDetail {
+	log.Error(mess)
+	return &http.ErrorDetail{
+		Type:     errType,
+		Title:    title,
+		Status:   400,
+		Detail:   mess,
+		Instance: v.GetApiPath(),
+	}
+}
+
+// formValuesToMap converts form values into a map. Fields with a single value are stored as a string and fields with multiple
+// values are stored as an array
+func formValuesToMap(values map[string][]string) map[string]interface{} {
+	results := map[string]interface{}{}
+	for k, vals := range values {
+		if len(vals) == 1 {
+			results[k] = vals[0]
+			continue
+		}
+		items := []interface{}{}
+		for _, val := range vals {
+			items = append(items, val)
+		}
+		results[k] = items
+	}
+	return results
+}
+
+func addRequestAttributes(eventData *message.EventData, listenerConfig *config.GenericListenerConfig, request *http.ListenerRequest) {
+	if eventData.Attributes == nil {
+		eventData.Attributes = map[string]string{}
+	}
+	for headerName, attributeName := range listenerConfig.HeaderAttributes {
+		vals := request.Headers.Values(headerName)
+		if len(vals) == 0 {
+			continue
+		}
+		if attributeName == "" {
+			attributeName = headerName
+		}
+		eventData.Attributes[attributeName] = strings.Join(vals, ",")
+	}
+	for queryName, attributeName := range listenerConfig.QueryAttributes {
+		vals, exists := request.Query[queryName]
+		if !exists || len(vals) == 0 {
+			continue
+		}
+		if attributeName == "" {
+			attributeName = queryName
+		}
+		eventData.Attributes[attributeName] = strings.Join(vals, ",")
+	}
+}
@@ -0,0 +1,192 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	lhttp "github.com/kcloutie/event-reactor/pkg/http"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestListener_ParseRequest(t *testing.T) {
+	multipartBody := &bytes.Buffer{}
+	multipartWriter := multipart.NewWriter(multipartBody)
+	_ = multipartWriter.WriteField("command", "/deploy")
+	_ = multipartWriter.WriteField("text", "app1")
+	multipartWriter.Close()
+
+	tests := []struct {
+		name    string
+		request *lhttp.ListenerRequest
+		want    []*message.EventData
+		wantErr string
+	}{
+		{
+			name: "json without content type",
+			request: &lhttp.ListenerRequest{
+				Payload: []byte(`{"test":"123","attributes":{"att1":"att1Val"}}`),
+			},
+			want: []*message.EventData{
+				{
+					Attributes: map[string]string{"att1": "att1Val"},
+					Data:       map[string]interface{}{"test": "123", "attributes": map[string]interface{}{"att1": "att1Val"}},
+				},
+			},
+		},
+		{
+			name: "yaml",
+			request: &lhttp.ListenerRequest{
+				ContentType: "application/yaml",
+				Payload:     []byte("test: \"123\"\nchild:\n  prop: val\n"),
+			},
+			want: []*message.EventData{
+				{
+					Attributes: map[string]string{},
+					Data:       map[string]interface{}{"test": "123", "child": map[string]interface{}{"prop": "val"}},
+				},
+			},
+		},
+		{
+			name: "form urlencoded",
+			request: &lhttp.ListenerRequest{
+				ContentType: "application/x-www-form-urlencoded",
+				Payload:     []byte("command=%2Fdeploy&text=app1&tag=a&tag=b"),
+			},
+			want: []*message.EventData{
+				{
+					Attributes: map[string]string{},
+					Data:       map[string]interface{}{"command": "/deploy", "text": "app1", "tag": []interface{}{"a", "b"}},
+				},
+			},
+		},
+		{
+			name: "multipart form",
+			request: &lhttp.ListenerRequest{
+				ContentType: multipartWriter.FormDataContentType(),
+				Payload:     multipartBody.Bytes(),
+			},
+			want: []*message.EventData{
+				{
+					Attributes: map[string]string{},
+					Data:       map[string]interface{}{"command": "/deploy", "text": "app1"},
+				},
+			},
+		},
+		{
+			name: "plain text",
+			request: &lhttp.ListenerRequest{
+				ContentType: "text/plain; charset=utf-8",
+				Payload:     []byte("hello world"),
+			},
+			want: []*message.EventData{
+				{
+					Attributes: map[string]string{},
+					Data:       map[string]interface{}{"body": "hello world"},
+				},
+			},
+		},
+		{
+			name: "ndjson",
+			request: &lhttp.ListenerRequest{
+				ContentType: "application/x-ndjson",
+				Payload:     []byte("{\"test\":\"1\"}\n\n{\"test\":\"2\"}"),
+			},
+			want: []*message.EventData{
+				{
+					Attributes: map[string]string{},
+					Data:       map[string]interface{}{"test": "1"},
+				},
+				{
+					Attributes: map[string]string{},
+					Data:       map[string]interface{}{"test": "2"},
+				},
+			},
+		},
+		{
+			name: "ndjson bad line",
+			request: &lhttp.ListenerRequest{
+				ContentType: "application/x-ndjson",
+				Payload:     []byte("{\"test\":\"1\"}\ndude"),
+			},
+			wantErr: "Failed to unmarshal line 2 of the NDJSON body. Error: invalid character 'd' looking for beginning of value",
+		},
+		{
+			name: "bad yaml",
+			request: &lhttp.ListenerRequest{
+				ContentType: "application/yaml",
+				Payload:     []byte("test: [123"),
+			},
+			wantErr: "Failed to unmarshal the yaml body. Error: yaml: line 1: did not find expected ',' or ']'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testLogger := zaptest.NewLogger(t)
+
+			l := New()
+			got, errD := l.ParseRequest(context.Background(), testLogger, tt.request)
+			if errD != nil {
+				if errD.Detail != tt.wantErr {
+					t.Errorf("Listener.ParseRequest() err = %v, want %v", errD.Detail, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Errorf("Listener.ParseRequest() err = nil, want %v", tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Listener.ParseRequest() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListener_ParseRequestAttributes(t *testing.T) {
+	cfg := &config.ServerConfiguration{
+		Listeners: &config.ListenerConfigs{
+			Generic: &config.GenericListenerConfig{
+				HeaderAttributes: map[string]string{
+					"X-Slack-Request-Timestamp": "slackTimestamp",
+					"X-Source":                  "",
+				},
+				QueryAttributes: map[string]string{
+					"team":    "team",
+					"missing": "missing",
+				},
+			},
+		},
+	}
+	ctx := config.WithCtx(context.Background(), cfg)
+
+	headers := http.Header{}
+	headers.Set("X-Slack-Request-Timestamp", "1531420618")
+	headers.Set("X-Source", "slack")
+	request := &lhttp.ListenerRequest{
+		ContentType: "application/json",
+		Headers:     headers,
+		Query:       url.Values{"team": []string{"devops"}},
+		Payload:     []byte(`{"test":"123","attributes":{"att1":"att1Val"}}`),
+	}
+
+	got, errD := New().ParseRequest(ctx, zaptest.NewLogger(t), request)
+	if errD != nil {
+		t.Fatalf("Listener.ParseRequest() err = %v", errD.Detail)
+	}
+	want := map[string]string{
+		"att1":           "att1Val",
+		"slackTimestamp": "1531420618",
+		"X-Source":       "slack",
+		"team":           "devops",
+	}
+	if !reflect.DeepEqual(got[0].Attributes, want) {
+		t.Errorf("Listener.ParseRequest() attributes = %v, want %v", got[0].Attributes, want)
+	}
+}
@@ -13,4 +13,7 @@ type ListenerInterface interface {
 	GetName() string
 	GetApiPath() string
 	ParsePayload(ctx context.Context, log *zap.Logger, payload []byte) (*message.EventData, *http.ErrorDetail)
+	// ParseRequest converts the full http request into one or more events. Listeners that only understand a single json
+	// payload should delegate to ParsePayload
+	ParseRequest(ctx context.Context, log *zap.Logger, request *http.ListenerRequest) ([]*message.EventData, *http.ErrorDetail)
 }
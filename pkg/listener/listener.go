@@ -3,12 +3,14 @@ package listener
 import (
 	"github.com/kcloutie/event-reactor/pkg/listener/generic"
 	"github.com/kcloutie/event-reactor/pkg/listener/pubsub"
+	"github.com/kcloutie/event-reactor/pkg/listener/webex"
 )
 
 func GetListeners() []ListenerInterface {
 	listeners := []ListenerInterface{}
 	listeners = append(listeners, generic.New())
 	listeners = append(listeners, pubsub.New())
+	listeners = append(listeners, webex.New())
 
 	return listeners
 }
@@ -12,7 +12,7 @@ func TestGetListeners(t *testing.T) {
 	}{
 		{
 			name: "basic",
-			want: []string{"generic", "pubsub"},
+			want: []string{"generic", "pubsub", "webex"},
 		},
 	}
 	for _, tt := range tests {
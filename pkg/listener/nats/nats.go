@@ -0,0 +1,175 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	lnats "github.com/kcloutie/event-reactor/pkg/nats"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	uuid "github.com/satori/go.uuid"
+	"go.uber.org/zap"
+)
+
+const DefaultAckWaitSeconds = 30
+
+type Listener struct {
+	Name          string
+	Connection    lnats.ConnectOptions
+	Subscriptions []config.NatsSubscription
+	Handler       message.EventHandler
+}
+
+func New(listenerConfig *config.NatsListenerConfig, handler message.EventHandler) *Listener {
+	return &Listener{
+		Name:          "nats",
+		Connection:    listenerConfig.ConnectOptions,
+		Subscriptions: listenerConfig.Subscriptions,
+		Handler:       handler,
+	}
+}
+
+func (v *Listener) GetName() string {
+	return v.Name
+}
+
+// Start connects to the NATS server and subscribes to each of the subscriptions until the context is done. Core NATS
+// subscriptions are processed at most once, JetStream subscriptions are acknowledged when the handler succeeds and
+// negatively acknowledged when it fails so the message is redelivered
+func (v *Listener) Start(ctx context.Context, log *zap.Logger) error {
+	nc, err := lnats.Connect("event-reactor", v.Connection)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	var js jetstream.JetStream
+	for _, sub := range v.Subscriptions {
+		log := log.With(zap.String("subject", sub.Subject))
+		if sub.Stream == "" {
+			_, err := nc.QueueSubscribe(sub.Subject, sub.Queue, v.coreHandler(ctx, log, sub))
+			if err != nil {
+				return fmt.Errorf("failed to subscribe to the NATS subject '%s' - %w", sub.Subject, err)
+			}
+			log.Info("subscribed to NATS subject", zap.String("queue", sub.Queue))
+			continue
+		}
+
+		if js == nil {
+			js, err = jetstream.New(nc)
+			if err != nil {
+				return fmt.Errorf("failed to create the JetStream context - %w", err)
+			}
+		}
+		consumer, err := js.CreateOrUpdateConsumer(ctx, sub.Stream, getConsumerConfig(sub))
+		if err != nil {
+			return fmt.Errorf("failed to create the durable consumer '%s' on the JetStream stream '%s' - %w", sub.Durable, sub.Stream, err)
+		}
+		cc, err := consumer.Consume(v.jetStreamHandler(ctx, log, sub))
+		if err != nil {
+			return fmt.Errorf("failed to consume from the JetStream stream '%s' - %w", sub.Stream, err)
+		}
+		defer cc.Stop()
+		log.Info("consuming from JetStream stream", zap.String("stream", sub.Stream), zap.String("durable", sub.Durable))
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func getConsumerConfig(sub config.NatsSubscription) jetstream.ConsumerConfig {
+	ackWait := sub.AckWaitSeconds
+	if ackWait <= 0 {
+		ackWait = DefaultAckWaitSeconds
+	}
+	maxDeliver := sub.MaxDeliver
+	if maxDeliver <= 0 {
+		maxDeliver = -1
+	}
+	return jetstream.ConsumerConfig{
+		Durable:       sub.Durable,
+		FilterSubject: sub.Subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       time.Duration(ackWait) * time.Second,
+		MaxDeliver:    maxDeliver,
+	}
+}
+
+func (v *Listener) coreHandler(ctx context.Context, log *zap.Logger, sub config.NatsSubscription) natsgo.MsgHandler {
+	return func(msg *natsgo.Msg) {
+		eventData, err := MessageToEventData(msg.Subject, msg.Header, msg.Data, sub.Decoder)
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+		err = v.Handler(ctx, log.With(zap.String("message_id", eventData.ID)), eventData)
+		if err != nil {
+			log.Error(fmt.Sprintf("failed to process the NATS message - %v", err))
+		}
+	}
+}
+
+func (v *Listener) jetStreamHandler(ctx context.Context, log *zap.Logger, sub config.NatsSubscription) jetstream.MessageHandler {
+	return func(msg jetstream.Msg) {
+		eventData, err := MessageToEventData(msg.Subject(), msg.Headers(), msg.Data(), sub.Decoder)
+		if err != nil {
+			// the message can never be decoded so there is no point redelivering it
+			log.Error(err.Error())
+			termErr := msg.Term()
+			if termErr != nil {
+				log.Error(fmt.Sprintf("failed to terminate the JetStream message - %v", termErr))
+			}
+			return
+		}
+		meta, err := msg.Metadata()
+		if err == nil {
+			if msg.Headers().Get(natsgo.MsgIdHdr) == "" {
+				eventData.ID = fmt.Sprintf("%s-%d", meta.Stream, meta.Sequence.Stream)
+			}
+			eventData.Attributes[lnats.StreamAttribute] = meta.Stream
+			eventData.Attributes[lnats.SequenceAttribute] = strconv.FormatUint(meta.Sequence.Stream, 10)
+			eventData.Attributes[lnats.DeliveryCountAttribute] = strconv.FormatUint(meta.NumDelivered, 10)
+		}
+		log := log.With(zap.String("message_id", eventData.ID))
+
+		err = v.Handler(ctx, log, eventData)
+		if err != nil {
+			log.Error(fmt.Sprintf("failed to process the JetStream message, it will be redelivered - %v", err))
+			nakErr := msg.Nak()
+			if nakErr != nil {
+				log.Error(fmt.Sprintf("failed to negatively acknowledge the JetStream message - %v", nakErr))
+			}
+			return
+		}
+		err = msg.Ack()
+		if err != nil {
+			log.Error(fmt.Sprintf("failed to acknowledge the JetStream message - %v", err))
+		}
+	}
+}
+
+// MessageToEventData converts a NATS message into event data. The headers of the message become the attributes and the
+// Nats-Msg-Id header is used as the id when present
+func MessageToEventData(subject string, header natsgo.Header, data []byte, decoder *message.PayloadDecoder) (*message.EventData, error) {
+	attributes := lnats.HeadersToAttributes(header)
+	attributes[lnats.SubjectAttribute] = subject
+
+	eventData, err := message.PayloadToData(data, attributes, decoder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the NATS message on subject '%s' - %v", subject, err)
+	}
+
+	id := header.Get(natsgo.MsgIdHdr)
+	if id == "" {
+		id = uuid.NewV4().String()
+	}
+	return &message.EventData{
+		ID:         id,
+		Attributes: attributes,
+		Data:       eventData,
+	}, nil
+}
@@ -0,0 +1,152 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	lnats "github.com/kcloutie/event-reactor/pkg/nats"
+	"github.com/kcloutie/event-reactor/pkg/nats/natstest"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestMessageToEventData(t *testing.T) {
+	header := natsgo.Header{}
+	header.Set(natsgo.MsgIdHdr, "msg-1")
+	header.Add("tag", "a")
+	header.Add("tag", "b")
+
+	got, err := MessageToEventData("events.test", header, []byte(`{"test":"123"}`), nil)
+	if err != nil {
+		t.Fatalf("MessageToEventData() error = %v", err)
+	}
+	want := &message.EventData{
+		ID: "msg-1",
+		Attributes: map[string]string{
+			natsgo.MsgIdHdr:        "msg-1",
+			"tag":                  "a,b",
+			lnats.SubjectAttribute: "events.test",
+		},
+		Data: map[string]interface{}{
+			"test": "123",
+			"raw":  []byte(`{"test":"123"}`),
+			"text": `{"test":"123"}`,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MessageToEventData() got = %v, want %v", got, want)
+	}
+
+	_, err = MessageToEventData("events.test", natsgo.Header{}, []byte(`dude`), &message.PayloadDecoder{Type: message.DecoderJson})
+	if err == nil {
+		t.Errorf("MessageToEventData() expected an error for invalid json")
+	}
+}
+
+func TestListener_Start(t *testing.T) {
+	ns := natstest.RunServer(t)
+	nc, err := natsgo.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.CreateStream(context.Background(), jetstream.StreamConfig{Name: "EVENTS", Subjects: []string{"events.>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan *message.EventData, 10)
+	var jetStreamAttempts int32
+	handler := func(ctx context.Context, log *zap.Logger, data *message.EventData) error {
+		received <- data
+		if data.Attributes[lnats.SubjectAttribute] == "events.test" && atomic.AddInt32(&jetStreamAttempts, 1) == 1 {
+			return fmt.Errorf("reactor failed")
+		}
+		return nil
+	}
+
+	l := New(&config.NatsListenerConfig{
+		ConnectOptions: lnats.ConnectOptions{Url: ns.ClientURL()},
+		Subscriptions: []config.NatsSubscription{
+			{Subject: "core.>", Queue: "workers"},
+			{Subject: "events.>", Stream: "EVENTS", Durable: "event-reactor"},
+		},
+	}, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	started := make(chan error, 1)
+	go func() {
+		started <- l.Start(ctx, zaptest.NewLogger(t))
+	}()
+
+	waitFor := func(subject string) *message.EventData {
+		t.Helper()
+		select {
+		case data := <-received:
+			if data.Attributes[lnats.SubjectAttribute] != subject {
+				t.Errorf("Listener.Start() received subject %v, want %v", data.Attributes[lnats.SubjectAttribute], subject)
+			}
+			return data
+		case err := <-started:
+			t.Fatalf("Listener.Start() returned early - %v", err)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Listener.Start() timed out waiting for %s", subject)
+		}
+		return nil
+	}
+
+	// the subscriptions are created asynchronously so keep publishing until the first message arrives
+	deadline := time.Now().Add(10 * time.Second)
+	for len(received) == 0 && time.Now().Before(deadline) {
+		_ = nc.Publish("core.test", []byte(`{"test":"core"}`))
+		_ = nc.Flush()
+		time.Sleep(50 * time.Millisecond)
+	}
+	data := waitFor("core.test")
+	if data.Data["test"] != "core" {
+		t.Errorf("Listener.Start() data = %v, want core", data.Data["test"])
+	}
+	for len(received) > 0 {
+		<-received
+	}
+
+	_, err = js.Publish(context.Background(), "events.test", []byte(`{"test":"jetstream"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := waitFor("events.test")
+	second := waitFor("events.test")
+	if first.Attributes[lnats.DeliveryCountAttribute] != "1" || second.Attributes[lnats.DeliveryCountAttribute] != "2" {
+		t.Errorf("Listener.Start() expected the message to be redelivered after the nak. attempts = %s, %s", first.Attributes[lnats.DeliveryCountAttribute], second.Attributes[lnats.DeliveryCountAttribute])
+	}
+	if second.ID != "EVENTS-1" {
+		t.Errorf("Listener.Start() id = %v, want EVENTS-1", second.ID)
+	}
+
+	consumer, err := js.Consumer(context.Background(), "EVENTS", "event-reactor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		info, err := consumer.Info(context.Background())
+		if err == nil && info.NumAckPending == 0 && info.NumPending == 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Errorf("Listener.Start() expected the JetStream message to be acknowledged")
+}
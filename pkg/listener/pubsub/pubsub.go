@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/kcloutie/event-reactor/pkg/config"
 	"github.com/kcloutie/event-reactor/pkg/http"
 	"github.com/kcloutie/event-reactor/pkg/message"
 	"go.uber.org/zap"
@@ -53,7 +54,9 @@ func (v *Listener) ParsePayload(ctx context.Context, log *zap.Logger, payload []
 		return nil, errD
 	}
 
-	notifyData, err := message.PubSubMessageToEventData(request)
+	subscription, _ := request["subscription"].(string)
+	decoder := config.FromCtx(ctx).GetPubSubListenerConfig().GetDecoder(subscription)
+	notifyData, err := message.PubSubMessageToEventDataWithDecoder(request, decoder)
 	if err != nil {
 		errD := &http.ErrorDetail{
 			Type:     "convert-pubsub-message",
@@ -67,3 +70,11 @@ func (v *Listener) ParsePayload(ctx context.Context, log *zap.Logger, payload []
 	}
 	return &notifyData, nil
 }
+
+func (v *Listener) ParseRequest(ctx context.Context, log *zap.Logger, request *http.ListenerRequest) ([]*message.EventData, *http.ErrorDetail) {
+	eventData, errD := v.ParsePayload(ctx, log, request.Payload)
+	if errD != nil {
+		return nil, errD
+	}
+	return []*message.EventData{eventData}, nil
+}
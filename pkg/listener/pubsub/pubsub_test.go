@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/kcloutie/event-reactor/pkg/config"
 	"github.com/kcloutie/event-reactor/pkg/message"
 	"go.uber.org/zap/zaptest"
 )
@@ -79,3 +80,29 @@ func TestListener_ParsePayload(t *testing.T) {
 		})
 	}
 }
+
+func TestListener_ParsePayloadSubscriptionDecoder(t *testing.T) {
+	cfg := &config.ServerConfiguration{
+		Listeners: &config.ListenerConfigs{
+			PubSub: &config.PubSubListenerConfig{
+				SubscriptionDecoders: map[string]*message.PayloadDecoder{
+					"text-sub": {Type: message.DecoderText},
+				},
+			},
+		},
+	}
+	ctx := config.WithCtx(context.Background(), cfg)
+	payload := []byte(`{"subscription":"projects/proj/subscriptions/text-sub","message":{"data":"eyJ0ZXN0IjoiMTIzIn0=","messageId":"1"}}`)
+
+	got, errD := New().ParsePayload(ctx, zaptest.NewLogger(t), payload)
+	if errD != nil {
+		t.Fatalf("Listener.ParsePayload() err = %v", errD.Detail)
+	}
+	want := map[string]interface{}{
+		"raw":  []byte(`{"test":"123"}`),
+		"text": `{"test":"123"}`,
+	}
+	if !reflect.DeepEqual(got.Data, want) {
+		t.Errorf("Listener.ParsePayload() data = %v, want %v", got.Data, want)
+	}
+}
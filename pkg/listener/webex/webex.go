@@ -0,0 +1,140 @@
+package webex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/http"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/webex"
+	"go.uber.org/zap"
+)
+
+type Listener struct {
+	Name    string
+	ApiPath string
+}
+
+func New() *Listener {
+	return &Listener{
+		Name:    "webex",
+		ApiPath: "webex",
+	}
+}
+
+func (v *Listener) Initialize(ctx context.Context) error {
+	return nil
+}
+
+func (v *Listener) GetName() string {
+	return v.Name
+}
+
+func (v *Listener) GetApiPath() string {
+	return v.ApiPath
+}
+
+// ParsePayload converts an attachmentActions webhook notification into an event. The submitted inputs and the person who
+// submitted them are fetched using the webex api as the notification only contains their ids. The signature of the
+// notification is verified by ParseRequest
+func (v *Listener) ParsePayload(ctx context.Context, log *zap.Logger, payload []byte) (*message.EventData, *http.ErrorDetail) {
+	event := webex.WebhookEvent{}
+	err := json.Unmarshal(payload, &event)
+	if err != nil {
+		return nil, v.error(log, "unmarshal-body-data", "Unmarshal Body Data", 400, fmt.Sprintf("Failed to unmarshal body to the webhook event type. Error: %v", err))
+	}
+	if event.Resource != webex.ResourceAttachmentActions || event.Event != webex.EventCreated {
+		return nil, v.error(log, "webex-unsupported-event", "Webex Unsupported Event", 400, fmt.Sprintf("the webex webhook resource '%s' and event '%s' are not supported. Only the %s resource and %s event are supported", event.Resource, event.Event, webex.ResourceAttachmentActions, webex.EventCreated))
+	}
+	if event.Data.ID == "" {
+		return nil, v.error(log, "webex-unsupported-event", "Webex Unsupported Event", 400, "the id of the attachment action was not found in the webex webhook event")
+	}
+
+	listenerConfig := config.FromCtx(ctx).GetWebexListenerConfig()
+	if listenerConfig.Token == "" {
+		return nil, v.error(log, "webex-listener-config", "Webex Listener Config", 500, "the token of the webex listener was not configured, the attachment action cannot be fetched")
+	}
+	client := webex.ApiClient{
+		Log:        log,
+		ApiUrl:     listenerConfig.GetApiUrl(),
+		ApiToken:   listenerConfig.Token,
+		MaxRetries: listenerConfig.GetMaxRetries(),
+	}
+	action, err := client.GetAttachmentAction(ctx, event.Data.ID)
+	if err != nil {
+		return nil, v.error(log, "webex-get-attachment-action", "Webex Get Attachment Action", 502, err.Error())
+	}
+	person, err := client.GetPerson(ctx, action.PersonID)
+	if err != nil {
+		return nil, v.error(log, "webex-get-person", "Webex Get Person", 502, err.Error())
+	}
+	email := ""
+	if len(person.Emails) > 0 {
+		email = person.Emails[0]
+	}
+	inputs := action.Inputs
+	if inputs == nil {
+		inputs = map[string]interface{}{}
+	}
+
+	return &message.EventData{
+		ID: action.ID,
+		Attributes: map[string]string{
+			"resource":    event.Resource,
+			"event":       event.Event,
+			"webhookName": event.Name,
+			"messageId":   action.MessageID,
+			"roomId":      action.RoomID,
+			"personId":    action.PersonID,
+			"personEmail": email,
+		},
+		Data: map[string]interface{}{
+			"id":        action.ID,
+			"type":      action.Type,
+			"messageId": action.MessageID,
+			"roomId":    action.RoomID,
+			"created":   action.Created,
+			"inputs":    inputs,
+			"person": map[string]interface{}{
+				"id":          person.ID,
+				"email":       email,
+				"displayName": person.DisplayName,
+			},
+		},
+	}, nil
+}
+
+// ParseRequest verifies the X-Spark-Signature header using the secret of the webex listener before converting the
+// notification into an event
+func (v *Listener) ParseRequest(ctx context.Context, log *zap.Logger, request *http.ListenerRequest) ([]*message.EventData, *http.ErrorDetail) {
+	listenerConfig := config.FromCtx(ctx).GetWebexListenerConfig()
+	if listenerConfig.Secret == "" {
+		return nil, v.error(log, "webex-listener-config", "Webex Listener Config", 500, "the secret of the webex listener was not configured, the signature of the webhook cannot be verified")
+	}
+	signature := request.Headers.Get(webex.SignatureHeader)
+	if signature == "" {
+		return nil, v.error(log, "webex-verify-signature", "Webex Verify Signature", 401, fmt.Sprintf("the %s header was not found", webex.SignatureHeader))
+	}
+	if !webex.VerifySignature(listenerConfig.Secret, request.Payload, signature) {
+		return nil, v.error(log, "webex-verify-signature", "Webex Verify Signature", 401, fmt.Sprintf("the %s header does not match the signature of the body", webex.SignatureHeader))
+	}
+
+	eventData, errD := v.ParsePayload(ctx, log, request.Payload)
+	if errD != nil {
+		return nil, errD
+	}
+	return []*message.EventData{eventData}, nil
+}
+
+func (v *Listener) error(log *zap.Logger, errType string, title string, status int64, mess string) *http.ErrorDetail {
+	log.Error(mess)
+	return &http.ErrorDetail{
+		Type:     errType,
+		Title:    title,
+		Status:   status,
+		Detail:   mess,
+		Instance: v.GetApiPath(),
+	}
+}
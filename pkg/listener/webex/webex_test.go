@@ -0,0 +1,156 @@
+package webex
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505 -- webex signs webhooks using HMAC-SHA1
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	httper "github.com/kcloutie/event-reactor/pkg/http"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap/zaptest"
+)
+
+func sign(secret string, body []byte) string {
+	h := hmac.New(sha1.New, []byte(secret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestListener_ParseRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.URL.Path {
+		case "/v1/attachment/actions/action1":
+			_, _ = rw.Write([]byte(`{"id":"action1","type":"submit","messageId":"msg1","personId":"person1","roomId":"room1","inputs":{"decision":"approve","comment":"lgtm"},"created":"2024-01-01T00:00:00.000Z"}`))
+		case "/v1/people/person1":
+			_, _ = rw.Write([]byte(`{"id":"person1","emails":["approver@example.com"],"displayName":"Approver"}`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	body := []byte(`{"id":"hook1","name":"approvals","resource":"attachmentActions","event":"created","data":{"id":"action1","type":"submit","messageId":"msg1","personId":"person1","roomId":"room1"}}`)
+	messagesBody := []byte(`{"id":"hook1","name":"approvals","resource":"messages","event":"created","data":{"id":"msg1"}}`)
+
+	tests := []struct {
+		name       string
+		secret     string
+		body       []byte
+		signature  string
+		want       []*message.EventData
+		wantStatus int64
+		wantErr    string
+	}{
+		{
+			name:      "attachment action",
+			secret:    "secret",
+			body:      body,
+			signature: sign("secret", body),
+			want: []*message.EventData{
+				{
+					ID: "action1",
+					Attributes: map[string]string{
+						"resource":    "attachmentActions",
+						"event":       "created",
+						"webhookName": "approvals",
+						"messageId":   "msg1",
+						"roomId":      "room1",
+						"personId":    "person1",
+						"personEmail": "approver@example.com",
+					},
+					Data: map[string]interface{}{
+						"id":        "action1",
+						"type":      "submit",
+						"messageId": "msg1",
+						"roomId":    "room1",
+						"created":   "2024-01-01T00:00:00.000Z",
+						"inputs":    map[string]interface{}{"decision": "approve", "comment": "lgtm"},
+						"person": map[string]interface{}{
+							"id":          "person1",
+							"email":       "approver@example.com",
+							"displayName": "Approver",
+						},
+					},
+				},
+			},
+		},
+		{
+			name:       "missing signature",
+			secret:     "secret",
+			body:       body,
+			wantStatus: 401,
+			wantErr:    "the X-Spark-Signature header was not found",
+		},
+		{
+			name:       "invalid signature",
+			secret:     "secret",
+			body:       body,
+			signature:  sign("dude", body),
+			wantStatus: 401,
+			wantErr:    "the X-Spark-Signature header does not match the signature of the body",
+		},
+		{
+			name:       "secret not configured",
+			body:       body,
+			signature:  sign("", body),
+			wantStatus: 500,
+			wantErr:    "the secret of the webex listener was not configured, the signature of the webhook cannot be verified",
+		},
+		{
+			name:       "unsupported resource",
+			secret:     "secret",
+			body:       messagesBody,
+			signature:  sign("secret", messagesBody),
+			wantStatus: 400,
+			wantErr:    "the webex webhook resource 'messages' and event 'created' are not supported. Only the attachmentActions resource and created event are supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxRetries := 0
+			cfg := &config.ServerConfiguration{
+				Listeners: &config.ListenerConfigs{
+					Webex: &config.WebexListenerConfig{
+						Secret:     tt.secret,
+						Token:      "token",
+						ApiUrl:     server.URL + "/v1",
+						MaxRetries: &maxRetries,
+					},
+				},
+			}
+			ctx := config.WithCtx(context.Background(), cfg)
+			request := &httper.ListenerRequest{
+				ContentType: "application/json",
+				Headers:     http.Header{},
+				Payload:     tt.body,
+			}
+			if tt.signature != "" {
+				request.Headers.Set("X-Spark-Signature", tt.signature)
+			}
+
+			got, errD := New().ParseRequest(ctx, zaptest.NewLogger(t), request)
+			if errD != nil {
+				if errD.Detail != tt.wantErr || errD.Status != tt.wantStatus {
+					t.Errorf("Listener.ParseRequest() err = %v (%d), want %v (%d)", errD.Detail, errD.Status, tt.wantErr, tt.wantStatus)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Listener.ParseRequest() err = nil, want %v", tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Listener.ParseRequest() = %+v, want %+v", got[0], tt.want[0])
+			}
+		})
+	}
+}
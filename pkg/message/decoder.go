@@ -0,0 +1,250 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	// DecoderAuto decodes the payload as json when possible, otherwise only the raw bytes and text are exposed
+	DecoderAuto     = "auto"
+	DecoderJson     = "json"
+	DecoderText     = "text"
+	DecoderProtobuf = "protobuf"
+	DecoderAvro     = "avro"
+
+	RawDataKey   = "raw"
+	TextDataKey  = "text"
+	ItemsDataKey = "items"
+	ValueDataKey = "value"
+
+	// SchemaEncodingAttribute is the attribute pub/sub adds to messages published to a topic with a schema
+	SchemaEncodingAttribute = "googclient_schemaencoding"
+)
+
+var (
+	protoDescriptorCache = sync.Map{}
+	avroCodecCache       = sync.Map{}
+)
+
+// PayloadDecoder describes how the raw bytes of a message are converted into the event data
+type PayloadDecoder struct {
+	// Type is one of auto, json, text, protobuf or avro. Defaults to auto
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	// DescriptorSetFile is the path to a file containing a serialized google.protobuf.FileDescriptorSet. Required for the protobuf decoder
+	DescriptorSetFile string `json:"descriptorSetFile,omitempty" yaml:"descriptorSetFile,omitempty"`
+	// MessageType is the full name of the protobuf message i.e. my.package.MyMessage. Required for the protobuf decoder
+	MessageType string `json:"messageType,omitempty" yaml:"messageType,omitempty"`
+	// SchemaFile is the path to the avro schema. Required for the avro decoder
+	SchemaFile string `json:"schemaFile,omitempty" yaml:"schemaFile,omitempty"`
+	// Encoding is either binary or json. When empty, the googclient_schemaencoding attribute is used and binary is the default
+	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+}
+
+func (d *PayloadDecoder) GetType() string {
+	if d == nil || d.Type == "" {
+		return DecoderAuto
+	}
+	return strings.ToLower(d.Type)
+}
+
+// Decode converts the raw bytes into a value using the decoder type. A nil value is returned when the payload should only be
+// exposed as raw bytes and text
+func (d *PayloadDecoder) Decode(raw []byte, attributes map[string]string) (interface{}, error) {
+	switch d.GetType() {
+	case DecoderAuto:
+		var decoded interface{}
+		err := json.Unmarshal(raw, &decoded)
+		if err != nil {
+			return nil, nil
+		}
+		return decoded, nil
+	case DecoderJson:
+		var decoded interface{}
+		err := json.Unmarshal(raw, &decoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the payload as json - %v", err)
+		}
+		return decoded, nil
+	case DecoderText:
+		return nil, nil
+	case DecoderProtobuf:
+		return d.decodeProtobuf(raw, attributes)
+	case DecoderAvro:
+		return d.decodeAvro(raw, attributes)
+	default:
+		return nil, fmt.Errorf("unknown payload decoder type '%s'. Valid types are %s, %s, %s, %s and %s", d.Type, DecoderAuto, DecoderJson, DecoderText, DecoderProtobuf, DecoderAvro)
+	}
+}
+
+func (d *PayloadDecoder) isJsonEncoded(attributes map[string]string) bool {
+	encoding := d.Encoding
+	if encoding == "" {
+		encoding = attributes[SchemaEncodingAttribute]
+	}
+	return strings.EqualFold(encoding, "json")
+}
+
+func (d *PayloadDecoder) decodeProtobuf(raw []byte, attributes map[string]string) (interface{}, error) {
+	desc, err := loadProtoMessageDescriptor(d.DescriptorSetFile, d.MessageType)
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamicpb.NewMessage(desc)
+	if d.isJsonEncoded(attributes) {
+		err = protojson.Unmarshal(raw, msg)
+	} else {
+		err = proto.Unmarshal(raw, msg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the payload as the protobuf message '%s' - %v", d.MessageType, err)
+	}
+	jsonBytes, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the protobuf message '%s' to json - %v", d.MessageType, err)
+	}
+	var decoded interface{}
+	err = json.Unmarshal(jsonBytes, &decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the json of the protobuf message '%s' - %v", d.MessageType, err)
+	}
+	return decoded, nil
+}
+
+func (d *PayloadDecoder) decodeAvro(raw []byte, attributes map[string]string) (interface{}, error) {
+	codec, err := loadAvroCodec(d.SchemaFile)
+	if err != nil {
+		return nil, err
+	}
+	var native interface{}
+	if d.isJsonEncoded(attributes) {
+		native, _, err = codec.NativeFromTextual(raw)
+	} else {
+		native, _, err = codec.NativeFromBinary(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the payload using the avro schema '%s' - %v", d.SchemaFile, err)
+	}
+	jsonBytes, err := codec.TextualFromNative(nil, native)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the avro record to json - %v", err)
+	}
+	var decoded interface{}
+	err = json.Unmarshal(jsonBytes, &decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the json of the avro record - %v", err)
+	}
+	return decoded, nil
+}
+
+func loadProtoMessageDescriptor(descriptorSetFile string, messageType string) (protoreflect.MessageDescriptor, error) {
+	if descriptorSetFile == "" || messageType == "" {
+		return nil, fmt.Errorf("the descriptorSetFile and messageType must be supplied when using the %s decoder", DecoderProtobuf)
+	}
+	key := descriptorSetFile + "#" + messageType
+	if cached, ok := protoDescriptorCache.Load(key); ok {
+		return cached.(protoreflect.MessageDescriptor), nil
+	}
+
+	content, err := os.ReadFile(descriptorSetFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the protobuf descriptor set file '%s' - %v", descriptorSetFile, err)
+	}
+	fileSet := &descriptorpb.FileDescriptorSet{}
+	err = proto.Unmarshal(content, fileSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the protobuf descriptor set file '%s' - %v", descriptorSetFile, err)
+	}
+	files, err := protodesc.NewFiles(fileSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the protobuf descriptor set file '%s' - %v", descriptorSetFile, err)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(messageType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the protobuf message '%s' in the descriptor set file '%s' - %v", messageType, descriptorSetFile, err)
+	}
+	msgDesc, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("'%s' in the descriptor set file '%s' is not a message", messageType, descriptorSetFile)
+	}
+	protoDescriptorCache.Store(key, msgDesc)
+	return msgDesc, nil
+}
+
+func loadAvroCodec(schemaFile string) (*goavro.Codec, error) {
+	if schemaFile == "" {
+		return nil, fmt.Errorf("the schemaFile must be supplied when using the %s decoder", DecoderAvro)
+	}
+	if cached, ok := avroCodecCache.Load(schemaFile); ok {
+		return cached.(*goavro.Codec), nil
+	}
+	content, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the avro schema file '%s' - %v", schemaFile, err)
+	}
+	codec, err := goavro.NewCodec(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the avro schema file '%s' - %v", schemaFile, err)
+	}
+	avroCodecCache.Store(schemaFile, codec)
+	return codec, nil
+}
+
+// PayloadToData decodes the raw payload using the decoder and converts the result into event data. Json objects become the
+// event data, arrays are stored in the items key and any other value in the value key. The raw bytes and decoded text of the
+// payload are always exposed in the raw and text keys unless the decoded payload already contains those keys
+func PayloadToData(raw []byte, attributes map[string]string, decoder *PayloadDecoder) (map[string]interface{}, error) {
+	decoded, err := decoder.Decode(raw, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{}
+	switch d := decoded.(type) {
+	case map[string]interface{}:
+		data = d
+	case []interface{}:
+		data[ItemsDataKey] = d
+	case nil:
+	default:
+		data[ValueDataKey] = d
+	}
+
+	if _, exists := data[RawDataKey]; !exists {
+		data[RawDataKey] = raw
+	}
+	if _, exists := data[TextDataKey]; !exists {
+		data[TextDataKey] = strings.ToValidUTF8(string(raw), "�")
+	}
+	return data, nil
+}
+
+// WithoutPayloadKeys returns a copy of the data without the raw and text keys added by PayloadToData, so the data can be
+// sent as json without the payload twice. Raw and text keys that are part of the decoded payload are kept
+func WithoutPayloadKeys(data map[string]interface{}) map[string]interface{} {
+	results := map[string]interface{}{}
+	for k, v := range data {
+		results[k] = v
+	}
+	// the decoders convert the payload to json values so raw bytes can only have been added by PayloadToData
+	raw, ok := data[RawDataKey].([]byte)
+	if !ok {
+		return results
+	}
+	delete(results, RawDataKey)
+	if text, ok := data[TextDataKey].(string); ok && text == strings.ToValidUTF8(string(raw), "�") {
+		delete(results, TextDataKey)
+	}
+	return results
+}
@@ -0,0 +1,241 @@
+package message
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const testAvroSchema = `{"type":"record","name":"Event","fields":[{"name":"name","type":"string"},{"name":"count","type":"long"}]}`
+
+func writeTestDescriptorSet(t *testing.T) (string, []byte) {
+	t.Helper()
+	fileDesc := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Event"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("name"), JsonName: proto.String("name"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+					{Name: proto.String("count"), JsonName: proto.String("count"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+				},
+			},
+		},
+	}
+	setBytes, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fileDesc}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "test.pb")
+	err = os.WriteFile(path, setBytes, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := protodesc.NewFile(fileDesc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := dynamicpb.NewMessage(file.Messages().ByName("Event"))
+	msg.Set(msg.Descriptor().Fields().ByName("name"), protoreflect.ValueOf("test"))
+	msg.Set(msg.Descriptor().Fields().ByName("count"), protoreflect.ValueOf(int32(5)))
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, payload
+}
+
+func TestPayloadDecoder_Decode(t *testing.T) {
+	descriptorSetFile, protoPayload := writeTestDescriptorSet(t)
+
+	schemaFile := filepath.Join(t.TempDir(), "event.avsc")
+	err := os.WriteFile(schemaFile, []byte(testAvroSchema), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := goavro.NewCodec(testAvroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	avroPayload, err := codec.BinaryFromNative(nil, map[string]interface{}{"name": "test", "count": int64(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		decoder    *PayloadDecoder
+		raw        []byte
+		attributes map[string]string
+		want       interface{}
+		wantErr    string
+	}{
+		{
+			name: "nil decoder json",
+			raw:  []byte(`{"name":"test"}`),
+			want: map[string]interface{}{"name": "test"},
+		},
+		{
+			name: "auto non json",
+			raw:  []byte(`dude`),
+			want: nil,
+		},
+		{
+			name:    "json invalid",
+			decoder: &PayloadDecoder{Type: DecoderJson},
+			raw:     []byte(`dude`),
+			wantErr: "failed to unmarshal the payload as json - invalid character 'd' looking for beginning of value",
+		},
+		{
+			name:    "text",
+			decoder: &PayloadDecoder{Type: DecoderText},
+			raw:     []byte(`{"name":"test"}`),
+			want:    nil,
+		},
+		{
+			name:    "protobuf binary",
+			decoder: &PayloadDecoder{Type: DecoderProtobuf, DescriptorSetFile: descriptorSetFile, MessageType: "test.Event"},
+			raw:     protoPayload,
+			want:    map[string]interface{}{"name": "test", "count": float64(5)},
+		},
+		{
+			name:       "protobuf json from schema encoding attribute",
+			decoder:    &PayloadDecoder{Type: DecoderProtobuf, DescriptorSetFile: descriptorSetFile, MessageType: "test.Event"},
+			raw:        []byte(`{"name":"test","count":5}`),
+			attributes: map[string]string{SchemaEncodingAttribute: "JSON"},
+			want:       map[string]interface{}{"name": "test", "count": float64(5)},
+		},
+		{
+			name:    "protobuf missing message type",
+			decoder: &PayloadDecoder{Type: DecoderProtobuf, DescriptorSetFile: descriptorSetFile},
+			raw:     protoPayload,
+			wantErr: "the descriptorSetFile and messageType must be supplied when using the protobuf decoder",
+		},
+		{
+			name:    "avro binary",
+			decoder: &PayloadDecoder{Type: DecoderAvro, SchemaFile: schemaFile},
+			raw:     avroPayload,
+			want:    map[string]interface{}{"name": "test", "count": float64(5)},
+		},
+		{
+			name:    "avro json",
+			decoder: &PayloadDecoder{Type: DecoderAvro, SchemaFile: schemaFile, Encoding: "json"},
+			raw:     []byte(`{"name":"test","count":5}`),
+			want:    map[string]interface{}{"name": "test", "count": float64(5)},
+		},
+		{
+			name:    "unknown type",
+			decoder: &PayloadDecoder{Type: "xml"},
+			raw:     []byte(`<a/>`),
+			wantErr: "unknown payload decoder type 'xml'. Valid types are auto, json, text, protobuf and avro",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.decoder.Decode(tt.raw, tt.attributes)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("PayloadDecoder.Decode() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Errorf("PayloadDecoder.Decode() error = nil, wantErr %v", tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PayloadDecoder.Decode() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayloadToData(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want map[string]interface{}
+	}{
+		{
+			name: "scalar",
+			raw:  []byte(`5`),
+			want: map[string]interface{}{"value": float64(5), "raw": []byte(`5`), "text": "5"},
+		},
+		{
+			name: "existing raw and text keys",
+			raw:  []byte(`{"raw":"r","text":"t"}`),
+			want: map[string]interface{}{"raw": "r", "text": "t"},
+		},
+		{
+			name: "binary",
+			raw:  []byte{0xff, 'a'},
+			want: map[string]interface{}{"raw": []byte{0xff, 'a'}, "text": "�a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PayloadToData(tt.raw, nil, nil)
+			if err != nil {
+				t.Fatalf("PayloadToData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PayloadToData() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithoutPayloadKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want map[string]interface{}
+	}{
+		{
+			name: "added keys are removed",
+			raw:  []byte(`{"app":"app1"}`),
+			want: map[string]interface{}{"app": "app1"},
+		},
+		{
+			name: "payload keys are kept",
+			raw:  []byte(`{"raw":"r","text":"t"}`),
+			want: map[string]interface{}{"raw": "r", "text": "t"},
+		},
+		{
+			name: "payload text key is kept",
+			raw:  []byte(`{"text":"t"}`),
+			want: map[string]interface{}{"text": "t"},
+		},
+		{
+			name: "text payload",
+			raw:  []byte("not json"),
+			want: map[string]interface{}{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := PayloadToData(tt.raw, nil, nil)
+			if err != nil {
+				t.Fatalf("PayloadToData() error = %v", err)
+			}
+			got := WithoutPayloadKeys(data)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WithoutPayloadKeys() got = %v, want %v", got, tt.want)
+			}
+			if _, exists := data[RawDataKey]; !exists {
+				t.Errorf("WithoutPayloadKeys() removed the raw key from the data")
+			}
+		})
+	}
+}
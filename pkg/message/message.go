@@ -1,13 +1,18 @@
 package message
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
 	lcel "github.com/kcloutie/event-reactor/pkg/cel"
+	"go.uber.org/zap"
 )
 
+// EventHandler is used by listeners that are not driven by http requests to hand events off to the reactors
+type EventHandler func(ctx context.Context, log *zap.Logger, data *EventData) error
+
 type EventData struct {
 	Data       map[string]interface{}
 	Attributes map[string]string
@@ -80,6 +85,7 @@ func GetAttributes(data map[string]interface{}) map[string]string {
 		for k, v := range a {
 			attributes[k] = fmt.Sprintf("%v", v)
 		}
+		return attributes
 	case map[string]string:
 		return a
 	}
@@ -88,3 +88,41 @@ func TestEventData_AsCelData(t *testing.T) {
 		})
 	}
 }
+
+func TestGetAttributes(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		want map[string]string
+	}{
+		{
+			name: "no attributes",
+			data: map[string]interface{}{"test": "123"},
+			want: map[string]string{},
+		},
+		{
+			name: "map string interface",
+			data: map[string]interface{}{
+				"attributes": map[string]interface{}{
+					"att1": "att1Val",
+					"att2": 2,
+				},
+			},
+			want: map[string]string{"att1": "att1Val", "att2": "2"},
+		},
+		{
+			name: "map string string",
+			data: map[string]interface{}{
+				"attributes": map[string]string{"att1": "att1Val"},
+			},
+			want: map[string]string{"att1": "att1Val"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetAttributes(tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetAttributes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,18 +1,34 @@
 package message
 
 import (
+	"bytes"
 	b64 "encoding/base64"
 	"encoding/json"
 	"fmt"
 )
 
+const (
+	PublishTimeAttribute     = "publishTime"
+	OrderingKeyAttribute     = "orderingKey"
+	SubscriptionAttribute    = "subscription"
+	DeliveryAttemptAttribute = "deliveryAttempt"
+)
+
 func PubSubMessageToEventData(message map[string]interface{}) (EventData, error) {
+	return PubSubMessageToEventDataWithDecoder(message, nil)
+}
+
+// PubSubMessageToEventDataWithDecoder converts a pub/sub push envelope into event data. The data of the message is decoded
+// using the supplied decoder, a nil decoder will decode json payloads and expose everything else as raw bytes and text.
+// The publishTime, orderingKey, subscription and deliveryAttempt metadata of the envelope are added to the attributes when the
+// message does not already have an attribute with the same name
+func PubSubMessageToEventDataWithDecoder(envelope map[string]interface{}, decoder *PayloadDecoder) (EventData, error) {
 
 	results := EventData{
 		Attributes: map[string]string{},
 	}
 
-	root, exists := message["message"]
+	root, exists := envelope["message"]
 	if !exists {
 		return EventData{}, fmt.Errorf("message property not found in the pub/sub message")
 	}
@@ -38,33 +54,69 @@ func PubSubMessageToEventData(message map[string]interface{}) (EventData, error)
 	}
 
 	results.ID, _ = message["messageId"].(string)
+	if results.ID == "" {
+		results.ID, _ = message["message_id"].(string)
+	}
+
+	setAttributeIfMissing(results.Attributes, PublishTimeAttribute, message["publishTime"], message["publish_time"])
+	setAttributeIfMissing(results.Attributes, OrderingKeyAttribute, message["orderingKey"], message["ordering_key"])
+	setAttributeIfMissing(results.Attributes, SubscriptionAttribute, envelope["subscription"])
+	setAttributeIfMissing(results.Attributes, DeliveryAttemptAttribute, envelope["deliveryAttempt"])
 
-	mesData := message["data"]
+	raw, err := getPubSubRawData(message["data"])
+	if err != nil {
+		return results, err
+	}
+
+	data, err := PayloadToData(raw, results.Attributes, decoder)
+	if err != nil {
+		return results, fmt.Errorf("failed to decode the pub/sub data property of the message - %v\nPAYLOAD:\n%s", err, string(raw))
+	}
 
-	data := map[string]interface{}{}
+	results.Data = data
+	return results, nil
+}
 
+// getPubSubRawData returns the bytes of the data property. Push messages contain base64 encoded data, however json objects
+// and arrays that were not encoded are also accepted
+func getPubSubRawData(mesData interface{}) ([]byte, error) {
 	switch d := mesData.(type) {
+	case nil:
+		return []byte{}, nil
 	case string:
-		err := json.Unmarshal([]byte(d), &data)
-		if err != nil {
-			sDec, err := b64.StdEncoding.DecodeString(d)
-			if err == nil {
-				err = json.Unmarshal(sDec, &data)
-				if err != nil {
-					return results, fmt.Errorf("failed to unmarshal the pub/sub data property of the message (base64) - %v\nPAYLOAD:\n%s", err, string(d))
-				}
-			} else {
-				return results, fmt.Errorf("failed to unmarshal the pub/sub data property of the message - %v\nPAYLOAD:\n%s", err, d)
-			}
-
+		trimmed := bytes.TrimSpace([]byte(d))
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+			return []byte(d), nil
 		}
+		sDec, err := b64.StdEncoding.DecodeString(d)
+		if err == nil {
+			return sDec, nil
+		}
+		return []byte(d), nil
 	case []byte:
-		err := json.Unmarshal(d, &data)
+		return d, nil
+	default:
+		jsonBytes, err := json.Marshal(d)
 		if err != nil {
-			return results, fmt.Errorf("failed to unmarshal the pub/sub data property of the message - %v\nPAYLOAD:\n%s", err, d)
+			return nil, fmt.Errorf("failed to marshal the pub/sub data property of the message of type %T - %v", d, err)
 		}
+		return jsonBytes, nil
 	}
+}
 
-	results.Data = data
-	return results, nil
+func setAttributeIfMissing(attributes map[string]string, name string, values ...interface{}) {
+	if _, exists := attributes[name]; exists {
+		return
+	}
+	for _, val := range values {
+		if val == nil {
+			continue
+		}
+		strVal := fmt.Sprintf("%v", val)
+		if strVal == "" {
+			continue
+		}
+		attributes[name] = strVal
+		return
+	}
 }
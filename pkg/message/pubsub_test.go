@@ -29,6 +29,8 @@ func TestToToEventData(t *testing.T) {
 				},
 				Data: map[string]interface{}{
 					"test": "123",
+					"raw":  []byte(`{"test":"123"}`),
+					"text": `{"test":"123"}`,
 				},
 				ID: "1",
 			},
@@ -45,13 +47,13 @@ func TestToToEventData(t *testing.T) {
 			},
 			want: EventData{
 				Attributes: map[string]string{},
-				Data:       map[string]interface{}{},
+				Data:       map[string]interface{}{"raw": []byte(`{}`), "text": `{}`},
 				ID:         "1",
 			},
 			wantErr: false,
 		},
 		{
-			name: "Invalid JSON data",
+			name: "Non JSON data",
 			message: map[string]interface{}{
 				"message": map[string]interface{}{
 					"attributes": map[string]string{
@@ -65,9 +67,54 @@ func TestToToEventData(t *testing.T) {
 				Attributes: map[string]string{
 					"att1": "att1Val",
 				},
+				Data: map[string]interface{}{"raw": []byte(`dude`), "text": "dude"},
+				ID:   "1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Base64 text data with envelope metadata",
+			message: map[string]interface{}{
+				"subscription":    "projects/proj/subscriptions/sub",
+				"deliveryAttempt": float64(3),
+				"message": map[string]interface{}{
+					"attributes":  map[string]interface{}{"orderingKey": "keep"},
+					"data":        "aGVsbG8gd29ybGQ=",
+					"message_id":  "2",
+					"publishTime": "2024-01-01T00:00:00Z",
+					"orderingKey": "key1",
+				},
+			},
+			want: EventData{
+				Attributes: map[string]string{
+					"orderingKey":     "keep",
+					"publishTime":     "2024-01-01T00:00:00Z",
+					"subscription":    "projects/proj/subscriptions/sub",
+					"deliveryAttempt": "3",
+				},
+				Data: map[string]interface{}{"raw": []byte("hello world"), "text": "hello world"},
+				ID:   "2",
+			},
+			wantErr: false,
+		},
+		{
+			name: "JSON array data",
+			message: map[string]interface{}{
+				"message": map[string]interface{}{
+					"data":      `[1,2]`,
+					"messageId": "1",
+				},
+			},
+			want: EventData{
+				Attributes: map[string]string{},
+				Data: map[string]interface{}{
+					"items": []interface{}{float64(1), float64(2)},
+					"raw":   []byte(`[1,2]`),
+					"text":  `[1,2]`,
+				},
 				ID: "1",
 			},
-			wantErr: true,
+			wantErr: false,
 		},
 	}
 	for _, tt := range tests {
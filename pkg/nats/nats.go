@@ -0,0 +1,121 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	SubjectAttribute       = "natsSubject"
+	StreamAttribute        = "natsStream"
+	SequenceAttribute      = "natsSequence"
+	DeliveryCountAttribute = "natsDeliveryCount"
+
+	DefaultPublishTimeout = 10 * time.Second
+)
+
+var connectionCache = sync.Map{}
+
+// ConnectOptions holds the details used to connect to a NATS server. Only one of the credentials file, token or user and
+// password should be supplied
+type ConnectOptions struct {
+	Url             string `json:"url,omitempty" yaml:"url,omitempty"`
+	CredentialsFile string `json:"credentialsFile,omitempty" yaml:"credentialsFile,omitempty"`
+	Token           string `json:"token,omitempty" yaml:"token,omitempty"`
+	User            string `json:"user,omitempty" yaml:"user,omitempty"`
+	Password        string `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+func (o ConnectOptions) GetUrl() string {
+	if o.Url == "" {
+		return natsgo.DefaultURL
+	}
+	return o.Url
+}
+
+// Connect creates a new connection to the NATS server. The caller is responsible for closing the connection
+func Connect(name string, opts ConnectOptions) (*natsgo.Conn, error) {
+	natsOpts := []natsgo.Option{
+		natsgo.Name(name),
+		natsgo.MaxReconnects(-1),
+	}
+	if opts.CredentialsFile != "" {
+		natsOpts = append(natsOpts, natsgo.UserCredentials(opts.CredentialsFile))
+	}
+	if opts.Token != "" {
+		natsOpts = append(natsOpts, natsgo.Token(opts.Token))
+	}
+	if opts.User != "" {
+		natsOpts = append(natsOpts, natsgo.UserInfo(opts.User, opts.Password))
+	}
+	nc, err := natsgo.Connect(opts.GetUrl(), natsOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the NATS server '%s' - %w", opts.GetUrl(), err)
+	}
+	return nc, nil
+}
+
+// GetConnection returns a cached connection for the options, creating a new connection when one does not exist or the
+// cached connection was closed
+func GetConnection(name string, opts ConnectOptions) (*natsgo.Conn, error) {
+	key := fmt.Sprintf("%s|%s|%s|%s|%s", opts.GetUrl(), opts.CredentialsFile, opts.Token, opts.User, opts.Password)
+	if cached, ok := connectionCache.Load(key); ok {
+		nc := cached.(*natsgo.Conn)
+		if !nc.IsClosed() {
+			return nc, nil
+		}
+	}
+	nc, err := Connect(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	connectionCache.Store(key, nc)
+	return nc, nil
+}
+
+// Publish sends the message to the subject. When jetStream is true, the message is published to the stream that captures the
+// subject and the stream sequence of the message is returned once the server acknowledges it
+func Publish(ctx context.Context, nc *natsgo.Conn, subject string, data []byte, headers map[string]string, jetStream bool) (string, error) {
+	msg := natsgo.NewMsg(subject)
+	msg.Data = data
+	for k, v := range headers {
+		msg.Header.Set(k, v)
+	}
+
+	if !jetStream {
+		err := nc.PublishMsg(msg)
+		if err != nil {
+			return "", fmt.Errorf("failed to publish the message to the NATS subject '%s' - %w", subject, err)
+		}
+		err = nc.FlushWithContext(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to flush the message to the NATS subject '%s' - %w", subject, err)
+		}
+		return "", nil
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return "", fmt.Errorf("failed to create the JetStream context - %w", err)
+	}
+	ack, err := js.PublishMsg(ctx, msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to publish the message to the JetStream subject '%s' - %w", subject, err)
+	}
+	return fmt.Sprintf("%s-%d", ack.Stream, ack.Sequence), nil
+}
+
+// HeadersToAttributes converts the message headers into attributes. Headers with multiple values are joined with a comma
+func HeadersToAttributes(header natsgo.Header) map[string]string {
+	attributes := map[string]string{}
+	for k, vals := range header {
+		attributes[k] = strings.Join(vals, ",")
+	}
+	return attributes
+}
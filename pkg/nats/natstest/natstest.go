@@ -0,0 +1,32 @@
+package natstest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// RunServer starts an in-process NATS server with JetStream enabled on a random port. The server is shut down when the test
+// completes
+func RunServer(t *testing.T) *server.Server {
+	t.Helper()
+	opts := &server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		NoLog:     true,
+		NoSigs:    true,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	}
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		t.Fatalf("failed to create the NATS server - %v", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(10 * time.Second) {
+		t.Fatal("the NATS server was not ready for connections")
+	}
+	t.Cleanup(ns.Shutdown)
+	return ns
+}
@@ -0,0 +1,139 @@
+package pagerduty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/kcloutie/event-reactor/pkg/http"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	ActionTrigger     = "trigger"
+	ActionAcknowledge = "acknowledge"
+	ActionResolve     = "resolve"
+)
+
+var (
+	ValidActions    = []string{ActionTrigger, ActionAcknowledge, ActionResolve}
+	ValidSeverities = []string{"critical", "error", "warning", "info"}
+)
+
+// PagerDutyConfiguration is used to send events to the PagerDuty Events API v2
+type PagerDutyConfiguration struct {
+	Log        *zap.Logger
+	EventsUrl  string
+	MaxRetries int
+	Event      Event
+}
+
+type Event struct {
+	RoutingKey  string        `json:"routing_key"`
+	EventAction string        `json:"event_action"`
+	DedupKey    string        `json:"dedup_key,omitempty"`
+	Payload     *EventPayload `json:"payload,omitempty"`
+	Links       []Link        `json:"links,omitempty"`
+	Client      string        `json:"client,omitempty"`
+	ClientUrl   string        `json:"client_url,omitempty"`
+}
+
+type EventPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp,omitempty"`
+	Component     string                 `json:"component,omitempty"`
+	Group         string                 `json:"group,omitempty"`
+	Class         string                 `json:"class,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+type Link struct {
+	Href string `json:"href"`
+	Text string `json:"text,omitempty"`
+}
+
+type EventResponse struct {
+	Status   string   `json:"status"`
+	Message  string   `json:"message"`
+	DedupKey string   `json:"dedup_key"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Validate checks the event contains the fields required by the action
+func (e Event) Validate() error {
+	if e.RoutingKey == "" {
+		return fmt.Errorf("the routing key of the pagerduty event must be supplied")
+	}
+	switch e.EventAction {
+	case ActionTrigger:
+		if e.Payload == nil || e.Payload.Summary == "" || e.Payload.Source == "" || e.Payload.Severity == "" {
+			return fmt.Errorf("the summary, source and severity of the pagerduty event must be supplied for the %s action", ActionTrigger)
+		}
+		if !slices.Contains(ValidSeverities, e.Payload.Severity) {
+			return fmt.Errorf("the severity '%s' is not valid. Valid severities are %v", e.Payload.Severity, ValidSeverities)
+		}
+	case ActionAcknowledge, ActionResolve:
+		if e.DedupKey == "" {
+			return fmt.Errorf("the dedup key of the pagerduty event must be supplied for the %s action", e.EventAction)
+		}
+	default:
+		return fmt.Errorf("the action '%s' is not valid. Valid actions are %v", e.EventAction, ValidActions)
+	}
+	return nil
+}
+
+// Send sends the event and returns the response from PagerDuty, which includes the dedup key of the alert. An event without a
+// dedup key is only retried when it was rate limited, PagerDuty generates a new dedup key for each event so retrying a trigger
+// that failed with a 5xx or a network error after it was accepted would open a second incident
+func (c PagerDutyConfiguration) Send(ctx context.Context) (*EventResponse, error) {
+	err := c.Event.Validate()
+	if err != nil {
+		return nil, err
+	}
+	event := c.Event
+	if event.EventAction != ActionTrigger {
+		// only trigger events have a payload
+		event.Payload = nil
+	}
+
+	bodyBytes, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the pagerduty event into json - %v", err)
+	}
+	req, err := retryablehttp.NewRequestWithContext(ctx, "POST", c.EventsUrl, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	retryClient := http.NewHttpRetryClient(c.Log, c.MaxRetries)
+	if event.DedupKey == "" {
+		retryClient.CheckRetry = http.RateLimitRetryPolicy
+	}
+	response, err := retryClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send the pagerduty event - %v", err)
+	}
+	defer response.Body.Close()
+
+	respBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response body: %v", err)
+	}
+	if response.StatusCode <= 199 || response.StatusCode >= 400 {
+		return nil, fmt.Errorf("pagerduty events api call failed. Status Code: %v. Response Body: %v", response.StatusCode, string(respBody))
+	}
+
+	eventResponse := &EventResponse{}
+	err = json.Unmarshal(respBody, eventResponse)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the pagerduty response - %v. Response Body: %v", err, string(respBody))
+	}
+	return eventResponse, nil
+}
@@ -0,0 +1,180 @@
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPagerDutyConfiguration_Send(t *testing.T) {
+	received := []map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body := map[string]interface{}{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		received = append(received, body)
+		if body["routing_key"] == "invalid" {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"status":"invalid event","message":"Event object is invalid","errors":["Invalid routing key"]}`))
+			return
+		}
+		dedupKey, _ := body["dedup_key"].(string)
+		if dedupKey == "" {
+			dedupKey = "generated"
+		}
+		rw.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(rw).Encode(EventResponse{Status: "success", Message: "Event processed", DedupKey: dedupKey})
+	}))
+	defer server.Close()
+
+	payload := &EventPayload{
+		Summary:       "deploy failed",
+		Source:        "app1",
+		Severity:      "critical",
+		CustomDetails: map[string]interface{}{"env": "prod"},
+	}
+	tests := []struct {
+		name         string
+		event        Event
+		wantDedupKey string
+		wantBody     string
+		wantErr      string
+	}{
+		{
+			name: "trigger",
+			event: Event{
+				RoutingKey:  "key",
+				EventAction: ActionTrigger,
+				Payload:     payload,
+				Links:       []Link{{Href: "https://example.com", Text: "logs"}},
+			},
+			wantDedupKey: "generated",
+			wantBody:     `{"event_action":"trigger","links":[{"href":"https://example.com","text":"logs"}],"payload":{"custom_details":{"env":"prod"},"severity":"critical","source":"app1","summary":"deploy failed"},"routing_key":"key"}`,
+		},
+		{
+			name: "resolve drops the payload",
+			event: Event{
+				RoutingKey:  "key",
+				EventAction: ActionResolve,
+				DedupKey:    "app1",
+				Payload:     payload,
+			},
+			wantDedupKey: "app1",
+			wantBody:     `{"dedup_key":"app1","event_action":"resolve","routing_key":"key"}`,
+		},
+		{
+			name: "api error",
+			event: Event{
+				RoutingKey:  "invalid",
+				EventAction: ActionAcknowledge,
+				DedupKey:    "app1",
+			},
+			wantErr: `pagerduty events api call failed. Status Code: 400. Response Body: {"status":"invalid event","message":"Event object is invalid","errors":["Invalid routing key"]}`,
+		},
+		{
+			name: "missing dedup key",
+			event: Event{
+				RoutingKey:  "key",
+				EventAction: ActionResolve,
+			},
+			wantErr: "the dedup key of the pagerduty event must be supplied for the resolve action",
+		},
+		{
+			name: "missing trigger payload",
+			event: Event{
+				RoutingKey:  "key",
+				EventAction: ActionTrigger,
+			},
+			wantErr: "the summary, source and severity of the pagerduty event must be supplied for the trigger action",
+		},
+		{
+			name: "invalid severity",
+			event: Event{
+				RoutingKey:  "key",
+				EventAction: ActionTrigger,
+				Payload:     &EventPayload{Summary: "s", Source: "s", Severity: "bad"},
+			},
+			wantErr: "the severity 'bad' is not valid. Valid severities are [critical error warning info]",
+		},
+		{
+			name: "invalid action",
+			event: Event{
+				RoutingKey:  "key",
+				EventAction: "close",
+			},
+			wantErr: "the action 'close' is not valid. Valid actions are [trigger acknowledge resolve]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = []map[string]interface{}{}
+			c := PagerDutyConfiguration{
+				Log:       zaptest.NewLogger(t),
+				EventsUrl: server.URL,
+				Event:     tt.event,
+			}
+			got, err := c.Send(context.Background())
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("PagerDutyConfiguration.Send() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("PagerDutyConfiguration.Send() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.DedupKey != tt.wantDedupKey {
+				t.Errorf("PagerDutyConfiguration.Send() dedup key = %v, want %v", got.DedupKey, tt.wantDedupKey)
+			}
+			gotBody, _ := json.Marshal(received[0])
+			if string(gotBody) != tt.wantBody {
+				t.Errorf("PagerDutyConfiguration.Send() body = %s, want %s", gotBody, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestPagerDutyConfiguration_Send_Retries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		attempts++
+		rw.Header().Set("Retry-After", "0")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		dedupKey     string
+		wantAttempts int
+	}{
+		{name: "trigger without a dedup key is not retried", wantAttempts: 1},
+		{name: "trigger with a dedup key is retried", dedupKey: "app1", wantAttempts: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts = 0
+			c := PagerDutyConfiguration{
+				Log:        zaptest.NewLogger(t),
+				EventsUrl:  server.URL,
+				MaxRetries: 1,
+				Event: Event{
+					RoutingKey:  "key",
+					EventAction: ActionTrigger,
+					DedupKey:    tt.dedupKey,
+					Payload:     &EventPayload{Summary: "deploy failed", Source: "app1", Severity: "critical"},
+				},
+			}
+			_, err := c.Send(context.Background())
+			if err == nil {
+				t.Fatalf("PagerDutyConfiguration.Send() error = nil, want an error")
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("PagerDutyConfiguration.Send() attempts = %v, want %v", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
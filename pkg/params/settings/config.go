@@ -4,10 +4,14 @@ const (
 	CliBinaryName               = "er"
 	DebugModeLoggerEnvVar       = "EVENT_REACTOR_DEBUG"
 	PubSubEndpoint              = "pubsub"
+	BatchEndpoint               = "batch"
 	GoTemplateDefaultDelimLeft  = "{{"
 	GoTemplateDefaultDelimRight = "}}"
 	SignatureHeader             = "X-Event-Reactor-Signature"
 	WebexApiUrlDefault          = "https://api.ciscospark.com/v1/messages"
+	WebexApiBaseUrlDefault      = "https://webexapis.com/v1"
+	SlackApiUrlDefault          = "https://slack.com/api"
+	PagerDutyEventsUrlDefault   = "https://events.pagerduty.com/v2/enqueue"
 )
 
 var (
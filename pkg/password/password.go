@@ -1,6 +1,10 @@
 package password
 
 import (
+	crand "crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"math/rand"
 	"time"
 )
@@ -60,3 +64,33 @@ func GeneratePassword(length int, useLowerLetters, useUpperLetters, useSpecial,
 	}
 	return string(b)
 }
+
+// GenerateHex returns the hex encoding of length random bytes generated using the crypto/rand package
+func GenerateHex(length int) (string, error) {
+	b, err := generateBytes(length)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GenerateBase64 returns the standard base64 encoding of length random bytes generated using the crypto/rand package
+func GenerateBase64(length int) (string, error) {
+	b, err := generateBytes(length)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func generateBytes(length int) ([]byte, error) {
+	if length <= 0 {
+		return nil, fmt.Errorf("the number of random bytes must be greater than 0, %d was supplied", length)
+	}
+	b := make([]byte, length)
+	_, err := crand.Read(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %d random bytes: %v", length, err)
+	}
+	return b, nil
+}
@@ -1,6 +1,8 @@
 package password
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"testing"
 )
@@ -90,3 +92,53 @@ func TestGeneratePassword(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func(int) (string, error)
+		decode   func(string) ([]byte, error)
+		length   int
+		wantErr  string
+	}{
+		{
+			name:     "hex",
+			generate: GenerateHex,
+			decode:   hex.DecodeString,
+			length:   16,
+		},
+		{
+			name:     "base64",
+			generate: GenerateBase64,
+			decode:   base64.StdEncoding.DecodeString,
+			length:   32,
+		},
+		{
+			name:     "zero length",
+			generate: GenerateHex,
+			length:   0,
+			wantErr:  "the number of random bytes must be greater than 0, 0 was supplied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.generate(tt.length)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("generate() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("generate() error = nil, wantErr %v", tt.wantErr)
+			}
+			decoded, err := tt.decode(got)
+			if err != nil {
+				t.Fatalf("failed to decode '%s': %v", got, err)
+			}
+			if len(decoded) != tt.length {
+				t.Errorf("generate() decoded length = %v, want %v", len(decoded), tt.length)
+			}
+		})
+	}
+}
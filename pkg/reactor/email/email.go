@@ -2,7 +2,9 @@ package email
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +15,7 @@ import (
 	"github.com/kcloutie/event-reactor/pkg/reactor"
 	"github.com/kcloutie/event-reactor/pkg/template"
 	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
 )
 
 var _ reactor.ReactorInterface = (*Reactor)(nil)
@@ -23,6 +26,13 @@ type Reactor struct {
 	reactorConfig config.ReactorConfig
 }
 
+const (
+	BodyFormatHtml     = "html"
+	BodyFormatMarkdown = "markdown"
+)
+
+var BodyFormats = []string{BodyFormatHtml, BodyFormatMarkdown}
+
 type ReactorConfig struct {
 	EmailCOnfig em.EmailConfiguration
 }
@@ -52,12 +62,44 @@ func (v *Reactor) GetConfigExample() string {
             version: latest
       to:
         value: someoneelse@somewherelese.com
+      cc:
+        value: team@somewhere.com
+      replyTo:
+        value: support@somewhere.com
       body:
-        value: this is a test email from event reactor
+        value: |
+          # {{ .data.app }}
+          This is a test email from event reactor for **{{ .data.app }}**
+      bodyFormat:
+        value: markdown
+      layout:
+        value: team-a
+      layoutDirectory:
+        value: /etc/event-reactor/email-layouts
+      layoutValues:
+        value:
+          team: Team A
+          color: "#1C6EA4"
+      headers:
+        value:
+          X-Priority: "1"
+      attachments:
+        value:
+        - filename: "{{ .data.app }}-report.txt"
+          content: "{{ .data.summary }}"
+        - filename: build.log
+          file: /var/log/build/{{ .data.buildId }}.log
+        - filename: results.zip
+          contentType: application/zip
+          payloadPath: data.resultsBase64
       smtpHost:
         value: smpt.com
       smtpPort:
         value: "587"
+      tlsMode:
+        value: starttls
+      authMechanism:
+        value: login
       maxRetries:
         value: "5"
 `
@@ -71,7 +113,7 @@ func (v *Reactor) GetName() string {
 }
 
 func (v *Reactor) GetDescription() string {
-	return "This reactor sends an email to the specified recipient(s) using the supplied smtp server and credentials. The email subject and body can be templated using Go's text/template package. The email is retried up to the specified number of times if it fails to send."
+	return "This reactor sends an email to the specified recipient(s) using the supplied smtp server and credentials. The email subject, html body and text body can be templated using Go's text/template package, when both bodies are supplied the email is sent as multipart/alternative. The body can be written in markdown and wrapped in a named html layout with partials and inlined css. Cc, bcc and reply-to recipients, custom headers and attachments from files, templated content or base64 payload fields are supported. The connection can be encrypted using STARTTLS or implicit tls and the plain, login, cram-md5 and xoauth2 auth mechanisms are supported. The email is retried up to the specified number of times if it fails to send."
 }
 
 func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
@@ -92,7 +134,7 @@ func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) err
 	if err != nil {
 		return err
 	}
-	v.Log = v.Log.With(zap.String("from", reactorConfig.EmailCOnfig.From), zap.String("smtpHost", reactorConfig.EmailCOnfig.SMTPHost), zap.Int("smtpPort", reactorConfig.EmailCOnfig.SMTPPort), zap.Strings("to", reactorConfig.EmailCOnfig.To), zap.String("subject", reactorConfig.EmailCOnfig.Subject))
+	v.Log = v.Log.With(zap.String("from", reactorConfig.EmailCOnfig.From), zap.String("smtpHost", reactorConfig.EmailCOnfig.SMTPHost), zap.Int("smtpPort", reactorConfig.EmailCOnfig.SMTPPort), zap.String("tlsMode", reactorConfig.EmailCOnfig.TLSMode), zap.Strings("to", reactorConfig.EmailCOnfig.To), zap.Strings("cc", reactorConfig.EmailCOnfig.Cc), zap.String("subject", reactorConfig.EmailCOnfig.Subject), zap.Int("attachments", len(reactorConfig.EmailCOnfig.Attachments)))
 	v.Log.Debug("Sending email")
 	err = reactorConfig.EmailCOnfig.SendEmail(ctx)
 	if err != nil {
@@ -196,10 +238,97 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 	if err != nil {
 		return nil, err
 	}
-	if from == "" {
+	config.EmailCOnfig.Password = password
+
+	// ===================================================================================
+	// Get tlsMode, caCertificates and insecureSkipVerify
+	// ===================================================================================
+
+	tlsMode, err := v.reactorConfig.Properties["tlsMode"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	tlsMode = strings.ToLower(strings.TrimSpace(tlsMode))
+	if tlsMode == "" {
+		tlsMode = em.TLSModeOpportunistic
+	}
+	if !slices.Contains(em.TLSModes, tlsMode) {
+		return nil, fmt.Errorf("the tlsMode '%s' is not valid. Valid tlsModes are %v", tlsMode, em.TLSModes)
+	}
+	config.EmailCOnfig.TLSMode = tlsMode
+
+	config.EmailCOnfig.CACertificates, err = v.reactorConfig.Properties["caCertificates"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+
+	insecureSkipVerifyStr, err := v.reactorConfig.Properties["insecureSkipVerify"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if insecureSkipVerifyStr != "" {
+		config.EmailCOnfig.InsecureSkipVerify, err = strconv.ParseBool(insecureSkipVerifyStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied insecureSkipVerify '%v' to a boolean. Error: %v", insecureSkipVerifyStr, err)
+		}
+	}
+
+	// ===================================================================================
+	// Get authMechanism, username and oauth2
+	// ===================================================================================
+
+	authMechanism, err := v.reactorConfig.Properties["authMechanism"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	authMechanism = strings.ToLower(strings.TrimSpace(authMechanism))
+	if authMechanism != "" && !slices.Contains(em.AuthMechanisms, authMechanism) {
+		return nil, fmt.Errorf("the authMechanism '%s' is not valid. Valid authMechanisms are %v", authMechanism, em.AuthMechanisms)
+	}
+	if (authMechanism == em.AuthPlain || authMechanism == em.AuthLogin || authMechanism == em.AuthCramMD5) && password == "" {
 		return nil, fmt.Errorf("the password property was not supplied or was empty")
 	}
-	config.EmailCOnfig.Password = password
+	config.EmailCOnfig.AuthMechanism = authMechanism
+
+	config.EmailCOnfig.Username, err = v.reactorConfig.Properties["username"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.EmailCOnfig.OAuth2Token, err = v.reactorConfig.Properties["oauth2Token"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	oauth2TokenUrl, err := v.reactorConfig.Properties["oauth2TokenUrl"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if oauth2TokenUrl != "" {
+		config.EmailCOnfig.OAuth2 = &em.OAuth2Config{
+			TokenUrl: oauth2TokenUrl,
+		}
+		config.EmailCOnfig.OAuth2.ClientId, err = v.reactorConfig.Properties["oauth2ClientId"].GetStringValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+		config.EmailCOnfig.OAuth2.ClientSecret, err = v.reactorConfig.Properties["oauth2ClientSecret"].GetStringValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+		if config.EmailCOnfig.OAuth2.ClientId == "" {
+			return nil, fmt.Errorf("the oauth2ClientId property must be supplied when the oauth2TokenUrl property is supplied")
+		}
+		config.EmailCOnfig.OAuth2.RefreshToken, err = v.reactorConfig.Properties["oauth2RefreshToken"].GetStringValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+		config.EmailCOnfig.OAuth2.Scopes, err = v.reactorConfig.Properties["oauth2Scopes"].GetStringArrayValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if authMechanism == em.AuthXOAuth2 && config.EmailCOnfig.OAuth2Token == "" && config.EmailCOnfig.OAuth2 == nil {
+		return nil, fmt.Errorf("the oauth2Token or oauth2TokenUrl property must be supplied when the authMechanism is xoauth2")
+	}
 
 	// ===================================================================================
 	// Get subject
@@ -230,12 +359,243 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 	if body == "" {
 		return nil, fmt.Errorf("the body property was not supplied or was empty")
 	}
-	config.EmailCOnfig.Subject = string(renderedBody)
+	config.EmailCOnfig.HtmlBody = string(renderedBody)
+
+	// ===================================================================================
+	// Get textBody
+	// ===================================================================================
+
+	textBody, err := v.reactorConfig.Properties["textBody"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if textBody != "" {
+		renderedTextBody, err := template.RenderTemplateValues(ctx, textBody, fmt.Sprintf("%s_%s/textBody", data.ID, v.reactorName), data.AsMap(), []string{}, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+		config.EmailCOnfig.TextBody = string(renderedTextBody)
+	}
+
+	// ===================================================================================
+	// Get bodyFormat
+	// ===================================================================================
+
+	bodyFormat, err := v.reactorConfig.Properties["bodyFormat"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	bodyFormat = strings.ToLower(strings.TrimSpace(bodyFormat))
+	if bodyFormat == "" {
+		bodyFormat = BodyFormatHtml
+	}
+	if !slices.Contains(BodyFormats, bodyFormat) {
+		return nil, fmt.Errorf("the bodyFormat '%s' is not valid. Valid bodyFormats are %v", bodyFormat, BodyFormats)
+	}
+	if bodyFormat == BodyFormatMarkdown {
+		// markdown is readable as plain text, so it is also used as the text body when one was not supplied
+		if config.EmailCOnfig.TextBody == "" {
+			config.EmailCOnfig.TextBody = config.EmailCOnfig.HtmlBody
+		}
+		config.EmailCOnfig.HtmlBody, err = em.MarkdownToHtml(config.EmailCOnfig.HtmlBody)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// ===================================================================================
+	// Get layout
+	// ===================================================================================
+
+	layout, err := v.reactorConfig.Properties["layout"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	layout = strings.TrimSpace(layout)
+	if layout != "" {
+		config.EmailCOnfig.HtmlBody, err = v.renderLayout(ctx, data, layout, config.EmailCOnfig, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// ===================================================================================
+	// Get cc, bcc and replyTo
+	// ===================================================================================
+
+	for _, recipients := range []struct {
+		name   string
+		target *[]string
+	}{{"cc", &config.EmailCOnfig.Cc}, {"bcc", &config.EmailCOnfig.Bcc}, {"replyTo", &config.EmailCOnfig.ReplyTo}} {
+		value, err := v.reactorConfig.Properties[recipients.name].GetStringValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			*recipients.target = splitEmailAddress(value)
+		}
+	}
+
+	// ===================================================================================
+	// Get headers
+	// ===================================================================================
+
+	headers, err := v.reactorConfig.Properties["headers"].GetMapStringInterfaceValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	headers, err = reactor.RenderTemplateParameters(ctx, headers, data, v.reactorName, templateConfig)
+	if err != nil {
+		return nil, err
+	}
+	config.EmailCOnfig.Headers = map[string]string{}
+	for k, val := range headers {
+		config.EmailCOnfig.Headers[k] = fmt.Sprintf("%v", val)
+	}
+
+	// ===================================================================================
+	// Get attachments
+	// ===================================================================================
+
+	config.EmailCOnfig.Attachments, err = v.getAttachments(ctx, data, templateConfig)
+	if err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 
 }
 
+// renderLayout wraps the html body in the layout. The layout can use the event data along with the subject, the body and
+// the layoutValues property, for example for the branding of a team
+func (v *Reactor) renderLayout(ctx context.Context, data *message.EventData, layout string, emailConfig em.EmailConfiguration, templateConfig template.RenderTemplateOptions) (string, error) {
+	layoutDirectory, err := v.reactorConfig.Properties["layoutDirectory"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return "", err
+	}
+	if layoutDirectory == "" && layout != em.DefaultLayoutName {
+		return "", fmt.Errorf("the layoutDirectory property must be supplied when the layout '%s' is used", layout)
+	}
+	layouts, err := em.LoadLayouts(layoutDirectory, templateConfig)
+	if err != nil {
+		return "", err
+	}
+
+	layoutValues, err := v.reactorConfig.Properties["layoutValues"].GetMapStringInterfaceValue(ctx, v.Log, data)
+	if err != nil {
+		return "", err
+	}
+	layoutValues, err = reactor.RenderTemplateParameters(ctx, layoutValues, data, v.reactorName, templateConfig)
+	if err != nil {
+		return "", err
+	}
+
+	inlineCss := true
+	inlineCssStr, err := v.reactorConfig.Properties["inlineCss"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return "", err
+	}
+	if inlineCssStr != "" {
+		inlineCss, err = strconv.ParseBool(inlineCssStr)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert the supplied inlineCss '%v' to a boolean. Error: %v", inlineCssStr, err)
+		}
+	}
+
+	layoutData := data.AsMap()
+	layoutData["subject"] = emailConfig.Subject
+	layoutData["body"] = emailConfig.HtmlBody
+	layoutData["values"] = layoutValues
+	return layouts.Render(layout, layoutData, inlineCss)
+}
+
+// getAttachments reads the list of attachments, each attachment has a filename and one of a file, content or payloadPath
+func (v *Reactor) getAttachments(ctx context.Context, data *message.EventData, templateConfig template.RenderTemplateOptions) ([]em.Attachment, error) {
+	attachments := []em.Attachment{}
+	if _, ok := v.reactorConfig.Properties["attachments"]; !ok {
+		return attachments, nil
+	}
+	value, err := v.reactorConfig.Properties["attachments"].GetValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		if value == nil {
+			return attachments, nil
+		}
+		return nil, fmt.Errorf("expected the attachments property to be a list, however it is of type %T", value)
+	}
+
+	for i, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected attachment %d to be an object, however it is of type %T", i, item)
+		}
+		getRendered := func(name string) (string, error) {
+			value, ok := fields[name]
+			if !ok || value == nil {
+				return "", nil
+			}
+			return reactor.RenderTemplateValue(ctx, fmt.Sprintf("%v", value), fmt.Sprintf("attachments/%d/%s", i, name), data, v.reactorName, templateConfig)
+		}
+
+		attachment := em.Attachment{}
+		attachment.Filename, err = getRendered("filename")
+		if err != nil {
+			return nil, err
+		}
+		attachment.Filename = strings.TrimSpace(attachment.Filename)
+		if attachment.Filename == "" {
+			return nil, fmt.Errorf("the filename of attachment %d was not supplied or was empty", i)
+		}
+		attachment.ContentType, err = getRendered("contentType")
+		if err != nil {
+			return nil, err
+		}
+
+		sources := 0
+		for _, source := range []string{"file", "content", "payloadPath"} {
+			if _, ok := fields[source]; ok {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return nil, fmt.Errorf("exactly one of the file, content or payloadPath fields must be supplied for the attachment '%s'", attachment.Filename)
+		}
+
+		switch {
+		case fields["file"] != nil:
+			path, err := getRendered("file")
+			if err != nil {
+				return nil, err
+			}
+			attachment.Content, err = os.ReadFile(strings.TrimSpace(path))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the file of the attachment '%s' - %v", attachment.Filename, err)
+			}
+		case fields["content"] != nil:
+			content, err := getRendered("content")
+			if err != nil {
+				return nil, err
+			}
+			attachment.Content = []byte(content)
+		default:
+			payloadPath := fmt.Sprintf("%v", fields["payloadPath"])
+			encoded, err := data.GetPropertyValue(payloadPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the content of the attachment '%s' from the payload - %v", attachment.Filename, err)
+			}
+			attachment.Content, err = base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode the base64 content of the attachment '%s' from the '%s' payload path - %v", attachment.Filename, payloadPath, err)
+			}
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
 func (v *Reactor) GetHelp() string {
 	return reactor.GetReactorHelp(v)
 }
@@ -250,8 +610,8 @@ func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
 		},
 		{
 			Name:        "password",
-			Description: "The password for the smtp server",
-			Required:    config.AsBoolPointer(true),
+			Description: "The password for the smtp server. Required by the plain, login and cram-md5 auth mechanisms",
+			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
 		{
@@ -290,6 +650,138 @@ func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
 			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
+		{
+			Name:        "textBody",
+			Description: "The plain text body of the email, sent with the html body as multipart/alternative for clients that do not display html. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "cc",
+			Description: "The email address of the cc recipient(s). Multiple addresses can be separated by a comma, semicolon, or space",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "bcc",
+			Description: "The email address of the bcc recipient(s), which are not included in the headers of the email. Multiple addresses can be separated by a comma, semicolon, or space",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "replyTo",
+			Description: "The email address(es) replies are sent to. Multiple addresses can be separated by a comma, semicolon, or space",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "headers",
+			Description: "Custom headers added to the email, for example X-Priority. Headers set by the reactor such as Subject or To cannot be supplied. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "attachments",
+			Description: "A list of attachments. Each attachment has a filename, an optional contentType and exactly one of file (the path of a file), content (templated text) or payloadPath (the path of a base64 encoded field in the event). The filename, contentType, file and content fields support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "bodyFormat",
+			Description: "The format of the body property. Valid values are html and markdown. A markdown body is converted to html and, when the textBody property is not supplied, is also sent as the plain text body. Defaults to html",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "layout",
+			Description: "The name of the layout the html body is wrapped in, for example team-a for the team-a.html file of the layoutDirectory. The built-in default layout is used when the name is default and the directory does not contain a default.html file. The layout is a go template that can use the event data, .subject, .body, .values and the partials of the partials sub directory, for example {{ template \"header\" . }}",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "layoutDirectory",
+			Description: "The directory containing the layouts and the partials sub directory, for example a mounted configmap. Required when a layout other than default is used",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "layoutValues",
+			Description: "Values available to the layout as .values, for example the name, logo and colors of a team. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "inlineCss",
+			Description: "Set to false to keep the css of the style elements of the layout instead of moving it to the style attributes of the elements, which is required by clients such as Outlook. Defaults to true",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "tlsMode",
+			Description: "How the connection to the smtp server is encrypted. Valid values are none (unencrypted), opportunistic (the connection is upgraded using STARTTLS when the server supports it, otherwise the email is sent unencrypted), starttls (the connection is upgraded using STARTTLS, usually on port 587, and fails when the server does not support it) and implicit (tls from the start of the connection, usually on port 465). Defaults to opportunistic",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "caCertificates",
+			Description: "PEM encoded certificates of the certificate authorities trusted in addition to the system certificates, for example for an internal smtp relay",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "insecureSkipVerify",
+			Description: "Set to true to skip the verification of the certificate of the smtp server. This should only be used for lab servers. Defaults to false",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "authMechanism",
+			Description: "The smtp authentication mechanism. Valid values are none, plain, login, cram-md5 and xoauth2. The plain and login mechanisms require tls unless the smtp server is localhost. Defaults to xoauth2 when an oauth2 token is configured, plain when a password is supplied and none otherwise",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "username",
+			Description: "The username used to authenticate with the smtp server. Defaults to the address of the from property",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2Token",
+			Description: "The access token used by the xoauth2 auth mechanism",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2TokenUrl",
+			Description: "The token url used to get the access token of the xoauth2 auth mechanism. The refresh token flow is used when the oauth2RefreshToken property is supplied (for example for Gmail), otherwise the client credentials flow is used (for example for Microsoft 365). Tokens are cached until they expire",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2ClientId",
+			Description: "The client id used to get the access token of the xoauth2 auth mechanism",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2ClientSecret",
+			Description: "The client secret used to get the access token of the xoauth2 auth mechanism",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2RefreshToken",
+			Description: "The refresh token used to get the access token of the xoauth2 auth mechanism",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2Scopes",
+			Description: "The scopes requested when getting the access token of the xoauth2 auth mechanism",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeStringArray,
+		},
 	}
 }
 
@@ -307,7 +799,8 @@ func splitEmailAddress(address string) []string {
 		return strings.Split(address, ",")
 	}
 
-	if strings.Contains(strings.Trim(address, " "), " ") {
+	// a single address with a display name, for example Build Bot <bot@example.com>, contains spaces
+	if strings.Contains(strings.Trim(address, " "), " ") && !strings.Contains(address, "<") {
 		return strings.Split(address, " ")
 	}
 	return []string{address}
@@ -1,14 +1,26 @@
 package email
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/kcloutie/event-reactor/pkg/config"
+	em "github.com/kcloutie/event-reactor/pkg/email"
 	"github.com/kcloutie/event-reactor/pkg/message"
 	"github.com/kcloutie/event-reactor/pkg/reactor"
+	testsmtp "github.com/kcloutie/event-reactor/pkg/test/smtp"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
 )
 
 func TestReactor_GetReactorConfig(t *testing.T) {
@@ -97,8 +109,8 @@ func TestReactor_GetProperties(t *testing.T) {
 		},
 		{
 			Name:        "password",
-			Description: "The password for the smtp server",
-			Required:    config.AsBoolPointer(true),
+			Description: "The password for the smtp server. Required by the plain, login and cram-md5 auth mechanisms",
+			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
 		{
@@ -137,11 +149,466 @@ func TestReactor_GetProperties(t *testing.T) {
 			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
+		{
+			Name:        "textBody",
+			Description: "The plain text body of the email, sent with the html body as multipart/alternative for clients that do not display html. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "cc",
+			Description: "The email address of the cc recipient(s). Multiple addresses can be separated by a comma, semicolon, or space",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "bcc",
+			Description: "The email address of the bcc recipient(s), which are not included in the headers of the email. Multiple addresses can be separated by a comma, semicolon, or space",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "replyTo",
+			Description: "The email address(es) replies are sent to. Multiple addresses can be separated by a comma, semicolon, or space",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "headers",
+			Description: "Custom headers added to the email, for example X-Priority. Headers set by the reactor such as Subject or To cannot be supplied. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "attachments",
+			Description: "A list of attachments. Each attachment has a filename, an optional contentType and exactly one of file (the path of a file), content (templated text) or payloadPath (the path of a base64 encoded field in the event). The filename, contentType, file and content fields support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "bodyFormat",
+			Description: "The format of the body property. Valid values are html and markdown. A markdown body is converted to html and, when the textBody property is not supplied, is also sent as the plain text body. Defaults to html",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "layout",
+			Description: "The name of the layout the html body is wrapped in, for example team-a for the team-a.html file of the layoutDirectory. The built-in default layout is used when the name is default and the directory does not contain a default.html file. The layout is a go template that can use the event data, .subject, .body, .values and the partials of the partials sub directory, for example {{ template \"header\" . }}",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "layoutDirectory",
+			Description: "The directory containing the layouts and the partials sub directory, for example a mounted configmap. Required when a layout other than default is used",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "layoutValues",
+			Description: "Values available to the layout as .values, for example the name, logo and colors of a team. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "inlineCss",
+			Description: "Set to false to keep the css of the style elements of the layout instead of moving it to the style attributes of the elements, which is required by clients such as Outlook. Defaults to true",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "tlsMode",
+			Description: "How the connection to the smtp server is encrypted. Valid values are none (unencrypted), opportunistic (the connection is upgraded using STARTTLS when the server supports it, otherwise the email is sent unencrypted), starttls (the connection is upgraded using STARTTLS, usually on port 587, and fails when the server does not support it) and implicit (tls from the start of the connection, usually on port 465). Defaults to opportunistic",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "caCertificates",
+			Description: "PEM encoded certificates of the certificate authorities trusted in addition to the system certificates, for example for an internal smtp relay",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "insecureSkipVerify",
+			Description: "Set to true to skip the verification of the certificate of the smtp server. This should only be used for lab servers. Defaults to false",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "authMechanism",
+			Description: "The smtp authentication mechanism. Valid values are none, plain, login, cram-md5 and xoauth2. The plain and login mechanisms require tls unless the smtp server is localhost. Defaults to xoauth2 when an oauth2 token is configured, plain when a password is supplied and none otherwise",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "username",
+			Description: "The username used to authenticate with the smtp server. Defaults to the address of the from property",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2Token",
+			Description: "The access token used by the xoauth2 auth mechanism",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2TokenUrl",
+			Description: "The token url used to get the access token of the xoauth2 auth mechanism. The refresh token flow is used when the oauth2RefreshToken property is supplied (for example for Gmail), otherwise the client credentials flow is used (for example for Microsoft 365). Tokens are cached until they expire",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2ClientId",
+			Description: "The client id used to get the access token of the xoauth2 auth mechanism",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2ClientSecret",
+			Description: "The client secret used to get the access token of the xoauth2 auth mechanism",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2RefreshToken",
+			Description: "The refresh token used to get the access token of the xoauth2 auth mechanism",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2Scopes",
+			Description: "The scopes requested when getting the access token of the xoauth2 auth mechanism",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeStringArray,
+		},
 	}
 	if got := r.GetProperties(); !reflect.DeepEqual(got, want) {
 		t.Errorf("Reactor.GetProperties() = %v, want %v", got, want)
 	}
 }
+func TestReactor_ProcessEvent(t *testing.T) {
+	server := testsmtp.NewServer(t)
+	attachmentFile := filepath.Join(t.TempDir(), "build.log")
+	err := os.WriteFile(attachmentFile, []byte("build log"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := New()
+	r.SetLogger(zaptest.NewLogger(t))
+	r.SetReactor(config.ReactorConfig{
+		Properties: map[string]config.PropertyAndValue{
+			"smtpHost":   {Value: server.Host},
+			"smtpPort":   {Value: strconv.Itoa(server.Port)},
+			"maxRetries": {Value: "1"},
+			"from":       {Value: "Build Bot <bot@example.com>"},
+			"password":   {Value: "password"},
+			"tlsMode":    {Value: "none"},
+			"to":         {Value: "to@example.com"},
+			"cc":         {Value: "cc1@example.com; cc2@example.com"},
+			"bcc":        {Value: "bcc@example.com"},
+			"replyTo":    {Value: "team@example.com"},
+			"subject":    {Value: "Build {{ .data.status }} für {{ .data.app }}"},
+			"body":       {Value: "<p>{{ .data.app }}</p>"},
+			"textBody":   {Value: "{{ .data.app }}"},
+			"headers":    {Value: map[string]interface{}{"X-App": "{{ .data.app }}"}},
+			"attachments": {Value: []interface{}{
+				map[string]interface{}{"filename": "{{ .data.app }}.txt", "content": "status: {{ .data.status }}"},
+				map[string]interface{}{"filename": "build.log", "file": attachmentFile},
+				map[string]interface{}{"filename": "results.bin", "payloadPath": "data.results"},
+			}},
+		},
+	})
+	err = r.ProcessEvent(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{
+		"app":     "app1",
+		"status":  "succeeded",
+		"results": base64.StdEncoding.EncodeToString([]byte("results")),
+	}})
+	if err != nil {
+		t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Reactor.ProcessEvent() messages = %v, want 1", len(messages))
+	}
+	if strings.Join(messages[0].To, ",") != "to@example.com,cc1@example.com,cc2@example.com,bcc@example.com" {
+		t.Errorf("Reactor.ProcessEvent() recipients = %v", messages[0].To)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(messages[0].Data))
+	if err != nil {
+		t.Fatalf("the message could not be parsed - %v", err)
+	}
+	subject, _ := (&mime.WordDecoder{}).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Build succeeded für app1" {
+		t.Errorf("Reactor.ProcessEvent() subject = %v", subject)
+	}
+	if msg.Header.Get("Cc") != "<cc1@example.com>, <cc2@example.com>" || msg.Header.Get("Reply-To") != "<team@example.com>" || msg.Header.Get("X-App") != "app1" {
+		t.Errorf("Reactor.ProcessEvent() headers = %v", msg.Header)
+	}
+	body, _ := io.ReadAll(msg.Body)
+	for _, want := range []string{"multipart/alternative", "<p>app1</p>", `filename=app1.txt`, base64.StdEncoding.EncodeToString([]byte("status: succeeded")), base64.StdEncoding.EncodeToString([]byte("build log")), base64.StdEncoding.EncodeToString([]byte("results"))} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Reactor.ProcessEvent() body does not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestReactor_GetReactorConfig_Transport(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties map[string]config.PropertyAndValue
+		want       em.EmailConfiguration
+		wantErr    string
+	}{
+		{
+			name: "defaults",
+			want: em.EmailConfiguration{TLSMode: em.TLSModeOpportunistic},
+		},
+		{
+			name: "implicit tls with login auth",
+			properties: map[string]config.PropertyAndValue{
+				"tlsMode":            {Value: "Implicit"},
+				"caCertificates":     {Value: "-----BEGIN CERTIFICATE-----"},
+				"insecureSkipVerify": {Value: "true"},
+				"authMechanism":      {Value: "LOGIN"},
+				"username":           {Value: "relay-user"},
+				"password":           {Value: "password"},
+			},
+			want: em.EmailConfiguration{TLSMode: em.TLSModeImplicit, CACertificates: "-----BEGIN CERTIFICATE-----", InsecureSkipVerify: true, AuthMechanism: em.AuthLogin, Username: "relay-user", Password: "password"},
+		},
+		{
+			name: "xoauth2 using a refresh token",
+			properties: map[string]config.PropertyAndValue{
+				"authMechanism":      {Value: "xoauth2"},
+				"oauth2TokenUrl":     {Value: "https://oauth2.example.com/token"},
+				"oauth2ClientId":     {Value: "id"},
+				"oauth2ClientSecret": {Value: "secret"},
+				"oauth2RefreshToken": {Value: "refresh"},
+				"oauth2Scopes":       {Value: []interface{}{"https://mail.example.com/"}},
+			},
+			want: em.EmailConfiguration{TLSMode: em.TLSModeOpportunistic, AuthMechanism: em.AuthXOAuth2, OAuth2: &em.OAuth2Config{TokenUrl: "https://oauth2.example.com/token", ClientId: "id", ClientSecret: "secret", RefreshToken: "refresh", Scopes: []string{"https://mail.example.com/"}}},
+		},
+		{
+			name:       "invalid tlsMode",
+			properties: map[string]config.PropertyAndValue{"tlsMode": {Value: "ssl"}},
+			wantErr:    "the tlsMode 'ssl' is not valid. Valid tlsModes are [none opportunistic starttls implicit]",
+		},
+		{
+			name:       "invalid insecureSkipVerify",
+			properties: map[string]config.PropertyAndValue{"insecureSkipVerify": {Value: "maybe"}},
+			wantErr:    "failed to convert the supplied insecureSkipVerify 'maybe' to a boolean. Error: strconv.ParseBool: parsing \"maybe\": invalid syntax",
+		},
+		{
+			name:       "invalid authMechanism",
+			properties: map[string]config.PropertyAndValue{"authMechanism": {Value: "ntlm"}},
+			wantErr:    "the authMechanism 'ntlm' is not valid. Valid authMechanisms are [none plain login cram-md5 xoauth2]",
+		},
+		{
+			name:       "missing password",
+			properties: map[string]config.PropertyAndValue{"authMechanism": {Value: "cram-md5"}},
+			wantErr:    "the password property was not supplied or was empty",
+		},
+		{
+			name:       "missing oauth2 token",
+			properties: map[string]config.PropertyAndValue{"authMechanism": {Value: "xoauth2"}},
+			wantErr:    "the oauth2Token or oauth2TokenUrl property must be supplied when the authMechanism is xoauth2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			properties := map[string]config.PropertyAndValue{
+				"smtpHost": {Value: "smtp.example.com"},
+				"smtpPort": {Value: "587"},
+				"from":     {Value: "from@example.com"},
+				"to":       {Value: "to@example.com"},
+				"subject":  {Value: "subject"},
+				"body":     {Value: "body"},
+			}
+			for k, val := range tt.properties {
+				properties[k] = val
+			}
+			r := New()
+			r.SetLogger(zaptest.NewLogger(t))
+			r.SetReactor(config.ReactorConfig{Properties: properties})
+			got, err := r.GetReactorConfig(context.Background(), &message.EventData{ID: "1"}, r.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			e := got.EmailCOnfig
+			if e.TLSMode != tt.want.TLSMode || e.CACertificates != tt.want.CACertificates || e.InsecureSkipVerify != tt.want.InsecureSkipVerify || e.AuthMechanism != tt.want.AuthMechanism || e.Username != tt.want.Username || e.Password != tt.want.Password || !reflect.DeepEqual(e.OAuth2, tt.want.OAuth2) {
+				t.Errorf("Reactor.GetReactorConfig() = %+v, want %+v", e, tt.want)
+			}
+		})
+	}
+}
+
+func TestReactor_GetReactorConfig_Layout(t *testing.T) {
+	layoutDirectory := t.TempDir()
+	err := os.MkdirAll(filepath.Join(layoutDirectory, "partials"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"team-a.html":          `<style>h1 { color: {{ .values.color }} }</style>{{ template "header" . }}{{ .body }}`,
+		"partials/header.html": `<p>{{ .values.team }} - {{ .subject }} - {{ .data.app }}</p>`,
+	} {
+		err = os.WriteFile(filepath.Join(layoutDirectory, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		properties   map[string]config.PropertyAndValue
+		wantHtmlBody string
+		wantTextBody string
+		wantErr      string
+	}{
+		{
+			name:         "markdown body",
+			properties:   map[string]config.PropertyAndValue{"body": {Value: "# {{ .data.app }}"}, "bodyFormat": {Value: "Markdown"}},
+			wantHtmlBody: "<h1>app1</h1>\n",
+			wantTextBody: "# app1",
+		},
+		{
+			name:         "markdown body with a text body",
+			properties:   map[string]config.PropertyAndValue{"body": {Value: "**done**"}, "bodyFormat": {Value: "markdown"}, "textBody": {Value: "done"}},
+			wantHtmlBody: "<p><strong>done</strong></p>\n",
+			wantTextBody: "done",
+		},
+		{
+			name: "markdown body in a layout",
+			properties: map[string]config.PropertyAndValue{
+				"body":            {Value: "# {{ .data.app }}"},
+				"bodyFormat":      {Value: "markdown"},
+				"layout":          {Value: "team-a"},
+				"layoutDirectory": {Value: layoutDirectory},
+				"layoutValues":    {Value: map[string]interface{}{"team": "Team A", "color": "{{ .data.color }}"}},
+			},
+			wantHtmlBody: `<html><head></head><body><p>Team A - Build - app1</p><h1 style="color: red">app1</h1>` + "\n</body></html>",
+			wantTextBody: "# app1",
+		},
+		{
+			name: "layout without inlining",
+			properties: map[string]config.PropertyAndValue{
+				"layout":          {Value: "team-a"},
+				"layoutDirectory": {Value: layoutDirectory},
+				"layoutValues":    {Value: map[string]interface{}{"team": "Team A", "color": "blue"}},
+				"inlineCss":       {Value: "false"},
+			},
+			wantHtmlBody: `<style>h1 { color: blue }</style><p>Team A - Build - app1</p><p>body</p>`,
+		},
+		{
+			name:       "invalid bodyFormat",
+			properties: map[string]config.PropertyAndValue{"bodyFormat": {Value: "rst"}},
+			wantErr:    "the bodyFormat 'rst' is not valid. Valid bodyFormats are [html markdown]",
+		},
+		{
+			name:       "missing layoutDirectory",
+			properties: map[string]config.PropertyAndValue{"layout": {Value: "team-a"}},
+			wantErr:    "the layoutDirectory property must be supplied when the layout 'team-a' is used",
+		},
+		{
+			name:       "missing layout",
+			properties: map[string]config.PropertyAndValue{"layout": {Value: "team-b"}, "layoutDirectory": {Value: layoutDirectory}},
+			wantErr:    "the email layout 'team-b' does not exist. Valid layouts are [default team-a]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			properties := map[string]config.PropertyAndValue{
+				"smtpHost": {Value: "smtp.example.com"},
+				"smtpPort": {Value: "587"},
+				"from":     {Value: "from@example.com"},
+				"to":       {Value: "to@example.com"},
+				"subject":  {Value: "Build"},
+				"body":     {Value: "<p>body</p>"},
+			}
+			for k, val := range tt.properties {
+				properties[k] = val
+			}
+			r := New()
+			r.SetLogger(zaptest.NewLogger(t))
+			r.SetReactor(config.ReactorConfig{Properties: properties})
+			got, err := r.GetReactorConfig(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{"app": "app1", "color": "red"}}, r.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.EmailCOnfig.HtmlBody != tt.wantHtmlBody {
+				t.Errorf("Reactor.GetReactorConfig() HtmlBody = %q, want %q", got.EmailCOnfig.HtmlBody, tt.wantHtmlBody)
+			}
+			if got.EmailCOnfig.TextBody != tt.wantTextBody {
+				t.Errorf("Reactor.GetReactorConfig() TextBody = %q, want %q", got.EmailCOnfig.TextBody, tt.wantTextBody)
+			}
+		})
+	}
+}
+
+func TestReactor_GetReactorConfig_Attachments(t *testing.T) {
+	properties := func(attachments []interface{}) map[string]config.PropertyAndValue {
+		return map[string]config.PropertyAndValue{
+			"smtpHost":    {Value: "smtp.example.com"},
+			"smtpPort":    {Value: "587"},
+			"from":        {Value: "from@example.com"},
+			"to":          {Value: "to@example.com"},
+			"password":    {Value: "password"},
+			"subject":     {Value: "subject"},
+			"body":        {Value: "body"},
+			"attachments": {Value: attachments},
+		}
+	}
+	tests := []struct {
+		name        string
+		attachments []interface{}
+		wantErr     string
+	}{
+		{
+			name:        "missing filename",
+			attachments: []interface{}{map[string]interface{}{"content": "a"}},
+			wantErr:     "the filename of attachment 0 was not supplied or was empty",
+		},
+		{
+			name:        "more than one source",
+			attachments: []interface{}{map[string]interface{}{"filename": "a.txt", "content": "a", "file": "a.txt"}},
+			wantErr:     "exactly one of the file, content or payloadPath fields must be supplied for the attachment 'a.txt'",
+		},
+		{
+			name:        "invalid base64",
+			attachments: []interface{}{map[string]interface{}{"filename": "a.bin", "payloadPath": "data.results"}},
+			wantErr:     "failed to decode the base64 content of the attachment 'a.bin' from the 'data.results' payload path - illegal base64 data at input byte 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New()
+			r.SetLogger(zaptest.NewLogger(t))
+			r.SetReactor(config.ReactorConfig{Properties: properties(tt.attachments)})
+			got, err := r.GetReactorConfig(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{"results": "not base64"}}, r.Log)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != nil {
+				t.Errorf("Reactor.GetReactorConfig() = %v, want nil", got)
+			}
+		})
+	}
+}
+
 func Test_splitEmailAddress(t *testing.T) {
 	type args struct {
 		address string
@@ -172,6 +639,13 @@ func Test_splitEmailAddress(t *testing.T) {
 			},
 			want: []string{"test1@test.com", "test2@test.com", "test3@test.com"},
 		},
+		{
+			name: "Display name",
+			args: args{
+				address: "Build Bot <bot@test.com>",
+			},
+			want: []string{"Build Bot <bot@test.com>"},
+		},
 		{
 			name: "No delimiters",
 			args: args{
@@ -2,9 +2,13 @@ package gcppublishpubsub
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
+	"cloud.google.com/go/pubsub"
 	"github.com/kcloutie/event-reactor/pkg/config"
 	"github.com/kcloutie/event-reactor/pkg/gcp"
 	"github.com/kcloutie/event-reactor/pkg/message"
@@ -12,6 +16,7 @@ import (
 	"github.com/kcloutie/event-reactor/pkg/template"
 
 	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
 )
 
 var _ reactor.ReactorInterface = (*Reactor)(nil)
@@ -20,18 +25,25 @@ type Reactor struct {
 	Log           *zap.Logger
 	reactorName   string
 	reactorConfig config.ReactorConfig
+	publisher     *gcp.Publisher
 }
 
 type ReactorConfig struct {
-	Project    string
-	TopicId    string
-	Payload    string
-	Attributes map[string]string
+	Project        string
+	Topics         []string
+	Payload        string
+	ForwardPayload bool
+	Attributes     map[string]string
+	OrderingKey    string
+	Endpoint       string
+	EmulatorHost   string
+	Settings       gcp.PublishSettings
 }
 
 func New() *Reactor {
 	return &Reactor{
 		reactorName: "gcp/pubsub/publish/message",
+		publisher:   gcp.DefaultPublisher(),
 	}
 }
 
@@ -43,7 +55,7 @@ func (v *Reactor) GetName() string {
 }
 
 func (v *Reactor) GetDescription() string {
-	return "This reactor is used to publish a message to a GCP Pub/Sub topic. The message is published to the topic using the GCP SDK. The payload can be a Go template. The Go template can use the data, attributes, and id properties of the event data. In addition the attributes key value pairs can be Go templates."
+	return "This reactor is used to publish a message to one or more GCP Pub/Sub topics. The message is published to the topics using the GCP SDK, the clients are cached per project and the messages of concurrent events are published in batches using the batching and flow control settings. The payload can be a Go template, or the original payload of the event can be forwarded unchanged. The Go template can use the data, attributes, and id properties of the event data. In addition the attributes key value pairs and the ordering key can be Go templates. A pub/sub emulator can be used by supplying the emulatorHost property."
 }
 
 func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
@@ -69,6 +81,25 @@ func (v *Reactor) GetConfigExample() string {
         eventType: "REDEPLOY_APP"
         dataFormat: "JSON_API_V1"
         secretId: "{{ .attributes.secretId }}"
+- name: test_gcpForwardPubSub
+  celExpressionFilter: attributes.type == 'deploy'
+  disabled: false
+  type: gcp/pubsub/publish/message
+  properties:
+    project:
+      value: some-project
+    topicIds:
+      value:
+      - deploys-audit
+      - projects/other-project/topics/deploys
+    forwardPayload:
+      value: "true"
+    orderingKey:
+      value: "{{ .data.app }}"
+    batchDelayThresholdMs:
+      value: "50"
+    maxOutstandingMessages:
+      value: "1000"
 `
 }
 
@@ -79,19 +110,49 @@ func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) err
 		return err
 	}
 
-	templateConfig := template.NewRenderTemplateOptions()
-	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
-
 	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
 	if err != nil {
 		return err
 	}
 
-	messageId, err := gcp.PublishEvent(reactorConfig.Project, reactorConfig.TopicId, []byte(reactorConfig.Payload), reactorConfig.Attributes)
-	if err != nil {
-		return err
+	// the message is added to the batch of every topic before waiting, so the topics are published to concurrently
+	type pending struct {
+		topic  string
+		result *pubsub.PublishResult
+		t      *pubsub.Topic
+	}
+	published := []pending{}
+	errs := []string{}
+	for _, topic := range reactorConfig.Topics {
+		result, t, err := v.publisher.PublishAsync(ctx, gcp.PublishMessage{
+			PubSubConnection: gcp.PubSubConnection{
+				Project:      reactorConfig.Project,
+				Endpoint:     reactorConfig.Endpoint,
+				EmulatorHost: reactorConfig.EmulatorHost,
+			},
+			Topic:       topic,
+			Data:        []byte(reactorConfig.Payload),
+			Attributes:  reactorConfig.Attributes,
+			OrderingKey: reactorConfig.OrderingKey,
+			Settings:    reactorConfig.Settings,
+		})
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		published = append(published, pending{topic: topic, result: result, t: t})
+	}
+	for _, p := range published {
+		messageId, err := v.publisher.Wait(ctx, p.result, p.t, reactorConfig.OrderingKey)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		v.Log.Info("Successfully published message to pub/sub", zap.String("messageId", messageId), zap.String("topicId", p.topic), zap.String("project", reactorConfig.Project), zap.String("orderingKey", reactorConfig.OrderingKey))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to publish the message to %d of the %d pub/sub topics - %s", len(errs), len(reactorConfig.Topics), strings.Join(errs, "; "))
 	}
-	v.Log.Info("Successfully published message to pub/sub", zap.String("messageId", messageId), zap.String("topicId", reactorConfig.TopicId), zap.String("project", reactorConfig.Project))
 
 	return nil
 }
@@ -103,6 +164,24 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 	templateConfig := template.NewRenderTemplateOptions()
 	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
 
+	render := func(name string, value string) (string, error) {
+		return reactor.RenderTemplateValue(ctx, value, name, data, v.reactorName, templateConfig)
+	}
+	getInt := func(name string) (int, error) {
+		value, err := v.reactorConfig.Properties[name].GetStringValue(ctx, v.Log, data)
+		if err != nil {
+			return 0, err
+		}
+		if value == "" {
+			return 0, nil
+		}
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("failed to convert the supplied %s '%v' to an integer. Error: %v", name, value, err)
+		}
+		return i, nil
+	}
+
 	// ===================================================================================
 	// Get Project
 	// ===================================================================================
@@ -110,26 +189,55 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 	if err != nil {
 		return nil, err
 	}
-	if project == "" {
-		return nil, fmt.Errorf("the project property was not supplied or was empty")
-	}
 	config.Project = project
 
 	// ===================================================================================
-	// Get Topic Id
+	// Get Topics
 	// ===================================================================================
 	topicId, err := v.reactorConfig.Properties["topicId"].GetStringValue(ctx, v.Log, data)
 	if err != nil {
 		return nil, err
 	}
-	if topicId == "" {
-		return nil, fmt.Errorf("the topicId property was not supplied or was empty")
+	topicIds, err := v.reactorConfig.Properties["topicIds"].GetStringArrayValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if topicId != "" {
+		topicIds = append([]string{topicId}, topicIds...)
+	}
+	for i, topic := range topicIds {
+		rendered, err := render(fmt.Sprintf("topicIds/%d", i), topic)
+		if err != nil {
+			return nil, err
+		}
+		rendered = strings.TrimSpace(rendered)
+		if rendered == "" {
+			continue
+		}
+		_, _, err = gcp.ParseTopicName(rendered, project)
+		if err != nil {
+			return nil, err
+		}
+		config.Topics = append(config.Topics, rendered)
+	}
+	if len(config.Topics) == 0 {
+		return nil, fmt.Errorf("the topicId property was not supplied or was empty. Supply either the topicId or topicIds property")
 	}
-	config.TopicId = topicId
 
 	// ===================================================================================
 	// Get Payload
 	// ===================================================================================
+	forwardPayload, err := v.reactorConfig.Properties["forwardPayload"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if forwardPayload != "" {
+		config.ForwardPayload, err = strconv.ParseBool(forwardPayload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied forwardPayload '%v' to a boolean. Error: %v", forwardPayload, err)
+		}
+	}
+
 	payload, err := v.reactorConfig.Properties["payload"].GetStringValue(ctx, v.Log, data)
 	if err != nil {
 		return nil, err
@@ -137,14 +245,21 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 	// if payload == "" {
 	// 	return nil, fmt.Errorf("the payload property was not supplied or was empty")
 	// }
-	renderedPayload := []byte(payload)
-	if strings.Contains(payload, "{{") {
-		renderedPayload, err = template.RenderTemplateValues(ctx, payload, fmt.Sprintf("%s_%s/payload", data.ID, v.reactorName), data.AsMap(), []string{}, templateConfig)
+	if config.ForwardPayload {
+		if payload != "" {
+			return nil, fmt.Errorf("only one of the payload or forwardPayload properties can be supplied")
+		}
+		original, err := originalPayload(data)
+		if err != nil {
+			return nil, err
+		}
+		config.Payload = string(original)
+	} else {
+		config.Payload, err = render("payload", payload)
 		if err != nil {
 			return nil, err
 		}
 	}
-	config.Payload = string(renderedPayload)
 
 	// ===================================================================================
 	// Get Attributes
@@ -163,37 +278,207 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 		attributes[k] = string(renderedAttributeVal)
 
 	}
+	if config.ForwardPayload {
+		// the original attributes are forwarded, without the attributes added by the pub/sub listener, and the attributes
+		// property is used to add or replace attributes
+		forwarded := map[string]string{}
+		for k, val := range data.Attributes {
+			if !slices.Contains(listenerAttributes, k) {
+				forwarded[k] = val
+			}
+		}
+		for k, val := range attributes {
+			forwarded[k] = val
+		}
+		attributes = forwarded
+	}
 	config.Attributes = attributes
 
+	// ===================================================================================
+	// Get Ordering Key
+	// ===================================================================================
+	orderingKey, err := v.reactorConfig.Properties["orderingKey"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.OrderingKey, err = render("orderingKey", orderingKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := v.reactorConfig.Properties["orderingKey"]; !exists && config.ForwardPayload {
+		config.OrderingKey = data.Attributes[message.OrderingKeyAttribute]
+	}
+	config.OrderingKey = strings.TrimSpace(config.OrderingKey)
+	config.Settings.EnableMessageOrdering = config.OrderingKey != ""
+
+	// ===================================================================================
+	// Get Endpoint and Emulator Host
+	// ===================================================================================
+	config.Endpoint, err = v.reactorConfig.Properties["endpoint"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.EmulatorHost, err = v.reactorConfig.Properties["emulatorHost"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if config.Endpoint != "" && config.EmulatorHost != "" {
+		return nil, fmt.Errorf("only one of the endpoint or emulatorHost properties can be supplied")
+	}
+
+	// ===================================================================================
+	// Get Batching and Flow Control Settings
+	// ===================================================================================
+	delayThresholdMs, err := getInt("batchDelayThresholdMs")
+	if err != nil {
+		return nil, err
+	}
+	config.Settings.DelayThreshold = time.Duration(delayThresholdMs) * time.Millisecond
+	config.Settings.CountThreshold, err = getInt("batchCountThreshold")
+	if err != nil {
+		return nil, err
+	}
+	config.Settings.ByteThreshold, err = getInt("batchByteThreshold")
+	if err != nil {
+		return nil, err
+	}
+	config.Settings.MaxOutstandingMessages, err = getInt("maxOutstandingMessages")
+	if err != nil {
+		return nil, err
+	}
+	config.Settings.MaxOutstandingBytes, err = getInt("maxOutstandingBytes")
+	if err != nil {
+		return nil, err
+	}
+	limitExceededBehavior, err := v.reactorConfig.Properties["limitExceededBehavior"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if limitExceededBehavior != "" && !containsFold(gcp.LimitExceededBehaviors, limitExceededBehavior) {
+		return nil, fmt.Errorf("the limitExceededBehavior '%s' is not valid. Valid behaviors are %v", limitExceededBehavior, gcp.LimitExceededBehaviors)
+	}
+	config.Settings.LimitExceededBehavior = limitExceededBehavior
+
 	return config, nil
 }
 
+// listenerAttributes are the attributes added to the event by the pub/sub listener, which are not forwarded
+var listenerAttributes = []string{message.PublishTimeAttribute, message.OrderingKeyAttribute, message.SubscriptionAttribute, message.DeliveryAttemptAttribute}
+
+// originalPayload returns the raw bytes of the event, which are available for events received by the pub/sub listener,
+// otherwise the data of the event is marshalled into json
+func originalPayload(data *message.EventData) ([]byte, error) {
+	if raw, ok := data.Data[message.RawDataKey].([]byte); ok {
+		return raw, nil
+	}
+	payload, err := json.Marshal(data.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the data of the event into json - %v", err)
+	}
+	return payload, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
 func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
 	return []config.ReactorConfigProperty{
 		{
 			Name:        "project",
-			Description: "The GCP project id where the pub/sub topic is located",
-			Required:    config.AsBoolPointer(true),
+			Description: "The GCP project id where the pub/sub topic is located. Required unless every topic is a full topic name",
+			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
 		{
 			Name:        "topicId",
-			Description: "The id of the pub/sub topic",
-			Required:    config.AsBoolPointer(true),
+			Description: "The id of the pub/sub topic, or the full name of the topic such as projects/my-project/topics/my-topic. Either the topicId or topicIds property must be supplied. Supports Go templates",
+			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
+		{
+			Name:        "topicIds",
+			Description: "A list of topic ids or full topic names the message is published to. Supports Go templates",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeStringArray,
+		},
 		{
 			Name:        "payload",
 			Description: "The payload to publish to the pub/sub topic. This should be json and supports Go templates",
 			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
+		{
+			Name:        "forwardPayload",
+			Description: "When true, the original payload of the event is published unchanged instead of the payload property. The raw bytes of events received by the pub/sub listener are forwarded, other events are marshalled into json. The original attributes and ordering key are forwarded too. Defaults to false",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
 		{
 			Name:        "attributes",
 			Description: "The attributes to publish to the pub/sub topic. This should be a map of key value pairs and supports Go templates",
 			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeMapString,
 		},
+		{
+			Name:        "orderingKey",
+			Description: "The ordering key of the message. Messages with the same ordering key are delivered in the order they are published to subscriptions with message ordering enabled. Supports Go templates",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "endpoint",
+			Description: "The pub/sub endpoint, for example the regional endpoint us-east1-pubsub.googleapis.com:443 which is recommended when using ordering keys",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "emulatorHost",
+			Description: "The host:port of a pub/sub emulator, the same as the PUBSUB_EMULATOR_HOST environment variable. The connection is not encrypted or authenticated",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "batchDelayThresholdMs",
+			Description: "The number of milliseconds a batch of messages is held before it is published. Defaults to 10",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "batchCountThreshold",
+			Description: "A batch is published when it has this many messages. Defaults to 100",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "batchByteThreshold",
+			Description: "A batch is published when its size in bytes reaches this value. Defaults to 1000000",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "maxOutstandingMessages",
+			Description: "The maximum number of messages waiting to be published before the limitExceededBehavior applies",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "maxOutstandingBytes",
+			Description: "The maximum number of bytes waiting to be published before the limitExceededBehavior applies",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "limitExceededBehavior",
+			Description: "What happens when the outstanding limits are exceeded. One of block (wait until the messages are published), ignore (flow control is disabled) or signalError (the publish fails). Defaults to block when one of the outstanding limits is supplied",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
 	}
 }
 
@@ -0,0 +1,333 @@
+package gcppublishpubsub
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"cloud.google.com/go/pubsub/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/gcp"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap"
+)
+
+type publishedMessage struct {
+	Topic       string
+	Data        string
+	Attributes  map[string]string
+	OrderingKey string
+}
+
+// newPubSubServer starts a pstest server with a subscription for each of the topics, the returned function pulls the
+// messages published to the topics
+func newPubSubServer(t *testing.T, topics ...string) (*pstest.Server, func() []publishedMessage) {
+	srv := gcp.NewFakePubSubServer(t, topics...)
+	ctx := context.Background()
+	for _, topic := range topics {
+		_, err := srv.GServer.CreateSubscription(ctx, &pubsubpb.Subscription{Name: topic + "-sub", Topic: topic, EnableMessageOrdering: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return srv, func() []publishedMessage {
+		messages := []publishedMessage{}
+		for _, topic := range topics {
+			resp, err := srv.GServer.Pull(ctx, &pubsubpb.PullRequest{Subscription: topic + "-sub", MaxMessages: 100})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range resp.ReceivedMessages {
+				messages = append(messages, publishedMessage{Topic: topic, Data: string(m.Message.Data), Attributes: m.Message.Attributes, OrderingKey: m.Message.OrderingKey})
+			}
+		}
+		return messages
+	}
+}
+
+func TestReactor_ProcessEvent(t *testing.T) {
+	pubsubEnvelope := map[string]interface{}{
+		"subscription": "projects/proj1/subscriptions/incoming",
+		"message": map[string]interface{}{
+			"attributes":  map[string]interface{}{"type": "deploy"},
+			"data":        []byte(`{"app":"app1",  "status":"started"}`),
+			"messageId":   "1",
+			"orderingKey": "app1",
+		},
+	}
+	pubsubEvent, err := message.PubSubMessageToEventData(pubsubEnvelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genericEvent := &message.EventData{
+		ID:         "2",
+		Data:       map[string]interface{}{"app": "app2", "status": "finished"},
+		Attributes: map[string]string{"type": "deploy"},
+	}
+
+	tests := []struct {
+		name       string
+		event      *message.EventData
+		properties map[string]config.PropertyAndValue
+		want       []publishedMessage
+		wantErr    string
+	}{
+		{
+			name:  "templated payload to multiple topics",
+			event: genericEvent,
+			properties: map[string]config.PropertyAndValue{
+				"project":     {Value: "proj1"},
+				"topicId":     {Value: "deploys"},
+				"topicIds":    {Value: []interface{}{"projects/proj2/topics/{{ .data.app }}"}},
+				"payload":     {Value: `{"app":"{{ .data.app }}"}`},
+				"attributes":  {Value: map[string]interface{}{"status": "{{ .data.status }}"}},
+				"orderingKey": {Value: "{{ .data.app }}"},
+			},
+			want: []publishedMessage{
+				{Topic: "projects/proj1/topics/deploys", Data: `{"app":"app2"}`, Attributes: map[string]string{"status": "finished"}, OrderingKey: "app2"},
+				{Topic: "projects/proj2/topics/app2", Data: `{"app":"app2"}`, Attributes: map[string]string{"status": "finished"}, OrderingKey: "app2"},
+			},
+		},
+		{
+			name:  "forward pub/sub payload",
+			event: &pubsubEvent,
+			properties: map[string]config.PropertyAndValue{
+				"project":        {Value: "proj1"},
+				"topicId":        {Value: "deploys"},
+				"forwardPayload": {Value: "true"},
+				"attributes":     {Value: map[string]interface{}{"forwarded": "true"}},
+			},
+			want: []publishedMessage{
+				{Topic: "projects/proj1/topics/deploys", Data: `{"app":"app1",  "status":"started"}`, Attributes: map[string]string{"type": "deploy", "forwarded": "true"}, OrderingKey: "app1"},
+			},
+		},
+		{
+			name:  "forward generic payload",
+			event: genericEvent,
+			properties: map[string]config.PropertyAndValue{
+				"project":               {Value: "proj1"},
+				"topicId":               {Value: "deploys"},
+				"forwardPayload":        {Value: "true"},
+				"batchDelayThresholdMs": {Value: "1"},
+				"batchCountThreshold":   {Value: "1"},
+			},
+			want: []publishedMessage{
+				{Topic: "projects/proj1/topics/deploys", Data: `{"app":"app2","status":"finished"}`, Attributes: map[string]string{"type": "deploy"}},
+			},
+		},
+		{
+			name:  "missing topic",
+			event: genericEvent,
+			properties: map[string]config.PropertyAndValue{
+				"project":  {Value: "proj1"},
+				"topicIds": {Value: []interface{}{"deploys", "dude"}},
+				"payload":  {Value: "payload"},
+			},
+			want: []publishedMessage{
+				{Topic: "projects/proj1/topics/deploys", Data: "payload"},
+			},
+			wantErr: "failed to publish the message to 1 of the 2 pub/sub topics - failed to publish the message to the pub/sub topic 'projects/proj1/topics/dude' - rpc error: code = NotFound desc = topic \"projects/proj1/topics/dude\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, getMessages := newPubSubServer(t, "projects/proj1/topics/deploys", "projects/proj2/topics/app2")
+			publisher := gcp.NewPublisher()
+			defer publisher.Close()
+
+			properties := map[string]config.PropertyAndValue{"emulatorHost": {Value: srv.Addr}}
+			for k, p := range tt.properties {
+				properties[k] = p
+			}
+			v := New()
+			v.publisher = publisher
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: properties})
+			err := v.ProcessEvent(context.Background(), tt.event)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.ProcessEvent() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			} else if tt.wantErr != "" {
+				t.Errorf("Reactor.ProcessEvent() error = nil, wantErr %v", tt.wantErr)
+			}
+
+			got := getMessages()
+			sort.Slice(got, func(i, j int) bool { return got[i].Topic < got[j].Topic })
+			for i := range got {
+				if len(got[i].Attributes) == 0 {
+					got[i].Attributes = nil
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reactor.ProcessEvent() messages = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReactor_ProcessEvent_Ordering(t *testing.T) {
+	srv := gcp.NewFakePubSubServer(t, "projects/proj1/topics/deploys")
+	publisher := gcp.NewPublisher()
+	defer publisher.Close()
+
+	for i := 0; i < 5; i++ {
+		v := New()
+		v.publisher = publisher
+		v.SetLogger(zap.NewNop())
+		v.SetReactor(config.ReactorConfig{Properties: map[string]config.PropertyAndValue{
+			"project":      {Value: "proj1"},
+			"topicId":      {Value: "deploys"},
+			"emulatorHost": {Value: srv.Addr},
+			"payload":      {Value: "{{ .data.step }}"},
+			"orderingKey":  {Value: "{{ .data.app }}"},
+		}})
+		err := v.ProcessEvent(context.Background(), &message.EventData{ID: fmt.Sprintf("%d", i), Data: map[string]interface{}{"app": "app1", "step": fmt.Sprintf("%d", i)}, Attributes: map[string]string{}})
+		if err != nil {
+			t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+		}
+	}
+
+	// the server returns the messages in the order they were published
+	got := []string{}
+	for _, m := range srv.Messages() {
+		if m.OrderingKey != "app1" {
+			t.Errorf("Reactor.ProcessEvent() ordering key = %v, want app1", m.OrderingKey)
+		}
+		got = append(got, string(m.Data))
+	}
+	want := []string{"0", "1", "2", "3", "4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reactor.ProcessEvent() messages = %v, want %v", got, want)
+	}
+}
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	data := &message.EventData{
+		ID:         "1",
+		Data:       map[string]interface{}{"app": "app1"},
+		Attributes: map[string]string{},
+	}
+	tests := []struct {
+		name       string
+		properties map[string]config.PropertyAndValue
+		want       *ReactorConfig
+		wantErr    string
+	}{
+		{
+			name: "settings",
+			properties: map[string]config.PropertyAndValue{
+				"topicId":                {Value: "projects/proj1/topics/deploys"},
+				"payload":                {Value: "payload"},
+				"orderingKey":            {Value: " {{ .data.app }} "},
+				"endpoint":               {Value: "us-east1-pubsub.googleapis.com:443"},
+				"batchDelayThresholdMs":  {Value: "50"},
+				"batchCountThreshold":    {Value: "10"},
+				"batchByteThreshold":     {Value: "1000"},
+				"maxOutstandingMessages": {Value: "100"},
+				"maxOutstandingBytes":    {Value: "10000"},
+				"limitExceededBehavior":  {Value: "signalError"},
+			},
+			want: &ReactorConfig{
+				Topics:      []string{"projects/proj1/topics/deploys"},
+				Payload:     "payload",
+				Attributes:  map[string]string{},
+				OrderingKey: "app1",
+				Endpoint:    "us-east1-pubsub.googleapis.com:443",
+				Settings: gcp.PublishSettings{
+					DelayThreshold:         50000000,
+					CountThreshold:         10,
+					ByteThreshold:          1000,
+					MaxOutstandingMessages: 100,
+					MaxOutstandingBytes:    10000,
+					LimitExceededBehavior:  "signalError",
+					EnableMessageOrdering:  true,
+				},
+			},
+		},
+		{
+			name: "no topic",
+			properties: map[string]config.PropertyAndValue{
+				"project": {Value: "proj1"},
+			},
+			wantErr: "the topicId property was not supplied or was empty. Supply either the topicId or topicIds property",
+		},
+		{
+			name: "no project",
+			properties: map[string]config.PropertyAndValue{
+				"topicId": {Value: "deploys"},
+			},
+			wantErr: "the project of the pub/sub topic 'deploys' was not supplied",
+		},
+		{
+			name: "payload and forwardPayload",
+			properties: map[string]config.PropertyAndValue{
+				"project":        {Value: "proj1"},
+				"topicId":        {Value: "deploys"},
+				"payload":        {Value: "payload"},
+				"forwardPayload": {Value: "true"},
+			},
+			wantErr: "only one of the payload or forwardPayload properties can be supplied",
+		},
+		{
+			name: "invalid forwardPayload",
+			properties: map[string]config.PropertyAndValue{
+				"project":        {Value: "proj1"},
+				"topicId":        {Value: "deploys"},
+				"forwardPayload": {Value: "dude"},
+			},
+			wantErr: "failed to convert the supplied forwardPayload 'dude' to a boolean. Error: strconv.ParseBool: parsing \"dude\": invalid syntax",
+		},
+		{
+			name: "invalid batchCountThreshold",
+			properties: map[string]config.PropertyAndValue{
+				"project":             {Value: "proj1"},
+				"topicId":             {Value: "deploys"},
+				"batchCountThreshold": {Value: "dude"},
+			},
+			wantErr: "failed to convert the supplied batchCountThreshold 'dude' to an integer. Error: strconv.Atoi: parsing \"dude\": invalid syntax",
+		},
+		{
+			name: "invalid limitExceededBehavior",
+			properties: map[string]config.PropertyAndValue{
+				"project":               {Value: "proj1"},
+				"topicId":               {Value: "deploys"},
+				"limitExceededBehavior": {Value: "dude"},
+			},
+			wantErr: "the limitExceededBehavior 'dude' is not valid. Valid behaviors are [block ignore signalError]",
+		},
+		{
+			name: "endpoint and emulatorHost",
+			properties: map[string]config.PropertyAndValue{
+				"project":      {Value: "proj1"},
+				"topicId":      {Value: "deploys"},
+				"endpoint":     {Value: "us-east1-pubsub.googleapis.com:443"},
+				"emulatorHost": {Value: "localhost:8085"},
+			},
+			wantErr: "only one of the endpoint or emulatorHost properties can be supplied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			got, err := v.GetReactorConfig(context.Background(), data, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reactor.GetReactorConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,22 +2,55 @@ package gcprotaterandom
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	"github.com/kcloutie/event-reactor/pkg/config"
 	"github.com/kcloutie/event-reactor/pkg/gcp"
 	"github.com/kcloutie/event-reactor/pkg/message"
 	"github.com/kcloutie/event-reactor/pkg/password"
 	"github.com/kcloutie/event-reactor/pkg/reactor"
 	"github.com/kcloutie/event-reactor/pkg/template"
+	uuid "github.com/satori/go.uuid"
 
 	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
 )
 
 var _ reactor.ReactorInterface = (*Reactor)(nil)
 
+const (
+	FormatJson = "json"
+	FormatYaml = "yaml"
+
+	GeneratorPassword = "password"
+	GeneratorUuid     = "uuid"
+	GeneratorHex      = "hex"
+	GeneratorBase64   = "base64"
+	GeneratorKeep     = "keep"
+	GeneratorValue    = "value"
+
+	OldVersionActionDisable = "disable"
+	OldVersionActionDestroy = "destroy"
+
+	defaultRandomBytesLength = 32
+)
+
+var (
+	Formats           = []string{FormatJson, FormatYaml}
+	Generators        = []string{GeneratorPassword, GeneratorUuid, GeneratorHex, GeneratorBase64, GeneratorKeep, GeneratorValue}
+	OldVersionActions = []string{OldVersionActionDisable, OldVersionActionDestroy}
+
+	fieldSettings = []string{"generator", "length", "useLowerCase", "useUpperCase", "useSpecial", "useNumbers", "specialCharOverride", "value"}
+)
+
 type Reactor struct {
 	Log           *zap.Logger
 	reactorName   string
@@ -34,6 +67,28 @@ type ReactorConfig struct {
 	SpecialCharOverride    string
 	GcpProject             string
 	GcpSecretName          string
+	// Format and Fields are supplied to generate a structured json or yaml secret instead of a single random value
+	Format string
+	Fields map[string]SecretField
+	// OldVersionAction is applied to the versions that are not one of the newest KeepVersions versions and, when a
+	// GracePeriod is supplied, were replaced by a newer version for longer than the grace period
+	OldVersionAction string
+	KeepVersions     int
+	GracePeriod      time.Duration
+}
+
+// SecretField is a field of a structured secret. The length of a password is the number of characters, the length of
+// hex and base64 values is the number of random bytes. The value is used by the value generator, and by the keep
+// generator when the previous version of the secret does not contain the field
+type SecretField struct {
+	Generator           string
+	Length              int
+	UseLowerCase        bool
+	UseUpperCase        bool
+	UseSpecial          bool
+	UseNumbers          bool
+	SpecialCharOverride string
+	Value               string
 }
 
 func New() *Reactor {
@@ -50,7 +105,7 @@ func (v *Reactor) GetName() string {
 }
 
 func (v *Reactor) GetDescription() string {
-	return "This reactor is used to rotate a GCP secret with a random value. The random value is generated using the crypto/rand package. The random value is then used to update the secret in GCP Secret Manager."
+	return "This reactor is used to rotate a GCP secret with a random value. The random value is then used to update the secret in GCP Secret Manager. A structured json or yaml secret can be generated using the fields property, where each field is a generated password, a UUID, random hex or base64 bytes, a Go template value, or is kept from the previous version of the secret. After the new version is added the older versions can be disabled or destroyed, keeping the newest versions and optionally only once a grace period has passed since they were replaced."
 }
 
 func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
@@ -76,7 +131,40 @@ properties:
   secretFullName:
     payloadValue:
       propertyPaths:
-      - data.name`
+      - data.name
+- name: test_gcpRotateStructured
+  celExpressionFilter: has(attributes.eventType) && attributes['eventType'] == 'SECRET_ROTATE'
+  type: gcp/secret/rotate/random
+  properties:
+    secretFullName:
+      payloadValue:
+        propertyPaths:
+        - data.name
+    format:
+      value: json
+    fields:
+      value:
+        username:
+          generator: keep
+          value: app-user
+        password:
+          generator: password
+          length: 32
+          useSpecial: false
+        apiKey:
+          generator: uuid
+        signingKey:
+          generator: base64
+          length: 64
+        rotatedBy:
+          generator: value
+          value: "{{ .attributes.eventType }}"
+    keepVersions:
+      value: "3"
+    oldVersionAction:
+      value: disable
+    oldVersionGracePeriodMinutes:
+      value: "60"`
 }
 
 func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
@@ -93,22 +181,200 @@ func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) err
 	if err != nil {
 		return err
 	}
-	password := password.GeneratePassword(reactorConfig.PasswordLength, reactorConfig.UseLowerCase, reactorConfig.UseUpperCase, reactorConfig.UseSpecial, reactorConfig.UseNumbers, reactorConfig.SpecialCharOverride)
+	client := gcp.FromCtx(ctx)
+	if client == nil {
+		client, err = secretmanager.NewClient(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to setup secret manager client: %v", err)
+		}
+		defer client.Close()
+	}
 
-	if reactorConfig.UseExistingSecretValue {
+	var secretValue string
+	switch {
+	case len(reactorConfig.Fields) > 0:
+		secretValue, err = v.generateStructuredSecret(ctx, client, reactorConfig)
+		if err != nil {
+			return err
+		}
+	case reactorConfig.UseExistingSecretValue:
 		v.Log.Info("Using the existing value of the secret to update the secret with")
-		password, err = gcp.GetSecret(ctx, nil, reactorConfig.GcpProject, reactorConfig.GcpSecretName, "latest")
+		secretValue, err = gcp.GetSecret(ctx, client, reactorConfig.GcpProject, reactorConfig.GcpSecretName, "latest")
 		if err != nil {
 			return fmt.Errorf("failed to get the value of the GCP secret: %v", err)
 		}
+	default:
+		secretValue = password.GeneratePassword(reactorConfig.PasswordLength, reactorConfig.UseLowerCase, reactorConfig.UseUpperCase, reactorConfig.UseSpecial, reactorConfig.UseNumbers, reactorConfig.SpecialCharOverride)
 	}
 
-	version, err := gcp.AddVersion(ctx, nil, reactorConfig.GcpProject, reactorConfig.GcpSecretName, password)
+	version, err := gcp.AddVersion(ctx, client, reactorConfig.GcpProject, reactorConfig.GcpSecretName, secretValue)
 	if err != nil {
 		return fmt.Errorf("failed to add a new version to the GCP secret: %v", err)
 	}
-	v.Log.Info("Successfully added a new version to the GCP secret", zap.String("version", version))
+	v.Log.Info("Successfully added a new version to the GCP secret", zap.String("secret", fmt.Sprintf("projects/%s/secrets/%s", reactorConfig.GcpProject, reactorConfig.GcpSecretName)), zap.String("version", version))
+
+	if reactorConfig.OldVersionAction != "" {
+		err = v.removeOldVersions(ctx, client, reactorConfig, version)
+		if err != nil {
+			return fmt.Errorf("the new version '%s' was added, however %v", version, err)
+		}
+	}
+
+	return nil
+}
+
+// ===================================================================================
+// Structured secrets
+// ===================================================================================
+
+// generateStructuredSecret generates the value of each field and marshals the fields using the format. The previous
+// version of the secret is only read when a field is kept
+func (v *Reactor) generateStructuredSecret(ctx context.Context, client *secretmanager.Client, reactorConfig *ReactorConfig) (string, error) {
+	previous := map[string]interface{}{}
+	for _, field := range reactorConfig.Fields {
+		if field.Generator != GeneratorKeep {
+			continue
+		}
+		var err error
+		previous, err = v.getPreviousFields(ctx, client, reactorConfig)
+		if err != nil {
+			return "", err
+		}
+		break
+	}
+
+	names := []string{}
+	for name := range reactorConfig.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := map[string]interface{}{}
+	for _, name := range names {
+		field := reactorConfig.Fields[name]
+		var value interface{}
+		var err error
+		switch field.Generator {
+		case GeneratorPassword:
+			value = password.GeneratePassword(field.Length, field.UseLowerCase, field.UseUpperCase, field.UseSpecial, field.UseNumbers, field.SpecialCharOverride)
+		case GeneratorUuid:
+			value = uuid.NewV4().String()
+		case GeneratorHex:
+			value, err = password.GenerateHex(field.Length)
+		case GeneratorBase64:
+			value, err = password.GenerateBase64(field.Length)
+		case GeneratorValue:
+			value = field.Value
+		case GeneratorKeep:
+			previousValue, exists := previous[name]
+			switch {
+			case exists:
+				value = previousValue
+			case field.Value != "":
+				v.Log.Info(fmt.Sprintf("The field '%s' was not found in the previous version of the GCP secret, using the supplied value", name))
+				value = field.Value
+			default:
+				err = fmt.Errorf("the field '%s' could not be kept as it was not found in the previous version of the GCP secret and no value was supplied", name)
+			}
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to generate the '%s' field of the GCP secret: %v", name, err)
+		}
+		values[name] = value
+	}
+
+	var secretBytes []byte
+	var err error
+	if reactorConfig.Format == FormatYaml {
+		secretBytes, err = yaml.Marshal(values)
+	} else {
+		secretBytes, err = json.Marshal(values)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the fields of the GCP secret to %s: %v", reactorConfig.Format, err)
+	}
+	return string(secretBytes), nil
+}
+
+// getPreviousFields reads the newest enabled version of the secret, which may be json or yaml. No fields are returned
+// when the secret does not have an enabled version yet
+func (v *Reactor) getPreviousFields(ctx context.Context, client *secretmanager.Client, reactorConfig *ReactorConfig) (map[string]interface{}, error) {
+	versions, err := gcp.ListVersions(ctx, client, reactorConfig.GcpProject, reactorConfig.GcpSecretName)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	for _, version := range versions {
+		if version.State != secretmanagerpb.SecretVersion_ENABLED {
+			continue
+		}
+		value, err := gcp.AccessVersion(ctx, client, reactorConfig.GcpProject, reactorConfig.GcpSecretName, strconv.Itoa(gcp.VersionNumber(version.Name)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the previous version of the GCP secret: %v", err)
+		}
+		err = json.Unmarshal([]byte(value), &fields)
+		if err != nil {
+			err = yaml.Unmarshal([]byte(value), &fields)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal the previous version '%s' of the GCP secret using yaml and json, the fields cannot be kept - %v", version.Name, err)
+			}
+		}
+		v.Log.Info("Keeping fields from the previous version of the GCP secret", zap.String("version", version.Name))
+		return fields, nil
+	}
+	return fields, nil
+}
+
+// ===================================================================================
+// Old versions
+// ===================================================================================
+
+// removeOldVersions disables or destroys the versions that are not one of the newest versions to keep. When a grace
+// period is supplied a version is only removed once the version that replaced it is older than the grace period, so
+// consumers of the secret have time to pick up the new version
+func (v *Reactor) removeOldVersions(ctx context.Context, client *secretmanager.Client, reactorConfig *ReactorConfig, newVersion string) error {
+	versions, err := gcp.ListVersions(ctx, client, reactorConfig.GcpProject, reactorConfig.GcpSecretName)
+	if err != nil {
+		return fmt.Errorf("failed to %s the old versions of the GCP secret: %v", reactorConfig.OldVersionAction, err)
+	}
 
+	now := time.Now()
+	replacedAt := now
+	removed := 0
+	errs := []string{}
+	for i, version := range versions {
+		// versions are listed newest first, so a version was replaced when the previous version in the list was created
+		versionReplacedAt := replacedAt
+		if version.CreateTime != nil {
+			replacedAt = version.CreateTime.AsTime()
+		}
+		switch {
+		case version.Name == newVersion:
+			continue
+		case reactorConfig.KeepVersions > 0 && i < reactorConfig.KeepVersions:
+			continue
+		case reactorConfig.GracePeriod > 0 && now.Sub(versionReplacedAt) < reactorConfig.GracePeriod:
+			v.Log.Debug("Keeping an old version of the GCP secret until the grace period has passed", zap.String("version", version.Name))
+			continue
+		case reactorConfig.OldVersionAction == OldVersionActionDisable && version.State != secretmanagerpb.SecretVersion_ENABLED:
+			continue
+		}
+
+		if reactorConfig.OldVersionAction == OldVersionActionDestroy {
+			err = gcp.DestroyVersion(ctx, client, version.Name)
+		} else {
+			err = gcp.DisableVersion(ctx, client, version.Name)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		removed++
+		v.Log.Info(fmt.Sprintf("Successfully applied the %s action to an old version of the GCP secret", reactorConfig.OldVersionAction), zap.String("version", version.Name))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to %s %d of the %d old versions of the GCP secret - %s", reactorConfig.OldVersionAction, len(errs), len(errs)+removed, strings.Join(errs, "; "))
+	}
 	return nil
 }
 
@@ -221,9 +487,199 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 			return nil, fmt.Errorf("the project property was not supplied or was empty")
 		}
 	}
+
+	// ===================================================================================
+	// Get structured secret fields
+	// ===================================================================================
+	format, err := v.reactorConfig.Properties["format"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.Format = strings.ToLower(format)
+	if config.Format == "" {
+		config.Format = FormatJson
+	}
+	if config.Format != FormatJson && config.Format != FormatYaml {
+		return nil, fmt.Errorf("the format '%s' is not valid. Valid formats are %v", format, Formats)
+	}
+
+	config.Fields, err = v.getFields(ctx, data, config)
+	if err != nil {
+		return nil, err
+	}
+	if format != "" && len(config.Fields) == 0 {
+		return nil, fmt.Errorf("the fields property must be supplied when the format property is supplied")
+	}
+	if len(config.Fields) > 0 && config.UseExistingSecretValue {
+		return nil, fmt.Errorf("only one of the fields or useExistingSecretValue properties can be supplied")
+	}
+
+	// ===================================================================================
+	// Get old version lifecycle
+	// ===================================================================================
+	keepVersionsStr, err := v.reactorConfig.Properties["keepVersions"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if keepVersionsStr != "" {
+		config.KeepVersions, err = strconv.Atoi(keepVersionsStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied keepVersions '%v' to an integer. Error: %v", keepVersionsStr, err)
+		}
+		if config.KeepVersions < 1 {
+			return nil, fmt.Errorf("the keepVersions '%d' is not valid. At least 1 version, the new version, must be kept", config.KeepVersions)
+		}
+	}
+
+	gracePeriodStr, err := v.reactorConfig.Properties["oldVersionGracePeriodMinutes"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if gracePeriodStr != "" {
+		gracePeriod, err := strconv.Atoi(gracePeriodStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied oldVersionGracePeriodMinutes '%v' to an integer. Error: %v", gracePeriodStr, err)
+		}
+		if gracePeriod < 0 {
+			return nil, fmt.Errorf("the oldVersionGracePeriodMinutes '%d' is not valid. The grace period cannot be negative", gracePeriod)
+		}
+		config.GracePeriod = time.Duration(gracePeriod) * time.Minute
+	}
+
+	oldVersionAction, err := v.reactorConfig.Properties["oldVersionAction"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.OldVersionAction = strings.ToLower(oldVersionAction)
+	if config.OldVersionAction != "" && config.OldVersionAction != OldVersionActionDisable && config.OldVersionAction != OldVersionActionDestroy {
+		return nil, fmt.Errorf("the oldVersionAction '%s' is not valid. Valid actions are %v", oldVersionAction, OldVersionActions)
+	}
+	if keepVersionsStr != "" || gracePeriodStr != "" {
+		if config.OldVersionAction == "" {
+			config.OldVersionAction = OldVersionActionDisable
+		}
+	} else if config.OldVersionAction != "" {
+		return nil, fmt.Errorf("the keepVersions or oldVersionGracePeriodMinutes property must be supplied when the oldVersionAction property is supplied")
+	}
+
 	return config, nil
 }
 
+// getFields reads the fields of a structured secret. A field is either a value, which can be a Go template, or an
+// object with the generator and its settings. The password settings default to the settings of the reactor
+func (v *Reactor) getFields(ctx context.Context, data *message.EventData, reactorConfig *ReactorConfig) (map[string]SecretField, error) {
+	if _, ok := v.reactorConfig.Properties["fields"]; !ok {
+		return nil, nil
+	}
+	value, err := v.reactorConfig.Properties["fields"].GetValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, nil
+	}
+	var fieldValues map[string]interface{}
+	switch val := value.(type) {
+	case map[string]interface{}:
+		fieldValues = val
+	case map[string]string:
+		fieldValues = map[string]interface{}{}
+		for k, v := range val {
+			fieldValues[k] = v
+		}
+	default:
+		return nil, fmt.Errorf("expected the fields property to be an object, however it is of type %T", value)
+	}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+	render := func(name string, value interface{}) (string, error) {
+		return reactor.RenderTemplateValue(ctx, fmt.Sprintf("%v", value), "fields/"+name, data, v.reactorName, templateConfig)
+	}
+
+	fields := map[string]SecretField{}
+	for name, fieldValue := range fieldValues {
+		field := SecretField{
+			Generator:           GeneratorPassword,
+			Length:              reactorConfig.PasswordLength,
+			UseLowerCase:        reactorConfig.UseLowerCase,
+			UseUpperCase:        reactorConfig.UseUpperCase,
+			UseSpecial:          reactorConfig.UseSpecial,
+			UseNumbers:          reactorConfig.UseNumbers,
+			SpecialCharOverride: reactorConfig.SpecialCharOverride,
+		}
+		settings, isObject := fieldValue.(map[string]interface{})
+		if !isObject {
+			if fieldValue == nil {
+				return nil, fmt.Errorf("the value of the field '%s' was not supplied. Supply a value or an object with the generator of the field", name)
+			}
+			field.Generator = GeneratorValue
+			field.Value, err = render(name, fieldValue)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = field
+			continue
+		}
+
+		for setting := range settings {
+			if !slices.Contains(fieldSettings, setting) {
+				return nil, fmt.Errorf("the setting '%s' of the field '%s' is not valid. Valid settings are %v", setting, name, fieldSettings)
+			}
+		}
+		if generator, ok := settings["generator"]; ok && generator != nil {
+			field.Generator = strings.ToLower(fmt.Sprintf("%v", generator))
+			if !slices.Contains(Generators, field.Generator) {
+				return nil, fmt.Errorf("the generator '%v' of the field '%s' is not valid. Valid generators are %v", generator, name, Generators)
+			}
+		}
+		if field.Generator == GeneratorHex || field.Generator == GeneratorBase64 {
+			field.Length = defaultRandomBytesLength
+		}
+		if length, ok := settings["length"]; ok && length != nil {
+			field.Length, err = strconv.Atoi(fmt.Sprintf("%v", length))
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert the supplied length '%v' of the field '%s' to an integer. Error: %v", length, name, err)
+			}
+			if field.Length < 1 {
+				return nil, fmt.Errorf("the length '%d' of the field '%s' is not valid. The length must be greater than 0", field.Length, name)
+			}
+		}
+		for setting, target := range map[string]*bool{
+			"useLowerCase": &field.UseLowerCase,
+			"useUpperCase": &field.UseUpperCase,
+			"useSpecial":   &field.UseSpecial,
+			"useNumbers":   &field.UseNumbers,
+		} {
+			settingValue, ok := settings[setting]
+			if !ok || settingValue == nil {
+				continue
+			}
+			*target, err = strconv.ParseBool(fmt.Sprintf("%v", settingValue))
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert the supplied %s '%v' of the field '%s' to a boolean. Error: %v", setting, settingValue, name, err)
+			}
+		}
+		if field.Generator == GeneratorPassword && !field.UseLowerCase && !field.UseUpperCase && !field.UseSpecial && !field.UseNumbers {
+			return nil, fmt.Errorf("the password of the field '%s' cannot be generated as all of the character sets are disabled", name)
+		}
+		if specialCharOverride, ok := settings["specialCharOverride"]; ok && specialCharOverride != nil {
+			field.SpecialCharOverride = fmt.Sprintf("%v", specialCharOverride)
+		}
+		if fieldValue, ok := settings["value"]; ok && fieldValue != nil {
+			field.Value, err = render(name, fieldValue)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if field.Generator == GeneratorValue && field.Value == "" {
+			return nil, fmt.Errorf("the value of the field '%s' was not supplied or was empty", name)
+		}
+		fields[name] = field
+	}
+	return fields, nil
+}
+
 func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
 	return []config.ReactorConfigProperty{
 		{
@@ -286,6 +742,36 @@ func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
 			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
+		{
+			Name:        "format",
+			Description: fmt.Sprintf("The format of the structured secret generated from the fields property. Valid formats are %v. Defaults to %s", Formats, FormatJson),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "fields",
+			Description: fmt.Sprintf("The fields of a structured secret. The value of a field is either a value, which can be a Go template, or an object with a generator and its settings. Valid generators are %v and the settings are %v. The password settings default to the password properties of the reactor, the length of the hex and base64 generators is the number of random bytes and defaults to %d. The keep generator keeps the value of the field from the previous version of the secret, using the value setting when the previous version does not contain the field", Generators, fieldSettings, defaultRandomBytesLength),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "keepVersions",
+			Description: "The number of the newest versions of the secret to keep after rotating the secret, including the new version. The older versions are disabled or destroyed using the oldVersionAction property",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oldVersionGracePeriodMinutes",
+			Description: "The number of minutes an old version of the secret is kept after it was replaced by a newer version, before it is disabled or destroyed. When supplied with keepVersions, a version is only disabled or destroyed when both policies allow it",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oldVersionAction",
+			Description: fmt.Sprintf("The action applied to the old versions of the secret. Valid actions are %v. Defaults to %s when the keepVersions or oldVersionGracePeriodMinutes property is supplied. Destroyed versions cannot be recovered", OldVersionActions, OldVersionActionDisable),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
 	}
 }
 
@@ -0,0 +1,361 @@
+package gcprotaterandom
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/gcp"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	uuid "github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/yaml.v3"
+)
+
+const testSecret = "projects/proj1/secrets/secret1"
+
+func TestReactor_ProcessEvent_StructuredSecret(t *testing.T) {
+	data := &message.EventData{
+		ID:         "1",
+		Data:       map[string]interface{}{"env": "prod"},
+		Attributes: map[string]string{"eventType": "SECRET_ROTATE"},
+	}
+
+	tests := []struct {
+		name     string
+		previous []string
+		format   string
+		fields   map[string]interface{}
+		check    func(t *testing.T, got map[string]interface{})
+		wantErr  string
+	}{
+		{
+			name:     "json with kept fields",
+			previous: []string{`{"username":"old-user","password":"old-password","port":5432}`},
+			fields: map[string]interface{}{
+				"username": map[string]interface{}{"generator": "keep"},
+				"port":     map[string]interface{}{"generator": "keep"},
+				"password": map[string]interface{}{"length": 16, "useSpecial": false},
+				"apiKey":   map[string]interface{}{"generator": "uuid"},
+				"key":      map[string]interface{}{"generator": "hex", "length": "8"},
+				"env":      "{{ .data.env }}",
+			},
+			check: func(t *testing.T, got map[string]interface{}) {
+				if got["username"] != "old-user" || got["port"] != float64(5432) || got["env"] != "prod" {
+					t.Errorf("fields = %v, want the kept username and port and the rendered env", got)
+				}
+				password := got["password"].(string)
+				if len(password) != 16 || password == "old-password" {
+					t.Errorf("password = %v, want a new password of 16 characters", password)
+				}
+				if _, err := uuid.FromString(got["apiKey"].(string)); err != nil {
+					t.Errorf("apiKey = %v, want a uuid", got["apiKey"])
+				}
+				if key, err := hex.DecodeString(got["key"].(string)); err != nil || len(key) != 8 {
+					t.Errorf("key = %v, want 8 hex encoded bytes", got["key"])
+				}
+			},
+		},
+		{
+			name:   "yaml without a previous version",
+			format: "yaml",
+			fields: map[string]interface{}{
+				"username": map[string]interface{}{"generator": "keep", "value": "app-user"},
+				"token":    map[string]interface{}{"generator": "base64"},
+			},
+			check: func(t *testing.T, got map[string]interface{}) {
+				if got["username"] != "app-user" || len(got["token"].(string)) != 44 {
+					t.Errorf("fields = %v, want the default username and 32 base64 encoded bytes", got)
+				}
+			},
+		},
+		{
+			name:     "kept from a yaml version",
+			previous: []string{"username: old-user\n"},
+			fields: map[string]interface{}{
+				"username": map[string]interface{}{"generator": "keep"},
+			},
+			check: func(t *testing.T, got map[string]interface{}) {
+				if !reflect.DeepEqual(got, map[string]interface{}{"username": "old-user"}) {
+					t.Errorf("fields = %v, want the kept username", got)
+				}
+			},
+		},
+		{
+			name:    "kept field not found",
+			fields:  map[string]interface{}{"username": map[string]interface{}{"generator": "keep"}},
+			wantErr: "failed to generate the 'username' field of the GCP secret: the field 'username' could not be kept as it was not found in the previous version of the GCP secret and no value was supplied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			testServer, client := gcp.NewFakeServerAndClient(ctx, t)
+			ctx = gcp.WithCtx(ctx, client)
+			for _, previous := range tt.previous {
+				_, err := gcp.AddVersion(ctx, client, "proj1", "secret1", previous)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			v := New()
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: map[string]config.PropertyAndValue{
+				"secretFullName": {Value: testSecret},
+				"format":         {Value: tt.format},
+				"fields":         {Value: tt.fields},
+			}})
+			err := v.ProcessEvent(ctx, data)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.ProcessEvent() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.ProcessEvent() error = nil, wantErr %v", tt.wantErr)
+			}
+
+			value, err := gcp.AccessVersion(ctx, client, "proj1", "secret1", "latest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]interface{}{}
+			if tt.format == "yaml" {
+				err = yaml.Unmarshal([]byte(value), &got)
+			} else {
+				err = json.Unmarshal([]byte(value), &got)
+			}
+			if err != nil {
+				t.Fatalf("failed to unmarshal the new version '%s': %v", value, err)
+			}
+			tt.check(t, got)
+			if len(testServer.Versions[testSecret]) != len(tt.previous)+1 {
+				t.Errorf("versions = %d, want %d", len(testServer.Versions[testSecret]), len(tt.previous)+1)
+			}
+		})
+	}
+}
+
+func TestReactor_ProcessEvent_OldVersions(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		properties map[string]config.PropertyAndValue
+		// the create times of the existing versions, oldest first
+		created  []time.Time
+		disabled []int
+		want     map[int]secretmanagerpb.SecretVersion_State
+	}{
+		{
+			name: "keep versions",
+			properties: map[string]config.PropertyAndValue{
+				"keepVersions": {Value: "2"},
+			},
+			created:  []time.Time{now, now, now},
+			disabled: []int{1},
+			want: map[int]secretmanagerpb.SecretVersion_State{
+				1: secretmanagerpb.SecretVersion_DISABLED,
+				2: secretmanagerpb.SecretVersion_DISABLED,
+				3: secretmanagerpb.SecretVersion_ENABLED,
+				4: secretmanagerpb.SecretVersion_ENABLED,
+			},
+		},
+		{
+			name: "destroy after the grace period",
+			properties: map[string]config.PropertyAndValue{
+				"oldVersionAction":             {Value: "destroy"},
+				"oldVersionGracePeriodMinutes": {Value: "60"},
+			},
+			created:  []time.Time{now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-90 * time.Minute)},
+			disabled: []int{2},
+			want: map[int]secretmanagerpb.SecretVersion_State{
+				1: secretmanagerpb.SecretVersion_DESTROYED,
+				2: secretmanagerpb.SecretVersion_DESTROYED,
+				3: secretmanagerpb.SecretVersion_ENABLED,
+				4: secretmanagerpb.SecretVersion_ENABLED,
+			},
+		},
+		{
+			name: "keep versions and grace period",
+			properties: map[string]config.PropertyAndValue{
+				"keepVersions":                 {Value: "1"},
+				"oldVersionGracePeriodMinutes": {Value: "60"},
+			},
+			created: []time.Time{now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-10 * time.Minute)},
+			want: map[int]secretmanagerpb.SecretVersion_State{
+				1: secretmanagerpb.SecretVersion_DISABLED,
+				2: secretmanagerpb.SecretVersion_ENABLED,
+				3: secretmanagerpb.SecretVersion_ENABLED,
+				4: secretmanagerpb.SecretVersion_ENABLED,
+			},
+		},
+		{
+			name:    "no policy",
+			created: []time.Time{now, now},
+			want: map[int]secretmanagerpb.SecretVersion_State{
+				1: secretmanagerpb.SecretVersion_ENABLED,
+				2: secretmanagerpb.SecretVersion_ENABLED,
+				3: secretmanagerpb.SecretVersion_ENABLED,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			testServer, client := gcp.NewFakeServerAndClient(ctx, t)
+			ctx = gcp.WithCtx(ctx, client)
+			for _, created := range tt.created {
+				version, err := gcp.AddVersion(ctx, client, "proj1", "secret1", "old")
+				if err != nil {
+					t.Fatal(err)
+				}
+				testServer.Versions[testSecret][gcp.VersionNumber(version)-1].CreateTime = timestamppb.New(created)
+			}
+			for _, disabled := range tt.disabled {
+				err := gcp.DisableVersion(ctx, client, fmt.Sprintf("%s/versions/%d", testSecret, disabled))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			properties := map[string]config.PropertyAndValue{
+				"secretFullName": {Value: testSecret},
+			}
+			for k, p := range tt.properties {
+				properties[k] = p
+			}
+			v := New()
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: properties})
+			err := v.ProcessEvent(ctx, &message.EventData{ID: "1"})
+			if err != nil {
+				t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+			}
+			got := testServer.VersionStates(testSecret)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("version states = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	data := &message.EventData{ID: "1", Data: map[string]interface{}{"user": "app-user"}}
+	tests := []struct {
+		name       string
+		properties map[string]config.PropertyAndValue
+		want       *ReactorConfig
+		wantErr    string
+	}{
+		{
+			name: "fields and old versions",
+			properties: map[string]config.PropertyAndValue{
+				"format":                       {Value: "YAML"},
+				"useSpecial":                   {Value: "false"},
+				"fields":                       {Value: map[string]interface{}{"user": "{{ .data.user }}", "password": map[string]interface{}{"useNumbers": "false"}, "key": map[string]interface{}{"generator": "Base64"}}},
+				"keepVersions":                 {Value: "3"},
+				"oldVersionGracePeriodMinutes": {Value: "30"},
+			},
+			want: &ReactorConfig{
+				PasswordLength: 20, UseLowerCase: true, UseUpperCase: true, UseNumbers: true,
+				GcpProject: "proj1", GcpSecretName: "secret1", Format: FormatYaml,
+				Fields: map[string]SecretField{
+					"user":     {Generator: GeneratorValue, Length: 20, UseLowerCase: true, UseUpperCase: true, UseNumbers: true, Value: "app-user"},
+					"password": {Generator: GeneratorPassword, Length: 20, UseLowerCase: true, UseUpperCase: true},
+					"key":      {Generator: GeneratorBase64, Length: 32, UseLowerCase: true, UseUpperCase: true, UseNumbers: true},
+				},
+				OldVersionAction: OldVersionActionDisable, KeepVersions: 3, GracePeriod: 30 * time.Minute,
+			},
+		},
+		{
+			name:       "invalid format",
+			properties: map[string]config.PropertyAndValue{"format": {Value: "toml"}},
+			wantErr:    "the format 'toml' is not valid. Valid formats are [json yaml]",
+		},
+		{
+			name:       "format without fields",
+			properties: map[string]config.PropertyAndValue{"format": {Value: "json"}},
+			wantErr:    "the fields property must be supplied when the format property is supplied",
+		},
+		{
+			name:       "fields not an object",
+			properties: map[string]config.PropertyAndValue{"fields": {Value: "password"}},
+			wantErr:    "expected the fields property to be an object, however it is of type string",
+		},
+		{
+			name:       "invalid generator",
+			properties: map[string]config.PropertyAndValue{"fields": {Value: map[string]interface{}{"key": map[string]interface{}{"generator": "dude"}}}},
+			wantErr:    "the generator 'dude' of the field 'key' is not valid. Valid generators are [password uuid hex base64 keep value]",
+		},
+		{
+			name:       "invalid setting",
+			properties: map[string]config.PropertyAndValue{"fields": {Value: map[string]interface{}{"key": map[string]interface{}{"lenght": 10}}}},
+			wantErr:    "the setting 'lenght' of the field 'key' is not valid. Valid settings are [generator length useLowerCase useUpperCase useSpecial useNumbers specialCharOverride value]",
+		},
+		{
+			name:       "invalid length",
+			properties: map[string]config.PropertyAndValue{"fields": {Value: map[string]interface{}{"key": map[string]interface{}{"generator": "hex", "length": 0}}}},
+			wantErr:    "the length '0' of the field 'key' is not valid. The length must be greater than 0",
+		},
+		{
+			name:       "no password characters",
+			properties: map[string]config.PropertyAndValue{"fields": {Value: map[string]interface{}{"key": map[string]interface{}{"useLowerCase": false, "useUpperCase": false, "useSpecial": false, "useNumbers": false}}}},
+			wantErr:    "the password of the field 'key' cannot be generated as all of the character sets are disabled",
+		},
+		{
+			name:       "fields and existing value",
+			properties: map[string]config.PropertyAndValue{"fields": {Value: map[string]interface{}{"key": "value"}}, "useExistingSecretValue": {Value: "true"}},
+			wantErr:    "only one of the fields or useExistingSecretValue properties can be supplied",
+		},
+		{
+			name:       "invalid keep versions",
+			properties: map[string]config.PropertyAndValue{"keepVersions": {Value: "0"}},
+			wantErr:    "the keepVersions '0' is not valid. At least 1 version, the new version, must be kept",
+		},
+		{
+			name:       "invalid old version action",
+			properties: map[string]config.PropertyAndValue{"keepVersions": {Value: "2"}, "oldVersionAction": {Value: "delete"}},
+			wantErr:    "the oldVersionAction 'delete' is not valid. Valid actions are [disable destroy]",
+		},
+		{
+			name:       "old version action without a policy",
+			properties: map[string]config.PropertyAndValue{"oldVersionAction": {Value: "destroy"}},
+			wantErr:    "the keepVersions or oldVersionGracePeriodMinutes property must be supplied when the oldVersionAction property is supplied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			properties := map[string]config.PropertyAndValue{
+				"secretFullName": {Value: testSecret},
+			}
+			for k, p := range tt.properties {
+				properties[k] = p
+			}
+			v := New()
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: properties})
+			got, err := v.GetReactorConfig(context.Background(), data, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reactor.GetReactorConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package reactor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/github"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap"
+)
+
+// GetGitHubConfiguration reads the authentication, org, repo, commitSha and enterpriseUrl properties shared by the github reactors.
+// The commit sha is optional, the reactors that require it must validate it
+func GetGitHubConfiguration(ctx context.Context, log *zap.Logger, properties map[string]config.PropertyAndValue, data *message.EventData) (*github.GitHubConfiguration, error) {
+	token, err := properties["token"].GetStringValue(ctx, log, data)
+	if err != nil {
+		return nil, err
+	}
+	app, err := getGitHubAppConfiguration(ctx, log, properties, data)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" && app == nil {
+		return nil, fmt.Errorf("one of the github token or appId properties must be supplied")
+	}
+	if token != "" && app != nil {
+		return nil, fmt.Errorf("only one of the github token or appId properties can be supplied")
+	}
+
+	org, err := properties["org"].GetStringValue(ctx, log, data)
+	if err != nil {
+		return nil, err
+	}
+	if org == "" {
+		return nil, fmt.Errorf("the github org property was not supplied or was empty")
+	}
+
+	repo, err := properties["repo"].GetStringValue(ctx, log, data)
+	if err != nil {
+		return nil, err
+	}
+	if repo == "" {
+		return nil, fmt.Errorf("the github repo property was not supplied or was empty")
+	}
+
+	commitSha, err := properties["commitSha"].GetStringValue(ctx, log, data)
+	if err != nil {
+		return nil, err
+	}
+
+	enterpriseUrl, err := properties["enterpriseUrl"].GetStringValue(ctx, log, data)
+	if err != nil {
+		return nil, err
+	}
+	isEnterprise := enterpriseUrl != ""
+	if !isEnterprise {
+		enterpriseUrl = github.DefaultBaseURL
+	}
+
+	githubConfig := github.New(ctx, log, org, repo, commitSha, token, 0, enterpriseUrl, isEnterprise)
+	githubConfig.App = app
+	return githubConfig, nil
+}
+
+func getGitHubAppConfiguration(ctx context.Context, log *zap.Logger, properties map[string]config.PropertyAndValue, data *message.EventData) (*github.AppConfiguration, error) {
+	appIdStr, err := properties["appId"].GetStringValue(ctx, log, data)
+	if err != nil {
+		return nil, err
+	}
+	if appIdStr == "" {
+		return nil, nil
+	}
+	app := &github.AppConfiguration{}
+	app.AppId, err = strconv.ParseInt(appIdStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the supplied appId '%v' to an integer. Error: %v", appIdStr, err)
+	}
+
+	installationIdStr, err := properties["appInstallationId"].GetStringValue(ctx, log, data)
+	if err != nil {
+		return nil, err
+	}
+	if installationIdStr != "" {
+		app.InstallationId, err = strconv.ParseInt(installationIdStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied appInstallationId '%v' to an integer. Error: %v", installationIdStr, err)
+		}
+	}
+
+	privateKey, err := properties["appPrivateKey"].GetStringValue(ctx, log, data)
+	if err != nil {
+		return nil, err
+	}
+	if privateKey == "" {
+		return nil, fmt.Errorf("the github appPrivateKey property must be supplied when the appId property is supplied")
+	}
+	app.PrivateKey = []byte(privateKey)
+	return app, nil
+}
+
+// GetGitHubProperties returns the properties read by GetGitHubConfiguration.
+// The commitSha property is left out when the description is empty, for the reactors that do not use it
+func GetGitHubProperties(commitShaDescription string, commitShaRequired bool) []config.ReactorConfigProperty {
+	properties := []config.ReactorConfigProperty{
+		{
+			Name:        "token",
+			Description: "The github token to use for authentication. Either the token or the appId, appPrivateKey and optionally the appInstallationId properties must be supplied",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "appId",
+			Description: "The id of the github app to authenticate as, instead of using a token",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "appInstallationId",
+			Description: "The id of the installation of the github app. When not supplied the installation of the app on the org/repo is looked up",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "appPrivateKey",
+			Description: "The PEM encoded private key of the github app. Required when the appId property is supplied",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "org",
+			Description: "The github organization",
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "repo",
+			Description: "The github repository",
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "enterpriseUrl",
+			Description: "The url of the github enterprise server, for example https://github.example.com. Leave blank for github.com",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+	}
+	if commitShaDescription == "" {
+		return properties
+	}
+	return append(properties, config.ReactorConfigProperty{
+		Name:        "commitSha",
+		Description: commitShaDescription,
+		Required:    config.AsBoolPointer(commitShaRequired),
+		Type:        config.PropertyTypeString,
+	})
+}
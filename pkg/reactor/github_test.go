@@ -0,0 +1,92 @@
+package reactor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/github"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestGetGitHubConfiguration(t *testing.T) {
+	repoProperties := func(properties map[string]config.PropertyAndValue) map[string]config.PropertyAndValue {
+		properties["org"] = config.PropertyAndValue{Value: "org"}
+		properties["repo"] = config.PropertyAndValue{Value: "repo"}
+		return properties
+	}
+	tests := []struct {
+		name              string
+		properties        map[string]config.PropertyAndValue
+		wantEnterprise    bool
+		wantEnterpriseUrl string
+		wantApp           *github.AppConfiguration
+		wantErr           string
+	}{
+		{
+			name:              "token",
+			properties:        repoProperties(map[string]config.PropertyAndValue{"token": {Value: "token"}}),
+			wantEnterpriseUrl: github.DefaultBaseURL,
+		},
+		{
+			name:              "enterprise",
+			properties:        repoProperties(map[string]config.PropertyAndValue{"token": {Value: "token"}, "enterpriseUrl": {Value: "https://github.example.com"}}),
+			wantEnterprise:    true,
+			wantEnterpriseUrl: "https://github.example.com",
+		},
+		{
+			name: "app",
+			properties: repoProperties(map[string]config.PropertyAndValue{
+				"appId":             {Value: "123"},
+				"appInstallationId": {Value: "42"},
+				"appPrivateKey":     {Value: "key"},
+			}),
+			wantEnterpriseUrl: github.DefaultBaseURL,
+			wantApp:           &github.AppConfiguration{AppId: 123, InstallationId: 42, PrivateKey: []byte("key")},
+		},
+		{
+			name:       "no authentication",
+			properties: repoProperties(map[string]config.PropertyAndValue{}),
+			wantErr:    "one of the github token or appId properties must be supplied",
+		},
+		{
+			name:       "token and app",
+			properties: repoProperties(map[string]config.PropertyAndValue{"token": {Value: "token"}, "appId": {Value: "123"}, "appPrivateKey": {Value: "key"}}),
+			wantErr:    "only one of the github token or appId properties can be supplied",
+		},
+		{
+			name:       "app without a private key",
+			properties: repoProperties(map[string]config.PropertyAndValue{"appId": {Value: "123"}}),
+			wantErr:    "the github appPrivateKey property must be supplied when the appId property is supplied",
+		},
+		{
+			name:       "invalid app id",
+			properties: repoProperties(map[string]config.PropertyAndValue{"appId": {Value: "my-app"}, "appPrivateKey": {Value: "key"}}),
+			wantErr:    "failed to convert the supplied appId 'my-app' to an integer. Error: strconv.ParseInt: parsing \"my-app\": invalid syntax",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetGitHubConfiguration(context.Background(), zaptest.NewLogger(t), tt.properties, &message.EventData{ID: "1"})
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("GetGitHubConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("GetGitHubConfiguration() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.IsEnterprise != tt.wantEnterprise || got.EnterpriseUrl != tt.wantEnterpriseUrl {
+				t.Errorf("GetGitHubConfiguration() IsEnterprise = %v, EnterpriseUrl = %v, want %v, %v", got.IsEnterprise, got.EnterpriseUrl, tt.wantEnterprise, tt.wantEnterpriseUrl)
+			}
+			if (got.App == nil) != (tt.wantApp == nil) {
+				t.Fatalf("GetGitHubConfiguration() App = %v, want %v", got.App, tt.wantApp)
+			}
+			if tt.wantApp != nil && (got.App.AppId != tt.wantApp.AppId || got.App.InstallationId != tt.wantApp.InstallationId || string(got.App.PrivateKey) != string(tt.wantApp.PrivateKey)) {
+				t.Errorf("GetGitHubConfiguration() App = %+v, want %+v", got.App, tt.wantApp)
+			}
+		})
+	}
+}
@@ -0,0 +1,498 @@
+package githubcheckrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	gogithub "github.com/google/go-github/v57/github"
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/github"
+	"github.com/kcloutie/event-reactor/pkg/maps"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+const (
+	StatusQueued     = "queued"
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+)
+
+var (
+	ValidStatuses         = []string{StatusQueued, StatusInProgress, StatusCompleted}
+	ValidConclusions      = []string{"action_required", "cancelled", "failure", "neutral", "success", "skipped", "stale", "timed_out"}
+	ValidAnnotationLevels = []string{"notice", "warning", "failure"}
+
+	// DefaultAnnotationFields are the fields of each annotation in the payload that are mapped to the check run annotation
+	DefaultAnnotationFields = map[string]string{
+		"path":      "file",
+		"startLine": "line",
+		"endLine":   "endLine",
+		"level":     "level",
+		"message":   "message",
+		"title":     "title",
+	}
+
+	// annotationLevelAliases maps the levels commonly reported by linters and scanners to the check run annotation levels
+	annotationLevelAliases = map[string]string{
+		"error":   "failure",
+		"fatal":   "failure",
+		"warn":    "warning",
+		"info":    "notice",
+		"note":    "notice",
+		"hint":    "notice",
+		"warning": "warning",
+		"notice":  "notice",
+		"failure": "failure",
+	}
+)
+
+type Reactor struct {
+	Log           *zap.Logger
+	reactorName   string
+	reactorConfig config.ReactorConfig
+}
+
+type ReactorConfig struct {
+	GithubConfig *github.GitHubConfiguration
+	CheckRun     github.CheckRunOptions
+	Annotations  []*gogithub.CheckRunAnnotation
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName: "github/checkrun",
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor creates or updates a GitHub Check Run on a commit. Unlike a commit status, a check run has a markdown title, summary and text and can carry line-level annotations, which are read from an array in the event payload and sent to GitHub 50 at a time. The conclusion can be mapped from the values reported by the event. Check runs can only be written by a GitHub App, so this reactor requires the appId and appPrivateKey properties and optionally the appInstallationId property. Unlike the other github reactors it does not accept a token."
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+  reactorConfigs:
+  - name: test_github_checkrun
+    celExpressionFilter: attributes.type == 'lint'
+    type: github/checkrun
+    properties:
+      name:
+        value: "lint/{{ .data.tool }}"
+      conclusion:
+        value: "{{ .data.result }}"
+      conclusionMap:
+        value:
+          passed: success
+          failed: failure
+          errored: action_required
+      title:
+        value: "{{ len .data.findings }} findings"
+      summary:
+        value: "{{ .data.tool }} found {{ len .data.findings }} issues"
+      text:
+        value: "[Full report]({{ .data.reportUrl }})"
+      detailsUrl:
+        value: "{{ .data.reportUrl }}"
+      annotations:
+        payloadValue:
+          propertyPaths:
+          - data.findings
+      annotationFields:
+        value:
+          path: file
+          startLine: line
+          level: severity
+          message: description
+      appId:
+        value: "123456"
+      appPrivateKey:
+        fromEnv: GITHUB_APP_PRIVATE_KEY
+      org:
+        payloadValue:
+          propertyPaths:
+          - data.githubOrg
+      repo:
+        payloadValue:
+          propertyPaths:
+          - data.githubRepo
+      commitSha:
+        payloadValue:
+          propertyPaths:
+          - data.githubHeadSha
+`
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName))
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+
+	v.Log = v.Log.With(zap.String("org", reactorConfig.GithubConfig.Org), zap.String("repo", reactorConfig.GithubConfig.Repo), zap.String("commitSha", reactorConfig.GithubConfig.CommitSha), zap.String("checkRun", reactorConfig.CheckRun.Name), zap.String("status", reactorConfig.CheckRun.Status), zap.String("conclusion", reactorConfig.CheckRun.Conclusion))
+
+	checkRun, updated, err := reactorConfig.GithubConfig.CreateOrUpdateCheckRun(reactorConfig.CheckRun, reactorConfig.Annotations)
+	if err != nil {
+		return fmt.Errorf("unable to write the github check run. Error: %v", err)
+	}
+	if updated {
+		v.Log.Info("github check run has been updated", zap.Int64("checkRunId", checkRun.GetID()), zap.Int("annotations", len(reactorConfig.Annotations)))
+		return nil
+	}
+	v.Log.Info("github check run has been created", zap.Int64("checkRunId", checkRun.GetID()), zap.Int("annotations", len(reactorConfig.Annotations)))
+	return nil
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+	config := &ReactorConfig{}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	getRendered := func(name string) (string, error) {
+		value, err := reactor.GetRenderedStringProperty(ctx, v.Log, v.reactorConfig.Properties, name, data, v.reactorName, templateConfig)
+		return strings.TrimSpace(value), err
+	}
+
+	// ===================================================================================
+	// Get github configuration
+	// ===================================================================================
+	var err error
+	config.GithubConfig, err = reactor.GetGitHubConfiguration(ctx, log, v.reactorConfig.Properties, data)
+	if err != nil {
+		return nil, err
+	}
+	if config.GithubConfig.App == nil {
+		return nil, fmt.Errorf("check runs can only be written by a github app, supply the appId and appPrivateKey properties instead of the token property")
+	}
+	if config.GithubConfig.CommitSha == "" {
+		return nil, fmt.Errorf("the github commitSha property was not supplied or was empty")
+	}
+
+	// ===================================================================================
+	// Get name
+	// ===================================================================================
+	config.CheckRun.Name, err = getRendered("name")
+	if err != nil {
+		return nil, err
+	}
+	if config.CheckRun.Name == "" {
+		return nil, fmt.Errorf("the name property was not supplied or was empty")
+	}
+
+	// ===================================================================================
+	// Get conclusion
+	// ===================================================================================
+	conclusion, err := getRendered("conclusion")
+	if err != nil {
+		return nil, err
+	}
+	conclusionMap, err := v.reactorConfig.Properties["conclusionMap"].GetMapStringStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if mapped, ok := conclusionMap[conclusion]; ok {
+		conclusion = mapped
+	}
+	conclusion = strings.ToLower(conclusion)
+	if conclusion != "" && !slices.Contains(ValidConclusions, conclusion) {
+		return nil, fmt.Errorf("the conclusion '%s' is not valid. Valid conclusions are %v", conclusion, ValidConclusions)
+	}
+	config.CheckRun.Conclusion = conclusion
+
+	// ===================================================================================
+	// Get status
+	// ===================================================================================
+	status, err := getRendered("status")
+	if err != nil {
+		return nil, err
+	}
+	status = strings.ToLower(status)
+	if status == "" {
+		status = StatusInProgress
+		if conclusion != "" {
+			status = StatusCompleted
+		}
+	}
+	if !slices.Contains(ValidStatuses, status) {
+		return nil, fmt.Errorf("the status '%s' is not valid. Valid statuses are %v", status, ValidStatuses)
+	}
+	if status == StatusCompleted && conclusion == "" {
+		return nil, fmt.Errorf("the conclusion property must be supplied when the status is '%s'", StatusCompleted)
+	}
+	if status != StatusCompleted && conclusion != "" {
+		return nil, fmt.Errorf("the status '%s' is not valid when a conclusion is supplied, the status must be '%s'", status, StatusCompleted)
+	}
+	config.CheckRun.Status = status
+
+	// ===================================================================================
+	// Get title, summary and text
+	// ===================================================================================
+	config.CheckRun.Title, err = getRendered("title")
+	if err != nil {
+		return nil, err
+	}
+	if config.CheckRun.Title == "" {
+		config.CheckRun.Title = config.CheckRun.Name
+	}
+	config.CheckRun.Summary, err = getRendered("summary")
+	if err != nil {
+		return nil, err
+	}
+	if config.CheckRun.Summary == "" {
+		return nil, fmt.Errorf("the summary property was not supplied or was empty")
+	}
+	config.CheckRun.Text, err = getRendered("text")
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get detailsUrl and externalId
+	// ===================================================================================
+	config.CheckRun.DetailsUrl, err = getRendered("detailsUrl")
+	if err != nil {
+		return nil, err
+	}
+	config.CheckRun.ExternalId, err = getRendered("externalId")
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get annotations
+	// ===================================================================================
+	annotationFields, err := v.reactorConfig.Properties["annotationFields"].GetMapStringStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	for field, defaultPath := range DefaultAnnotationFields {
+		if annotationFields[field] == "" {
+			annotationFields[field] = defaultPath
+		}
+	}
+	config.Annotations, err = v.getAnnotations(ctx, data, annotationFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// getAnnotations reads the annotations array from the payload, or from a json string, and maps each item to a check run annotation
+func (v *Reactor) getAnnotations(ctx context.Context, data *message.EventData, fields map[string]string) ([]*gogithub.CheckRunAnnotation, error) {
+	annotations := []*gogithub.CheckRunAnnotation{}
+	if _, ok := v.reactorConfig.Properties["annotations"]; !ok {
+		return annotations, nil
+	}
+	value, err := v.reactorConfig.Properties["annotations"].GetValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []interface{}{}
+	switch t := value.(type) {
+	case nil:
+		return annotations, nil
+	case []interface{}:
+		items = t
+	case string:
+		if strings.TrimSpace(t) == "" {
+			return annotations, nil
+		}
+		err = json.Unmarshal([]byte(t), &items)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the annotations property as a json array. Error: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("expected the annotations property to be an array, however it is of type %T", value)
+	}
+
+	for i, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected annotation %d to be an object, however it is of type %T", i, item)
+		}
+		annotation, err := newAnnotation(itemMap, fields)
+		if err != nil {
+			return nil, fmt.Errorf("annotation %d is not valid. Error: %v", i, err)
+		}
+		annotations = append(annotations, annotation)
+	}
+	return annotations, nil
+}
+
+func newAnnotation(item map[string]interface{}, fields map[string]string) (*gogithub.CheckRunAnnotation, error) {
+	getField := func(name string) string {
+		value, err := maps.GetStringValueFromMapByPath(maps.NewMapPath(fields[name]), item, false)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(value)
+	}
+
+	path := getField("path")
+	if path == "" {
+		return nil, fmt.Errorf("the '%s' field was not supplied or was empty", fields["path"])
+	}
+	message := getField("message")
+	if message == "" {
+		return nil, fmt.Errorf("the '%s' field was not supplied or was empty", fields["message"])
+	}
+
+	startLineStr := getField("startLine")
+	if startLineStr == "" {
+		return nil, fmt.Errorf("the '%s' field was not supplied or was empty", fields["startLine"])
+	}
+	startLine, err := strconv.Atoi(startLineStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the supplied %s '%v' to an integer. Error: %v", fields["startLine"], startLineStr, err)
+	}
+	endLine := startLine
+	if endLineStr := getField("endLine"); endLineStr != "" {
+		endLine, err = strconv.Atoi(endLineStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied %s '%v' to an integer. Error: %v", fields["endLine"], endLineStr, err)
+		}
+	}
+
+	level := "warning"
+	if levelStr := strings.ToLower(getField("level")); levelStr != "" {
+		var ok bool
+		level, ok = annotationLevelAliases[levelStr]
+		if !ok {
+			return nil, fmt.Errorf("the level '%s' is not valid. Valid levels are %v", levelStr, ValidAnnotationLevels)
+		}
+	}
+
+	annotation := &gogithub.CheckRunAnnotation{
+		Path:            &path,
+		StartLine:       &startLine,
+		EndLine:         &endLine,
+		AnnotationLevel: &level,
+		Message:         &message,
+	}
+	if title := getField("title"); title != "" {
+		annotation.Title = &title
+	}
+	return annotation, nil
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	properties := []config.ReactorConfigProperty{
+		{
+			Name:        "name",
+			Description: "The name of the check run. An existing check run with the same name on the commit, created by the same github app, is updated while it is not completed. Once it is completed a new check run is created, so the annotations of a previous run are not kept. This field supports go templating",
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "status",
+			Description: fmt.Sprintf("The status of the check run. One of %v. Default is %s, or %s when a conclusion is supplied. This field supports go templating", ValidStatuses, StatusInProgress, StatusCompleted),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "conclusion",
+			Description: fmt.Sprintf("The conclusion of the check run, after the conclusionMap is applied it must be one of %v. Supplying a conclusion completes the check run. This field supports go templating", ValidConclusions),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "conclusionMap",
+			Description: "Maps the rendered conclusion to a check run conclusion, for example passed: success. Values that are not in the map are used as is",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "title",
+			Description: "The title of the check run output. Defaults to the name of the check run. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "summary",
+			Description: "The markdown summary of the check run output. This field supports go templating",
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "text",
+			Description: "The markdown details of the check run output. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "detailsUrl",
+			Description: "The url opened when clicking on the details link of the check run. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "externalId",
+			Description: "A reference to the check run in an external system. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "annotations",
+			Description: fmt.Sprintf("An array of objects in the payload, or a json array, used to add line-level annotations to the check run. The annotations are sent %d at a time", github.MaxCheckRunAnnotations),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "annotationFields",
+			Description: fmt.Sprintf("Maps the path, startLine, endLine, level, message and title of an annotation to the fields of the objects in the annotations array. Default: %v. The level can be notice, warning or failure, and the common aliases error, warn, info and note are also accepted. The default level is warning", DefaultAnnotationFields),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+	}
+	// check runs can only be written by a github app, so the token property of the other github reactors is not offered
+	for _, prop := range reactor.GetGitHubProperties("The commit sha where the check run will be written", true) {
+		switch prop.Name {
+		case "token":
+			continue
+		case "appId":
+			prop.Description = "The id of the github app to authenticate as. Check runs can only be written by a github app, a token is not supported"
+			prop.Required = config.AsBoolPointer(true)
+		case "appPrivateKey":
+			prop.Description = "The PEM encoded private key of the github app"
+			prop.Required = config.AsBoolPointer(true)
+		}
+		properties = append(properties, prop)
+	}
+	return properties
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
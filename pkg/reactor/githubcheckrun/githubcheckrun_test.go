@@ -0,0 +1,208 @@
+package githubcheckrun
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestReactor_ProcessEvent(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	created := []map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/api/v3/app/installations/42/access_tokens" && req.Method == "POST":
+			rw.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(rw).Encode(map[string]string{"token": "ghs_1", "expires_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339)})
+		case req.URL.Path == "/api/v3/repos/org/repo/commits/sha/check-runs" && req.Method == "GET":
+			rw.Write([]byte(`{"total_count":0,"check_runs":[]}`))
+		case req.URL.Path == "/api/v3/repos/org/repo/check-runs" && req.Method == "POST":
+			if req.Header.Get("Authorization") != "Bearer ghs_1" {
+				t.Fatalf("Invalid token: '%v'", req.Header.Get("Authorization"))
+			}
+			body := map[string]interface{}{}
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			created = append(created, body)
+			rw.WriteHeader(http.StatusCreated)
+			rw.Write([]byte(`{"id":1}`))
+		default:
+			t.Fatalf("Unexpected path: %v, method: %s", req.URL.Path, req.Method)
+		}
+	}))
+	defer server.Close()
+
+	v := New()
+	v.SetLogger(zaptest.NewLogger(t))
+	v.SetReactor(config.ReactorConfig{
+		Properties: map[string]config.PropertyAndValue{
+			"name":              {Value: "lint/{{ .data.tool }}"},
+			"conclusion":        {Value: "{{ .data.result }}"},
+			"conclusionMap":     {Value: map[string]interface{}{"failed": "failure"}},
+			"summary":           {Value: "{{ .data.tool }} found {{ len .data.findings }} issues"},
+			"annotations":       {PayloadValue: &config.PayloadValueRef{PropertyPaths: []string{"data.findings"}}},
+			"appId":             {Value: "123"},
+			"appInstallationId": {Value: "42"},
+			"appPrivateKey":     {Value: privateKey},
+			"org":               {Value: "org"},
+			"repo":              {Value: "repo"},
+			"commitSha":         {Value: "sha"},
+			"enterpriseUrl":     {Value: server.URL},
+		},
+	})
+	err = v.ProcessEvent(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{
+		"tool":   "golint",
+		"result": "failed",
+		"findings": []interface{}{
+			map[string]interface{}{"file": "main.go", "line": float64(10), "level": "error", "message": "unused variable"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+	}
+	if len(created) != 1 {
+		t.Fatalf("Reactor.ProcessEvent() created = %v, want 1 check run", created)
+	}
+	want := `map[annotations:[map[annotation_level:failure end_line:10 message:unused variable path:main.go start_line:10]] summary:golint found 1 issues title:lint/golint]`
+	if got := fmt.Sprint(created[0]["output"]); got != want {
+		t.Errorf("Reactor.ProcessEvent() output = %v, want %v", got, want)
+	}
+	if created[0]["name"] != "lint/golint" || created[0]["status"] != "completed" || created[0]["conclusion"] != "failure" {
+		t.Errorf("Reactor.ProcessEvent() name = %v, status = %v, conclusion = %v", created[0]["name"], created[0]["status"], created[0]["conclusion"])
+	}
+}
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	appProperties := func(properties map[string]config.PropertyAndValue) map[string]config.PropertyAndValue {
+		properties["appId"] = config.PropertyAndValue{Value: "123"}
+		properties["appPrivateKey"] = config.PropertyAndValue{Value: "key"}
+		properties["org"] = config.PropertyAndValue{Value: "org"}
+		properties["repo"] = config.PropertyAndValue{Value: "repo"}
+		properties["commitSha"] = config.PropertyAndValue{Value: "sha"}
+		return properties
+	}
+	tests := []struct {
+		name            string
+		properties      map[string]config.PropertyAndValue
+		data            map[string]interface{}
+		wantStatus      string
+		wantConclusion  string
+		wantTitle       string
+		wantAnnotations string
+		wantErr         string
+	}{
+		{
+			name:       "defaults",
+			properties: appProperties(map[string]config.PropertyAndValue{"name": {Value: "build"}, "summary": {Value: "running"}}),
+			wantStatus: StatusInProgress,
+			wantTitle:  "build",
+		},
+		{
+			name: "annotations from a json string with mapped fields",
+			properties: appProperties(map[string]config.PropertyAndValue{
+				"name":             {Value: "build"},
+				"summary":          {Value: "done"},
+				"title":            {Value: "Build"},
+				"conclusion":       {Value: "Success"},
+				"annotations":      {Value: `[{"location":{"file":"a.go","start":"3","end":5},"severity":"info","text":"note"},{"location":{"file":"b.go","start":1},"text":"warn"}]`},
+				"annotationFields": {Value: map[string]interface{}{"path": "location.file", "startLine": "location.start", "endLine": "location.end", "level": "severity", "message": "text"}},
+			}),
+			wantStatus:      StatusCompleted,
+			wantConclusion:  "success",
+			wantTitle:       "Build",
+			wantAnnotations: "a.go:3-5:notice:note,b.go:1-1:warning:warn",
+		},
+		{
+			name:       "token is not supported",
+			properties: map[string]config.PropertyAndValue{"name": {Value: "build"}, "summary": {Value: "done"}, "token": {Value: "token"}, "org": {Value: "org"}, "repo": {Value: "repo"}, "commitSha": {Value: "sha"}},
+			wantErr:    "check runs can only be written by a github app, supply the appId and appPrivateKey properties instead of the token property",
+		},
+		{
+			name:       "invalid conclusion",
+			properties: appProperties(map[string]config.PropertyAndValue{"name": {Value: "build"}, "summary": {Value: "done"}, "conclusion": {Value: "passed"}}),
+			wantErr:    "the conclusion 'passed' is not valid. Valid conclusions are [action_required cancelled failure neutral success skipped stale timed_out]",
+		},
+		{
+			name:       "completed without a conclusion",
+			properties: appProperties(map[string]config.PropertyAndValue{"name": {Value: "build"}, "summary": {Value: "done"}, "status": {Value: "completed"}}),
+			wantErr:    "the conclusion property must be supplied when the status is 'completed'",
+		},
+		{
+			name: "invalid annotation level",
+			properties: appProperties(map[string]config.PropertyAndValue{
+				"name":        {Value: "build"},
+				"summary":     {Value: "done"},
+				"annotations": {Value: `[{"file":"a.go","line":1,"level":"critical","message":"bad"}]`},
+			}),
+			wantErr: "annotation 0 is not valid. Error: the level 'critical' is not valid. Valid levels are [notice warning failure]",
+		},
+		{
+			name: "annotation without a line",
+			properties: appProperties(map[string]config.PropertyAndValue{
+				"name":        {Value: "build"},
+				"summary":     {Value: "done"},
+				"annotations": {Value: `[{"file":"a.go","message":"bad"}]`},
+			}),
+			wantErr: "annotation 0 is not valid. Error: the 'line' field was not supplied or was empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zaptest.NewLogger(t))
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			got, err := v.GetReactorConfig(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{}}, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.CheckRun.Status != tt.wantStatus || got.CheckRun.Conclusion != tt.wantConclusion || got.CheckRun.Title != tt.wantTitle {
+				t.Errorf("Reactor.GetReactorConfig() status = %v, conclusion = %v, title = %v, want %v, %v, %v", got.CheckRun.Status, got.CheckRun.Conclusion, got.CheckRun.Title, tt.wantStatus, tt.wantConclusion, tt.wantTitle)
+			}
+			annotations := []string{}
+			for _, a := range got.Annotations {
+				annotations = append(annotations, fmt.Sprintf("%s:%d-%d:%s:%s", a.GetPath(), a.GetStartLine(), a.GetEndLine(), a.GetAnnotationLevel(), a.GetMessage()))
+			}
+			if strings.Join(annotations, ",") != tt.wantAnnotations {
+				t.Errorf("Reactor.GetReactorConfig() annotations = %v, want %v", strings.Join(annotations, ","), tt.wantAnnotations)
+			}
+		})
+	}
+}
+
+func TestReactor_GetProperties(t *testing.T) {
+	required := map[string]bool{}
+	for _, prop := range New().GetProperties() {
+		required[prop.Name] = *prop.Required
+	}
+	if _, ok := required["token"]; ok {
+		t.Errorf("Reactor.GetProperties() contains the token property, check runs can only be written by a github app")
+	}
+	for _, name := range []string{"appId", "appPrivateKey"} {
+		if !required[name] {
+			t.Errorf("Reactor.GetProperties() the %s property is not required", name)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"strconv"
+	"strings"
 
 	"github.com/kcloutie/event-reactor/pkg/config"
 	"github.com/kcloutie/event-reactor/pkg/github"
@@ -51,8 +52,23 @@ type ReactorConfig struct {
 
 	Heading string
 	Body    string
+
+	// The mode used to write the comments. In the replace mode the existing comments matching the heading are removed and new comments are written.
+	// In the upsert mode the existing comments containing the hidden marker are edited in place
+	Mode string
+	// The value hidden in the comments written in the upsert mode, used to find the comments to edit. Defaults to the heading
+	Marker string
+	// The number of previous results kept in a collapsed section of the comments written in the upsert mode
+	PreviousResultsCount int
 }
 
+const (
+	ModeReplace = "replace"
+	ModeUpsert  = "upsert"
+)
+
+var ValidModes = []string{ModeReplace, ModeUpsert}
+
 func New() *Reactor {
 	return &Reactor{
 		reactorName: "github/comment",
@@ -101,7 +117,7 @@ func (v *Reactor) GetConfigExample() string {
 }
 
 func (v *Reactor) GetDescription() string {
-	return "This reactor writes comments on commits and pull requests. It requires a GitHub token with appropriate permissions to interact with the specified repository. Key inputs include the organization, repository, commit SHA, and pull request number. One of the standout features of this reactor is its support for Go templating, which can be used to customize the heading and body of the comments. The heading also plays a crucial role in identifying previous comments for deletion. Moreover, the reactor offers a suite of configuration options for enhanced control. These include the ability to purge existing comments from all commits associated with a pull request, remove comments from the pull request itself, and eliminate duplicate commit comments."
+	return "This reactor writes comments on commits and pull requests. It requires a GitHub token, or a GitHub App installation, with appropriate permissions to interact with the specified repository. Key inputs include the organization, repository, commit SHA, and pull request number. One of the standout features of this reactor is its support for Go templating, which can be used to customize the heading and body of the comments. The heading also plays a crucial role in identifying previous comments for deletion. Moreover, the reactor offers a suite of configuration options for enhanced control. These include the ability to purge existing comments from all commits associated with a pull request, remove comments from the pull request itself, and eliminate duplicate commit comments."
 }
 
 func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
@@ -122,6 +138,10 @@ func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) err
 
 	v.Log = v.Log.With(zap.String("org", reactorConfig.GithubConfig.Org), zap.String("repo", reactorConfig.GithubConfig.Repo), zap.String("commitSha", reactorConfig.GithubConfig.CommitSha), zap.Int("pr", reactorConfig.GithubConfig.PrNumber), zap.String("enterpriseUrl", reactorConfig.GithubConfig.EnterpriseUrl))
 
+	if reactorConfig.Mode == ModeUpsert {
+		return v.upsertComments(reactorConfig)
+	}
+
 	if reactorConfig.RemoveExistingCommentsFromAllPullRequestCommits {
 		v.Log.Info("Cleaning up existing commit comments")
 		reactorConfig.GithubConfig.CleanExistingCommentsOnAllPullRequestCommits(reactorConfig.Heading)
@@ -170,6 +190,27 @@ func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) err
 	return nil
 }
 
+func (v *Reactor) upsertComments(reactorConfig *ReactorConfig) error {
+	v.Log.Info("Upserting commit comment")
+	commitComment, updated, err := reactorConfig.GithubConfig.UpsertCommitComment(reactorConfig.Marker, reactorConfig.Body, reactorConfig.PreviousResultsCount)
+	if err != nil {
+		return fmt.Errorf("unable to upsert github commit comment. Error: %v", err)
+	}
+	v.Log.Info("github commit comment has been upserted", zap.String("commitCommentUrl", commitComment.GetHTMLURL()), zap.Bool("updated", updated))
+
+	if reactorConfig.GithubConfig.PrNumber <= 0 {
+		v.Log.Info("Pull request number was not greater than 0, skipping the upsert of the pull request comment")
+		return nil
+	}
+	v.Log.Info("Upserting pull request comment")
+	prComment, updated, err := reactorConfig.GithubConfig.UpsertPullRequestComment(reactorConfig.Marker, reactorConfig.Body, reactorConfig.PreviousResultsCount)
+	if err != nil {
+		return fmt.Errorf("unable to upsert github pull request comment. Error: %v", err)
+	}
+	v.Log.Info("github pull request comment has been upserted", zap.String("PrCommentUrl", prComment.GetHTMLURL()), zap.Bool("updated", updated))
+	return nil
+}
+
 func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
 
 	templateConfig := template.NewRenderTemplateOptions()
@@ -199,6 +240,46 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 	// ==========================================================
 	// Get general reactor configuration
 	// ==========================================================
+	mode, err := v.reactorConfig.Properties["mode"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	mode = strings.ToLower(mode)
+	if mode == "" {
+		mode = ModeReplace
+	}
+	if mode != ModeReplace && mode != ModeUpsert {
+		return nil, fmt.Errorf("the mode '%s' is not valid. Valid modes are %v", mode, ValidModes)
+	}
+
+	marker := string(renderedHeading)
+	markerStr, err := v.reactorConfig.Properties["marker"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if markerStr != "" {
+		renderedMarker, err := template.RenderTemplateValues(ctx, markerStr, fmt.Sprintf("%s_%s/marker", data.ID, v.reactorName), data.AsMap(), []string{}, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+		marker = string(renderedMarker)
+	}
+	if mode == ModeUpsert && strings.TrimSpace(marker) == "" {
+		return nil, fmt.Errorf("one of the marker or heading properties must be supplied when the mode is %s", ModeUpsert)
+	}
+
+	previousResultsCount := 0
+	previousResultsCountStr, err := v.reactorConfig.Properties["previousResultsCount"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if previousResultsCountStr != "" {
+		previousResultsCount, err = strconv.Atoi(previousResultsCountStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied previousResultsCount '%v' to an integer. Error: %v", previousResultsCountStr, err)
+		}
+	}
+
 	planTaskName, err := v.reactorConfig.Properties["planTaskName"].GetStringValue(ctx, v.Log, data)
 	if err != nil {
 		planTaskName = ""
@@ -234,35 +315,11 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 	// ==========================================================
 	// Get github configuration
 	// ==========================================================
-	token, err := v.reactorConfig.Properties["token"].GetStringValue(ctx, v.Log, data)
-	if err != nil {
-		return nil, err
-	}
-	if token == "" {
-		return nil, fmt.Errorf("the github token property was not supplied or was empty")
-	}
-
-	org, err := v.reactorConfig.Properties["org"].GetStringValue(ctx, v.Log, data)
-	if err != nil {
-		return nil, err
-	}
-	if org == "" {
-		return nil, fmt.Errorf("the github org property was not supplied or was empty")
-	}
-
-	repo, err := v.reactorConfig.Properties["repo"].GetStringValue(ctx, v.Log, data)
+	githubConfig, err := reactor.GetGitHubConfiguration(ctx, log, v.reactorConfig.Properties, data)
 	if err != nil {
 		return nil, err
 	}
-	if repo == "" {
-		return nil, fmt.Errorf("the github repo property was not supplied or was empty")
-	}
-
-	commitSha, err := v.reactorConfig.Properties["commitSha"].GetStringValue(ctx, v.Log, data)
-	if err != nil {
-		return nil, err
-	}
-	if commitSha == "" {
+	if githubConfig.CommitSha == "" {
 		return nil, fmt.Errorf("the github commitSha property was not supplied or was empty")
 	}
 
@@ -280,22 +337,19 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 		return nil, fmt.Errorf("failed to convert the supplied pr number '%v' to an integer. Error: %v", prNumberStr, err)
 	}
 
-	isEnterprise := false
-	enterpriseUrl, err := v.reactorConfig.Properties["enterpriseUrl"].GetStringValue(ctx, v.Log, data)
-	if err != nil {
-		enterpriseUrl = github.DefaultBaseURL
-	} else {
-		isEnterprise = true
-	}
+	githubConfig.PrNumber = prNumber
 
 	config := ReactorConfig{
 		PlanTaskName: planTaskName,
 		RemoveExistingCommentsFromAllPullRequestCommits: removeExistingCommentsFromAllPullRequestCommits,
 		RemoveExistingPullRequestComments:               removeExistingPullRequestComments,
 		RemoveDuplicateCommitComments:                   removeDuplicateCommitComments,
-		GithubConfig:                                    github.New(ctx, log, org, repo, commitSha, token, prNumber, enterpriseUrl, isEnterprise),
+		GithubConfig:                                    githubConfig,
 		Heading:                                         string(renderedHeading),
 		Body:                                            string(renderedBody),
+		Mode:                                            mode,
+		Marker:                                          marker,
+		PreviousResultsCount:                            previousResultsCount,
 	}
 	return &config, nil
 }
@@ -305,7 +359,7 @@ func (v *Reactor) GetHelp() string {
 }
 
 func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
-	return []config.ReactorConfigProperty{
+	properties := []config.ReactorConfigProperty{
 		{
 			Name:        "heading",
 			Description: "The heading of the comment. This field supports go templating. The heading is also used to find previous comments to remove",
@@ -319,27 +373,21 @@ func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
 			Type:        config.PropertyTypeString,
 		},
 		{
-			Name:        "token",
-			Description: "The github token to use for authentication. This token should have the necessary permissions to write comments to the repository",
-			Required:    config.AsBoolPointer(true),
+			Name:        "mode",
+			Description: fmt.Sprintf("How the comments are written. One of %v. The replace mode removes the existing comments matching the heading and writes new comments. The upsert mode edits the existing comments containing a hidden marker in place, which keeps their reactions and only notifies watchers once. Default: %s", ValidModes, ModeReplace),
+			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
 		{
-			Name:        "org",
-			Description: "The github organization",
-			Required:    config.AsBoolPointer(true),
+			Name:        "marker",
+			Description: "The value hidden in the comments written in the upsert mode, used to find the comments to edit. Defaults to the heading. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
 		{
-			Name:        "repo",
-			Description: "The github repository",
-			Required:    config.AsBoolPointer(true),
-			Type:        config.PropertyTypeString,
-		},
-		{
-			Name:        "commitSha",
-			Description: "The commit sha where the comment will be written",
-			Required:    config.AsBoolPointer(true),
+			Name:        "previousResultsCount",
+			Description: "The number of previous results kept in a collapsed section of the comments written in the upsert mode. Default is 0",
+			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
 		{
@@ -349,6 +397,7 @@ func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
 			Type:        config.PropertyTypeString,
 		},
 	}
+	return append(properties, reactor.GetGitHubProperties("The commit sha where the comment will be written", true)...)
 }
 
 func (v *Reactor) GetRequiredPropertyNames() []string {
@@ -328,3 +328,55 @@ func TestReactor_ExecuteReactor(t *testing.T) {
 		})
 	}
 }
+
+func TestReactor_ProcessEvent_Upsert(t *testing.T) {
+	calls := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+		switch {
+		case req.URL.Path == "/api/v3/repos/org/repo/commits/sha/comments" && req.Method == "GET":
+			rw.Write([]byte(fmt.Sprintf(`[{"id":1,"html_url":"url","body":"heading\nold\n\n%s"}]`, github.CommentMarker("build/app1"))))
+		case req.URL.Path == "/api/v3/repos/org/repo/comments/1" && req.Method == "PATCH":
+			rw.Write([]byte(`{"id":1,"html_url":"url"}`))
+		case req.URL.Path == "/api/v3/repos/org/repo/issues/2/comments" && req.Method == "GET":
+			rw.Write([]byte(`[]`))
+		case req.URL.Path == "/api/v3/repos/org/repo/issues/2/comments" && req.Method == "POST":
+			rw.WriteHeader(http.StatusCreated)
+			rw.Write([]byte(`{"id":2,"html_url":"url"}`))
+		default:
+			t.Fatalf("Unexpected path: %v, method: %s", req.URL.Path, req.Method)
+		}
+	}))
+	defer server.Close()
+
+	p := New()
+	p.SetLogger(zaptest.NewLogger(t))
+	p.SetReactor(config.ReactorConfig{
+		Properties: map[string]config.PropertyAndValue{
+			"heading":              {Value: toPtrString("heading")},
+			"body":                 {Value: toPtrString("heading\n{{ .data.status }}")},
+			"mode":                 {Value: toPtrString("upsert")},
+			"marker":               {Value: toPtrString("build/{{ .data.app }}")},
+			"previousResultsCount": {Value: toPtrString("3")},
+			"token":                {Value: toPtrString("token")},
+			"org":                  {Value: toPtrString("org")},
+			"repo":                 {Value: toPtrString("repo")},
+			"commitSha":            {Value: toPtrString("sha")},
+			"prNumber":             {Value: toPtrString("2")},
+			"enterpriseUrl":        {Value: toPtrString(server.URL)},
+		},
+	})
+	err := p.ProcessEvent(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{"app": "app1", "status": "passed"}})
+	if err != nil {
+		t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+	}
+	wantCalls := []string{
+		"GET /api/v3/repos/org/repo/commits/sha/comments",
+		"PATCH /api/v3/repos/org/repo/comments/1",
+		"GET /api/v3/repos/org/repo/issues/2/comments",
+		"POST /api/v3/repos/org/repo/issues/2/comments",
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("Reactor.ProcessEvent() calls = %v, want %v", calls, wantCalls)
+	}
+}
@@ -0,0 +1,335 @@
+package githubdeployment
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/github"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+var ValidStates = []string{"error", "failure", "inactive", "in_progress", "queued", "pending", "success"}
+
+type Reactor struct {
+	Log           *zap.Logger
+	reactorName   string
+	reactorConfig config.ReactorConfig
+}
+
+type ReactorConfig struct {
+	GithubConfig *github.GitHubConfiguration
+
+	Environment    string
+	Production     bool
+	State          string
+	Description    string
+	LogUrl         *string
+	EnvironmentUrl *string
+	AutoMerge      bool
+	AutoInactive   bool
+
+	// nil when the requiredContexts property was not supplied so github verifies all the commit statuses
+	RequiredContexts []string
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName: "github/deployment",
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor creates GitHub deployments and updates their status from CD pipeline events. The existing deployment of the same ref to the same environment is looked up first, so later events (for example in_progress followed by success or failure) add a status to the deployment rather than creating duplicates. The environment, ref, state, description, log url and environment url support go templating."
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+  reactorConfigs:
+  - name: test_github_deployment
+    celExpressionFilter: attributes.type == 'deploy'
+    type: github/deployment
+    properties:
+      environment:
+        value: "{{ .data.environment }}"
+      production:
+        value: "{{ eq .data.environment \"prod\" }}"
+      state:
+        value: "{{ .data.status }}"
+      description:
+        value: "Deploying {{ .data.app }} to {{ .data.environment }}"
+      logUrl:
+        value: "{{ .data.logUrl }}"
+      environmentUrl:
+        value: "https://{{ .data.environment }}.example.com"
+      requiredContexts:
+        value: []
+      token:
+        fromEnv: GIT_TOKEN
+      org:
+        payloadValue:
+          propertyPaths:
+          - data.githubOrg
+      repo:
+        payloadValue:
+          propertyPaths:
+          - data.githubRepo
+      ref:
+        payloadValue:
+          propertyPaths:
+          - data.githubHeadSha
+`
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName))
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+
+	v.Log = v.Log.With(zap.String("org", reactorConfig.GithubConfig.Org), zap.String("repo", reactorConfig.GithubConfig.Repo), zap.String("ref", reactorConfig.GithubConfig.CommitSha), zap.String("environment", reactorConfig.Environment), zap.String("state", reactorConfig.State))
+
+	deployment, _, created, err := reactorConfig.GithubConfig.CreateOrUpdateDeploymentStatus(reactorConfig.Environment, reactorConfig.Production, reactorConfig.AutoMerge, reactorConfig.Description, reactorConfig.RequiredContexts, reactorConfig.State, reactorConfig.AutoInactive, reactorConfig.LogUrl, reactorConfig.EnvironmentUrl)
+	if err != nil {
+		return fmt.Errorf("unable to write the github deployment. Error: %v", err)
+	}
+
+	v.Log = v.Log.With(zap.Int64("deploymentId", deployment.GetID()))
+	if created {
+		v.Log.Info("github deployment has been created")
+	} else {
+		v.Log.Info("github deployment status has been updated")
+	}
+	return nil
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+	config := &ReactorConfig{}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	getRendered := func(name string) (string, error) {
+		value, err := reactor.GetRenderedStringProperty(ctx, v.Log, v.reactorConfig.Properties, name, data, v.reactorName, templateConfig)
+		return strings.TrimSpace(value), err
+	}
+
+	getBool := func(name string, defaultValue bool) (bool, error) {
+		value, err := getRendered(name)
+		if err != nil {
+			return false, err
+		}
+		if value == "" {
+			return defaultValue, nil
+		}
+		result, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("failed to convert the supplied %s '%v' to a boolean. Error: %v", name, value, err)
+		}
+		return result, nil
+	}
+
+	// ===================================================================================
+	// Get environment
+	// ===================================================================================
+	var err error
+	config.Environment, err = getRendered("environment")
+	if err != nil {
+		return nil, err
+	}
+	if config.Environment == "" {
+		return nil, fmt.Errorf("the environment property was not supplied or was empty")
+	}
+
+	// ===================================================================================
+	// Get state
+	// ===================================================================================
+	state, err := getRendered("state")
+	if err != nil {
+		return nil, err
+	}
+	state = strings.ToLower(state)
+	if !slices.Contains(ValidStates, state) {
+		return nil, fmt.Errorf("the state '%s' is not valid. Valid states are %v", state, ValidStates)
+	}
+	config.State = state
+
+	// ===================================================================================
+	// Get description, logUrl and environmentUrl
+	// ===================================================================================
+	config.Description, err = getRendered("description")
+	if err != nil {
+		return nil, err
+	}
+
+	logUrl, err := getRendered("logUrl")
+	if err != nil {
+		return nil, err
+	}
+	if logUrl != "" {
+		config.LogUrl = &logUrl
+	}
+
+	environmentUrl, err := getRendered("environmentUrl")
+	if err != nil {
+		return nil, err
+	}
+	if environmentUrl != "" {
+		config.EnvironmentUrl = &environmentUrl
+	}
+
+	// ===================================================================================
+	// Get production, autoMerge and autoInactive
+	// ===================================================================================
+	config.Production, err = getBool("production", false)
+	if err != nil {
+		return nil, err
+	}
+	config.AutoMerge, err = getBool("autoMerge", false)
+	if err != nil {
+		return nil, err
+	}
+	config.AutoInactive, err = getBool("autoInactive", true)
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get requiredContexts
+	// ===================================================================================
+	if requiredContextsProp, ok := v.reactorConfig.Properties["requiredContexts"]; ok {
+		requiredContexts, err := requiredContextsProp.GetStringArrayValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+		config.RequiredContexts = []string{}
+		for i, requiredContext := range requiredContexts {
+			rendered, err := reactor.RenderTemplateValue(ctx, requiredContext, fmt.Sprintf("requiredContexts/%d", i), data, v.reactorName, templateConfig)
+			if err != nil {
+				return nil, err
+			}
+			config.RequiredContexts = append(config.RequiredContexts, rendered)
+		}
+	}
+
+	// ===================================================================================
+	// Get github configuration
+	// ===================================================================================
+	config.GithubConfig, err = reactor.GetGitHubConfiguration(ctx, log, v.reactorConfig.Properties, data)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := getRendered("ref")
+	if err != nil {
+		return nil, err
+	}
+	if ref != "" {
+		// the deployment helpers use the commit sha as the ref of the deployment
+		config.GithubConfig.CommitSha = ref
+	}
+	if config.GithubConfig.CommitSha == "" {
+		return nil, fmt.Errorf("one of the github ref or commitSha properties must be supplied")
+	}
+
+	return config, nil
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	properties := []config.ReactorConfigProperty{
+		{
+			Name:        "environment",
+			Description: "The name of the environment being deployed to, for example staging or prod. This field supports go templating",
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "state",
+			Description: fmt.Sprintf("The state of the deployment. One of %v. This field supports go templating", ValidStates),
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "ref",
+			Description: "The ref to deploy. This can be a branch, tag or commit sha. Defaults to the commitSha property. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "description",
+			Description: "A short description of the deployment. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "logUrl",
+			Description: "The url of the deployment logs. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "environmentUrl",
+			Description: "The url of the deployed environment. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "production",
+			Description: "True when the environment is a production environment, otherwise the environment is marked as transient. Default is false. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "requiredContexts",
+			Description: "The commit status contexts that must be successful before the deployment is created. When not supplied github verifies all the commit statuses, an empty list skips the verification. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeStringArray,
+		},
+		{
+			Name:        "autoMerge",
+			Description: "True to merge the default branch into the ref when it is behind the default branch. Default is false",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "autoInactive",
+			Description: "True to mark the previous successful deployments to the environment as inactive when the deployment is successful. Default is true",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+	}
+	return append(properties, reactor.GetGitHubProperties("The commit sha to deploy. Ignored when the ref property is supplied", false)...)
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
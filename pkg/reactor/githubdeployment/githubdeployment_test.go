@@ -0,0 +1,191 @@
+package githubdeployment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestReactor_ProcessEvent(t *testing.T) {
+	deployments := []map[string]interface{}{}
+	statuses := []map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/api/v3/repos/org/repo/deployments" && req.Method == "GET" {
+			found := []map[string]interface{}{}
+			for _, deployment := range deployments {
+				if deployment["ref"] == req.URL.Query().Get("ref") && deployment["environment"] == req.URL.Query().Get("environment") {
+					found = append(found, deployment)
+				}
+			}
+			_ = json.NewEncoder(rw).Encode(found)
+			return
+		}
+		if req.URL.Path == "/api/v3/repos/org/repo/deployments" && req.Method == "POST" {
+			deployment := map[string]interface{}{}
+			_ = json.NewDecoder(req.Body).Decode(&deployment)
+			deployment["id"] = len(deployments) + 1
+			deployments = append(deployments, deployment)
+			rw.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(rw).Encode(deployment)
+			return
+		}
+		if req.URL.Path == "/api/v3/repos/org/repo/deployments/1/statuses" && req.Method == "POST" {
+			status := map[string]interface{}{}
+			_ = json.NewDecoder(req.Body).Decode(&status)
+			statuses = append(statuses, status)
+			rw.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(rw).Encode(status)
+			return
+		}
+		t.Fatalf("Unexpected path: %v, method: %s", req.URL.Path, req.Method)
+	}))
+	defer server.Close()
+
+	reactorConfig := config.ReactorConfig{
+		Properties: map[string]config.PropertyAndValue{
+			"environment":      {Value: "{{ .data.environment }}"},
+			"production":       {Value: `{{ eq .data.environment "prod" }}`},
+			"state":            {Value: "{{ .data.status }}"},
+			"description":      {Value: "Deploying {{ .data.app }}"},
+			"logUrl":           {Value: "https://logs/{{ .data.app }}"},
+			"requiredContexts": {Value: []interface{}{}},
+			"token":            {Value: "token"},
+			"org":              {Value: "org"},
+			"repo":             {Value: "repo"},
+			"ref":              {Value: "{{ .data.sha }}"},
+			"enterpriseUrl":    {Value: server.URL},
+		},
+	}
+
+	for _, status := range []string{"in_progress", "success"} {
+		v := New()
+		v.SetLogger(zaptest.NewLogger(t))
+		v.SetReactor(reactorConfig)
+		err := v.ProcessEvent(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{"app": "app1", "environment": "prod", "status": status, "sha": "sha"}})
+		if err != nil {
+			t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+		}
+	}
+
+	if len(deployments) != 1 {
+		t.Fatalf("Reactor.ProcessEvent() deployments = %v, want 1", deployments)
+	}
+	deployment := deployments[0]
+	if deployment["ref"] != "sha" || deployment["environment"] != "prod" || deployment["production_environment"] != true || deployment["auto_merge"] != false {
+		t.Errorf("Reactor.ProcessEvent() deployment = %v", deployment)
+	}
+	if requiredContexts, ok := deployment["required_contexts"].([]interface{}); !ok || len(requiredContexts) != 0 {
+		t.Errorf("Reactor.ProcessEvent() required_contexts = %v, want []", deployment["required_contexts"])
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Reactor.ProcessEvent() statuses = %v, want 2", statuses)
+	}
+	if statuses[0]["state"] != "in_progress" || statuses[1]["state"] != "success" || statuses[1]["log_url"] != "https://logs/app1" {
+		t.Errorf("Reactor.ProcessEvent() statuses = %v", statuses)
+	}
+}
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	githubProperties := func(properties map[string]config.PropertyAndValue) map[string]config.PropertyAndValue {
+		properties["token"] = config.PropertyAndValue{Value: "token"}
+		properties["org"] = config.PropertyAndValue{Value: "org"}
+		properties["repo"] = config.PropertyAndValue{Value: "repo"}
+		return properties
+	}
+	tests := []struct {
+		name                 string
+		properties           map[string]config.PropertyAndValue
+		wantRef              string
+		wantRequiredContexts []string
+		wantAutoInactive     bool
+		wantErr              string
+	}{
+		{
+			name: "commit sha is the default ref",
+			properties: githubProperties(map[string]config.PropertyAndValue{
+				"environment": {Value: "staging"},
+				"state":       {Value: "queued"},
+				"commitSha":   {Value: "sha"},
+			}),
+			wantRef:          "sha",
+			wantAutoInactive: true,
+		},
+		{
+			name: "ref and required contexts",
+			properties: githubProperties(map[string]config.PropertyAndValue{
+				"environment":      {Value: "staging"},
+				"state":            {Value: "queued"},
+				"commitSha":        {Value: "sha"},
+				"ref":              {Value: "v1.0.0"},
+				"requiredContexts": {Value: []interface{}{"build/{{ .data.app }}"}},
+				"autoInactive":     {Value: "false"},
+			}),
+			wantRef:              "v1.0.0",
+			wantRequiredContexts: []string{"build/app1"},
+		},
+		{
+			name: "missing ref",
+			properties: githubProperties(map[string]config.PropertyAndValue{
+				"environment": {Value: "staging"},
+				"state":       {Value: "queued"},
+			}),
+			wantErr: "one of the github ref or commitSha properties must be supplied",
+		},
+		{
+			name: "invalid state",
+			properties: githubProperties(map[string]config.PropertyAndValue{
+				"environment": {Value: "staging"},
+				"state":       {Value: "done"},
+				"commitSha":   {Value: "sha"},
+			}),
+			wantErr: "the state 'done' is not valid. Valid states are [error failure inactive in_progress queued pending success]",
+		},
+		{
+			name: "invalid production",
+			properties: githubProperties(map[string]config.PropertyAndValue{
+				"environment": {Value: "staging"},
+				"state":       {Value: "success"},
+				"production":  {Value: "maybe"},
+				"commitSha":   {Value: "sha"},
+			}),
+			wantErr: "failed to convert the supplied production 'maybe' to a boolean. Error: strconv.ParseBool: parsing \"maybe\": invalid syntax",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zaptest.NewLogger(t))
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			got, err := v.GetReactorConfig(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{"app": "app1"}}, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.GithubConfig.CommitSha != tt.wantRef {
+				t.Errorf("Reactor.GetReactorConfig() ref = %v, want %v", got.GithubConfig.CommitSha, tt.wantRef)
+			}
+			if len(got.RequiredContexts) != len(tt.wantRequiredContexts) || (tt.wantRequiredContexts == nil) != (got.RequiredContexts == nil) {
+				t.Fatalf("Reactor.GetReactorConfig() requiredContexts = %#v, want %#v", got.RequiredContexts, tt.wantRequiredContexts)
+			}
+			for i := range tt.wantRequiredContexts {
+				if got.RequiredContexts[i] != tt.wantRequiredContexts[i] {
+					t.Errorf("Reactor.GetReactorConfig() requiredContexts = %v, want %v", got.RequiredContexts, tt.wantRequiredContexts)
+				}
+			}
+			if got.AutoInactive != tt.wantAutoInactive {
+				t.Errorf("Reactor.GetReactorConfig() autoInactive = %v, want %v", got.AutoInactive, tt.wantAutoInactive)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package githubdispatch
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/github"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+const DefaultWaitTimeoutSeconds = 60
+
+type Reactor struct {
+	Log           *zap.Logger
+	reactorName   string
+	reactorConfig config.ReactorConfig
+	pollInterval  time.Duration
+}
+
+type ReactorConfig struct {
+	GithubConfig *github.GitHubConfiguration
+
+	// Set when sending a repository_dispatch event
+	EventType     string
+	ClientPayload map[string]interface{}
+
+	// Set when sending a workflow_dispatch event
+	WorkflowFile string
+	Ref          string
+	Inputs       map[string]interface{}
+
+	WaitForRun  bool
+	WaitTimeout time.Duration
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName:  "github/dispatch",
+		pollInterval: 5 * time.Second,
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor triggers GitHub Actions workflows. It either sends a repository_dispatch event with an event type and a client payload built from the event data, or a workflow_dispatch event for a workflow file and ref with inputs. The event type, client payload, ref and inputs support go templating. The reactor can optionally wait for the triggered workflow run to appear and log its url."
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+  reactorConfigs:
+  - name: test_github_repository_dispatch
+    celExpressionFilter: attributes.type == 'release'
+    type: github/dispatch
+    properties:
+      eventType:
+        value: "release-{{ .data.app }}"
+      clientPayload:
+        value:
+          version: "{{ .data.version }}"
+      includeEventData:
+        value: "true"
+      token:
+        fromEnv: GIT_TOKEN
+      org:
+        value: my-org
+      repo:
+        value: my-repo
+  - name: test_github_workflow_dispatch
+    celExpressionFilter: attributes.type == 'deploy'
+    type: github/dispatch
+    properties:
+      workflowFile:
+        value: deploy.yaml
+      ref:
+        value: main
+      inputs:
+        value:
+          environment: "{{ .data.environment }}"
+      waitForRun:
+        value: "true"
+      token:
+        fromEnv: GIT_TOKEN
+      org:
+        value: my-org
+      repo:
+        value: my-repo
+      enterpriseUrl:
+        value: https://github.someplace.com
+`
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName))
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+
+	v.Log = v.Log.With(zap.String("org", reactorConfig.GithubConfig.Org), zap.String("repo", reactorConfig.GithubConfig.Repo))
+
+	since := time.Now()
+	event := github.EventRepositoryDispatch
+	branch := ""
+	if reactorConfig.WorkflowFile != "" {
+		event = github.EventWorkflowDispatch
+		branch = reactorConfig.Ref
+		v.Log = v.Log.With(zap.String("workflowFile", reactorConfig.WorkflowFile), zap.String("ref", reactorConfig.Ref))
+		err = reactorConfig.GithubConfig.SendWorkflowDispatch(reactorConfig.WorkflowFile, reactorConfig.Ref, reactorConfig.Inputs)
+		if err != nil {
+			return fmt.Errorf("unable to send the github workflow_dispatch event. Error: %v", err)
+		}
+	} else {
+		v.Log = v.Log.With(zap.String("eventType", reactorConfig.EventType))
+		err = reactorConfig.GithubConfig.SendRepositoryDispatch(reactorConfig.EventType, reactorConfig.ClientPayload)
+		if err != nil {
+			return fmt.Errorf("unable to send the github repository_dispatch event. Error: %v", err)
+		}
+	}
+	v.Log.Info(fmt.Sprintf("github %s event has been sent", event))
+
+	if !reactorConfig.WaitForRun {
+		return nil
+	}
+	run, err := reactorConfig.GithubConfig.WaitForWorkflowRun(reactorConfig.WorkflowFile, event, branch, since, reactorConfig.WaitTimeout, v.pollInterval)
+	if err != nil {
+		// the event was sent, so failing to find the run does not fail the reactor
+		v.Log.Warn("Unable to find the triggered workflow run", zap.Error(err))
+		return nil
+	}
+	v.Log.Info("github workflow run has been triggered", zap.Int64("runId", run.GetID()), zap.String("runUrl", run.GetHTMLURL()))
+	return nil
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+	config := &ReactorConfig{}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	getRendered := func(name string) (string, error) {
+		value, err := reactor.GetRenderedStringProperty(ctx, v.Log, v.reactorConfig.Properties, name, data, v.reactorName, templateConfig)
+		return strings.TrimSpace(value), err
+	}
+
+	// ===================================================================================
+	// Get eventType and workflowFile
+	// ===================================================================================
+	var err error
+	config.EventType, err = getRendered("eventType")
+	if err != nil {
+		return nil, err
+	}
+	config.WorkflowFile, err = getRendered("workflowFile")
+	if err != nil {
+		return nil, err
+	}
+	if (config.EventType == "") == (config.WorkflowFile == "") {
+		return nil, fmt.Errorf("one of the eventType or workflowFile properties must be supplied")
+	}
+
+	if config.EventType != "" {
+		// ===================================================================================
+		// Get clientPayload
+		// ===================================================================================
+		clientPayload, err := v.reactorConfig.Properties["clientPayload"].GetMapStringInterfaceValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientPayload, err = reactor.RenderTemplateParameters(ctx, clientPayload, data, v.reactorName, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		includeEventDataStr, err := v.reactorConfig.Properties["includeEventData"].GetStringValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+		if includeEventDataStr != "" {
+			includeEventData, err := strconv.ParseBool(includeEventDataStr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert the supplied includeEventData '%v' to a boolean. Error: %v", includeEventDataStr, err)
+			}
+			if includeEventData {
+				config.ClientPayload["data"] = data.Data
+				config.ClientPayload["attributes"] = data.Attributes
+			}
+		}
+	} else {
+		// ===================================================================================
+		// Get ref and inputs
+		// ===================================================================================
+		config.Ref, err = getRendered("ref")
+		if err != nil {
+			return nil, err
+		}
+		if config.Ref == "" {
+			return nil, fmt.Errorf("the ref property was not supplied or was empty, it is required when the workflowFile property is supplied")
+		}
+
+		inputs, err := v.reactorConfig.Properties["inputs"].GetMapStringInterfaceValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+		config.Inputs, err = reactor.RenderTemplateParameters(ctx, inputs, data, v.reactorName, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// ===================================================================================
+	// Get waitForRun and waitTimeoutSeconds
+	// ===================================================================================
+	waitForRunStr, err := v.reactorConfig.Properties["waitForRun"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if waitForRunStr != "" {
+		config.WaitForRun, err = strconv.ParseBool(waitForRunStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied waitForRun '%v' to a boolean. Error: %v", waitForRunStr, err)
+		}
+	}
+
+	waitTimeoutSeconds := DefaultWaitTimeoutSeconds
+	waitTimeoutSecondsStr, err := v.reactorConfig.Properties["waitTimeoutSeconds"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if waitTimeoutSecondsStr != "" {
+		waitTimeoutSeconds, err = strconv.Atoi(waitTimeoutSecondsStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied waitTimeoutSeconds '%v' to an integer. Error: %v", waitTimeoutSecondsStr, err)
+		}
+	}
+	config.WaitTimeout = time.Duration(waitTimeoutSeconds) * time.Second
+
+	// ===================================================================================
+	// Get github configuration
+	// ===================================================================================
+	config.GithubConfig, err = reactor.GetGitHubConfiguration(ctx, log, v.reactorConfig.Properties, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	properties := []config.ReactorConfigProperty{
+		{
+			Name:        "eventType",
+			Description: "The event type of the repository_dispatch event. Cannot be used with the workflowFile property. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "clientPayload",
+			Description: "The client payload of the repository_dispatch event. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "includeEventData",
+			Description: "True to add the data and attributes of the event to the client payload of the repository_dispatch event. Default is false",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "workflowFile",
+			Description: "The file name of the workflow triggered by a workflow_dispatch event, for example deploy.yaml. Cannot be used with the eventType property. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "ref",
+			Description: "The branch or tag the workflow_dispatch event runs the workflow on. Required when the workflowFile property is supplied. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "inputs",
+			Description: "The inputs of the workflow_dispatch event. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "waitForRun",
+			Description: "True to wait for the triggered workflow run to appear and log its url. Default is false",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "waitTimeoutSeconds",
+			Description: fmt.Sprintf("The number of seconds to wait for the triggered workflow run to appear. Default is %d", DefaultWaitTimeoutSeconds),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+	}
+	return append(properties, reactor.GetGitHubProperties("", false)...)
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
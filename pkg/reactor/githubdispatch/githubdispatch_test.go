@@ -0,0 +1,161 @@
+package githubdispatch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestReactor_ProcessEvent(t *testing.T) {
+	received := map[string]map[string]interface{}{}
+	runListCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/api/v3/repos/org/repo/dispatches" && req.Method == "POST",
+			req.URL.Path == "/api/v3/repos/org/repo/actions/workflows/deploy.yaml/dispatches" && req.Method == "POST":
+			body := map[string]interface{}{}
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			received[req.URL.Path] = body
+			rw.WriteHeader(http.StatusNoContent)
+		case req.URL.Path == "/api/v3/repos/org/repo/actions/workflows/deploy.yaml/runs" && req.Method == "GET":
+			if req.URL.Query().Get("event") != "workflow_dispatch" || req.URL.Query().Get("branch") != "main" {
+				t.Fatalf("Unexpected query: %v", req.URL.RawQuery)
+			}
+			runListCalls++
+			if runListCalls == 1 {
+				// the run has not been created yet, only an older run exists
+				_, _ = rw.Write([]byte(`{"total_count":1,"workflow_runs":[{"id":1,"html_url":"https://github/runs/1","created_at":"2020-01-01T00:00:00Z"}]}`))
+				return
+			}
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+				"total_count": 2,
+				"workflow_runs": []map[string]interface{}{
+					{"id": 2, "html_url": "https://github/runs/2", "created_at": time.Now().UTC().Format(time.RFC3339)},
+					{"id": 1, "html_url": "https://github/runs/1", "created_at": "2020-01-01T00:00:00Z"},
+				},
+			})
+		default:
+			t.Fatalf("Unexpected path: %v, method: %s", req.URL.Path, req.Method)
+		}
+	}))
+	defer server.Close()
+
+	githubProperties := func(properties map[string]config.PropertyAndValue) map[string]config.PropertyAndValue {
+		properties["token"] = config.PropertyAndValue{Value: "token"}
+		properties["org"] = config.PropertyAndValue{Value: "org"}
+		properties["repo"] = config.PropertyAndValue{Value: "repo"}
+		properties["enterpriseUrl"] = config.PropertyAndValue{Value: server.URL}
+		return properties
+	}
+	data := &message.EventData{ID: "1", Data: map[string]interface{}{"app": "app1", "version": "1.0.0"}, Attributes: map[string]string{"type": "release"}}
+
+	t.Run("repository_dispatch", func(t *testing.T) {
+		v := New()
+		v.SetLogger(zaptest.NewLogger(t))
+		v.SetReactor(config.ReactorConfig{Properties: githubProperties(map[string]config.PropertyAndValue{
+			"eventType":        {Value: "release-{{ .data.app }}"},
+			"clientPayload":    {Value: map[string]interface{}{"version": "{{ .data.version }}"}},
+			"includeEventData": {Value: "true"},
+		})})
+		err := v.ProcessEvent(context.Background(), data)
+		if err != nil {
+			t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+		}
+		body := received["/api/v3/repos/org/repo/dispatches"]
+		gotBody, _ := json.Marshal(body)
+		wantBody := `{"client_payload":{"attributes":{"type":"release"},"data":{"app":"app1","version":"1.0.0"},"version":"1.0.0"},"event_type":"release-app1"}`
+		if string(gotBody) != wantBody {
+			t.Errorf("Reactor.ProcessEvent() body = %s, want %s", gotBody, wantBody)
+		}
+	})
+
+	t.Run("workflow_dispatch", func(t *testing.T) {
+		v := New()
+		v.pollInterval = 10 * time.Millisecond
+		v.SetLogger(zaptest.NewLogger(t))
+		v.SetReactor(config.ReactorConfig{Properties: githubProperties(map[string]config.PropertyAndValue{
+			"workflowFile": {Value: "deploy.yaml"},
+			"ref":          {Value: "main"},
+			"inputs":       {Value: map[string]interface{}{"version": "{{ .data.version }}"}},
+			"waitForRun":   {Value: "true"},
+		})})
+		err := v.ProcessEvent(context.Background(), data)
+		if err != nil {
+			t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+		}
+		body := received["/api/v3/repos/org/repo/actions/workflows/deploy.yaml/dispatches"]
+		gotBody, _ := json.Marshal(body)
+		wantBody := `{"inputs":{"version":"1.0.0"},"ref":"main"}`
+		if string(gotBody) != wantBody {
+			t.Errorf("Reactor.ProcessEvent() body = %s, want %s", gotBody, wantBody)
+		}
+		if runListCalls != 2 {
+			t.Errorf("Reactor.ProcessEvent() workflow run list calls = %v, want 2", runListCalls)
+		}
+	})
+}
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		properties      map[string]config.PropertyAndValue
+		wantWaitTimeout time.Duration
+		wantErr         string
+	}{
+		{
+			name:            "repository_dispatch",
+			properties:      map[string]config.PropertyAndValue{"eventType": {Value: "release"}},
+			wantWaitTimeout: DefaultWaitTimeoutSeconds * time.Second,
+		},
+		{
+			name:            "workflow_dispatch",
+			properties:      map[string]config.PropertyAndValue{"workflowFile": {Value: "deploy.yaml"}, "ref": {Value: "main"}, "waitTimeoutSeconds": {Value: "10"}},
+			wantWaitTimeout: 10 * time.Second,
+		},
+		{
+			name:       "eventType and workflowFile",
+			properties: map[string]config.PropertyAndValue{"eventType": {Value: "release"}, "workflowFile": {Value: "deploy.yaml"}},
+			wantErr:    "one of the eventType or workflowFile properties must be supplied",
+		},
+		{
+			name:       "missing ref",
+			properties: map[string]config.PropertyAndValue{"workflowFile": {Value: "deploy.yaml"}},
+			wantErr:    "the ref property was not supplied or was empty, it is required when the workflowFile property is supplied",
+		},
+		{
+			name:       "invalid waitTimeoutSeconds",
+			properties: map[string]config.PropertyAndValue{"eventType": {Value: "release"}, "waitTimeoutSeconds": {Value: "soon"}},
+			wantErr:    "failed to convert the supplied waitTimeoutSeconds 'soon' to an integer. Error: strconv.Atoi: parsing \"soon\": invalid syntax",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.properties["token"] = config.PropertyAndValue{Value: "token"}
+			tt.properties["org"] = config.PropertyAndValue{Value: "org"}
+			tt.properties["repo"] = config.PropertyAndValue{Value: "repo"}
+			v := New()
+			v.SetLogger(zaptest.NewLogger(t))
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			got, err := v.GetReactorConfig(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{}}, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.WaitTimeout != tt.wantWaitTimeout {
+				t.Errorf("Reactor.GetReactorConfig() waitTimeout = %v, want %v", got.WaitTimeout, tt.wantWaitTimeout)
+			}
+		})
+	}
+}
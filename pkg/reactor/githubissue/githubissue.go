@@ -0,0 +1,363 @@
+package githubissue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	lcel "github.com/kcloutie/event-reactor/pkg/cel"
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/github"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+const (
+	ExistingIssueActionComment = "comment"
+	ExistingIssueActionUpdate  = "update"
+	ExistingIssueActionNone    = "none"
+)
+
+var ValidExistingIssueActions = []string{ExistingIssueActionComment, ExistingIssueActionUpdate, ExistingIssueActionNone}
+
+type Reactor struct {
+	Log           *zap.Logger
+	reactorName   string
+	reactorConfig config.ReactorConfig
+}
+
+type ReactorConfig struct {
+	GithubConfig *github.GitHubConfiguration
+
+	Marker              string
+	Title               string
+	Body                string
+	Comment             string
+	ExistingIssueAction string
+	Labels              []string
+	Assignees           []string
+
+	// True when the resolvedExpression matched and the open issue should be closed
+	Resolved     bool
+	CloseComment string
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName: "github/issue",
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor opens, updates and closes GitHub issues. The open issue is found using a marker that is hidden in the body of the issue, so a failure event can open a tracking issue, later failures can comment on or update the same issue and a success event can close it when the resolvedExpression CEL expression matches. The marker, title, body, comments, labels and assignees support go templating."
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+  reactorConfigs:
+  - name: test_github_issue
+    celExpressionFilter: attributes.type == 'build'
+    type: github/issue
+    properties:
+      marker:
+        value: "build/{{ .data.pipeline }}"
+      title:
+        value: "The {{ .data.pipeline }} build is failing"
+      body:
+        value: "The build failed on commit {{ .data.sha }}. See {{ .data.logUrl }}"
+      existingIssueAction:
+        value: comment
+      labels:
+        value:
+        - build-failure
+      assignees:
+        value:
+        - "{{ .data.author }}"
+      resolvedExpression:
+        value: data.status == 'succeeded'
+      closeComment:
+        value: "The build succeeded on commit {{ .data.sha }}"
+      token:
+        fromEnv: GIT_TOKEN
+      org:
+        value: my-org
+      repo:
+        value: my-repo
+`
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName))
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+
+	v.Log = v.Log.With(zap.String("org", reactorConfig.GithubConfig.Org), zap.String("repo", reactorConfig.GithubConfig.Repo), zap.String("marker", reactorConfig.Marker))
+
+	issue, err := reactorConfig.GithubConfig.FindOpenIssue(reactorConfig.Marker)
+	if err != nil {
+		return err
+	}
+
+	if reactorConfig.Resolved {
+		if issue == nil {
+			v.Log.Info("The resolvedExpression matched but there is no open issue to close")
+			return nil
+		}
+		_, err = reactorConfig.GithubConfig.CloseIssue(issue.GetNumber(), reactorConfig.CloseComment)
+		if err != nil {
+			return fmt.Errorf("unable to close github issue %v. Error: %v", issue.GetNumber(), err)
+		}
+		v.Log.Info("github issue has been closed", zap.Int("issue", issue.GetNumber()))
+		return nil
+	}
+
+	if issue == nil {
+		if reactorConfig.Title == "" {
+			return fmt.Errorf("the title property was not supplied or was empty, it is required to create an issue")
+		}
+		newIssue, err := reactorConfig.GithubConfig.CreateIssue(reactorConfig.Marker, reactorConfig.Title, reactorConfig.Body, reactorConfig.Labels, reactorConfig.Assignees)
+		if err != nil {
+			return fmt.Errorf("unable to create github issue. Error: %v", err)
+		}
+		v.Log.Info("github issue has been created", zap.Int("issue", newIssue.GetNumber()), zap.String("issueUrl", newIssue.GetHTMLURL()))
+		return nil
+	}
+
+	v.Log = v.Log.With(zap.Int("issue", issue.GetNumber()), zap.String("issueUrl", issue.GetHTMLURL()))
+	switch reactorConfig.ExistingIssueAction {
+	case ExistingIssueActionUpdate:
+		_, err = reactorConfig.GithubConfig.UpdateIssue(issue.GetNumber(), reactorConfig.Marker, reactorConfig.Title, reactorConfig.Body)
+		if err != nil {
+			return fmt.Errorf("unable to update github issue %v. Error: %v", issue.GetNumber(), err)
+		}
+		v.Log.Info("github issue has been updated")
+	case ExistingIssueActionComment:
+		_, err = reactorConfig.GithubConfig.WriteIssueComment(issue.GetNumber(), reactorConfig.Comment)
+		if err != nil {
+			return fmt.Errorf("unable to write github issue comment on issue %v. Error: %v", issue.GetNumber(), err)
+		}
+		v.Log.Info("github issue comment has been created")
+	default:
+		v.Log.Info("An open issue already exists, skipping the update of the issue")
+	}
+
+	return reactorConfig.GithubConfig.AddIssueLabelsAndAssignees(issue.GetNumber(), reactorConfig.Labels, reactorConfig.Assignees)
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+	config := &ReactorConfig{}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	getRendered := func(name string) (string, error) {
+		return reactor.GetRenderedStringProperty(ctx, v.Log, v.reactorConfig.Properties, name, data, v.reactorName, templateConfig)
+	}
+
+	getRenderedArray := func(name string) ([]string, error) {
+		values, err := v.reactorConfig.Properties[name].GetStringArrayValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+		results := []string{}
+		for i, value := range values {
+			rendered, err := reactor.RenderTemplateValue(ctx, value, fmt.Sprintf("%s/%d", name, i), data, v.reactorName, templateConfig)
+			if err != nil {
+				return nil, err
+			}
+			// templated values such as an optional assignee may render to an empty string
+			if trimmed := strings.TrimSpace(rendered); trimmed != "" {
+				results = append(results, trimmed)
+			}
+		}
+		return results, nil
+	}
+
+	// ===================================================================================
+	// Get marker
+	// ===================================================================================
+	var err error
+	config.Marker, err = getRendered("marker")
+	if err != nil {
+		return nil, err
+	}
+	config.Marker = strings.TrimSpace(config.Marker)
+	if config.Marker == "" {
+		return nil, fmt.Errorf("the marker property was not supplied or was empty")
+	}
+
+	// ===================================================================================
+	// Get resolvedExpression
+	// ===================================================================================
+	resolvedExpression, err := v.reactorConfig.Properties["resolvedExpression"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if resolvedExpression != "" {
+		val, err := lcel.CelEvaluate(ctx, resolvedExpression, message.GetCelDecl(), data.AsMap())
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate the resolvedExpression - %v", err)
+		}
+		var ok bool
+		config.Resolved, ok = val.Value().(bool)
+		if !ok {
+			return nil, fmt.Errorf("the resolvedExpression '%s' did not return a boolean, it returned '%v'", resolvedExpression, val.Value())
+		}
+	}
+	config.CloseComment, err = getRendered("closeComment")
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get title, body and comment
+	// ===================================================================================
+	config.Title, err = getRendered("title")
+	if err != nil {
+		return nil, err
+	}
+	config.Title = strings.TrimSpace(config.Title)
+	config.Body, err = getRendered("body")
+	if err != nil {
+		return nil, err
+	}
+	config.Comment, err = getRendered("comment")
+	if err != nil {
+		return nil, err
+	}
+	if config.Comment == "" {
+		config.Comment = config.Body
+	}
+
+	// ===================================================================================
+	// Get existingIssueAction
+	// ===================================================================================
+	existingIssueAction, err := v.reactorConfig.Properties["existingIssueAction"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.ExistingIssueAction = strings.ToLower(existingIssueAction)
+	if config.ExistingIssueAction == "" {
+		config.ExistingIssueAction = ExistingIssueActionComment
+	}
+	if !slices.Contains(ValidExistingIssueActions, config.ExistingIssueAction) {
+		return nil, fmt.Errorf("the existingIssueAction '%s' is not valid. Valid actions are %v", existingIssueAction, ValidExistingIssueActions)
+	}
+	if config.ExistingIssueAction == ExistingIssueActionComment && config.Comment == "" && !config.Resolved {
+		return nil, fmt.Errorf("one of the comment or body properties must be supplied when the existingIssueAction is %s", ExistingIssueActionComment)
+	}
+
+	// ===================================================================================
+	// Get labels and assignees
+	// ===================================================================================
+	config.Labels, err = getRenderedArray("labels")
+	if err != nil {
+		return nil, err
+	}
+	config.Assignees, err = getRenderedArray("assignees")
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get github configuration
+	// ===================================================================================
+	config.GithubConfig, err = reactor.GetGitHubConfiguration(ctx, log, v.reactorConfig.Properties, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	properties := []config.ReactorConfigProperty{
+		{
+			Name:        "marker",
+			Description: "The value used to find the open issue created by this reactor, for example build/{{ .data.pipeline }}. The marker is hidden in the body of the issue. This field supports go templating",
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "title",
+			Description: "The title of the issue. Required to create the issue. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "body",
+			Description: "The body of the issue. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "existingIssueAction",
+			Description: fmt.Sprintf("What to do when an open issue already exists. One of %v. The update action replaces the title and body of the issue. Default: %s", ValidExistingIssueActions, ExistingIssueActionComment),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "comment",
+			Description: "The comment written on an existing issue when the existingIssueAction is comment. Defaults to the body. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "labels",
+			Description: "The labels added to the issue. Existing labels are not removed. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeStringArray,
+		},
+		{
+			Name:        "assignees",
+			Description: "The users assigned to the issue. Existing assignees are not removed. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeStringArray,
+		},
+		{
+			Name:        "resolvedExpression",
+			Description: "A CEL expression returning true when the open issue should be closed, for example data.status == 'succeeded'",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "closeComment",
+			Description: "The comment written on the issue before it is closed. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+	}
+	return append(properties, reactor.GetGitHubProperties("", false)...)
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
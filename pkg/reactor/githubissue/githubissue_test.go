@@ -0,0 +1,192 @@
+package githubissue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestReactor_ProcessEvent(t *testing.T) {
+	issues := []map[string]interface{}{}
+	calls := []string{}
+	// the request uses lists of names for the labels and assignees while the response uses objects, so they are left out of the responses
+	issueResponse := func(issue map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"number": issue["number"], "state": issue["state"], "title": issue["title"], "body": issue["body"]}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body := map[string]interface{}{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+		switch {
+		case req.URL.Path == "/api/v3/repos/org/repo/issues" && req.Method == "GET":
+			open := []map[string]interface{}{}
+			for _, issue := range issues {
+				if issue["state"] == "open" {
+					open = append(open, issueResponse(issue))
+				}
+			}
+			_ = json.NewEncoder(rw).Encode(open)
+		case req.URL.Path == "/api/v3/repos/org/repo/issues" && req.Method == "POST":
+			body["number"] = len(issues) + 1
+			body["state"] = "open"
+			issues = append(issues, body)
+			rw.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(rw).Encode(issueResponse(body))
+		case req.URL.Path == "/api/v3/repos/org/repo/issues/1" && req.Method == "PATCH":
+			for k, v := range body {
+				issues[0][k] = v
+			}
+			_ = json.NewEncoder(rw).Encode(issueResponse(issues[0]))
+		case req.URL.Path == "/api/v3/repos/org/repo/issues/1/comments" && req.Method == "POST":
+			rw.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(rw).Encode(body)
+		case req.URL.Path == "/api/v3/repos/org/repo/issues/1/labels" && req.Method == "POST":
+			rw.Write([]byte(`[]`))
+		case req.URL.Path == "/api/v3/repos/org/repo/issues/1/assignees" && req.Method == "POST":
+			rw.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(rw).Encode(issueResponse(issues[0]))
+		default:
+			t.Fatalf("Unexpected path: %v, method: %s", req.URL.Path, req.Method)
+		}
+	}))
+	defer server.Close()
+
+	reactorConfig := config.ReactorConfig{
+		Properties: map[string]config.PropertyAndValue{
+			"marker":             {Value: "build/{{ .data.pipeline }}"},
+			"title":              {Value: "The {{ .data.pipeline }} build is failing"},
+			"body":               {Value: "Build {{ .data.build }} failed"},
+			"labels":             {Value: []interface{}{"build-failure"}},
+			"assignees":          {Value: []interface{}{"{{ .data.author }}"}},
+			"resolvedExpression": {Value: "data.status == 'succeeded'"},
+			"closeComment":       {Value: "Build {{ .data.build }} succeeded"},
+			"token":              {Value: "token"},
+			"org":                {Value: "org"},
+			"repo":               {Value: "repo"},
+			"enterpriseUrl":      {Value: server.URL},
+		},
+	}
+
+	events := []struct {
+		data      map[string]interface{}
+		wantCalls []string
+	}{
+		{
+			data:      map[string]interface{}{"pipeline": "app1", "build": "1", "status": "failed", "author": "octocat"},
+			wantCalls: []string{"GET /api/v3/repos/org/repo/issues", "POST /api/v3/repos/org/repo/issues"},
+		},
+		{
+			data: map[string]interface{}{"pipeline": "app1", "build": "2", "status": "failed", "author": ""},
+			wantCalls: []string{
+				"GET /api/v3/repos/org/repo/issues",
+				"POST /api/v3/repos/org/repo/issues/1/comments",
+				"POST /api/v3/repos/org/repo/issues/1/labels",
+			},
+		},
+		{
+			data: map[string]interface{}{"pipeline": "app1", "build": "3", "status": "succeeded", "author": ""},
+			wantCalls: []string{
+				"GET /api/v3/repos/org/repo/issues",
+				"POST /api/v3/repos/org/repo/issues/1/comments",
+				"PATCH /api/v3/repos/org/repo/issues/1",
+			},
+		},
+		{
+			data:      map[string]interface{}{"pipeline": "app1", "build": "4", "status": "succeeded", "author": ""},
+			wantCalls: []string{"GET /api/v3/repos/org/repo/issues"},
+		},
+	}
+	for i, event := range events {
+		calls = []string{}
+		v := New()
+		v.SetLogger(zaptest.NewLogger(t))
+		v.SetReactor(reactorConfig)
+		err := v.ProcessEvent(context.Background(), &message.EventData{ID: "1", Data: event.data})
+		if err != nil {
+			t.Fatalf("Reactor.ProcessEvent() event %d error = %v", i, err)
+		}
+		if fmt.Sprint(calls) != fmt.Sprint(event.wantCalls) {
+			t.Errorf("Reactor.ProcessEvent() event %d calls = %v, want %v", i, calls, event.wantCalls)
+		}
+	}
+
+	if len(issues) != 1 {
+		t.Fatalf("Reactor.ProcessEvent() issues = %v, want 1", issues)
+	}
+	issue := issues[0]
+	if issue["title"] != "The app1 build is failing" || issue["body"] != "Build 1 failed\n\n<!-- event-reactor:issue-marker=build/app1 -->" || issue["state"] != "closed" {
+		t.Errorf("Reactor.ProcessEvent() issue = %v", issue)
+	}
+	if fmt.Sprint(issue["assignees"]) != "[octocat]" || fmt.Sprint(issue["labels"]) != "[build-failure]" {
+		t.Errorf("Reactor.ProcessEvent() issue assignees = %v, labels = %v", issue["assignees"], issue["labels"])
+	}
+}
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		properties   map[string]config.PropertyAndValue
+		wantResolved bool
+		wantComment  string
+		wantErr      string
+	}{
+		{
+			name:         "resolved",
+			properties:   map[string]config.PropertyAndValue{"marker": {Value: "m"}, "resolvedExpression": {Value: "data.status == 'succeeded'"}},
+			wantResolved: true,
+		},
+		{
+			name:        "comment defaults to the body",
+			properties:  map[string]config.PropertyAndValue{"marker": {Value: "m"}, "body": {Value: "status {{ .data.status }}"}},
+			wantComment: "status succeeded",
+		},
+		{
+			name:       "missing comment",
+			properties: map[string]config.PropertyAndValue{"marker": {Value: "m"}},
+			wantErr:    "one of the comment or body properties must be supplied when the existingIssueAction is comment",
+		},
+		{
+			name:       "invalid existingIssueAction",
+			properties: map[string]config.PropertyAndValue{"marker": {Value: "m"}, "existingIssueAction": {Value: "replace"}},
+			wantErr:    "the existingIssueAction 'replace' is not valid. Valid actions are [comment update none]",
+		},
+		{
+			name:       "resolvedExpression not a boolean",
+			properties: map[string]config.PropertyAndValue{"marker": {Value: "m"}, "resolvedExpression": {Value: "data.status"}},
+			wantErr:    "the resolvedExpression 'data.status' did not return a boolean, it returned 'succeeded'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.properties["token"] = config.PropertyAndValue{Value: "token"}
+			tt.properties["org"] = config.PropertyAndValue{Value: "org"}
+			tt.properties["repo"] = config.PropertyAndValue{Value: "repo"}
+			v := New()
+			v.SetLogger(zaptest.NewLogger(t))
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			got, err := v.GetReactorConfig(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{"status": "succeeded"}}, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.Resolved != tt.wantResolved {
+				t.Errorf("Reactor.GetReactorConfig() resolved = %v, want %v", got.Resolved, tt.wantResolved)
+			}
+			if got.Comment != tt.wantComment {
+				t.Errorf("Reactor.GetReactorConfig() comment = %v, want %v", got.Comment, tt.wantComment)
+			}
+		})
+	}
+}
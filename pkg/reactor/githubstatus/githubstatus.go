@@ -0,0 +1,262 @@
+package githubstatus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/github"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+const (
+	DefaultContext       = "event-reactor"
+	MaxDescriptionLength = 140
+)
+
+var ValidStates = []string{"error", "failure", "pending", "success"}
+
+type Reactor struct {
+	Log           *zap.Logger
+	reactorName   string
+	reactorConfig config.ReactorConfig
+}
+
+type ReactorConfig struct {
+	GithubConfig *github.GitHubConfiguration
+
+	State       string
+	Context     string
+	TargetUrl   string
+	Description string
+
+	// When true, a pending or success status will not be written when the latest status of the same context is a failure or error
+	DoNotOverwriteFailure bool
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName: "github/status",
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor writes a commit status to a GitHub commit, which is displayed as a check on any pull request containing the commit. The state, context, target url and description support go templating so a single reactor can report the pending, success and failure states of a build. The reactor can optionally skip writing a status when the latest status of the same context is a failure or error, so a later success does not hide an earlier failure."
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+  reactorConfigs:
+  - name: test_github_status
+    celExpressionFilter: attributes.type == 'build'
+    type: github/status
+    properties:
+      state:
+        value: "{{ if eq .data.status \"succeeded\" }}success{{ else if eq .data.status \"running\" }}pending{{ else }}failure{{ end }}"
+      context:
+        value: "build/{{ .data.pipeline }}"
+      targetUrl:
+        value: "{{ .data.logUrl }}"
+      description:
+        value: "The {{ .data.pipeline }} build {{ .data.status }}"
+      doNotOverwriteFailure:
+        value: "true"
+      token:
+        fromEnv: GIT_TOKEN
+      org:
+        payloadValue:
+          propertyPaths:
+          - data.githubOrg
+      repo:
+        payloadValue:
+          propertyPaths:
+          - data.githubRepo
+      commitSha:
+        payloadValue:
+          propertyPaths:
+          - data.githubHeadSha
+`
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName))
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+
+	v.Log = v.Log.With(zap.String("org", reactorConfig.GithubConfig.Org), zap.String("repo", reactorConfig.GithubConfig.Repo), zap.String("commitSha", reactorConfig.GithubConfig.CommitSha), zap.String("state", reactorConfig.State), zap.String("context", reactorConfig.Context))
+
+	if !reactorConfig.DoNotOverwriteFailure {
+		_, err = reactorConfig.GithubConfig.WriteCommitCheckStatus(reactorConfig.State, reactorConfig.Context, reactorConfig.TargetUrl, reactorConfig.Description)
+		if err != nil {
+			return fmt.Errorf("unable to write the github commit status. Error: %v", err)
+		}
+		v.Log.Info("github commit status has been written")
+		return nil
+	}
+
+	status, err := reactorConfig.GithubConfig.WriteCommitCheckStatusIfFailedDoesNotExist(reactorConfig.State, reactorConfig.Context, reactorConfig.TargetUrl, reactorConfig.Description)
+	if err != nil {
+		return fmt.Errorf("unable to write the github commit status. Error: %v", err)
+	}
+	if status == nil {
+		v.Log.Info("The latest status of the context is a failure, the github commit status was not written")
+		return nil
+	}
+	v.Log.Info("github commit status has been written")
+	return nil
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+	config := &ReactorConfig{}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	getRendered := func(name string) (string, error) {
+		value, err := reactor.GetRenderedStringProperty(ctx, v.Log, v.reactorConfig.Properties, name, data, v.reactorName, templateConfig)
+		return strings.TrimSpace(value), err
+	}
+
+	// ===================================================================================
+	// Get state
+	// ===================================================================================
+	state, err := getRendered("state")
+	if err != nil {
+		return nil, err
+	}
+	state = strings.ToLower(state)
+	if !slices.Contains(ValidStates, state) {
+		return nil, fmt.Errorf("the state '%s' is not valid. Valid states are %v", state, ValidStates)
+	}
+	config.State = state
+
+	// ===================================================================================
+	// Get context
+	// ===================================================================================
+	config.Context, err = getRendered("context")
+	if err != nil {
+		return nil, err
+	}
+	if config.Context == "" {
+		config.Context = DefaultContext
+	}
+
+	// ===================================================================================
+	// Get targetUrl
+	// ===================================================================================
+	config.TargetUrl, err = getRendered("targetUrl")
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get description
+	// ===================================================================================
+	description, err := getRendered("description")
+	if err != nil {
+		return nil, err
+	}
+	// github rejects descriptions longer than 140 characters
+	if descriptionRunes := []rune(description); len(descriptionRunes) > MaxDescriptionLength {
+		description = string(descriptionRunes[:MaxDescriptionLength-3]) + "..."
+	}
+	config.Description = description
+
+	// ===================================================================================
+	// Get doNotOverwriteFailure
+	// ===================================================================================
+	doNotOverwriteFailureStr, err := v.reactorConfig.Properties["doNotOverwriteFailure"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if doNotOverwriteFailureStr != "" {
+		config.DoNotOverwriteFailure, err = strconv.ParseBool(doNotOverwriteFailureStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied doNotOverwriteFailure '%v' to a boolean. Error: %v", doNotOverwriteFailureStr, err)
+		}
+	}
+
+	// ===================================================================================
+	// Get github configuration
+	// ===================================================================================
+	config.GithubConfig, err = reactor.GetGitHubConfiguration(ctx, log, v.reactorConfig.Properties, data)
+	if err != nil {
+		return nil, err
+	}
+	if config.GithubConfig.CommitSha == "" {
+		return nil, fmt.Errorf("the github commitSha property was not supplied or was empty")
+	}
+
+	return config, nil
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	properties := []config.ReactorConfigProperty{
+		{
+			Name:        "state",
+			Description: fmt.Sprintf("The state of the status. One of %v. This field supports go templating", ValidStates),
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "context",
+			Description: fmt.Sprintf("The label used to identify the status, for example build/unit-tests. Default: %s. This field supports go templating", DefaultContext),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "targetUrl",
+			Description: "The url opened when clicking on the status, for example the build logs. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "description",
+			Description: fmt.Sprintf("A short description of the status. Descriptions longer than %d characters are truncated. This field supports go templating", MaxDescriptionLength),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "doNotOverwriteFailure",
+			Description: "When true, a pending or success status is not written when the latest status of the same context is a failure or error. Default is false",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+	}
+	return append(properties, reactor.GetGitHubProperties("The commit sha where the status will be written", true)...)
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
@@ -0,0 +1,196 @@
+package githubstatus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestReactor_ProcessEvent(t *testing.T) {
+	written := []map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token" {
+			t.Fatalf("Invalid token: '%v'", req.Header.Get("Authorization"))
+		}
+		if req.URL.Path == "/api/v3/repos/org/repo/commits/sha/statuses" && req.Method == "GET" {
+			rw.Write([]byte(`[{"id":1,"state":"failure","context":"build/app1"}]`))
+			return
+		}
+		if req.URL.Path == "/api/v3/repos/org/repo/statuses/sha" && req.Method == "POST" {
+			body := map[string]interface{}{}
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			written = append(written, body)
+			rw.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(rw).Encode(body)
+			return
+		}
+		t.Fatalf("Unexpected path: %v, method: %s", req.URL.Path, req.Method)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name                  string
+		status                string
+		doNotOverwriteFailure string
+		wantWritten           map[string]interface{}
+	}{
+		{
+			name:   "failure",
+			status: "failed",
+			wantWritten: map[string]interface{}{
+				"state":       "failure",
+				"context":     "build/app1",
+				"target_url":  "https://logs/app1",
+				"description": "The app1 build failed",
+			},
+		},
+		{
+			name:   "success overwrites the failure",
+			status: "succeeded",
+			wantWritten: map[string]interface{}{
+				"state":       "success",
+				"context":     "build/app1",
+				"target_url":  "https://logs/app1",
+				"description": "The app1 build succeeded",
+			},
+		},
+		{
+			name:                  "success does not overwrite the failure",
+			status:                "succeeded",
+			doNotOverwriteFailure: "true",
+			wantWritten:           nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			written = []map[string]interface{}{}
+			v := New()
+			v.SetLogger(zaptest.NewLogger(t))
+			v.SetReactor(config.ReactorConfig{
+				Properties: map[string]config.PropertyAndValue{
+					"state":                 {Value: `{{ if eq .data.status "succeeded" }}success{{ else }}failure{{ end }}`},
+					"context":               {Value: "build/{{ .data.app }}"},
+					"targetUrl":             {Value: "https://logs/{{ .data.app }}"},
+					"description":           {Value: "The {{ .data.app }} build {{ .data.status }}"},
+					"doNotOverwriteFailure": {Value: tt.doNotOverwriteFailure},
+					"token":                 {Value: "token"},
+					"org":                   {Value: "org"},
+					"repo":                  {Value: "repo"},
+					"commitSha":             {Value: "sha"},
+					"enterpriseUrl":         {Value: server.URL},
+				},
+			})
+			err := v.ProcessEvent(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{"app": "app1", "status": tt.status}})
+			if err != nil {
+				t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+			}
+			if tt.wantWritten == nil {
+				if len(written) != 0 {
+					t.Errorf("Reactor.ProcessEvent() written = %v, want none", written)
+				}
+				return
+			}
+			if len(written) != 1 {
+				t.Fatalf("Reactor.ProcessEvent() written = %v, want 1 status", written)
+			}
+			for k, want := range tt.wantWritten {
+				if written[0][k] != want {
+					t.Errorf("Reactor.ProcessEvent() %s = %v, want %v", k, written[0][k], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	githubProperties := func(properties map[string]config.PropertyAndValue) map[string]config.PropertyAndValue {
+		properties["token"] = config.PropertyAndValue{Value: "token"}
+		properties["org"] = config.PropertyAndValue{Value: "org"}
+		properties["repo"] = config.PropertyAndValue{Value: "repo"}
+		properties["commitSha"] = config.PropertyAndValue{Value: "sha"}
+		return properties
+	}
+	tests := []struct {
+		name            string
+		properties      map[string]config.PropertyAndValue
+		wantContext     string
+		wantDescription string
+		wantEnterprise  bool
+		wantErr         string
+	}{
+		{
+			name:        "defaults",
+			properties:  githubProperties(map[string]config.PropertyAndValue{"state": {Value: "Pending"}}),
+			wantContext: DefaultContext,
+		},
+		{
+			name: "long description is truncated",
+			properties: githubProperties(map[string]config.PropertyAndValue{
+				"state":       {Value: "success"},
+				"description": {Value: strings.Repeat("a", 200)},
+			}),
+			wantContext:     DefaultContext,
+			wantDescription: strings.Repeat("a", 137) + "...",
+		},
+		{
+			name: "enterprise url",
+			properties: githubProperties(map[string]config.PropertyAndValue{
+				"state":         {Value: "success"},
+				"enterpriseUrl": {Value: "https://github.example.com"},
+			}),
+			wantContext:    DefaultContext,
+			wantEnterprise: true,
+		},
+		{
+			name:       "invalid state",
+			properties: githubProperties(map[string]config.PropertyAndValue{"state": {Value: "done"}}),
+			wantErr:    "the state 'done' is not valid. Valid states are [error failure pending success]",
+		},
+		{
+			name: "invalid doNotOverwriteFailure",
+			properties: githubProperties(map[string]config.PropertyAndValue{
+				"state":                 {Value: "success"},
+				"doNotOverwriteFailure": {Value: "maybe"},
+			}),
+			wantErr: "failed to convert the supplied doNotOverwriteFailure 'maybe' to a boolean. Error: strconv.ParseBool: parsing \"maybe\": invalid syntax",
+		},
+		{
+			name:       "missing commit sha",
+			properties: map[string]config.PropertyAndValue{"state": {Value: "success"}, "token": {Value: "token"}, "org": {Value: "org"}, "repo": {Value: "repo"}},
+			wantErr:    "the github commitSha property was not supplied or was empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zaptest.NewLogger(t))
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			got, err := v.GetReactorConfig(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{}}, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.Context != tt.wantContext {
+				t.Errorf("Reactor.GetReactorConfig() context = %v, want %v", got.Context, tt.wantContext)
+			}
+			if got.Description != tt.wantDescription {
+				t.Errorf("Reactor.GetReactorConfig() description = %v, want %v", got.Description, tt.wantDescription)
+			}
+			if got.GithubConfig.IsEnterprise != tt.wantEnterprise {
+				t.Errorf("Reactor.GetReactorConfig() IsEnterprise = %v, want %v", got.GithubConfig.IsEnterprise, tt.wantEnterprise)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package httprequest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/http"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+type Reactor struct {
+	Log           *zap.Logger
+	reactorName   string
+	reactorConfig config.ReactorConfig
+}
+
+type ReactorConfig struct {
+	RequestConfig http.RequestConfig
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName: "http/request",
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+  reactorConfigs:
+  - name: test_http_request
+    celExpressionFilter: attributes.test == 'http'
+    disabled: false
+    type: http/request
+    properties:
+      method:
+        value: PATCH
+      url:
+        value: https://api.example.com/apps/{{ .data.app }}
+      queryParameters:
+        value:
+          environment: "{{ .attributes.environment }}"
+      headers:
+        value:
+          Content-Type: application/json
+      body:
+        value: '{"status": "{{ .data.status }}"}'
+      oauth2TokenUrl:
+        value: https://login.example.com/oauth2/token
+      oauth2ClientId:
+        value: my-client
+      oauth2ClientSecret:
+        fromEnv: OAUTH2_CLIENT_SECRET
+      expectedStatusCodes:
+        value:
+          - "200"
+          - "204"
+      responseAssertion:
+        value: response.status == 204 || response.body.status == data.status
+`
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor sends an http request using any method to a specified URL. The url, query parameters, headers and body support go templating. Basic, bearer token and OAuth2 client credentials authentication are supported. The response status code can be checked against a list of expected status codes and the response can be validated using a CEL expression. The response status, headers and body are logged and included in the error when the request fails."
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName))
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+	v.Log = v.Log.With(zap.String("method", reactorConfig.RequestConfig.Method), zap.String("url", reactorConfig.RequestConfig.Url))
+
+	resp, err := reactorConfig.RequestConfig.Send(ctx)
+	if resp == nil {
+		return err
+	}
+	if err != nil {
+		v.Log.Error("Http request failed", resp.LogFields()...)
+		return fmt.Errorf("%v\n%s", err, resp.String())
+	}
+	v.Log.Info("Http request sent", resp.LogFields()...)
+
+	return nil
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+
+	config := &ReactorConfig{
+		RequestConfig: http.RequestConfig{
+			Log:       v.Log,
+			EventData: data.AsMap(),
+		},
+	}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	render := func(name string, value string) (string, error) {
+		return reactor.RenderTemplateValue(ctx, value, name, data, v.reactorName, templateConfig)
+	}
+
+	// ===================================================================================
+	// Get url
+	// ===================================================================================
+	url, err := v.reactorConfig.Properties["url"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, fmt.Errorf("the url property was not supplied or was empty")
+	}
+	config.RequestConfig.Url, err = render("url", url)
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get method
+	// ===================================================================================
+	method, err := v.reactorConfig.Properties["method"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.RequestConfig.Method = strings.ToUpper(method)
+	if config.RequestConfig.Method == "" {
+		config.RequestConfig.Method = "GET"
+	}
+
+	// ===================================================================================
+	// Get queryParameters
+	// ===================================================================================
+	queryParameters, err := v.reactorConfig.Properties["queryParameters"].GetMapStringStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	for k, propv := range queryParameters {
+		queryParameters[k], err = render(fmt.Sprintf("queryParameters/%s", k), propv)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.RequestConfig.QueryParameters = queryParameters
+
+	// ===================================================================================
+	// Get headers
+	// ===================================================================================
+	headers, err := v.reactorConfig.Properties["headers"].GetMapStringStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	for k, propv := range headers {
+		headers[k], err = render(fmt.Sprintf("headers/%s", k), propv)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.RequestConfig.Headers = headers
+
+	// ===================================================================================
+	// Get body
+	// ===================================================================================
+	body, err := v.reactorConfig.Properties["body"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.RequestConfig.Body, err = render("body", body)
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get basic auth
+	// ===================================================================================
+	config.RequestConfig.BasicAuthUser, err = v.reactorConfig.Properties["basicAuthUser"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.RequestConfig.BasicAuthPassword, err = v.reactorConfig.Properties["basicAuthPassword"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get token
+	// ===================================================================================
+	config.RequestConfig.Token, err = v.reactorConfig.Properties["token"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.RequestConfig.TokenType, err = v.reactorConfig.Properties["tokenType"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get oauth2
+	// ===================================================================================
+	oauth2TokenUrl, err := v.reactorConfig.Properties["oauth2TokenUrl"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if oauth2TokenUrl != "" {
+		config.RequestConfig.OAuth2 = &http.OAuth2Config{
+			TokenUrl: oauth2TokenUrl,
+		}
+		config.RequestConfig.OAuth2.ClientId, err = v.reactorConfig.Properties["oauth2ClientId"].GetStringValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+		config.RequestConfig.OAuth2.ClientSecret, err = v.reactorConfig.Properties["oauth2ClientSecret"].GetStringValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+		if config.RequestConfig.OAuth2.ClientId == "" || config.RequestConfig.OAuth2.ClientSecret == "" {
+			return nil, fmt.Errorf("the oauth2ClientId and oauth2ClientSecret properties must be supplied when the oauth2TokenUrl property is supplied")
+		}
+		config.RequestConfig.OAuth2.Scopes, err = v.reactorConfig.Properties["oauth2Scopes"].GetStringArrayValue(ctx, v.Log, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// ===================================================================================
+	// Get expectedStatusCodes
+	// ===================================================================================
+	expectedStatusCodes, err := v.reactorConfig.Properties["expectedStatusCodes"].GetStringArrayValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	for _, codeStr := range expectedStatusCodes {
+		code, err := strconv.Atoi(strings.TrimSpace(codeStr))
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied expectedStatusCodes value '%v' to an integer. Error: %v", codeStr, err)
+		}
+		config.RequestConfig.ExpectedStatusCodes = append(config.RequestConfig.ExpectedStatusCodes, code)
+	}
+
+	// ===================================================================================
+	// Get responseAssertion
+	// ===================================================================================
+	config.RequestConfig.ResponseAssertion, err = v.reactorConfig.Properties["responseAssertion"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get maxRetries
+	// ===================================================================================
+	maxRetries := 4
+	maxRetriesStr, err := v.reactorConfig.Properties["maxRetries"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if maxRetriesStr != "" {
+		maxRetries, err = strconv.Atoi(maxRetriesStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied maxRetries '%v' to an integer. Error: %v", maxRetriesStr, err)
+		}
+	}
+	config.RequestConfig.MaxRetries = maxRetries
+
+	return config, nil
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	return []config.ReactorConfigProperty{
+		{
+			Name:        "url",
+			Description: "The url to send the request to. This field supports go templating",
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "method",
+			Description: "The http method of the request, for example GET, POST, PUT, PATCH or DELETE. Defaults to GET",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "queryParameters",
+			Description: "The query parameters to add to the url. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "headers",
+			Description: "The headers to send with the request. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "body",
+			Description: "The body of the request. If blank, no body is sent. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "basicAuthUser",
+			Description: "The user used for basic authentication",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "basicAuthPassword",
+			Description: "The password used for basic authentication",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "token",
+			Description: "The token to use for authentication. If set, the token will be sent in the Authorization header",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "tokenType",
+			Description: "The type of token to use for authentication. If not set, it will default to Bearer",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2TokenUrl",
+			Description: "The token url used to get a token using the OAuth2 client credentials flow. Tokens are cached until they expire",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2ClientId",
+			Description: "The client id used for the OAuth2 client credentials flow",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2ClientSecret",
+			Description: "The client secret used for the OAuth2 client credentials flow",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "oauth2Scopes",
+			Description: "The scopes requested using the OAuth2 client credentials flow",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeStringArray,
+		},
+		{
+			Name:        "expectedStatusCodes",
+			Description: "The status codes that are considered a success. Defaults to any 2xx or 3xx status code",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeStringArray,
+		},
+		{
+			Name:        "responseAssertion",
+			Description: "A CEL expression that must return true for the request to be considered a success. The response is available as response.status, response.headers and response.body (the decoded json body, or the body as a string), along with the event data, attributes and id",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "maxRetries",
+			Description: "The maximum number of times to retry the request when it is rate limited, fails with a 5xx status code that is not expected or fails with a network error. A POST or PATCH is only retried when it is rate limited, so it is not sent twice. Default is 4",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+	}
+
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
@@ -0,0 +1,105 @@
+package httprequest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap"
+)
+
+func TestReactor_ProcessEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		rw.Header().Set("Content-Type", "application/json")
+		if req.URL.Path == "/apps/app1" && req.Method == http.MethodPatch && req.URL.Query().Get("environment") == "prod" && req.Header.Get("X-App") == "app1" {
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{"received": string(body)})
+			return
+		}
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = rw.Write([]byte(`{"error":"bad request"}`))
+	}))
+	defer server.Close()
+
+	data := &message.EventData{
+		Data: map[string]interface{}{
+			"app":    "app1",
+			"status": "deployed",
+		},
+		Attributes: map[string]string{
+			"environment": "prod",
+		},
+		ID: "test-id",
+	}
+
+	tests := []struct {
+		name       string
+		properties map[string]config.PropertyAndValue
+		wantErr    string
+	}{
+		{
+			name: "templated request with assertion",
+			properties: map[string]config.PropertyAndValue{
+				"method":              {Value: "patch"},
+				"url":                 {Value: server.URL + "/apps/{{ .data.app }}"},
+				"queryParameters":     {Value: map[string]interface{}{"environment": "{{ .attributes.environment }}"}},
+				"headers":             {Value: map[string]interface{}{"X-App": "{{ .data.app }}"}},
+				"body":                {Value: `{"status":"{{ .data.status }}"}`},
+				"expectedStatusCodes": {Value: []interface{}{"200"}},
+				"responseAssertion":   {Value: `response.body.received == '{"status":"deployed"}'`},
+			},
+		},
+		{
+			name: "unexpected status includes the response",
+			properties: map[string]config.PropertyAndValue{
+				"url":        {Value: server.URL + "/other"},
+				"maxRetries": {Value: "0"},
+			},
+			wantErr: "the request to '" + server.URL + "/other' returned the status code 400 which is not a 2xx or 3xx status code\nSTATUS: 400\nHEADERS:\n  Content-Length: 23\n  Content-Type: application/json\n",
+		},
+		{
+			name: "missing oauth2 client",
+			properties: map[string]config.PropertyAndValue{
+				"url":            {Value: server.URL},
+				"oauth2TokenUrl": {Value: server.URL + "/token"},
+			},
+			wantErr: "the oauth2ClientId and oauth2ClientSecret properties must be supplied when the oauth2TokenUrl property is supplied",
+		},
+		{
+			name: "invalid expected status code",
+			properties: map[string]config.PropertyAndValue{
+				"url":                 {Value: server.URL},
+				"expectedStatusCodes": {Value: []string{"ok"}},
+			},
+			wantErr: "failed to convert the supplied expectedStatusCodes value 'ok' to an integer. Error: strconv.Atoi: parsing \"ok\": invalid syntax",
+		},
+		{
+			name:       "missing url",
+			properties: map[string]config.PropertyAndValue{},
+			wantErr:    "missing the following required properties: url",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			err := v.ProcessEvent(context.Background(), data)
+			if err != nil {
+				if tt.wantErr == "" || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Errorf("Reactor.ProcessEvent() error = %q, wantErr %q", err.Error(), tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Errorf("Reactor.ProcessEvent() error = nil, wantErr %v", tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,392 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/kubernetes"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+const DefaultWaitTimeoutSeconds = 300
+
+type Reactor struct {
+	Log           *zap.Logger
+	reactorName   string
+	reactorConfig config.ReactorConfig
+	newClient     func(kubeconfig string, kubeContext string) (*kubernetes.Client, error)
+	pollInterval  time.Duration
+}
+
+type ReactorConfig struct {
+	Objects      []*unstructured.Unstructured
+	ApplyOptions kubernetes.ApplyOptions
+	Kubeconfig   string
+	KubeContext  string
+
+	WaitForJob   bool
+	WaitTimeout  time.Duration
+	LogTailLines int64
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName:  "kubernetes",
+		newClient:    kubernetes.NewClient,
+		pollInterval: 5 * time.Second,
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor renders a templated kubernetes manifest and writes its objects to a cluster, for example a Job that runs a container with parameters from the event, a ConfigMap patch or an annotation bump that restarts a Deployment. The objects are written using server-side apply, create-only or patch mode with the in-cluster credentials or a kubeconfig. The reactor can optionally wait for the Jobs in the manifest to complete and log their final status and pod logs."
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+  reactorConfigs:
+  - name: test_kubernetes
+    celExpressionFilter: attributes.type == 'migrate'
+    type: kubernetes
+    properties:
+      mode:
+        value: create
+      namespace:
+        value: "{{ .data.namespace }}"
+      manifest:
+        value: |
+          apiVersion: batch/v1
+          kind: Job
+          metadata:
+            generateName: migrate-{{ .data.app }}-
+          spec:
+            backoffLimit: 0
+            ttlSecondsAfterFinished: 3600
+            template:
+              spec:
+                restartPolicy: Never
+                containers:
+                - name: migrate
+                  image: "{{ .data.image }}"
+                  args: ["migrate", "--version", "{{ .data.version }}"]
+      waitForJob:
+        value: "true"
+      waitTimeoutSeconds:
+        value: "600"
+`
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName))
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+	v.Log = v.Log.With(zap.String("mode", reactorConfig.ApplyOptions.Mode))
+
+	client, err := v.newClient(reactorConfig.Kubeconfig, reactorConfig.KubeContext)
+	if err != nil {
+		return err
+	}
+
+	results, err := client.Apply(ctx, v.Log, reactorConfig.Objects, reactorConfig.ApplyOptions)
+	if err != nil {
+		return err
+	}
+	if !reactorConfig.WaitForJob {
+		return nil
+	}
+
+	jobs := 0
+	for _, result := range results {
+		if result.Skipped || result.Object.GetKind() != "Job" || !strings.HasPrefix(result.Object.GetAPIVersion(), "batch/") {
+			continue
+		}
+		jobs++
+		err = v.waitForJob(ctx, client, result.Object, reactorConfig)
+		if err != nil {
+			return err
+		}
+	}
+	if jobs == 0 {
+		v.Log.Warn("waitForJob is true, however no jobs were written by the manifest")
+	}
+	return nil
+}
+
+func (v *Reactor) waitForJob(ctx context.Context, client *kubernetes.Client, job *unstructured.Unstructured, reactorConfig *ReactorConfig) error {
+	log := v.Log.With(zap.String("job", job.GetName()), zap.String("namespace", job.GetNamespace()))
+	log.Info("Waiting for the job to complete", zap.Duration("timeout", reactorConfig.WaitTimeout))
+
+	result, err := client.WaitForJob(ctx, job.GetNamespace(), job.GetName(), reactorConfig.WaitTimeout, v.pollInterval, reactorConfig.LogTailLines)
+	if err != nil {
+		return err
+	}
+
+	fields := []zap.Field{
+		zap.Bool("succeeded", result.Succeeded),
+		zap.Int32("succeededPods", result.Job.Status.Succeeded),
+		zap.Int32("failedPods", result.Job.Status.Failed),
+		zap.Any("podLogs", result.PodLogs),
+	}
+	if !result.Succeeded {
+		log.Error("Job failed", fields...)
+		return fmt.Errorf("the job '%s/%s' failed\n%s", job.GetNamespace(), job.GetName(), kubernetes.FormatPodLogs(result.PodLogs))
+	}
+	log.Info("Job completed", fields...)
+	return nil
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+	config := &ReactorConfig{
+		ApplyOptions: kubernetes.ApplyOptions{
+			Mode:         kubernetes.ModeApply,
+			FieldManager: kubernetes.DefaultFieldManager,
+			PatchType:    kubernetes.PatchTypeMerge,
+		},
+		WaitTimeout:  DefaultWaitTimeoutSeconds * time.Second,
+		LogTailLines: kubernetes.DefaultLogTailLines,
+	}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	getRendered := func(name string) (string, error) {
+		value, err := reactor.GetRenderedStringProperty(ctx, v.Log, v.reactorConfig.Properties, name, data, v.reactorName, templateConfig)
+		return strings.TrimSpace(value), err
+	}
+
+	// ===================================================================================
+	// Get manifest
+	// ===================================================================================
+	manifest, err := getRendered("manifest")
+	if err != nil {
+		return nil, err
+	}
+	config.Objects, err = kubernetes.ParseManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get mode and patchType
+	// ===================================================================================
+	mode, err := v.reactorConfig.Properties["mode"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if mode != "" {
+		mode = strings.ToLower(mode)
+		if !slices.Contains(kubernetes.ValidModes, mode) {
+			return nil, fmt.Errorf("the mode '%s' is not valid. Valid modes are %v", mode, kubernetes.ValidModes)
+		}
+		config.ApplyOptions.Mode = mode
+	}
+
+	patchType, err := v.reactorConfig.Properties["patchType"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if patchType != "" {
+		patchType = strings.ToLower(patchType)
+		if !slices.Contains(kubernetes.ValidPatchTypes, patchType) {
+			return nil, fmt.Errorf("the patchType '%s' is not valid. Valid patch types are %v", patchType, kubernetes.ValidPatchTypes)
+		}
+		config.ApplyOptions.PatchType = patchType
+	}
+
+	if config.ApplyOptions.Mode != kubernetes.ModeCreate {
+		for _, obj := range config.Objects {
+			if obj.GetName() == "" {
+				return nil, fmt.Errorf("the %s '%s' uses a generateName, which is only supported by the %s mode", obj.GetKind(), obj.GetGenerateName(), kubernetes.ModeCreate)
+			}
+		}
+	}
+
+	// ===================================================================================
+	// Get namespace
+	// ===================================================================================
+	config.ApplyOptions.Namespace, err = getRendered("namespace")
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get fieldManager and force
+	// ===================================================================================
+	fieldManager, err := v.reactorConfig.Properties["fieldManager"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if fieldManager != "" {
+		config.ApplyOptions.FieldManager = fieldManager
+	}
+
+	forceStr, err := v.reactorConfig.Properties["force"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if forceStr != "" {
+		config.ApplyOptions.Force, err = strconv.ParseBool(forceStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied force '%v' to a boolean. Error: %v", forceStr, err)
+		}
+	}
+
+	// ===================================================================================
+	// Get kubeconfig and kubeContext
+	// ===================================================================================
+	config.Kubeconfig, err = v.reactorConfig.Properties["kubeconfig"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.KubeContext, err = v.reactorConfig.Properties["kubeContext"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get waitForJob, waitTimeoutSeconds and logTailLines
+	// ===================================================================================
+	waitForJobStr, err := v.reactorConfig.Properties["waitForJob"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if waitForJobStr != "" {
+		config.WaitForJob, err = strconv.ParseBool(waitForJobStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied waitForJob '%v' to a boolean. Error: %v", waitForJobStr, err)
+		}
+	}
+
+	waitTimeoutStr, err := v.reactorConfig.Properties["waitTimeoutSeconds"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if waitTimeoutStr != "" {
+		waitTimeoutSeconds, err := strconv.Atoi(waitTimeoutStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied waitTimeoutSeconds '%v' to an integer. Error: %v", waitTimeoutStr, err)
+		}
+		config.WaitTimeout = time.Duration(waitTimeoutSeconds) * time.Second
+	}
+
+	logTailLinesStr, err := v.reactorConfig.Properties["logTailLines"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if logTailLinesStr != "" {
+		config.LogTailLines, err = strconv.ParseInt(logTailLinesStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied logTailLines '%v' to an integer. Error: %v", logTailLinesStr, err)
+		}
+	}
+
+	return config, nil
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	return []config.ReactorConfigProperty{
+		{
+			Name:        "manifest",
+			Description: "The yaml or json manifest of the objects to write to the cluster. Multiple objects can be separated using ---. This field supports go templating",
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "mode",
+			Description: fmt.Sprintf("How the objects are written. One of %v. apply uses server-side apply, create only creates objects that do not exist and supports generateName, patch patches existing objects. Default is %s", kubernetes.ValidModes, kubernetes.ModeApply),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "patchType",
+			Description: fmt.Sprintf("The type of patch used by the patch mode. One of %v. Default is %s", kubernetes.ValidPatchTypes, kubernetes.PatchTypeMerge),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "namespace",
+			Description: fmt.Sprintf("The namespace of the namespaced objects in the manifest that do not set a namespace. Default is %s. This field supports go templating", kubernetes.DefaultNamespace),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "fieldManager",
+			Description: fmt.Sprintf("The field manager recorded on the written objects. Default is %s", kubernetes.DefaultFieldManager),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "force",
+			Description: "When true, server-side apply takes ownership of fields owned by other field managers instead of failing with a conflict. Default is false",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "kubeconfig",
+			Description: "The path of the kubeconfig file used to connect to the cluster. When not supplied, the in-cluster service account credentials are used",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "kubeContext",
+			Description: "The context of the kubeconfig file to use. Defaults to the current context of the kubeconfig file",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "waitForJob",
+			Description: "When true, the reactor waits for the Jobs in the manifest to complete and logs their final status and pod logs. The reactor fails when a Job fails. Default is false",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "waitTimeoutSeconds",
+			Description: fmt.Sprintf("The number of seconds to wait for each Job to complete. Default is %d", DefaultWaitTimeoutSeconds),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "logTailLines",
+			Description: fmt.Sprintf("The number of lines of the logs of each pod of a completed Job that are logged. 0 disables the pod logs. Default is %d", kubernetes.DefaultLogTailLines),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+	}
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
@@ -0,0 +1,186 @@
+package kubernetes
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/kubernetes"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap/zaptest"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testManifest = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate-{{ .data.app }}
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: migrate
+        image: "{{ .data.image }}"
+`
+
+func TestReactor_ProcessEvent(t *testing.T) {
+	tests := []struct {
+		name          string
+		jobCondition  batchv1.JobConditionType
+		wantErr       string
+		wantNamespace string
+	}{
+		{
+			name:          "job completed",
+			jobCondition:  batchv1.JobComplete,
+			wantNamespace: "apps",
+		},
+		{
+			name:          "job failed",
+			jobCondition:  batchv1.JobFailed,
+			wantErr:       "the job 'apps/migrate-app1' failed\nPOD migrate-app1-abcde:\nfake logs\n",
+			wantNamespace: "apps",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := meta.NewDefaultRESTMapper(nil)
+			mapper.Add(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, meta.RESTScopeNamespace)
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			// the job status is read using the typed clientset, which is a separate fake
+			typedClient := fake.NewSimpleClientset(
+				&batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Name: "migrate-app1", Namespace: "apps"},
+					Spec:       batchv1.JobSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "migrate-app1"}}},
+					Status:     batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: tt.jobCondition, Status: corev1.ConditionTrue}}},
+				},
+				&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "migrate-app1-abcde", Namespace: "apps", Labels: map[string]string{"job-name": "migrate-app1"}},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "migrate"}}},
+				},
+			)
+
+			v := New()
+			v.SetLogger(zaptest.NewLogger(t))
+			v.pollInterval = time.Millisecond
+			v.newClient = func(kubeconfig string, kubeContext string) (*kubernetes.Client, error) {
+				return &kubernetes.Client{Dynamic: dynamicClient, Clientset: typedClient, Mapper: mapper}, nil
+			}
+			v.SetReactor(config.ReactorConfig{
+				Properties: map[string]config.PropertyAndValue{
+					"manifest":   {Value: testManifest},
+					"mode":       {Value: "create"},
+					"namespace":  {Value: "{{ .data.namespace }}"},
+					"waitForJob": {Value: "true"},
+				},
+			})
+			err := v.ProcessEvent(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{"app": "app1", "image": "migrate:1.0", "namespace": "apps"}})
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.ProcessEvent() error = %q, wantErr %q", err, tt.wantErr)
+				}
+			} else if tt.wantErr != "" {
+				t.Fatalf("Reactor.ProcessEvent() error = nil, wantErr %v", tt.wantErr)
+			}
+
+			created, err := dynamicClient.Resource(schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}).Namespace(tt.wantNamespace).Get(context.Background(), "migrate-app1", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("the job was not created - %v", err)
+			}
+			image, _, _ := unstructured.NestedSlice(created.Object, "spec", "template", "spec", "containers")
+			if image[0].(map[string]interface{})["image"] != "migrate:1.0" {
+				t.Errorf("Reactor.ProcessEvent() containers = %v, want the image to be rendered", image)
+			}
+		})
+	}
+}
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		properties     map[string]config.PropertyAndValue
+		wantMode       string
+		wantPatchType  string
+		wantWait       time.Duration
+		wantForce      bool
+		wantObjectKind string
+		wantErr        string
+	}{
+		{
+			name:           "defaults",
+			properties:     map[string]config.PropertyAndValue{"manifest": {Value: testManifest}},
+			wantMode:       kubernetes.ModeApply,
+			wantPatchType:  kubernetes.PatchTypeMerge,
+			wantWait:       DefaultWaitTimeoutSeconds * time.Second,
+			wantObjectKind: "Job",
+		},
+		{
+			name: "strategic patch",
+			properties: map[string]config.PropertyAndValue{
+				"manifest":           {Value: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\nspec:\n  template:\n    metadata:\n      annotations:\n        restartedAt: \"{{ .data.app }}\"\n"},
+				"mode":               {Value: "Patch"},
+				"patchType":          {Value: "strategic"},
+				"force":              {Value: "true"},
+				"waitTimeoutSeconds": {Value: "10"},
+			},
+			wantMode:       kubernetes.ModePatch,
+			wantPatchType:  kubernetes.PatchTypeStrategic,
+			wantWait:       10 * time.Second,
+			wantForce:      true,
+			wantObjectKind: "Deployment",
+		},
+		{
+			name:       "invalid mode",
+			properties: map[string]config.PropertyAndValue{"manifest": {Value: testManifest}, "mode": {Value: "replace"}},
+			wantErr:    "the mode 'replace' is not valid. Valid modes are [apply create patch]",
+		},
+		{
+			name:       "generateName requires the create mode",
+			properties: map[string]config.PropertyAndValue{"manifest": {Value: "apiVersion: batch/v1\nkind: Job\nmetadata:\n  generateName: migrate-\n"}},
+			wantErr:    "the Job 'migrate-' uses a generateName, which is only supported by the create mode",
+		},
+		{
+			name:       "invalid waitForJob",
+			properties: map[string]config.PropertyAndValue{"manifest": {Value: testManifest}, "waitForJob": {Value: "maybe"}},
+			wantErr:    "failed to convert the supplied waitForJob 'maybe' to a boolean. Error: strconv.ParseBool: parsing \"maybe\": invalid syntax",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zaptest.NewLogger(t))
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			got, err := v.GetReactorConfig(context.Background(), &message.EventData{ID: "1", Data: map[string]interface{}{"app": "app1", "image": "migrate:1.0"}}, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.ApplyOptions.Mode != tt.wantMode || got.ApplyOptions.PatchType != tt.wantPatchType || got.ApplyOptions.Force != tt.wantForce {
+				t.Errorf("Reactor.GetReactorConfig() options = %+v, want mode %v, patchType %v, force %v", got.ApplyOptions, tt.wantMode, tt.wantPatchType, tt.wantForce)
+			}
+			if got.WaitTimeout != tt.wantWait {
+				t.Errorf("Reactor.GetReactorConfig() WaitTimeout = %v, want %v", got.WaitTimeout, tt.wantWait)
+			}
+			if len(got.Objects) != 1 || got.Objects[0].GetKind() != tt.wantObjectKind || strings.Contains(got.Objects[0].GetName(), "{{") {
+				t.Errorf("Reactor.GetReactorConfig() objects = %v, want one rendered %s", got.Objects, tt.wantObjectKind)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package natspublish
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/nats"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+type Reactor struct {
+	Log           *zap.Logger
+	reactorName   string
+	reactorConfig config.ReactorConfig
+}
+
+type ReactorConfig struct {
+	Connection nats.ConnectOptions
+	Subject    string
+	Payload    string
+	Headers    map[string]string
+	JetStream  bool
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName: "nats/publish",
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor is used to publish a message to a NATS subject. When jetStream is true, the message is published to the JetStream stream capturing the subject and the reactor waits for the server to acknowledge it. The subject and payload can be Go templates. The Go template can use the data, attributes, and id properties of the event data. In addition the headers key value pairs can be Go templates."
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+- name: test_natsPublish
+  celExpressionFilter: has(attributes.eventType) && attributes['eventType'] == 'SECRET_VERSION_ADD'
+  failOnError: false
+  disabled: false
+  type: nats/publish
+  properties:
+    url:
+      value: nats://nats.example.com:4222
+    token:
+      fromEnv: NATS_TOKEN
+    subject:
+      value: apps.{{ .attributes.appName }}.redeploy
+    jetStream:
+      value: "true"
+    payload:
+      value: '{"appName":"testApp","platform":"cloudRun"}'
+    headers:
+      value:
+        eventType: "REDEPLOY_APP"
+        secretId: "{{ .attributes.secretId }}"
+`
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName))
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+
+	nc, err := nats.GetConnection("event-reactor", reactorConfig.Connection)
+	if err != nil {
+		return err
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, nats.DefaultPublishTimeout)
+	defer cancel()
+	messageId, err := nats.Publish(publishCtx, nc, reactorConfig.Subject, []byte(reactorConfig.Payload), reactorConfig.Headers, reactorConfig.JetStream)
+	if err != nil {
+		return err
+	}
+	v.Log.Info("Successfully published message to NATS", zap.String("messageId", messageId), zap.String("subject", reactorConfig.Subject), zap.Bool("jetStream", reactorConfig.JetStream))
+
+	return nil
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+
+	config := &ReactorConfig{}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	// ===================================================================================
+	// Get Connection
+	// ===================================================================================
+	url, err := v.reactorConfig.Properties["url"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.Connection.Url = url
+
+	credentialsFile, err := v.reactorConfig.Properties["credentialsFile"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.Connection.CredentialsFile = credentialsFile
+
+	token, err := v.reactorConfig.Properties["token"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.Connection.Token = token
+
+	user, err := v.reactorConfig.Properties["user"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.Connection.User = user
+
+	password, err := v.reactorConfig.Properties["password"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.Connection.Password = password
+
+	// ===================================================================================
+	// Get Subject
+	// ===================================================================================
+	subject, err := v.reactorConfig.Properties["subject"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(subject, "{{") {
+		renderedSubject, err := template.RenderTemplateValues(ctx, subject, fmt.Sprintf("%s_%s/subject", data.ID, v.reactorName), data.AsMap(), []string{}, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+		subject = string(renderedSubject)
+	}
+	if subject == "" {
+		return nil, fmt.Errorf("the subject property was not supplied or was empty")
+	}
+	config.Subject = subject
+
+	// ===================================================================================
+	// Get JetStream
+	// ===================================================================================
+	jetStream, err := v.reactorConfig.Properties["jetStream"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if jetStream != "" {
+		config.JetStream, err = strconv.ParseBool(jetStream)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied jetStream '%v' to a boolean. Error: %v", jetStream, err)
+		}
+	}
+
+	// ===================================================================================
+	// Get Payload
+	// ===================================================================================
+	payload, err := v.reactorConfig.Properties["payload"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	renderedPayload := []byte(payload)
+	if strings.Contains(payload, "{{") {
+		renderedPayload, err = template.RenderTemplateValues(ctx, payload, fmt.Sprintf("%s_%s/payload", data.ID, v.reactorName), data.AsMap(), []string{}, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.Payload = string(renderedPayload)
+
+	// ===================================================================================
+	// Get Headers
+	// ===================================================================================
+	headers, err := v.reactorConfig.Properties["headers"].GetMapStringStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+
+	renderedHeaders := map[string]string{}
+	for k, val := range headers {
+		renderedHeaderVal, err := template.RenderTemplateValues(ctx, val, fmt.Sprintf("%s_%s/header_%s", data.ID, v.reactorName, k), data.AsMap(), []string{}, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+		renderedHeaders[k] = string(renderedHeaderVal)
+	}
+	config.Headers = renderedHeaders
+
+	return config, nil
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	return []config.ReactorConfigProperty{
+		{
+			Name:        "url",
+			Description: "The url of the NATS server. Defaults to nats://127.0.0.1:4222",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "credentialsFile",
+			Description: "The path to the NATS user credentials file used to authenticate",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "token",
+			Description: "The token used to authenticate with the NATS server",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "user",
+			Description: "The user used to authenticate with the NATS server",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "password",
+			Description: "The password of the user used to authenticate with the NATS server",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "subject",
+			Description: "The subject to publish the message to. Supports Go templates",
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "jetStream",
+			Description: "When true, the message is published using JetStream and the reactor waits for the stream to acknowledge the message. Defaults to false",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "payload",
+			Description: "The payload of the message. Supports Go templates",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "headers",
+			Description: "The headers of the message. This should be a map of key value pairs and supports Go templates",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+	}
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
@@ -0,0 +1,139 @@
+package natspublish
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/nats/natstest"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+func TestReactor_ProcessEvent(t *testing.T) {
+	ns := natstest.RunServer(t)
+	nc, err := natsgo.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+
+	sub, err := nc.SubscribeSync("apps.>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := js.CreateStream(context.Background(), jetstream.StreamConfig{Name: "EVENTS", Subjects: []string{"events.>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := &message.EventData{
+		Data:       map[string]interface{}{"app": "app1"},
+		Attributes: map[string]string{"secretId": "secret1"},
+		ID:         "test-id",
+	}
+
+	tests := []struct {
+		name       string
+		properties map[string]config.PropertyAndValue
+		wantErr    string
+	}{
+		{
+			name: "core nats",
+			properties: map[string]config.PropertyAndValue{
+				"url":     {Value: ns.ClientURL()},
+				"subject": {Value: "apps.{{ .data.app }}.redeploy"},
+				"payload": {Value: `{"app":"{{ .data.app }}"}`},
+				"headers": {Value: map[string]interface{}{"secretId": "{{ .attributes.secretId }}"}},
+			},
+		},
+		{
+			name: "jetstream",
+			properties: map[string]config.PropertyAndValue{
+				"url":       {Value: ns.ClientURL()},
+				"subject":   {Value: "events.redeploy"},
+				"jetStream": {Value: "true"},
+				"payload":   {Value: `{"app":"{{ .data.app }}"}`},
+			},
+		},
+		{
+			name: "jetstream subject without stream",
+			properties: map[string]config.PropertyAndValue{
+				"url":       {Value: ns.ClientURL()},
+				"subject":   {Value: "nostream.redeploy"},
+				"jetStream": {Value: "true"},
+			},
+			wantErr: "failed to publish the message to the JetStream subject 'nostream.redeploy' - nats: no response from stream",
+		},
+		{
+			name: "invalid jetStream",
+			properties: map[string]config.PropertyAndValue{
+				"url":       {Value: ns.ClientURL()},
+				"subject":   {Value: "events.redeploy"},
+				"jetStream": {Value: "dude"},
+			},
+			wantErr: "failed to convert the supplied jetStream 'dude' to a boolean. Error: strconv.ParseBool: parsing \"dude\": invalid syntax",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			err := v.ProcessEvent(context.Background(), data)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.ProcessEvent() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Errorf("Reactor.ProcessEvent() error = nil, wantErr %v", tt.wantErr)
+			}
+		})
+	}
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("expected a core NATS message - %v", err)
+	}
+	if msg.Subject != "apps.app1.redeploy" || string(msg.Data) != `{"app":"app1"}` || msg.Header.Get("secretId") != "secret1" {
+		t.Errorf("unexpected core NATS message. subject = %s, data = %s, headers = %v", msg.Subject, string(msg.Data), msg.Header)
+	}
+
+	info, err := stream.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Errorf("expected 1 message in the JetStream stream, got %d", info.State.Msgs)
+	}
+}
+
+func TestReactor_GetReactorConfig_Headers(t *testing.T) {
+	v := New()
+	v.SetLogger(zap.NewNop())
+	v.SetReactor(config.ReactorConfig{Properties: map[string]config.PropertyAndValue{
+		"url":     {Value: "nats://localhost:4222"},
+		"subject": {Value: "apps.redeploy"},
+		"headers": {Value: map[string]interface{}{"secretId": "{{ .attributes.secretId }}"}},
+	}})
+
+	for _, secretId := range []string{"secret1", "secret2"} {
+		data := &message.EventData{Attributes: map[string]string{"secretId": secretId}, ID: secretId}
+		got, err := v.GetReactorConfig(context.Background(), data, zap.NewNop())
+		if err != nil {
+			t.Fatalf("Reactor.GetReactorConfig() error = %v", err)
+		}
+		if got.Headers["secretId"] != secretId {
+			t.Errorf("Reactor.GetReactorConfig() secretId header = %v, want %v", got.Headers["secretId"], secretId)
+		}
+	}
+}
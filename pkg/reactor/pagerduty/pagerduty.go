@@ -0,0 +1,351 @@
+package pagerduty
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	lcel "github.com/kcloutie/event-reactor/pkg/cel"
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/pagerduty"
+	"github.com/kcloutie/event-reactor/pkg/params/settings"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+type Reactor struct {
+	Log           *zap.Logger
+	reactorName   string
+	reactorConfig config.ReactorConfig
+}
+
+type ReactorConfig struct {
+	PagerDutyCfg pagerduty.PagerDutyConfiguration
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName: "pagerduty",
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor sends trigger, acknowledge and resolve events to the PagerDuty Events API v2. The action can be static or selected using a CEL expression so one reactor can trigger an incident on failure and resolve it on recovery using the same dedup key. The dedup key, summary, source, severity, component, group, class, custom details and links support go templating."
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+  reactorConfigs:
+  - name: test_pagerduty
+    celExpressionFilter: attributes.type == 'deploy'
+    disabled: false
+    type: pagerduty
+    properties:
+      routingKey:
+        fromEnv: PAGERDUTY_ROUTING_KEY
+      actionExpression:
+        value: "data.status == 'failed' ? 'trigger' : 'resolve'"
+      dedupKey:
+        value: "deploy-{{ .data.app }}"
+      summary:
+        value: "Deploy of {{ .data.app }} failed"
+      source:
+        value: "{{ .data.app }}"
+      severity:
+        value: critical
+      customDetails:
+        value:
+          environment: "{{ .attributes.environment }}"
+      links:
+        value:
+          Build logs: "{{ .data.logUrl }}"
+`
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName))
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+	event := reactorConfig.PagerDutyCfg.Event
+	if event.EventAction == "" {
+		v.Log.Info("The action expression did not return an action, no pagerduty event was sent")
+		return nil
+	}
+
+	resp, err := reactorConfig.PagerDutyCfg.Send(ctx)
+	if err != nil {
+		return err
+	}
+	v.Log.Info("Sent the pagerduty event", zap.String("action", event.EventAction), zap.String("dedupKey", resp.DedupKey), zap.String("status", resp.Status))
+	return nil
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+	config := &ReactorConfig{
+		PagerDutyCfg: pagerduty.PagerDutyConfiguration{
+			Log: log,
+		},
+	}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	getRendered := func(name string) (string, error) {
+		return reactor.GetRenderedStringProperty(ctx, v.Log, v.reactorConfig.Properties, name, data, v.reactorName, templateConfig)
+	}
+
+	// ===================================================================================
+	// Get routingKey
+	// ===================================================================================
+	routingKey, err := v.reactorConfig.Properties["routingKey"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if routingKey == "" {
+		return nil, fmt.Errorf("the routingKey property was not supplied or was empty")
+	}
+	config.PagerDutyCfg.Event.RoutingKey = routingKey
+
+	// ===================================================================================
+	// Get eventsUrl
+	// ===================================================================================
+	eventsUrl, err := v.reactorConfig.Properties["eventsUrl"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if eventsUrl == "" {
+		eventsUrl = settings.PagerDutyEventsUrlDefault
+	}
+	config.PagerDutyCfg.EventsUrl = eventsUrl
+
+	// ===================================================================================
+	// Get action
+	// ===================================================================================
+	action, err := v.reactorConfig.Properties["action"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	actionExpression, err := v.reactorConfig.Properties["actionExpression"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if action != "" && actionExpression != "" {
+		return nil, fmt.Errorf("only one of the action or actionExpression properties can be supplied")
+	}
+	if actionExpression != "" {
+		val, err := lcel.CelEvaluate(ctx, actionExpression, message.GetCelDecl(), data.AsMap())
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate the actionExpression - %v", err)
+		}
+		var ok bool
+		action, ok = val.Value().(string)
+		if !ok {
+			return nil, fmt.Errorf("the actionExpression '%s' did not return a string, it returned '%v'", actionExpression, val.Value())
+		}
+	} else if action == "" {
+		action = pagerduty.ActionTrigger
+	}
+	config.PagerDutyCfg.Event.EventAction = strings.ToLower(action)
+
+	// ===================================================================================
+	// Get dedupKey
+	// ===================================================================================
+	config.PagerDutyCfg.Event.DedupKey, err = getRendered("dedupKey")
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get payload
+	// ===================================================================================
+	payload := &pagerduty.EventPayload{}
+	fields := map[string]*string{
+		"summary":   &payload.Summary,
+		"source":    &payload.Source,
+		"severity":  &payload.Severity,
+		"component": &payload.Component,
+		"group":     &payload.Group,
+		"class":     &payload.Class,
+	}
+	for name, field := range fields {
+		*field, err = getRendered(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	payload.Severity = strings.ToLower(payload.Severity)
+	if payload.Severity == "" {
+		payload.Severity = "error"
+	}
+
+	customDetails, err := v.reactorConfig.Properties["customDetails"].GetMapStringInterfaceValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(customDetails) > 0 {
+		payload.CustomDetails, err = reactor.RenderTemplateParameters(ctx, customDetails, data, v.reactorName, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.PagerDutyCfg.Event.Payload = payload
+
+	// ===================================================================================
+	// Get links
+	// ===================================================================================
+	links, err := v.reactorConfig.Properties["links"].GetMapStringStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	linkTexts := make([]string, 0, len(links))
+	for text := range links {
+		linkTexts = append(linkTexts, text)
+	}
+	sort.Strings(linkTexts)
+	for _, text := range linkTexts {
+		href, err := reactor.RenderTemplateValue(ctx, links[text], "links/"+text, data, v.reactorName, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+		config.PagerDutyCfg.Event.Links = append(config.PagerDutyCfg.Event.Links, pagerduty.Link{Href: href, Text: text})
+	}
+
+	// ===================================================================================
+	// Get maxRetries
+	// ===================================================================================
+	config.PagerDutyCfg.MaxRetries = 4
+	maxRetriesStr, err := v.reactorConfig.Properties["maxRetries"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if maxRetriesStr != "" {
+		config.PagerDutyCfg.MaxRetries, err = strconv.Atoi(maxRetriesStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied maxRetries '%v' to an integer. Error: %v", maxRetriesStr, err)
+		}
+	}
+
+	return config, nil
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	return []config.ReactorConfigProperty{
+		{
+			Name:        "routingKey",
+			Description: "The integration key of the PagerDuty service",
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "action",
+			Description: "The event action. One of trigger, acknowledge or resolve. Defaults to trigger",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "actionExpression",
+			Description: "A CEL expression returning the event action, for example data.status == 'failed' ? 'trigger' : 'resolve'. When the expression returns an empty string no event is sent. Cannot be used with the action property",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "dedupKey",
+			Description: "The key used to correlate trigger, acknowledge and resolve events for the same alert. Required for the acknowledge and resolve actions. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "summary",
+			Description: "The summary of the alert. Required for the trigger action. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "source",
+			Description: "The affected system. Required for the trigger action. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "severity",
+			Description: "The severity of the alert. One of critical, error, warning or info. Defaults to error. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "component",
+			Description: "The component of the source that is responsible for the alert. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "group",
+			Description: "The logical grouping of components. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "class",
+			Description: "The class or type of the alert. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "customDetails",
+			Description: "Additional details about the alert. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "links",
+			Description: "Links to add to the alert. The key is the text of the link and the value is the url. The values support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "eventsUrl",
+			Description: fmt.Sprintf("The url of the PagerDuty Events API v2. Default: %s", settings.PagerDutyEventsUrlDefault),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "maxRetries",
+			Description: "The maximum number of times to retry sending the event. An event without a dedupKey is only retried when it is rate limited, so a failed trigger does not open a second incident. Default is 4",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+	}
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
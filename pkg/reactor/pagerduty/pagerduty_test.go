@@ -0,0 +1,134 @@
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/pagerduty"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestReactor_ProcessEvent(t *testing.T) {
+	received := []pagerduty.Event{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		event := pagerduty.Event{}
+		_ = json.NewDecoder(req.Body).Decode(&event)
+		received = append(received, event)
+		rw.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(rw).Encode(pagerduty.EventResponse{Status: "success", DedupKey: event.DedupKey})
+	}))
+	defer server.Close()
+
+	reactorConfig := config.ReactorConfig{
+		Properties: map[string]config.PropertyAndValue{
+			"routingKey":       {Value: "routing-key"},
+			"eventsUrl":        {Value: server.URL},
+			"actionExpression": {Value: "data.status == 'failed' ? 'trigger' : data.status == 'recovered' ? 'resolve' : ''"},
+			"dedupKey":         {Value: "deploy-{{ .data.app }}"},
+			"summary":          {Value: "Deploy of {{ .data.app }} failed"},
+			"source":           {Value: "{{ .data.app }}"},
+			"severity":         {Value: "Critical"},
+			"customDetails":    {Value: map[string]interface{}{"environment": "{{ .attributes.environment }}"}},
+			"links":            {Value: map[string]interface{}{"Logs": "https://logs/{{ .data.app }}"}},
+		},
+	}
+
+	events := []map[string]interface{}{
+		{"app": "app1", "status": "failed"},
+		{"app": "app1", "status": "running"},
+		{"app": "app1", "status": "recovered"},
+	}
+	for _, event := range events {
+		v := New()
+		v.SetLogger(zaptest.NewLogger(t))
+		v.SetReactor(reactorConfig)
+		err := v.ProcessEvent(context.Background(), &message.EventData{ID: "1", Data: event, Attributes: map[string]string{"environment": "prod"}})
+		if err != nil {
+			t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+		}
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("Reactor.ProcessEvent() events = %v, want 2", len(received))
+	}
+	trigger := received[0]
+	if trigger.EventAction != "trigger" || trigger.DedupKey != "deploy-app1" || trigger.RoutingKey != "routing-key" {
+		t.Errorf("Reactor.ProcessEvent() trigger = %+v", trigger)
+	}
+	if trigger.Payload == nil || trigger.Payload.Summary != "Deploy of app1 failed" || trigger.Payload.Source != "app1" || trigger.Payload.Severity != "critical" || trigger.Payload.CustomDetails["environment"] != "prod" {
+		t.Errorf("Reactor.ProcessEvent() trigger payload = %+v", trigger.Payload)
+	}
+	if len(trigger.Links) != 1 || trigger.Links[0].Href != "https://logs/app1" || trigger.Links[0].Text != "Logs" {
+		t.Errorf("Reactor.ProcessEvent() trigger links = %+v", trigger.Links)
+	}
+	resolve := received[1]
+	if resolve.EventAction != "resolve" || resolve.DedupKey != "deploy-app1" || resolve.Payload != nil {
+		t.Errorf("Reactor.ProcessEvent() resolve = %+v", resolve)
+	}
+	if reactorConfig.Properties["customDetails"].Value.(map[string]interface{})["environment"] != "{{ .attributes.environment }}" {
+		t.Errorf("Reactor.ProcessEvent() modified the reactor configuration")
+	}
+}
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	data := &message.EventData{ID: "1", Data: map[string]interface{}{"status": "failed"}, Attributes: map[string]string{}}
+	tests := []struct {
+		name       string
+		properties map[string]config.PropertyAndValue
+		wantAction string
+		wantErr    string
+	}{
+		{
+			name:       "default action",
+			properties: map[string]config.PropertyAndValue{"routingKey": {Value: "key"}},
+			wantAction: "trigger",
+		},
+		{
+			name:       "static action",
+			properties: map[string]config.PropertyAndValue{"routingKey": {Value: "key"}, "action": {Value: "Acknowledge"}},
+			wantAction: "acknowledge",
+		},
+		{
+			name: "action and expression",
+			properties: map[string]config.PropertyAndValue{
+				"routingKey":       {Value: "key"},
+				"action":           {Value: "trigger"},
+				"actionExpression": {Value: "'resolve'"},
+			},
+			wantErr: "only one of the action or actionExpression properties can be supplied",
+		},
+		{
+			name: "expression not a string",
+			properties: map[string]config.PropertyAndValue{
+				"routingKey":       {Value: "key"},
+				"actionExpression": {Value: "data.status == 'failed'"},
+			},
+			wantErr: "the actionExpression 'data.status == 'failed'' did not return a string, it returned 'true'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zaptest.NewLogger(t))
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			got, err := v.GetReactorConfig(context.Background(), data, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.PagerDutyCfg.Event.EventAction != tt.wantAction {
+				t.Errorf("Reactor.GetReactorConfig() action = %v, want %v", got.PagerDutyCfg.Event.EventAction, tt.wantAction)
+			}
+		})
+	}
+}
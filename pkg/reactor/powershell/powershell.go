@@ -137,15 +137,9 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 		return nil, err
 	}
 
-	for k, propv := range parameters {
-		switch val := propv.(type) {
-		case string:
-			renderedSubject, err := template.RenderTemplateValues(ctx, val, fmt.Sprintf("%s_%s/param_%s", data.ID, v.reactorName, k), data.AsMap(), []string{}, templateConfig)
-			if err != nil {
-				return nil, err
-			}
-			parameters[k] = string(renderedSubject)
-		}
+	parameters, err = reactor.RenderTemplateParameters(ctx, parameters, data, v.reactorName, templateConfig)
+	if err != nil {
+		return nil, err
 	}
 	config.Parameters = parameters
 
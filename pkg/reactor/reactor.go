@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
 	"github.com/kcloutie/event-reactor/pkg/template"
 	"go.uber.org/zap"
 )
@@ -77,6 +78,91 @@ func SetGoTemplateOptionValues(ctx context.Context, log *zap.Logger, config *tem
 	}
 }
 
+// RenderTemplateParameters renders each string value of the parameters as a Go template using the event data. The string values
+// of nested maps and arrays are rendered as well, other values are returned as is. The parameters are not modified, the rendered
+// values are returned in a new map
+func RenderTemplateParameters(ctx context.Context, parameters map[string]interface{}, data *message.EventData, reactorName string, templateConfig template.RenderTemplateOptions) (map[string]interface{}, error) {
+	results := map[string]interface{}{}
+	for k, propv := range parameters {
+		rendered, err := renderTemplateParameter(ctx, propv, fmt.Sprintf("param_%s", k), data, reactorName, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+		results[k] = rendered
+	}
+	return results, nil
+}
+
+// renderTemplateParameter renders the value when it is a string, or each of its values when it is a map or an array. The name
+// identifies the template in errors
+func renderTemplateParameter(ctx context.Context, value interface{}, name string, data *message.EventData, reactorName string, templateConfig template.RenderTemplateOptions) (interface{}, error) {
+	switch val := value.(type) {
+	case string:
+		rendered, err := template.RenderTemplateValues(ctx, val, fmt.Sprintf("%s_%s/%s", data.ID, reactorName, name), data.AsMap(), []string{}, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+		return string(rendered), nil
+	case map[string]interface{}:
+		results := map[string]interface{}{}
+		for k, v := range val {
+			rendered, err := renderTemplateParameter(ctx, v, fmt.Sprintf("%s/%s", name, k), data, reactorName, templateConfig)
+			if err != nil {
+				return nil, err
+			}
+			results[k] = rendered
+		}
+		return results, nil
+	case []interface{}:
+		results := make([]interface{}, 0, len(val))
+		for i, v := range val {
+			rendered, err := renderTemplateParameter(ctx, v, fmt.Sprintf("%s/%d", name, i), data, reactorName, templateConfig)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, rendered)
+		}
+		return results, nil
+	}
+	return value, nil
+}
+
+// RenderTemplateArgs renders each argument as a Go template using the event data
+func RenderTemplateArgs(ctx context.Context, args []string, data *message.EventData, reactorName string, templateConfig template.RenderTemplateOptions) ([]string, error) {
+	results := []string{}
+	for i, arg := range args {
+		rendered, err := template.RenderTemplateValues(ctx, arg, fmt.Sprintf("%s_%s/arg_%d", data.ID, reactorName, i), data.AsMap(), []string{}, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, string(rendered))
+	}
+	return results, nil
+}
+
+// RenderTemplateValue renders the value as a Go template using the event data. The name identifies the template in errors, a
+// value that does not contain the left delimiter is returned as is
+func RenderTemplateValue(ctx context.Context, value string, name string, data *message.EventData, reactorName string, templateConfig template.RenderTemplateOptions) (string, error) {
+	if !strings.Contains(value, templateConfig.LeftDelim) {
+		return value, nil
+	}
+	rendered, err := template.RenderTemplateValues(ctx, value, fmt.Sprintf("%s_%s/%s", data.ID, reactorName, name), data.AsMap(), []string{}, templateConfig)
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
+}
+
+// GetRenderedStringProperty gets the string value of the property and renders it as a Go template using the event data. An empty
+// string is returned when the property was not supplied
+func GetRenderedStringProperty(ctx context.Context, log *zap.Logger, properties map[string]config.PropertyAndValue, name string, data *message.EventData, reactorName string, templateConfig template.RenderTemplateOptions) (string, error) {
+	value, err := properties[name].GetStringValue(ctx, log, data)
+	if err != nil {
+		return "", err
+	}
+	return RenderTemplateValue(ctx, value, name, data, reactorName, templateConfig)
+}
+
 func GetRequiredPropertyNames(p ReactorInterface) []string {
 	results := []string{}
 	for _, p := range p.GetProperties() {
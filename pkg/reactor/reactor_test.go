@@ -3,11 +3,13 @@ package reactor
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
 	"github.com/kcloutie/event-reactor/pkg/template"
 	"go.uber.org/zap/zaptest"
 )
@@ -140,6 +142,120 @@ func TestSetGoTemplateOptionValues(t *testing.T) {
 	}
 }
 
+func TestGetRenderedStringProperty(t *testing.T) {
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	data := &message.EventData{ID: "1", Data: map[string]interface{}{"name": "world"}}
+
+	tests := []struct {
+		name           string
+		properties     map[string]config.PropertyAndValue
+		templateConfig template.RenderTemplateOptions
+		want           string
+	}{
+		{
+			name:           "template is rendered",
+			properties:     map[string]config.PropertyAndValue{"message": {Value: strPtr("hello {{ .data.name }}")}},
+			templateConfig: template.NewRenderTemplateOptions(),
+			want:           "hello world",
+		},
+		{
+			name:           "custom delimiters",
+			properties:     map[string]config.PropertyAndValue{"message": {Value: strPtr("hello <% .data.name %> {{ not a template }}")}},
+			templateConfig: template.RenderTemplateOptions{LeftDelim: "<%", RightDelim: "%>"},
+			want:           "hello world {{ not a template }}",
+		},
+		{
+			name:           "value without a template",
+			properties:     map[string]config.PropertyAndValue{"message": {Value: strPtr("hello")}},
+			templateConfig: template.NewRenderTemplateOptions(),
+			want:           "hello",
+		},
+		{
+			name:           "missing property",
+			properties:     map[string]config.PropertyAndValue{},
+			templateConfig: template.NewRenderTemplateOptions(),
+			want:           "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetRenderedStringProperty(ctx, logger, tt.properties, "message", data, "test", tt.templateConfig)
+			if err != nil {
+				t.Fatalf("GetRenderedStringProperty() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetRenderedStringProperty() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateParameters(t *testing.T) {
+	ctx := context.Background()
+	data := &message.EventData{ID: "1", Data: map[string]interface{}{"name": "world"}}
+
+	tests := []struct {
+		name       string
+		parameters map[string]interface{}
+		want       map[string]interface{}
+		wantErr    string
+	}{
+		{
+			name:       "string values are rendered",
+			parameters: map[string]interface{}{"message": "hello {{ .data.name }}", "count": 1},
+			want:       map[string]interface{}{"message": "hello world", "count": 1},
+		},
+		{
+			name: "nested values are rendered",
+			parameters: map[string]interface{}{
+				"nested": map[string]interface{}{"message": "hello {{ .data.name }}"},
+				"list":   []interface{}{"{{ .data.name }}", true},
+			},
+			want: map[string]interface{}{
+				"nested": map[string]interface{}{"message": "hello world"},
+				"list":   []interface{}{"world", true},
+			},
+		},
+		{
+			name:       "invalid template",
+			parameters: map[string]interface{}{"nested": map[string]interface{}{"message": "hello {{ .data.name "}},
+			wantErr:    "failed to parse the template for '1_test/param_nested/message'. Error: template: content:1: unclosed action",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderTemplateParameters(ctx, tt.parameters, data, "test", template.NewRenderTemplateOptions())
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("RenderTemplateParameters() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RenderTemplateParameters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateParameters_DoesNotModifyParameters(t *testing.T) {
+	parameters := map[string]interface{}{
+		"message": "hello {{ .data.name }}",
+		"nested":  map[string]interface{}{"message": "hello {{ .data.name }}"},
+	}
+	for _, name := range []string{"world", "there"} {
+		data := &message.EventData{ID: name, Data: map[string]interface{}{"name": name}}
+		got, err := RenderTemplateParameters(context.Background(), parameters, data, "test", template.NewRenderTemplateOptions())
+		if err != nil {
+			t.Fatalf("RenderTemplateParameters() error = %v", err)
+		}
+		if got["message"] != "hello "+name || got["nested"].(map[string]interface{})["message"] != "hello "+name {
+			t.Errorf("RenderTemplateParameters() = %v, want the values rendered with %s", got, name)
+		}
+	}
+}
+
 // strPtr is a helper function for creating a pointer to a string
 func strPtr(s string) *string {
 	return &s
@@ -295,8 +411,9 @@ EXAMPLE CONFIG
 		t.Run(tt.name, func(t *testing.T) {
 			got := GetReactorHelp(tt.args.p)
 
-			os.WriteFile("got.txt", []byte(got), 0644)
-			os.WriteFile("want.txt", []byte(tt.want), 0644)
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "got.txt"), []byte(got), 0644)
+			os.WriteFile(filepath.Join(dir, "want.txt"), []byte(tt.want), 0644)
 
 			if !strings.Contains(tt.want, got) {
 				t.Errorf("GetReactorHelp() = %v, want %v", got, tt.want)
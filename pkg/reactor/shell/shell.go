@@ -0,0 +1,344 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/shell"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+type Reactor struct {
+	Log           *zap.Logger
+	reactorName   string
+	reactorConfig config.ReactorConfig
+}
+
+type ReactorConfig struct {
+	ExecConfig      shell.ExecConfig
+	ParseJsonOutput bool
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName: "shell",
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+  reactorConfigs:
+  - name: test_shell
+    celExpressionFilter: attributes.test == 'shell'
+    disabled: false
+    type: shell
+    properties:
+      script:
+        value: |
+          set -e
+          echo "deploying $1 to $TARGET_ENV"
+          jq -r '.data.app' "$EVENT_DATA_FILE"
+      interpreter:
+        value: bash
+      args:
+        value:
+          - "{{ .data.app }}"
+      env:
+        value:
+          TARGET_ENV: "{{ .attributes.environment }}"
+      timeoutSeconds:
+        value: "120"
+`
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor executes an inline script or a script file using a configurable interpreter such as bash, sh or python. The event data is passed to the script as a json file, whose path is in the EVENT_DATA_FILE environment variable, or on stdin. The args and env values support go templating. The stdout, stderr and exit code of the script are logged and included in the error when the script fails."
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName), zap.String("interpreter", reactorConfig.ExecConfig.Interpreter), zap.String("scriptFile", reactorConfig.ExecConfig.ScriptFile))
+
+	result, err := reactorConfig.ExecConfig.Execute(ctx)
+	if result == nil {
+		return err
+	}
+
+	fields := []zap.Field{
+		zap.Int("exitCode", result.ExitCode),
+		zap.String("stdout", result.Stdout),
+		zap.String("stderr", result.Stderr),
+		zap.Bool("outputTruncated", result.OutputTruncated),
+	}
+	if reactorConfig.ParseJsonOutput {
+		var output interface{}
+		jsonErr := json.Unmarshal([]byte(result.Stdout), &output)
+		if jsonErr != nil {
+			v.Log.Warn(fmt.Sprintf("failed to parse the stdout of the script as json - %v", jsonErr))
+		} else {
+			fields = append(fields, zap.Any("output", output))
+		}
+	}
+
+	if err != nil {
+		v.Log.Error("Script failed", fields...)
+		return fmt.Errorf("%v\nEXIT CODE: %d\nSTDOUT:\n%s\nSTDERR:\n%s", err, result.ExitCode, result.Stdout, result.Stderr)
+	}
+	v.Log.Info("Script executed", fields...)
+	return nil
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+	config := ReactorConfig{
+		ExecConfig: shell.NewExecConfig(),
+	}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	// ===================================================================================
+	// Get script
+	// ===================================================================================
+	script, err := v.reactorConfig.Properties["script"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	scriptFile, err := v.reactorConfig.Properties["scriptFile"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if script == "" && scriptFile == "" {
+		return nil, fmt.Errorf("either the script or scriptFile property must be supplied")
+	}
+	if script != "" && scriptFile != "" {
+		return nil, fmt.Errorf("only one of the script or scriptFile properties can be supplied")
+	}
+	config.ExecConfig.Script = script
+	config.ExecConfig.ScriptFile = scriptFile
+
+	// ===================================================================================
+	// Get interpreter
+	// ===================================================================================
+	interpreter, err := v.reactorConfig.Properties["interpreter"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if interpreter != "" {
+		config.ExecConfig.Interpreter = interpreter
+	}
+
+	interpreterArgs, err := v.reactorConfig.Properties["interpreterArgs"].GetStringArrayValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.ExecConfig.InterpreterArgs = interpreterArgs
+
+	// ===================================================================================
+	// Get args
+	// ===================================================================================
+	args, err := v.reactorConfig.Properties["args"].GetStringArrayValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.ExecConfig.Args, err = reactor.RenderTemplateArgs(ctx, args, data, v.reactorName, templateConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get env
+	// ===================================================================================
+	env, err := v.reactorConfig.Properties["env"].GetMapStringInterfaceValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	env, err = reactor.RenderTemplateParameters(ctx, env, data, v.reactorName, templateConfig)
+	if err != nil {
+		return nil, err
+	}
+	config.ExecConfig.Env = map[string]string{}
+	for k, val := range env {
+		config.ExecConfig.Env[k] = fmt.Sprintf("%v", val)
+	}
+
+	// ===================================================================================
+	// Get workingDirectory
+	// ===================================================================================
+	workingDirectory, err := v.reactorConfig.Properties["workingDirectory"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.ExecConfig.WorkingDirectory = workingDirectory
+
+	// ===================================================================================
+	// Get timeoutSeconds
+	// ===================================================================================
+	timeoutStr, err := v.reactorConfig.Properties["timeoutSeconds"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if timeoutStr != "" {
+		timeout, err := strconv.Atoi(timeoutStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied timeoutSeconds '%v' to an integer. Error: %v", timeoutStr, err)
+		}
+		config.ExecConfig.Timeout = time.Duration(timeout) * time.Second
+	}
+
+	// ===================================================================================
+	// Get maxOutputBytes
+	// ===================================================================================
+	maxOutputStr, err := v.reactorConfig.Properties["maxOutputBytes"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if maxOutputStr != "" {
+		config.ExecConfig.MaxOutputBytes, err = strconv.Atoi(maxOutputStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied maxOutputBytes '%v' to an integer. Error: %v", maxOutputStr, err)
+		}
+	}
+
+	// ===================================================================================
+	// Get eventDataMode
+	// ===================================================================================
+	eventDataMode, err := v.reactorConfig.Properties["eventDataMode"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if eventDataMode != "" {
+		config.ExecConfig.EventDataMode = strings.ToLower(eventDataMode)
+	}
+	if config.ExecConfig.EventDataMode != shell.EventDataModeNone {
+		config.ExecConfig.EventData, err = json.Marshal(data.AsMap())
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal the event data to json - %v", err)
+		}
+	}
+
+	// ===================================================================================
+	// Get parseJsonOutput
+	// ===================================================================================
+	parseJsonOutput, err := v.reactorConfig.Properties["parseJsonOutput"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if parseJsonOutput != "" {
+		config.ParseJsonOutput, err = strconv.ParseBool(parseJsonOutput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied parseJsonOutput '%v' to a boolean. Error: %v", parseJsonOutput, err)
+		}
+	}
+
+	return &config, nil
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	return []config.ReactorConfigProperty{
+		{
+			Name:        "script",
+			Description: "The inline script to execute. Either script or scriptFile must be supplied",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "scriptFile",
+			Description: "The path to the script file to execute. Either script or scriptFile must be supplied",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "interpreter",
+			Description: "The interpreter used to run the script, for example bash, sh or python3. Defaults to bash",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "interpreterArgs",
+			Description: "The arguments passed to the interpreter before the script, for example -e",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeStringArray,
+		},
+		{
+			Name:        "args",
+			Description: "The arguments passed to the script. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeStringArray,
+		},
+		{
+			Name:        "env",
+			Description: "The environment variables set for the script in addition to the environment of the server. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "workingDirectory",
+			Description: "The working directory of the script. Defaults to the working directory of the server",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "timeoutSeconds",
+			Description: "The number of seconds the script is allowed to run before it is killed. Defaults to 60",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "maxOutputBytes",
+			Description: "The maximum number of bytes of stdout and stderr that are captured, the rest of the output is discarded. Defaults to 1048576",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "eventDataMode",
+			Description: "How the event data is passed to the script. One of file (the path is in the EVENT_DATA_FILE environment variable), stdin or none. Defaults to file",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "parseJsonOutput",
+			Description: "When true, the stdout of the script is parsed as json and logged as a structured field. Defaults to false",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+	}
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
@@ -0,0 +1,134 @@
+package shell
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"go.uber.org/zap"
+)
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	data := &message.EventData{
+		Data:       map[string]interface{}{"app": "app1"},
+		Attributes: map[string]string{"environment": "prod"},
+		ID:         "test-id",
+	}
+	tests := []struct {
+		name       string
+		properties map[string]config.PropertyAndValue
+		wantErr    string
+	}{
+		{
+			name: "valid",
+			properties: map[string]config.PropertyAndValue{
+				"script":          {Value: "echo $1"},
+				"args":            {Value: []interface{}{"{{ .data.app }}"}},
+				"env":             {Value: map[string]interface{}{"TARGET_ENV": "{{ .attributes.environment }}"}},
+				"timeoutSeconds":  {Value: "5"},
+				"maxOutputBytes":  {Value: "100"},
+				"eventDataMode":   {Value: "stdin"},
+				"parseJsonOutput": {Value: "true"},
+			},
+		},
+		{
+			name:       "missing script",
+			properties: map[string]config.PropertyAndValue{},
+			wantErr:    "either the script or scriptFile property must be supplied",
+		},
+		{
+			name: "script and script file",
+			properties: map[string]config.PropertyAndValue{
+				"script":     {Value: "echo"},
+				"scriptFile": {Value: "script.sh"},
+			},
+			wantErr: "only one of the script or scriptFile properties can be supplied",
+		},
+		{
+			name: "invalid timeout",
+			properties: map[string]config.PropertyAndValue{
+				"script":         {Value: "echo"},
+				"timeoutSeconds": {Value: "dude"},
+			},
+			wantErr: "failed to convert the supplied timeoutSeconds 'dude' to an integer. Error: strconv.Atoi: parsing \"dude\": invalid syntax",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			got, err := v.GetReactorConfig(context.Background(), data, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.ExecConfig.Args[0] != "app1" || got.ExecConfig.Env["TARGET_ENV"] != "prod" {
+				t.Errorf("Reactor.GetReactorConfig() args = %v, env = %v", got.ExecConfig.Args, got.ExecConfig.Env)
+			}
+			if got.ExecConfig.Timeout != 5*time.Second || got.ExecConfig.MaxOutputBytes != 100 || got.ExecConfig.EventDataMode != "stdin" || !got.ParseJsonOutput {
+				t.Errorf("Reactor.GetReactorConfig() got = %+v", got)
+			}
+			if !strings.Contains(string(got.ExecConfig.EventData), `"app":"app1"`) {
+				t.Errorf("Reactor.GetReactorConfig() event data = %s", string(got.ExecConfig.EventData))
+			}
+		})
+	}
+}
+
+func TestReactor_ProcessEvent(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	data := &message.EventData{
+		Data:       map[string]interface{}{"app": "app1"},
+		Attributes: map[string]string{},
+		ID:         "test-id",
+	}
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{
+			name:   "success",
+			script: `echo '{"app":"'$1'"}'`,
+		},
+		{
+			name:    "failure",
+			script:  `echo out; echo bad >&2; exit 2`,
+			wantErr: "the script exited with code 2\nEXIT CODE: 2\nSTDOUT:\nout\n\nSTDERR:\nbad\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: map[string]config.PropertyAndValue{
+				"interpreter":     {Value: "sh"},
+				"script":          {Value: tt.script},
+				"args":            {Value: []string{"{{ .data.app }}"}},
+				"parseJsonOutput": {Value: "true"},
+			}})
+			err := v.ProcessEvent(context.Background(), data)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.ProcessEvent() error = %q, wantErr %q", err.Error(), tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Errorf("Reactor.ProcessEvent() error = nil, wantErr %v", tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/correlation"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/params/settings"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/slack"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+)
+
+const (
+	CorrelationModeUpdate = "update"
+	CorrelationModeThread = "thread"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+type Reactor struct {
+	Log              *zap.Logger
+	reactorName      string
+	reactorConfig    config.ReactorConfig
+	correlationStore *correlation.Store
+}
+
+type ReactorConfig struct {
+	SlackCfg        slack.SlackConfiguration
+	CorrelationKey  string
+	CorrelationMode string
+	CorrelationTtl  time.Duration
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName:      "slack",
+		correlationStore: correlation.Default(),
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor sends a slack message using either an incoming webhook or the chat.postMessage api with a bot token. The text and Block Kit blocks support go templating. Messages can be posted as thread replies, and when a correlation key is supplied the message posted for an earlier event with the same key is updated, or replied to, instead of posting a new message. Rate limited requests are retried after the Retry-After duration."
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+  reactorConfigs:
+  - name: test_slack
+    celExpressionFilter: attributes.type == 'deploy'
+    disabled: false
+    type: slack
+    properties:
+      token:
+        fromEnv: SLACK_BOT_TOKEN
+      channel:
+        value: "#deploys"
+      text:
+        value: "Deploy of {{ .data.app }} {{ .data.status }}"
+      blocks:
+        value: |
+          [{"type": "section", "text": {"type": "mrkdwn", "text": "*{{ .data.app }}* deploy {{ .data.status }}"}}]
+      correlationKey:
+        value: "{{ .data.app }}-{{ .data.deployId }}"
+      correlationMode:
+        value: update
+`
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName))
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+	slackCfg := reactorConfig.SlackCfg
+
+	if slackCfg.WebhookUrl != "" {
+		return slackCfg.SendWebhook(ctx)
+	}
+
+	storeKey := ""
+	if reactorConfig.CorrelationKey != "" {
+		storeKey = fmt.Sprintf("%s/%s/%s", v.reactorName, slackCfg.Channel, reactorConfig.CorrelationKey)
+		// events with the same key are sent one at a time so only the first event posts a new message
+		unlock := v.correlationStore.Lock(storeKey)
+		defer unlock()
+		previous, exists := v.correlationStore.Get(storeKey)
+		if exists {
+			log := v.Log.With(zap.String("correlationKey", reactorConfig.CorrelationKey), zap.String("ts", previous["ts"]))
+			if reactorConfig.CorrelationMode == CorrelationModeUpdate {
+				_, err = slackCfg.UpdateMessage(ctx, previous["channel"], previous["ts"])
+				if err != nil {
+					return err
+				}
+				log.Info("Updated the slack message")
+				v.correlationStore.Set(storeKey, previous, reactorConfig.CorrelationTtl)
+				return nil
+			}
+			if slackCfg.ThreadTs == "" {
+				slackCfg.ThreadTs = previous["ts"]
+			}
+			_, err = slackCfg.PostMessage(ctx)
+			if err != nil {
+				return err
+			}
+			log.Info("Replied to the slack message")
+			return nil
+		}
+	}
+
+	resp, err := slackCfg.PostMessage(ctx)
+	if err != nil {
+		return err
+	}
+	v.Log.Info("Posted the slack message", zap.String("channel", resp.Channel), zap.String("ts", resp.Ts))
+	if storeKey != "" {
+		v.correlationStore.Set(storeKey, map[string]string{"channel": resp.Channel, "ts": resp.Ts}, reactorConfig.CorrelationTtl)
+	}
+	return nil
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+	config := &ReactorConfig{
+		SlackCfg: slack.SlackConfiguration{
+			Log: log,
+		},
+		CorrelationMode: CorrelationModeUpdate,
+		CorrelationTtl:  correlation.DefaultTtl,
+	}
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	render := func(name string, value string) (string, error) {
+		return reactor.RenderTemplateValue(ctx, value, name, data, v.reactorName, templateConfig)
+	}
+
+	// ===================================================================================
+	// Get webhookUrl, token and channel
+	// ===================================================================================
+	webhookUrl, err := v.reactorConfig.Properties["webhookUrl"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.SlackCfg.WebhookUrl = webhookUrl
+
+	token, err := v.reactorConfig.Properties["token"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.SlackCfg.Token = token
+
+	channel, err := v.reactorConfig.Properties["channel"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.SlackCfg.Channel, err = render("channel", channel)
+	if err != nil {
+		return nil, err
+	}
+
+	if webhookUrl == "" && (token == "" || config.SlackCfg.Channel == "") {
+		return nil, fmt.Errorf("either the webhookUrl property, or the token and channel properties must be supplied")
+	}
+	if webhookUrl != "" && token != "" {
+		return nil, fmt.Errorf("only one of the webhookUrl or token properties can be supplied")
+	}
+
+	// ===================================================================================
+	// Get apiUrl
+	// ===================================================================================
+	apiUrl, err := v.reactorConfig.Properties["apiUrl"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if apiUrl == "" {
+		apiUrl = settings.SlackApiUrlDefault
+	}
+	config.SlackCfg.ApiUrl = apiUrl
+
+	// ===================================================================================
+	// Get text and blocks
+	// ===================================================================================
+	text, err := v.reactorConfig.Properties["text"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.SlackCfg.Text, err = render("text", text)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := v.reactorConfig.Properties["blocks"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.SlackCfg.Blocks, err = render("blocks", blocks)
+	if err != nil {
+		return nil, err
+	}
+	if config.SlackCfg.Text == "" && config.SlackCfg.Blocks == "" {
+		return nil, fmt.Errorf("either the text or blocks property must be supplied")
+	}
+
+	// ===================================================================================
+	// Get threadTs
+	// ===================================================================================
+	threadTs, err := v.reactorConfig.Properties["threadTs"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.SlackCfg.ThreadTs, err = render("threadTs", threadTs)
+	if err != nil {
+		return nil, err
+	}
+
+	// ===================================================================================
+	// Get correlation
+	// ===================================================================================
+	correlationKey, err := v.reactorConfig.Properties["correlationKey"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	config.CorrelationKey, err = render("correlationKey", correlationKey)
+	if err != nil {
+		return nil, err
+	}
+	if config.CorrelationKey != "" && webhookUrl != "" {
+		return nil, fmt.Errorf("the correlationKey property requires the token property, messages sent using an incoming webhook cannot be updated")
+	}
+
+	correlationMode, err := v.reactorConfig.Properties["correlationMode"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if correlationMode != "" {
+		config.CorrelationMode = strings.ToLower(correlationMode)
+	}
+	if config.CorrelationMode != CorrelationModeUpdate && config.CorrelationMode != CorrelationModeThread {
+		return nil, fmt.Errorf("the correlationMode '%s' is not valid. Valid modes are %s and %s", correlationMode, CorrelationModeUpdate, CorrelationModeThread)
+	}
+
+	ttlStr, err := v.reactorConfig.Properties["correlationTtlMinutes"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if ttlStr != "" {
+		ttl, err := strconv.Atoi(ttlStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied correlationTtlMinutes '%v' to an integer. Error: %v", ttlStr, err)
+		}
+		config.CorrelationTtl = time.Duration(ttl) * time.Minute
+	}
+
+	// ===================================================================================
+	// Get maxRetries
+	// ===================================================================================
+	config.SlackCfg.MaxRetries = 4
+	maxRetriesStr, err := v.reactorConfig.Properties["maxRetries"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if maxRetriesStr != "" {
+		config.SlackCfg.MaxRetries, err = strconv.Atoi(maxRetriesStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied maxRetries '%v' to an integer. Error: %v", maxRetriesStr, err)
+		}
+	}
+
+	return config, nil
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	return []config.ReactorConfigProperty{
+		{
+			Name:        "webhookUrl",
+			Description: "The url of the slack incoming webhook. Either the webhookUrl or the token and channel must be supplied",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "token",
+			Description: "The slack bot token used to call chat.postMessage and chat.update. The token requires the chat:write scope",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "channel",
+			Description: "The channel name or id to post the message to. Required when the token is supplied. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "apiUrl",
+			Description: fmt.Sprintf("The base url of the slack web api. Default: %s", settings.SlackApiUrlDefault),
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "text",
+			Description: "The text of the message. When blocks are supplied, the text is used in notifications. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "blocks",
+			Description: "The Block Kit json of the message. Either an array of blocks, or an object containing a blocks array as exported by the Block Kit Builder. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "threadTs",
+			Description: "The ts of the message to reply to in a thread. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "correlationKey",
+			Description: "A key identifying related events, for example the deployment id. The ts of the posted message is remembered so later events with the same key update, or reply to, the message. The messages are remembered in the memory of the server, they are lost when the server restarts and are not shared between replicas. Events with the same key are sent one at a time. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "correlationMode",
+			Description: "What happens when a message was already posted for the correlation key. One of update (replace the message) or thread (reply in the thread of the message). Defaults to update",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "correlationTtlMinutes",
+			Description: "The number of minutes a posted message is remembered for the correlation key. Defaults to 1440",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "maxRetries",
+			Description: "The maximum number of times to retry the request, including when slack rate limits the request. Default is 4",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+	}
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
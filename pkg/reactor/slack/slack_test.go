@@ -0,0 +1,234 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/correlation"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/slack"
+	"go.uber.org/zap"
+)
+
+type slackCall struct {
+	Method  string
+	Request slack.MessageRequest
+}
+
+func newSlackServer(t *testing.T) (*httptest.Server, func() []slackCall) {
+	mu := sync.Mutex{}
+	calls := []slackCall{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body := slack.MessageRequest{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		mu.Lock()
+		calls = append(calls, slackCall{Method: req.URL.Path, Request: body})
+		count := len(calls)
+		mu.Unlock()
+		if req.URL.Path == "/webhook" {
+			_, _ = rw.Write([]byte("ok"))
+			return
+		}
+		ts := body.Ts
+		if ts == "" {
+			ts = fmt.Sprintf("1700000000.00000%d", count)
+		}
+		_ = json.NewEncoder(rw).Encode(slack.ApiResponse{Ok: true, Channel: "C123", Ts: ts})
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []slackCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]slackCall{}, calls...)
+	}
+}
+
+func TestReactor_ProcessEvent_Correlation(t *testing.T) {
+	tests := []struct {
+		name            string
+		correlationMode string
+		wantCalls       []slackCall
+	}{
+		{
+			name:            "update",
+			correlationMode: "update",
+			wantCalls: []slackCall{
+				{Method: "/api/chat.postMessage", Request: slack.MessageRequest{Channel: "#deploys", Text: "app1 deploy started"}},
+				{Method: "/api/chat.update", Request: slack.MessageRequest{Channel: "C123", Ts: "1700000000.000001", Text: "app1 deploy finished"}},
+				{Method: "/api/chat.postMessage", Request: slack.MessageRequest{Channel: "#deploys", Text: "app2 deploy started"}},
+			},
+		},
+		{
+			name:            "thread",
+			correlationMode: "thread",
+			wantCalls: []slackCall{
+				{Method: "/api/chat.postMessage", Request: slack.MessageRequest{Channel: "#deploys", Text: "app1 deploy started"}},
+				{Method: "/api/chat.postMessage", Request: slack.MessageRequest{Channel: "#deploys", ThreadTs: "1700000000.000001", Text: "app1 deploy finished"}},
+				{Method: "/api/chat.postMessage", Request: slack.MessageRequest{Channel: "#deploys", Text: "app2 deploy started"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, getCalls := newSlackServer(t)
+			store := correlation.NewStore()
+			events := []map[string]interface{}{
+				{"app": "app1", "status": "started"},
+				{"app": "app1", "status": "finished"},
+				{"app": "app2", "status": "started"},
+			}
+			for i, event := range events {
+				v := New()
+				v.correlationStore = store
+				v.SetLogger(zap.NewNop())
+				v.SetReactor(config.ReactorConfig{Properties: map[string]config.PropertyAndValue{
+					"apiUrl":          {Value: server.URL + "/api"},
+					"token":           {Value: "token"},
+					"channel":         {Value: "#deploys"},
+					"text":            {Value: "{{ .data.app }} deploy {{ .data.status }}"},
+					"correlationKey":  {Value: "{{ .data.app }}"},
+					"correlationMode": {Value: tt.correlationMode},
+				}})
+				err := v.ProcessEvent(context.Background(), &message.EventData{ID: fmt.Sprintf("%d", i), Data: event, Attributes: map[string]string{}})
+				if err != nil {
+					t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+				}
+			}
+			got := getCalls()
+			if len(got) != len(tt.wantCalls) {
+				t.Fatalf("Reactor.ProcessEvent() calls = %+v, want %+v", got, tt.wantCalls)
+			}
+			for i := range got {
+				if got[i].Method != tt.wantCalls[i].Method || fmt.Sprintf("%+v", got[i].Request) != fmt.Sprintf("%+v", tt.wantCalls[i].Request) {
+					t.Errorf("Reactor.ProcessEvent() call %d = %+v, want %+v", i, got[i], tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	data := &message.EventData{
+		ID:         "1",
+		Data:       map[string]interface{}{"app": "app1"},
+		Attributes: map[string]string{},
+	}
+	tests := []struct {
+		name       string
+		properties map[string]config.PropertyAndValue
+		wantErr    string
+	}{
+		{
+			name: "webhook with blocks",
+			properties: map[string]config.PropertyAndValue{
+				"webhookUrl": {Value: "http://localhost/webhook"},
+				"blocks":     {Value: `[{"type":"section","text":{"type":"plain_text","text":"{{ .data.app }}"}}]`},
+			},
+		},
+		{
+			name: "no destination",
+			properties: map[string]config.PropertyAndValue{
+				"text": {Value: "text"},
+			},
+			wantErr: "either the webhookUrl property, or the token and channel properties must be supplied",
+		},
+		{
+			name: "webhook and token",
+			properties: map[string]config.PropertyAndValue{
+				"webhookUrl": {Value: "http://localhost/webhook"},
+				"token":      {Value: "token"},
+				"text":       {Value: "text"},
+			},
+			wantErr: "only one of the webhookUrl or token properties can be supplied",
+		},
+		{
+			name: "no message",
+			properties: map[string]config.PropertyAndValue{
+				"webhookUrl": {Value: "http://localhost/webhook"},
+			},
+			wantErr: "either the text or blocks property must be supplied",
+		},
+		{
+			name: "webhook with correlation key",
+			properties: map[string]config.PropertyAndValue{
+				"webhookUrl":     {Value: "http://localhost/webhook"},
+				"text":           {Value: "text"},
+				"correlationKey": {Value: "key"},
+			},
+			wantErr: "the correlationKey property requires the token property, messages sent using an incoming webhook cannot be updated",
+		},
+		{
+			name: "invalid correlation mode",
+			properties: map[string]config.PropertyAndValue{
+				"token":           {Value: "token"},
+				"channel":         {Value: "#deploys"},
+				"text":            {Value: "text"},
+				"correlationMode": {Value: "dude"},
+			},
+			wantErr: "the correlationMode 'dude' is not valid. Valid modes are update and thread",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			got, err := v.GetReactorConfig(context.Background(), data, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got.SlackCfg.Blocks != `[{"type":"section","text":{"type":"plain_text","text":"app1"}}]` {
+				t.Errorf("Reactor.GetReactorConfig() blocks = %v", got.SlackCfg.Blocks)
+			}
+		})
+	}
+}
+
+func TestReactor_ProcessEvent_ConcurrentCorrelation(t *testing.T) {
+	server, getCalls := newSlackServer(t)
+	store := correlation.NewStore()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v := New()
+			v.correlationStore = store
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: map[string]config.PropertyAndValue{
+				"apiUrl":         {Value: server.URL + "/api"},
+				"token":          {Value: "token"},
+				"channel":        {Value: "#deploys"},
+				"text":           {Value: "deploy {{ .data.status }}"},
+				"correlationKey": {Value: "app1"},
+			}})
+			err := v.ProcessEvent(context.Background(), &message.EventData{ID: fmt.Sprintf("%d", i), Data: map[string]interface{}{"status": i}, Attributes: map[string]string{}})
+			if err != nil {
+				t.Errorf("Reactor.ProcessEvent() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	posted := 0
+	for _, call := range getCalls() {
+		if call.Method == "/api/chat.postMessage" {
+			posted++
+		}
+	}
+	if posted != 1 {
+		t.Errorf("Reactor.ProcessEvent() posted %d messages, want 1 message updated by the other events", posted)
+	}
+}
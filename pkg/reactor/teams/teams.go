@@ -0,0 +1,202 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/reactor"
+	"github.com/kcloutie/event-reactor/pkg/teams"
+	"github.com/kcloutie/event-reactor/pkg/template"
+
+	"go.uber.org/zap"
+)
+
+var _ reactor.ReactorInterface = (*Reactor)(nil)
+
+type Reactor struct {
+	Log           *zap.Logger
+	reactorName   string
+	reactorConfig config.ReactorConfig
+}
+
+type ReactorConfig struct {
+	TeamsCfg teams.TeamsConfiguration
+}
+
+func New() *Reactor {
+	return &Reactor{
+		reactorName: "teams",
+	}
+}
+
+func (v *Reactor) SetLogger(logger *zap.Logger) {
+	v.Log = logger
+}
+func (v *Reactor) GetName() string {
+	return v.reactorName
+}
+
+func (v *Reactor) GetDescription() string {
+	return "This reactor posts an adaptive card to a Microsoft Teams incoming webhook or Workflows url. The card uses the same json as the webex reactor so one card template can target both. The message is used as the fallback text of the card, or is sent as a simple card when no card is supplied. The message and card support go templating."
+}
+
+func (v *Reactor) GetConfigExample() string {
+	return `
+  reactorConfigs:
+  - name: test_teams
+    celExpressionFilter: attributes.test == 'teams'
+    disabled: false
+    type: teams
+    properties:
+      webhookUrl:
+        fromEnv: TEAMS_WEBHOOK_URL
+      message:
+        value: "Deploy of {{ .data.app }} failed"
+      card:
+        value: |
+          {
+            "type": "AdaptiveCard",
+            "version": "1.4",
+            "body": [{"type": "TextBlock", "text": "<at>Jane Doe</at> deploy of {{ .data.app }} failed", "wrap": true}]
+          }
+      mentions:
+        value:
+          Jane Doe: jane.doe@example.com
+`
+}
+
+func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
+	v.reactorConfig = reactor
+}
+
+func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) error {
+	v.Log = v.Log.With(zap.String("reactor", v.reactorName))
+	_, err := reactor.HasRequiredProperties(v.reactorConfig.Properties, v.GetRequiredPropertyNames())
+	if err != nil {
+		return err
+	}
+
+	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
+	if err != nil {
+		return err
+	}
+
+	return reactorConfig.TeamsCfg.Send(ctx)
+}
+
+func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+
+	templateConfig := template.NewRenderTemplateOptions()
+	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
+
+	webhookUrl, err := v.reactorConfig.Properties["webhookUrl"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if webhookUrl == "" {
+		return nil, fmt.Errorf("the webhookUrl property was not supplied or was empty")
+	}
+
+	message, err := v.reactorConfig.Properties["message"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	renderedMessage := []byte("")
+	if message != "" {
+		renderedMessage, err = template.RenderTemplateValues(ctx, message, fmt.Sprintf("%s_%s/message", data.ID, v.reactorName), data.AsMap(), []string{}, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	card, err := v.reactorConfig.Properties["card"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	renderedCard := []byte("")
+	if card != "" {
+		renderedCard, err = template.RenderTemplateValues(ctx, card, fmt.Sprintf("%s_%s/card", data.ID, v.reactorName), data.AsMap(), []string{}, templateConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(renderedMessage) == 0 && len(renderedCard) == 0 {
+		return nil, fmt.Errorf("either the message or card property must be supplied")
+	}
+
+	mentions, err := v.reactorConfig.Properties["mentions"].GetMapStringStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+
+	maxRetries := 4
+	maxRetriesStr, err := v.reactorConfig.Properties["maxRetries"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if maxRetriesStr != "" {
+		maxRetries, err = strconv.Atoi(maxRetriesStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied maxRetries '%v' to an integer. Error: %v", maxRetriesStr, err)
+		}
+	}
+
+	config := &ReactorConfig{
+		TeamsCfg: teams.TeamsConfiguration{
+			Log:        log,
+			WebhookUrl: webhookUrl,
+			Message:    string(renderedMessage),
+			Card:       string(renderedCard),
+			Mentions:   mentions,
+			MaxRetries: maxRetries,
+		},
+	}
+
+	return config, nil
+}
+
+func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
+	return []config.ReactorConfigProperty{
+		{
+			Name:        "webhookUrl",
+			Description: "The Teams incoming webhook or Workflows url to post the card to.",
+			Required:    config.AsBoolPointer(true),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "message",
+			Description: "The plain text message. It is used as the fallback text of the card, or sent as a simple card when no card is supplied. This field supports go templating.",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "card",
+			Description: "The adaptive card to send. This should be a JSON string and can be the same card used by the webex reactor. See https://adaptivecards.io for more information. This field supports go templating.",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "mentions",
+			Description: "The users to mention. The key is the name used in the card as <at>name</at> and the value is the user principal name or id of the user. Mentions that are not in the message are appended to it when no card is supplied.",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "maxRetries",
+			Description: "The maximum number of times to retry sending the card. Default is 4",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+	}
+}
+
+func (v *Reactor) GetRequiredPropertyNames() []string {
+	return reactor.GetRequiredPropertyNames(v)
+}
+
+func (v *Reactor) GetHelp() string {
+	return reactor.GetReactorHelp(v)
+}
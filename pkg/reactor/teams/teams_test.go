@@ -0,0 +1,94 @@
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/teams"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestProcessEvent(t *testing.T) {
+	received := []teams.MessageRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body := teams.MessageRequest{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		received = append(received, body)
+		rw.Write([]byte(`1`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		properties   map[string]config.PropertyAndValue
+		wantText     string
+		wantFallback string
+		wantErr      bool
+	}{
+		{
+			name: "Test with card",
+			properties: map[string]config.PropertyAndValue{
+				"webhookUrl": {Value: server.URL},
+				"message":    {Value: `{{ .data.app }} failed`},
+				"card":       {Value: `{"type":"AdaptiveCard","body":[{"type":"TextBlock","text":"{{ .data.app }}"}]}`},
+			},
+			wantText:     "app1",
+			wantFallback: "app1 failed",
+		},
+		{
+			name: "Test with message",
+			properties: map[string]config.PropertyAndValue{
+				"webhookUrl": {Value: server.URL},
+				"message":    {Value: `{{ .data.app }} failed`},
+				"mentions":   {Value: map[string]interface{}{"Jane": "jane@example.com"}},
+			},
+			wantText:     "app1 failed <at>Jane</at>",
+			wantFallback: "app1 failed",
+		},
+		{
+			name: "Test without message or card",
+			properties: map[string]config.PropertyAndValue{
+				"webhookUrl": {Value: server.URL},
+			},
+			wantErr: true,
+		},
+		{
+			name:       "Test without webhookUrl",
+			properties: map[string]config.PropertyAndValue{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = []teams.MessageRequest{}
+			v := New()
+			v.SetLogger(zaptest.NewLogger(t))
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			data := &message.EventData{
+				ID:         "1",
+				Data:       map[string]interface{}{"app": "app1"},
+				Attributes: map[string]string{},
+			}
+			err := v.ProcessEvent(context.Background(), data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProcessEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(received) != 1 {
+				t.Fatalf("ProcessEvent() requests = %v, want 1", len(received))
+			}
+			content := received[0].Attachments[0].Content
+			text := content["body"].([]interface{})[0].(map[string]interface{})["text"]
+			if text != tt.wantText || content["fallbackText"] != tt.wantFallback {
+				t.Errorf("ProcessEvent() text = %v, fallbackText = %v, want %v, %v", text, content["fallbackText"], tt.wantText, tt.wantFallback)
+			}
+		})
+	}
+}
@@ -3,8 +3,14 @@ package webex
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/correlation"
 	"github.com/kcloutie/event-reactor/pkg/message"
 	"github.com/kcloutie/event-reactor/pkg/params/settings"
 	"github.com/kcloutie/event-reactor/pkg/reactor"
@@ -14,21 +20,31 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	CorrelationModeUpdate = "update"
+	CorrelationModeThread = "thread"
+)
+
 var _ reactor.ReactorInterface = (*Reactor)(nil)
 
 type Reactor struct {
-	Log           *zap.Logger
-	reactorName   string
-	reactorConfig config.ReactorConfig
+	Log              *zap.Logger
+	reactorName      string
+	reactorConfig    config.ReactorConfig
+	correlationStore *correlation.Store
 }
 
 type ReactorConfig struct {
-	WebexCfg webex.WebexConfiguration
+	WebexCfg        webex.WebexConfiguration
+	CorrelationKey  string
+	CorrelationMode string
+	CorrelationTtl  time.Duration
 }
 
 func New() *Reactor {
 	return &Reactor{
-		reactorName: "webex",
+		reactorName:      "webex",
+		correlationStore: correlation.Default(),
 	}
 }
 
@@ -40,11 +56,45 @@ func (v *Reactor) GetName() string {
 }
 
 func (v *Reactor) GetDescription() string {
-	return "This reactor will send a webex message. The message and card support go templating."
+	return "This reactor will send a webex message to a space, or a direct message to a person. The message, markdown and card support go templating and a file from a local path or templated content can be uploaded with the message. Messages can be posted as threaded replies, and when a correlation key is supplied the message sent for an earlier event with the same key is edited, or replied to, instead of sending a new message. Rate limited requests are retried after the Retry-After duration."
 }
 
 func (v *Reactor) GetConfigExample() string {
-	return ``
+	return `
+  reactorConfigs:
+  - name: test_webex
+    celExpressionFilter: attributes.type == 'deploy'
+    disabled: false
+    type: webex
+    properties:
+      token:
+        fromEnv: WEBEX_BOT_TOKEN
+      spaceId:
+        value: Y2lzY29zcGFyazovL3VzL1JPT00vMTIz
+      message:
+        value: "Deploy of {{ .data.app }} {{ .data.status }}"
+      markdown:
+        value: "Deploy of **{{ .data.app }}** {{ .data.status }}"
+      correlationKey:
+        value: "{{ .data.app }}-{{ .data.deployId }}"
+      correlationMode:
+        value: update
+  - name: test_webex_approval
+    celExpressionFilter: attributes.type == 'release'
+    disabled: false
+    type: webex
+    properties:
+      token:
+        fromEnv: WEBEX_BOT_TOKEN
+      toPersonEmail:
+        value: "{{ .data.approver }}"
+      markdown:
+        value: "Release notes for **{{ .data.app }}** {{ .data.version }} are attached"
+      attachment:
+        value:
+          filename: "{{ .data.app }}-{{ .data.version }}.md"
+          content: "{{ .data.releaseNotes }}"
+`
 }
 
 func (v *Reactor) SetReactor(reactor config.ReactorConfig) {
@@ -58,54 +108,106 @@ func (v *Reactor) ProcessEvent(ctx context.Context, data *message.EventData) err
 		return err
 	}
 
-	templateConfig := template.NewRenderTemplateOptions()
-	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
-
 	reactorConfig, err := v.GetReactorConfig(ctx, data, v.Log)
 	if err != nil {
 		return err
 	}
+	webexCfg := reactorConfig.WebexCfg
 
-	if reactorConfig.WebexCfg.Card != "" {
-		err = reactorConfig.WebexCfg.SendWithCard()
-	} else {
-		err = reactorConfig.WebexCfg.SendMessage()
+	storeKey := ""
+	if reactorConfig.CorrelationKey != "" {
+		storeKey = fmt.Sprintf("%s/%s%s%s/%s", v.reactorName, webexCfg.SpaceId, webexCfg.ToPersonId, webexCfg.ToPersonEmail, reactorConfig.CorrelationKey)
+		// events with the same key are sent one at a time so only the first event sends a new message
+		unlock := v.correlationStore.Lock(storeKey)
+		defer unlock()
+		previous, exists := v.correlationStore.Get(storeKey)
+		if exists {
+			log := v.Log.With(zap.String("correlationKey", reactorConfig.CorrelationKey), zap.String("messageId", previous["id"]))
+			if reactorConfig.CorrelationMode == CorrelationModeUpdate {
+				if webexCfg.Card != "" || webexCfg.File != nil {
+					log.Warn("Webex does not support editing the card or file of a message, only the text and markdown of the message are updated")
+				}
+				_, err = webexCfg.Edit(ctx, previous["id"], previous["roomId"])
+				if err != nil {
+					return err
+				}
+				log.Info("Edited the webex message")
+				v.correlationStore.Set(storeKey, previous, reactorConfig.CorrelationTtl)
+				return nil
+			}
+			if webexCfg.ParentId == "" {
+				// replies are posted to the room of the parent, which is also the room of a direct message
+				webexCfg.ParentId = previous["id"]
+				webexCfg.SpaceId, webexCfg.ToPersonId, webexCfg.ToPersonEmail = previous["roomId"], "", ""
+			}
+			_, err = webexCfg.Create(ctx)
+			if err != nil {
+				return err
+			}
+			log.Info("Replied to the webex message")
+			return nil
+		}
 	}
+
+	resp, err := webexCfg.Create(ctx)
 	if err != nil {
 		return err
 	}
+	v.Log.Info("Sent the webex message", zap.String("messageId", resp.ID), zap.String("roomId", resp.RoomID))
+	if storeKey != "" {
+		if resp.ID == "" {
+			v.Log.Warn("The id of the webex message is unknown, later events with the same correlation key will send a new message", zap.String("correlationKey", reactorConfig.CorrelationKey))
+			return nil
+		}
+		// replies must use the id of the first message of the thread
+		id := resp.ID
+		if resp.ParentID != "" {
+			id = resp.ParentID
+		}
+		v.correlationStore.Set(storeKey, map[string]string{"id": id, "roomId": resp.RoomID}, reactorConfig.CorrelationTtl)
+	}
 	return nil
 }
 
 func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData, log *zap.Logger) (*ReactorConfig, error) {
+	config := &ReactorConfig{
+		WebexCfg: webex.WebexConfiguration{
+			Log: log,
+		},
+		CorrelationMode: CorrelationModeUpdate,
+		CorrelationTtl:  correlation.DefaultTtl,
+	}
 
 	templateConfig := template.NewRenderTemplateOptions()
 	reactor.SetGoTemplateOptionValues(ctx, v.Log, &templateConfig, v.reactorConfig.Properties)
 
-	message, err := v.reactorConfig.Properties["message"].GetStringValue(ctx, v.Log, data)
+	getRendered := func(name string) (string, error) {
+		return reactor.GetRenderedStringProperty(ctx, v.Log, v.reactorConfig.Properties, name, data, v.reactorName, templateConfig)
+	}
+
+	// ===================================================================================
+	// Get message, markdown and card
+	// ===================================================================================
+	renderedMessage, err := getRendered("message")
 	if err != nil {
 		return nil, err
 	}
-	renderedMessage := []byte("empty message")
-	if message != "" {
-		renderedMessage, err = template.RenderTemplateValues(ctx, message, fmt.Sprintf("%s_%s/message", data.ID, v.reactorName), data.AsMap(), []string{}, templateConfig)
-		if err != nil {
-			return nil, err
-		}
+	renderedMarkdown, err := getRendered("markdown")
+	if err != nil {
+		return nil, err
+	}
+	if renderedMessage == "" && renderedMarkdown == "" {
+		renderedMessage = "empty message"
 	}
 
-	card, err := v.reactorConfig.Properties["card"].GetStringValue(ctx, v.Log, data)
+	renderedCard, err := getRendered("card")
 	if err != nil {
 		return nil, err
 	}
-	renderedCard := []byte("")
-	if card != "" {
-		renderedCard, err = template.RenderTemplateValues(ctx, card, fmt.Sprintf("%s_%s/card", data.ID, v.reactorName), data.AsMap(), []string{}, templateConfig)
-		if err != nil {
-			return nil, err
-		}
-	}
 
+	// ===================================================================================
+	// Get apiUrl and token
+	// ===================================================================================
 	apiUrl, err := v.reactorConfig.Properties["apiUrl"].GetStringValue(ctx, v.Log, data)
 	if err != nil {
 		apiUrl = ""
@@ -122,28 +224,178 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 		return nil, fmt.Errorf("the token property was not supplied or was empty")
 	}
 
-	spaceId, err := v.reactorConfig.Properties["spaceId"].GetStringValue(ctx, v.Log, data)
+	// ===================================================================================
+	// Get spaceId, toPersonId and toPersonEmail
+	// ===================================================================================
+	spaceId, err := getRendered("spaceId")
+	if err != nil {
+		return nil, err
+	}
+	toPersonId, err := getRendered("toPersonId")
+	if err != nil {
+		return nil, err
+	}
+	toPersonEmail, err := getRendered("toPersonEmail")
+	if err != nil {
+		return nil, err
+	}
+	destinations := 0
+	for _, destination := range []string{spaceId, toPersonId, toPersonEmail} {
+		if destination != "" {
+			destinations++
+		}
+	}
+	if destinations == 0 {
+		return nil, fmt.Errorf("the spaceId property was not supplied or was empty. Supply either the spaceId, toPersonId or toPersonEmail property")
+	}
+	if destinations > 1 {
+		return nil, fmt.Errorf("only one of the spaceId, toPersonId or toPersonEmail properties can be supplied")
+	}
+
+	// ===================================================================================
+	// Get parentId
+	// ===================================================================================
+	parentId, err := getRendered("parentId")
+	if err != nil {
+		return nil, err
+	}
+
+	config.WebexCfg.ApiUrl = apiUrl
+	config.WebexCfg.ApiToken = token
+	config.WebexCfg.SpaceId = spaceId
+	config.WebexCfg.ToPersonId = toPersonId
+	config.WebexCfg.ToPersonEmail = toPersonEmail
+	config.WebexCfg.ParentId = parentId
+	config.WebexCfg.Message = renderedMessage
+	config.WebexCfg.Markdown = renderedMarkdown
+	config.WebexCfg.Card = renderedCard
+
+	// ===================================================================================
+	// Get attachment
+	// ===================================================================================
+	config.WebexCfg.File, err = v.getAttachment(ctx, data, templateConfig)
 	if err != nil {
 		return nil, err
 	}
-	if spaceId == "" {
-		return nil, fmt.Errorf("the spaceId property was not supplied or was empty")
+	if config.WebexCfg.File != nil && config.WebexCfg.Card != "" {
+		return nil, fmt.Errorf("only one of the card or attachment properties can be supplied, webex does not support sending a card and a file in the same message")
 	}
 
-	config := &ReactorConfig{
-		WebexCfg: webex.WebexConfiguration{
-			Log:      log,
-			ApiUrl:   apiUrl,
-			ApiToken: token,
-			SpaceId:  spaceId,
-			Message:  string(renderedMessage),
-			Card:     string(renderedCard),
-		},
+	// ===================================================================================
+	// Get correlation
+	// ===================================================================================
+	config.CorrelationKey, err = getRendered("correlationKey")
+	if err != nil {
+		return nil, err
+	}
+
+	correlationMode, err := v.reactorConfig.Properties["correlationMode"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if correlationMode != "" {
+		config.CorrelationMode = strings.ToLower(correlationMode)
+	}
+	if config.CorrelationMode != CorrelationModeUpdate && config.CorrelationMode != CorrelationModeThread {
+		return nil, fmt.Errorf("the correlationMode '%s' is not valid. Valid modes are %s and %s", correlationMode, CorrelationModeUpdate, CorrelationModeThread)
+	}
+
+	ttlStr, err := v.reactorConfig.Properties["correlationTtlMinutes"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if ttlStr != "" {
+		ttl, err := strconv.Atoi(ttlStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied correlationTtlMinutes '%v' to an integer. Error: %v", ttlStr, err)
+		}
+		config.CorrelationTtl = time.Duration(ttl) * time.Minute
+	}
+
+	// ===================================================================================
+	// Get maxRetries
+	// ===================================================================================
+	config.WebexCfg.MaxRetries = 4
+	maxRetriesStr, err := v.reactorConfig.Properties["maxRetries"].GetStringValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if maxRetriesStr != "" {
+		config.WebexCfg.MaxRetries, err = strconv.Atoi(maxRetriesStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the supplied maxRetries '%v' to an integer. Error: %v", maxRetriesStr, err)
+		}
 	}
 
 	return config, nil
 }
 
+// getAttachment reads the file uploaded with the message, the attachment has a filename and one of a file or content
+func (v *Reactor) getAttachment(ctx context.Context, data *message.EventData, templateConfig template.RenderTemplateOptions) (*webex.FileAttachment, error) {
+	if _, ok := v.reactorConfig.Properties["attachment"]; !ok {
+		return nil, nil
+	}
+	value, err := v.reactorConfig.Properties["attachment"].GetValue(ctx, v.Log, data)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, nil
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected the attachment property to be an object, however it is of type %T", value)
+	}
+	getRendered := func(name string) (string, error) {
+		value, ok := fields[name]
+		if !ok || value == nil {
+			return "", nil
+		}
+		return reactor.RenderTemplateValue(ctx, fmt.Sprintf("%v", value), "attachment/"+name, data, v.reactorName, templateConfig)
+	}
+
+	attachment := &webex.FileAttachment{}
+	attachment.Name, err = getRendered("filename")
+	if err != nil {
+		return nil, err
+	}
+	attachment.Name = strings.TrimSpace(attachment.Name)
+	attachment.ContentType, err = getRendered("contentType")
+	if err != nil {
+		return nil, err
+	}
+
+	_, hasFile := fields["file"]
+	_, hasContent := fields["content"]
+	if hasFile == hasContent {
+		return nil, fmt.Errorf("exactly one of the file or content fields must be supplied for the attachment")
+	}
+	if hasFile {
+		path, err := getRendered("file")
+		if err != nil {
+			return nil, err
+		}
+		path = strings.TrimSpace(path)
+		attachment.Content, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the file of the attachment - %v", err)
+		}
+		if attachment.Name == "" {
+			attachment.Name = filepath.Base(path)
+		}
+	} else {
+		content, err := getRendered("content")
+		if err != nil {
+			return nil, err
+		}
+		attachment.Content = []byte(content)
+	}
+	if attachment.Name == "" {
+		return nil, fmt.Errorf("the filename of the attachment was not supplied or was empty")
+	}
+	return attachment, nil
+}
+
 func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
 	return []config.ReactorConfigProperty{
 		{
@@ -160,22 +412,76 @@ func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
 		},
 		{
 			Name:        "spaceId",
-			Description: "The spaceId to send the message to.",
-			Required:    config.AsBoolPointer(true),
+			Description: "The spaceId to send the message to. Either the spaceId, toPersonId or toPersonEmail must be supplied. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "toPersonId",
+			Description: "The id of the person to send a direct message to. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "toPersonEmail",
+			Description: "The email address of the person to send a direct message to. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
 		{
 			Name:        "message",
-			Description: "The message to send to the spaceId.",
+			Description: "The message to send to the spaceId. When the markdown property is supplied, the message is shown by clients that cannot render markdown. This field supports go templating",
 			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
 		{
-			Name:        "cardJson",
+			Name:        "markdown",
+			Description: "The message in markdown format. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "card",
 			Description: "The card to send to the spaceId. This should be a JSON string. The card will be sent as an attachment to the message. The card should be in the format of a Webex card. See https://developer.webex.com/docs/api/guides/cards for more information.",
 			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
+		{
+			Name:        "attachment",
+			Description: "A file uploaded with the message. The attachment has a filename, an optional contentType and exactly one of file (the path of a file) or content (templated text). The filename defaults to the name of the file. Webex supports a single file per message, which cannot be combined with a card. The filename, contentType, file and content fields support go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeMapString,
+		},
+		{
+			Name:        "parentId",
+			Description: "The id of the message to reply to in a thread. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "correlationKey",
+			Description: "A key identifying related events, for example the deployment id. The id of the sent message is remembered so later events with the same key edit, or reply to, the message. The messages are remembered in the memory of the server, they are lost when the server restarts and are not shared between replicas. Events with the same key are sent one at a time. This field supports go templating",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "correlationMode",
+			Description: "What happens when a message was already sent for the correlation key. One of update (edit the text and markdown of the message, webex does not support editing cards) or thread (reply in the thread of the message). Defaults to update",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "correlationTtlMinutes",
+			Description: "The number of minutes a sent message is remembered for the correlation key. Defaults to 1440",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
+		{
+			Name:        "maxRetries",
+			Description: "The maximum number of times to retry the request, including when webex rate limits the request. Default is 4",
+			Required:    config.AsBoolPointer(false),
+			Type:        config.PropertyTypeString,
+		},
 	}
 }
 
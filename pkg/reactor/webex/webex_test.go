@@ -2,12 +2,21 @@ package webex
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/kcloutie/event-reactor/pkg/config"
+	"github.com/kcloutie/event-reactor/pkg/correlation"
 	"github.com/kcloutie/event-reactor/pkg/message"
+	"github.com/kcloutie/event-reactor/pkg/webex"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 )
 
@@ -97,3 +106,319 @@ func TestProcessEvent(t *testing.T) {
 		})
 	}
 }
+
+type webexCall struct {
+	Method  string
+	Path    string
+	Request webex.MessageCreateRequest
+}
+
+func newWebexServer(t *testing.T) (*httptest.Server, func() []webexCall) {
+	mu := sync.Mutex{}
+	calls := []webexCall{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body := webex.MessageCreateRequest{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		mu.Lock()
+		calls = append(calls, webexCall{Method: req.Method, Path: req.URL.Path, Request: body})
+		count := len(calls)
+		mu.Unlock()
+		roomId := body.RoomID
+		if roomId == "" {
+			roomId = "direct1"
+		}
+		_ = json.NewEncoder(rw).Encode(webex.MessageResponse{ID: fmt.Sprintf("msg%d", count), RoomID: roomId, ParentID: body.ParentID})
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []webexCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]webexCall{}, calls...)
+	}
+}
+
+func TestReactor_ProcessEvent_Correlation(t *testing.T) {
+	tests := []struct {
+		name            string
+		correlationMode string
+		destination     map[string]config.PropertyAndValue
+		wantCalls       []webexCall
+	}{
+		{
+			name:            "update",
+			correlationMode: "update",
+			destination:     map[string]config.PropertyAndValue{"spaceId": {Value: "room1"}},
+			wantCalls: []webexCall{
+				{Method: "POST", Path: "/v1/messages", Request: webex.MessageCreateRequest{RoomID: "room1", Text: "app1 deploy started", Markdown: "**app1** deploy started"}},
+				{Method: "PUT", Path: "/v1/messages/msg1", Request: webex.MessageCreateRequest{RoomID: "room1", Text: "app1 deploy finished", Markdown: "**app1** deploy finished"}},
+				{Method: "POST", Path: "/v1/messages", Request: webex.MessageCreateRequest{RoomID: "room1", Text: "app2 deploy started", Markdown: "**app2** deploy started"}},
+			},
+		},
+		{
+			name:            "thread",
+			correlationMode: "thread",
+			destination:     map[string]config.PropertyAndValue{"spaceId": {Value: "room1"}},
+			wantCalls: []webexCall{
+				{Method: "POST", Path: "/v1/messages", Request: webex.MessageCreateRequest{RoomID: "room1", Text: "app1 deploy started", Markdown: "**app1** deploy started"}},
+				{Method: "POST", Path: "/v1/messages", Request: webex.MessageCreateRequest{RoomID: "room1", ParentID: "msg1", Text: "app1 deploy finished", Markdown: "**app1** deploy finished"}},
+				{Method: "POST", Path: "/v1/messages", Request: webex.MessageCreateRequest{RoomID: "room1", Text: "app2 deploy started", Markdown: "**app2** deploy started"}},
+			},
+		},
+		{
+			name:            "direct message thread",
+			correlationMode: "thread",
+			destination:     map[string]config.PropertyAndValue{"toPersonEmail": {Value: "{{ .data.owner }}"}},
+			wantCalls: []webexCall{
+				{Method: "POST", Path: "/v1/messages", Request: webex.MessageCreateRequest{ToPersonEmail: "owner@example.com", Text: "app1 deploy started", Markdown: "**app1** deploy started"}},
+				{Method: "POST", Path: "/v1/messages", Request: webex.MessageCreateRequest{RoomID: "direct1", ParentID: "msg1", Text: "app1 deploy finished", Markdown: "**app1** deploy finished"}},
+				{Method: "POST", Path: "/v1/messages", Request: webex.MessageCreateRequest{ToPersonEmail: "owner@example.com", Text: "app2 deploy started", Markdown: "**app2** deploy started"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, getCalls := newWebexServer(t)
+			store := correlation.NewStore()
+			events := []map[string]interface{}{
+				{"app": "app1", "status": "started", "owner": "owner@example.com"},
+				{"app": "app1", "status": "finished", "owner": "owner@example.com"},
+				{"app": "app2", "status": "started", "owner": "owner@example.com"},
+			}
+			for i, event := range events {
+				properties := map[string]config.PropertyAndValue{
+					"apiUrl":          {Value: server.URL + "/v1/messages"},
+					"token":           {Value: "token"},
+					"message":         {Value: "{{ .data.app }} deploy {{ .data.status }}"},
+					"markdown":        {Value: "**{{ .data.app }}** deploy {{ .data.status }}"},
+					"correlationKey":  {Value: "{{ .data.app }}"},
+					"correlationMode": {Value: tt.correlationMode},
+				}
+				for k, p := range tt.destination {
+					properties[k] = p
+				}
+				v := New()
+				v.correlationStore = store
+				v.SetLogger(zap.NewNop())
+				v.SetReactor(config.ReactorConfig{Properties: properties})
+				err := v.ProcessEvent(context.Background(), &message.EventData{ID: fmt.Sprintf("%d", i), Data: event, Attributes: map[string]string{}})
+				if err != nil {
+					t.Fatalf("Reactor.ProcessEvent() error = %v", err)
+				}
+			}
+			got := getCalls()
+			if len(got) != len(tt.wantCalls) {
+				t.Fatalf("Reactor.ProcessEvent() calls = %+v, want %+v", got, tt.wantCalls)
+			}
+			for i := range got {
+				if fmt.Sprintf("%+v", got[i]) != fmt.Sprintf("%+v", tt.wantCalls[i]) {
+					t.Errorf("Reactor.ProcessEvent() call %d = %+v, want %+v", i, got[i], tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReactor_GetReactorConfig(t *testing.T) {
+	data := &message.EventData{
+		ID:         "1",
+		Data:       map[string]interface{}{"app": "app1", "owner": "owner@example.com"},
+		Attributes: map[string]string{},
+	}
+	tests := []struct {
+		name       string
+		properties map[string]config.PropertyAndValue
+		want       webex.WebexConfiguration
+		wantErr    string
+	}{
+		{
+			name: "direct message by email",
+			properties: map[string]config.PropertyAndValue{
+				"token":         {Value: "token"},
+				"toPersonEmail": {Value: "{{ .data.owner }}"},
+				"markdown":      {Value: "**{{ .data.app }}**"},
+				"parentId":      {Value: "parent1"},
+			},
+			want: webex.WebexConfiguration{ApiUrl: "https://api.ciscospark.com/v1/messages", ApiToken: "token", ToPersonEmail: "owner@example.com", ParentId: "parent1", Markdown: "**app1**", MaxRetries: 4},
+		},
+		{
+			name: "default message",
+			properties: map[string]config.PropertyAndValue{
+				"token":      {Value: "token"},
+				"toPersonId": {Value: "person1"},
+				"maxRetries": {Value: "1"},
+			},
+			want: webex.WebexConfiguration{ApiUrl: "https://api.ciscospark.com/v1/messages", ApiToken: "token", ToPersonId: "person1", Message: "empty message", MaxRetries: 1},
+		},
+		{
+			name: "no destination",
+			properties: map[string]config.PropertyAndValue{
+				"token":   {Value: "token"},
+				"message": {Value: "message"},
+			},
+			wantErr: "the spaceId property was not supplied or was empty. Supply either the spaceId, toPersonId or toPersonEmail property",
+		},
+		{
+			name: "space and person",
+			properties: map[string]config.PropertyAndValue{
+				"token":         {Value: "token"},
+				"spaceId":       {Value: "room1"},
+				"toPersonEmail": {Value: "owner@example.com"},
+			},
+			wantErr: "only one of the spaceId, toPersonId or toPersonEmail properties can be supplied",
+		},
+		{
+			name: "invalid correlation mode",
+			properties: map[string]config.PropertyAndValue{
+				"token":           {Value: "token"},
+				"spaceId":         {Value: "room1"},
+				"correlationMode": {Value: "dude"},
+			},
+			wantErr: "the correlationMode 'dude' is not valid. Valid modes are update and thread",
+		},
+		{
+			name: "invalid max retries",
+			properties: map[string]config.PropertyAndValue{
+				"token":      {Value: "token"},
+				"spaceId":    {Value: "room1"},
+				"maxRetries": {Value: "dude"},
+			},
+			wantErr: "failed to convert the supplied maxRetries 'dude' to an integer. Error: strconv.Atoi: parsing \"dude\": invalid syntax",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: tt.properties})
+			got, err := v.GetReactorConfig(context.Background(), data, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			got.WebexCfg.Log = nil
+			if got.WebexCfg != tt.want {
+				t.Errorf("Reactor.GetReactorConfig() = %+v, want %+v", got.WebexCfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestReactor_GetReactorConfig_Attachment(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "build.log")
+	err := os.WriteFile(path, []byte("build output"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := &message.EventData{
+		ID:         "1",
+		Data:       map[string]interface{}{"app": "app1", "notes": "release notes"},
+		Attributes: map[string]string{},
+	}
+	tests := []struct {
+		name       string
+		attachment interface{}
+		card       string
+		want       *webex.FileAttachment
+		wantErr    string
+	}{
+		{
+			name:       "file",
+			attachment: map[string]interface{}{"file": path},
+			want:       &webex.FileAttachment{Name: "build.log", Content: []byte("build output")},
+		},
+		{
+			name:       "templated content",
+			attachment: map[string]interface{}{"filename": "{{ .data.app }}.md", "contentType": "text/markdown", "content": "{{ .data.notes }}"},
+			want:       &webex.FileAttachment{Name: "app1.md", ContentType: "text/markdown", Content: []byte("release notes")},
+		},
+		{
+			name:       "content without filename",
+			attachment: map[string]interface{}{"content": "{{ .data.notes }}"},
+			wantErr:    "the filename of the attachment was not supplied or was empty",
+		},
+		{
+			name:       "file and content",
+			attachment: map[string]interface{}{"filename": "notes.md", "file": path, "content": "notes"},
+			wantErr:    "exactly one of the file or content fields must be supplied for the attachment",
+		},
+		{
+			name:       "not an object",
+			attachment: "notes.md",
+			wantErr:    "expected the attachment property to be an object, however it is of type string",
+		},
+		{
+			name:       "attachment and card",
+			attachment: map[string]interface{}{"file": path},
+			card:       `{"type":"AdaptiveCard","body":[]}`,
+			wantErr:    "only one of the card or attachment properties can be supplied, webex does not support sending a card and a file in the same message",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: map[string]config.PropertyAndValue{
+				"token":      {Value: "token"},
+				"spaceId":    {Value: "room1"},
+				"card":       {Value: tt.card},
+				"attachment": {Value: tt.attachment},
+			}})
+			got, err := v.GetReactorConfig(context.Background(), data, v.Log)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Reactor.GetReactorConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("Reactor.GetReactorConfig() error = nil, wantErr %v", tt.wantErr)
+			}
+			if !reflect.DeepEqual(got.WebexCfg.File, tt.want) {
+				t.Errorf("Reactor.GetReactorConfig() attachment = %+v, want %+v", got.WebexCfg.File, tt.want)
+			}
+		})
+	}
+}
+
+func TestReactor_ProcessEvent_ConcurrentCorrelation(t *testing.T) {
+	server, getCalls := newWebexServer(t)
+	store := correlation.NewStore()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v := New()
+			v.correlationStore = store
+			v.SetLogger(zap.NewNop())
+			v.SetReactor(config.ReactorConfig{Properties: map[string]config.PropertyAndValue{
+				"apiUrl":         {Value: server.URL + "/v1/messages"},
+				"token":          {Value: "token"},
+				"spaceId":        {Value: "room1"},
+				"message":        {Value: "deploy {{ .data.status }}"},
+				"correlationKey": {Value: "app1"},
+			}})
+			err := v.ProcessEvent(context.Background(), &message.EventData{ID: fmt.Sprintf("%d", i), Data: map[string]interface{}{"status": i}, Attributes: map[string]string{}})
+			if err != nil {
+				t.Errorf("Reactor.ProcessEvent() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, call := range getCalls() {
+		if call.Method == "POST" {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Errorf("Reactor.ProcessEvent() created %d messages, want 1 message edited by the other events", created)
+	}
+}
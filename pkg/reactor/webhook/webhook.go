@@ -137,7 +137,9 @@ func (v *Reactor) GetReactorConfig(ctx context.Context, data *message.EventData,
 	config.WebhookConfig.Body = bodyTemplate
 
 	if config.WebhookConfig.Body == "" {
-		payloadContent, err := json.Marshal(data)
+		body := *data
+		body.Data = message.WithoutPayloadKeys(data.Data)
+		payloadContent, err := json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal the event data to json. Error: %v", err)
 		}
@@ -238,7 +240,7 @@ func (v *Reactor) GetProperties() []config.ReactorConfigProperty {
 		},
 		{
 			Name:        "bodyTemplate",
-			Description: "The body to send to the webhook. if blank, the original event data will be sent. This field supports go templating.",
+			Description: "The body to send to the webhook. if blank, the original event data will be sent, without the raw and text keys added for the pub/sub payload. This field supports go templating.",
 			Required:    config.AsBoolPointer(false),
 			Type:        config.PropertyTypeString,
 		},
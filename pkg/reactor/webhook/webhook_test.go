@@ -123,3 +123,23 @@ func TestReactor_ProcessEvent(t *testing.T) {
 		})
 	}
 }
+
+func TestReactor_GetReactorConfig_DefaultBody(t *testing.T) {
+	raw := []byte(`{"app":"app1"}`)
+	payload, err := message.PayloadToData(raw, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := New()
+	v.SetLogger(zap.NewNop())
+	v.SetReactor(config.ReactorConfig{Properties: map[string]config.PropertyAndValue{"url": {Value: "http://localhost/valid"}}})
+
+	got, err := v.GetReactorConfig(context.Background(), &message.EventData{Data: payload, Attributes: map[string]string{}, ID: "test-id"}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Reactor.GetReactorConfig() error = %v", err)
+	}
+	want := `{"Data":{"app":"app1"},"Attributes":{},"ID":"test-id"}`
+	if got.WebhookConfig.Body != want {
+		t.Errorf("Reactor.GetReactorConfig() body = %v, want %v", got.WebhookConfig.Body, want)
+	}
+}
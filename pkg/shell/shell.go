@@ -0,0 +1,179 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const (
+	DefaultInterpreter    = "bash"
+	DefaultTimeout        = 60 * time.Second
+	DefaultMaxOutputBytes = 1024 * 1024
+
+	// EventDataFileEnvVar is the environment variable containing the path of the event data file
+	EventDataFileEnvVar = "EVENT_DATA_FILE"
+
+	EventDataModeFile  = "file"
+	EventDataModeStdin = "stdin"
+	EventDataModeNone  = "none"
+)
+
+// ExecConfig describes the script to run and how it is run. Either the script or the script file must be supplied
+type ExecConfig struct {
+	Interpreter      string
+	InterpreterArgs  []string
+	Script           string
+	ScriptFile       string
+	Args             []string
+	Env              map[string]string
+	WorkingDirectory string
+	Timeout          time.Duration
+	MaxOutputBytes   int
+	// EventData is passed to the script using the event data mode
+	EventData []byte
+	// EventDataMode is one of file, stdin or none. When file, the path of the file is available in the EVENT_DATA_FILE
+	// environment variable
+	EventDataMode string
+}
+
+type ExecResult struct {
+	Stdout          string
+	Stderr          string
+	ExitCode        int
+	OutputTruncated bool
+	TimedOut        bool
+}
+
+func NewExecConfig() ExecConfig {
+	return ExecConfig{
+		Interpreter:    DefaultInterpreter,
+		Timeout:        DefaultTimeout,
+		MaxOutputBytes: DefaultMaxOutputBytes,
+		EventDataMode:  EventDataModeFile,
+	}
+}
+
+// Execute runs the script and waits for it to complete. An error is returned when the script could not be started, timed out
+// or exited with a non zero exit code. The result is returned whenever the script was started
+func (o *ExecConfig) Execute(ctx context.Context) (*ExecResult, error) {
+	if o.Script == "" && o.ScriptFile == "" {
+		return nil, fmt.Errorf("either the script or the script file must be supplied")
+	}
+
+	td, err := os.MkdirTemp("", "shell")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(td)
+
+	scriptPath := o.ScriptFile
+	if o.Script != "" {
+		scriptPath = filepath.Join(td, "script")
+		err = os.WriteFile(scriptPath, []byte(o.Script), 0600)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := o.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	interpreter := o.Interpreter
+	if interpreter == "" {
+		interpreter = DefaultInterpreter
+	}
+	args := append([]string{}, o.InterpreterArgs...)
+	args = append(args, scriptPath)
+	args = append(args, o.Args...)
+	cmd := exec.CommandContext(cmdCtx, interpreter, args...)
+	cmd.Dir = o.WorkingDirectory
+	cmd.WaitDelay = 5 * time.Second
+
+	cmd.Env = os.Environ()
+	for k, v := range o.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	switch o.EventDataMode {
+	case EventDataModeFile, "":
+		eventDataPath := filepath.Join(td, "event.json")
+		err = os.WriteFile(eventDataPath, o.EventData, 0600)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", EventDataFileEnvVar, eventDataPath))
+	case EventDataModeStdin:
+		cmd.Stdin = bytes.NewReader(o.EventData)
+	case EventDataModeNone:
+	default:
+		return nil, fmt.Errorf("unknown event data mode '%s'. Valid modes are %s, %s and %s", o.EventDataMode, EventDataModeFile, EventDataModeStdin, EventDataModeNone)
+	}
+
+	maxOutput := o.MaxOutputBytes
+	if maxOutput <= 0 {
+		maxOutput = DefaultMaxOutputBytes
+	}
+	stdout := &limitedBuffer{max: maxOutput}
+	stderr := &limitedBuffer{max: maxOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	result := &ExecResult{
+		Stdout:          stdout.String(),
+		Stderr:          stderr.String(),
+		ExitCode:        -1,
+		OutputTruncated: stdout.truncated || stderr.truncated,
+		TimedOut:        errors.Is(cmdCtx.Err(), context.DeadlineExceeded),
+	}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	if result.TimedOut {
+		return result, fmt.Errorf("the script did not complete within %v", timeout)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return result, fmt.Errorf("the script exited with code %d", result.ExitCode)
+		}
+		return result, fmt.Errorf("failed to run the script using the interpreter '%s' - %v", interpreter, err)
+	}
+	return result, nil
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	remaining := b.max - b.buf.Len()
+	if remaining <= 0 {
+		b.truncated = b.truncated || len(p) > 0
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		b.buf.Write(p[:remaining])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,148 @@
+package shell
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecConfig_Execute(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	workDir := t.TempDir()
+	scriptFile := filepath.Join(t.TempDir(), "script.sh")
+	err := os.WriteFile(scriptFile, []byte(`echo "file $1"`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		config        ExecConfig
+		wantStdout    string
+		wantStderr    string
+		wantExitCode  int
+		wantTruncated bool
+		wantErr       string
+	}{
+		{
+			name: "inline script with args and env",
+			config: ExecConfig{
+				Interpreter: "sh",
+				Script:      `echo "$1 $2 $MY_VAR"; echo err >&2`,
+				Args:        []string{"hello", "world"},
+				Env:         map[string]string{"MY_VAR": "env"},
+			},
+			wantStdout: "hello world env\n",
+			wantStderr: "err\n",
+		},
+		{
+			name: "script file",
+			config: ExecConfig{
+				Interpreter: "sh",
+				ScriptFile:  scriptFile,
+				Args:        []string{"arg"},
+			},
+			wantStdout: "file arg\n",
+		},
+		{
+			name: "event data file",
+			config: ExecConfig{
+				Interpreter: "sh",
+				Script:      `cat "$EVENT_DATA_FILE"`,
+				EventData:   []byte(`{"id":"1"}`),
+			},
+			wantStdout: `{"id":"1"}`,
+		},
+		{
+			name: "event data stdin",
+			config: ExecConfig{
+				Interpreter:   "sh",
+				Script:        `cat`,
+				EventData:     []byte(`{"id":"1"}`),
+				EventDataMode: EventDataModeStdin,
+			},
+			wantStdout: `{"id":"1"}`,
+		},
+		{
+			name: "working directory",
+			config: ExecConfig{
+				Interpreter:      "sh",
+				Script:           `basename "$(pwd)"`,
+				WorkingDirectory: workDir,
+			},
+			wantStdout: filepath.Base(workDir) + "\n",
+		},
+		{
+			name: "output truncated",
+			config: ExecConfig{
+				Interpreter:    "sh",
+				Script:         `echo 1234567890`,
+				MaxOutputBytes: 4,
+			},
+			wantStdout:    "1234",
+			wantTruncated: true,
+		},
+		{
+			name: "non zero exit code",
+			config: ExecConfig{
+				Interpreter: "sh",
+				Script:      `echo failed; exit 3`,
+			},
+			wantStdout:   "failed\n",
+			wantExitCode: 3,
+			wantErr:      "the script exited with code 3",
+		},
+		{
+			name: "timeout",
+			config: ExecConfig{
+				Interpreter: "sh",
+				Script:      `sleep 5`,
+				Timeout:     100 * time.Millisecond,
+			},
+			wantExitCode: -1,
+			wantErr:      "the script did not complete within 100ms",
+		},
+		{
+			name: "unknown event data mode",
+			config: ExecConfig{
+				Interpreter:   "sh",
+				Script:        `echo`,
+				EventDataMode: "dude",
+			},
+			wantErr: "unknown event data mode 'dude'. Valid modes are file, stdin and none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.Execute(context.Background())
+			if err != nil {
+				if !strings.HasPrefix(err.Error(), tt.wantErr) || tt.wantErr == "" {
+					t.Fatalf("ExecConfig.Execute() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			} else if tt.wantErr != "" {
+				t.Fatalf("ExecConfig.Execute() error = nil, wantErr %v", tt.wantErr)
+			}
+			if got == nil {
+				return
+			}
+			if got.Stdout != tt.wantStdout {
+				t.Errorf("ExecConfig.Execute() stdout = %q, want %q", got.Stdout, tt.wantStdout)
+			}
+			if got.Stderr != tt.wantStderr {
+				t.Errorf("ExecConfig.Execute() stderr = %q, want %q", got.Stderr, tt.wantStderr)
+			}
+			if got.ExitCode != tt.wantExitCode {
+				t.Errorf("ExecConfig.Execute() exit code = %v, want %v", got.ExitCode, tt.wantExitCode)
+			}
+			if got.OutputTruncated != tt.wantTruncated {
+				t.Errorf("ExecConfig.Execute() truncated = %v, want %v", got.OutputTruncated, tt.wantTruncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/kcloutie/event-reactor/pkg/http"
+	"go.uber.org/zap"
+)
+
+// SlackConfiguration is used to send messages using either an incoming webhook or the chat.postMessage and chat.update web
+// api methods. Requests that are rate limited are retried after the Retry-After duration returned by slack
+type SlackConfiguration struct {
+	Log        *zap.Logger
+	ApiUrl     string
+	Token      string
+	WebhookUrl string
+	Channel    string
+	Text       string
+	// Blocks is the Block Kit json. It can either be an array of blocks or an object containing a blocks array, which is
+	// the format exported by the Block Kit Builder
+	Blocks     string
+	ThreadTs   string
+	MaxRetries int
+}
+
+type MessageRequest struct {
+	Channel  string          `json:"channel,omitempty"`
+	Ts       string          `json:"ts,omitempty"`
+	ThreadTs string          `json:"thread_ts,omitempty"`
+	Text     string          `json:"text,omitempty"`
+	Blocks   json.RawMessage `json:"blocks,omitempty"`
+}
+
+type ApiResponse struct {
+	Ok      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Ts      string `json:"ts,omitempty"`
+}
+
+// PostMessage posts a new message, or a reply when the ThreadTs is set, using chat.postMessage
+func (c SlackConfiguration) PostMessage(ctx context.Context) (*ApiResponse, error) {
+	body, err := c.newMessageRequest()
+	if err != nil {
+		return nil, err
+	}
+	body.Channel = c.Channel
+	body.ThreadTs = c.ThreadTs
+	return c.callApi(ctx, "chat.postMessage", body)
+}
+
+// UpdateMessage replaces the text and blocks of the message with the supplied timestamp using chat.update
+func (c SlackConfiguration) UpdateMessage(ctx context.Context, channel string, ts string) (*ApiResponse, error) {
+	body, err := c.newMessageRequest()
+	if err != nil {
+		return nil, err
+	}
+	body.Channel = channel
+	body.Ts = ts
+	return c.callApi(ctx, "chat.update", body)
+}
+
+// SendWebhook posts the message to the incoming webhook url
+func (c SlackConfiguration) SendWebhook(ctx context.Context) error {
+	body, err := c.newMessageRequest()
+	if err != nil {
+		return err
+	}
+	body.ThreadTs = c.ThreadTs
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the slack request body into json - %v", err)
+	}
+
+	statusCode, respBody, err := c.post(ctx, c.WebhookUrl, bodyBytes, false)
+	if err != nil {
+		return fmt.Errorf("unable to send the slack webhook - %v", err)
+	}
+	if statusCode <= 199 || statusCode >= 400 {
+		return fmt.Errorf("slack webhook call failed. Status Code: %v. Response Body: %v", statusCode, string(respBody))
+	}
+	return nil
+}
+
+func (c SlackConfiguration) newMessageRequest() (*MessageRequest, error) {
+	body := &MessageRequest{
+		Text: c.Text,
+	}
+	if strings.TrimSpace(c.Blocks) != "" {
+		blocks, err := ParseBlocks(c.Blocks)
+		if err != nil {
+			return nil, err
+		}
+		body.Blocks = blocks
+	}
+	if body.Text == "" && body.Blocks == nil {
+		return nil, fmt.Errorf("either the text or the blocks of the slack message must be supplied")
+	}
+	return body, nil
+}
+
+// ParseBlocks returns the blocks array from the supplied Block Kit json
+func ParseBlocks(blocks string) (json.RawMessage, error) {
+	trimmed := strings.TrimSpace(blocks)
+	if strings.HasPrefix(trimmed, "{") {
+		wrapper := struct {
+			Blocks json.RawMessage `json:"blocks"`
+		}{}
+		err := json.Unmarshal([]byte(trimmed), &wrapper)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the slack blocks into json - %v", err)
+		}
+		if wrapper.Blocks == nil {
+			return nil, fmt.Errorf("the slack blocks object does not contain a blocks array")
+		}
+		trimmed = string(wrapper.Blocks)
+	}
+	parsed := []map[string]interface{}{}
+	err := json.Unmarshal([]byte(trimmed), &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the slack blocks into json - %v", err)
+	}
+	return json.RawMessage(trimmed), nil
+}
+
+func (c SlackConfiguration) callApi(ctx context.Context, method string, body *MessageRequest) (*ApiResponse, error) {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the slack request body into json - %v", err)
+	}
+
+	url := fmt.Sprintf("%s/%s", strings.TrimSuffix(c.ApiUrl, "/"), method)
+	// only chat.update can be retried safely, retrying a failed chat.postMessage could post the message twice
+	statusCode, respBody, err := c.post(ctx, url, bodyBytes, method == "chat.update")
+	if err != nil {
+		return nil, fmt.Errorf("unable to call the slack %s api - %v", method, err)
+	}
+	if statusCode <= 199 || statusCode >= 400 {
+		return nil, fmt.Errorf("slack %s api call failed. Status Code: %v. Response Body: %v", method, statusCode, string(respBody))
+	}
+
+	resp := &ApiResponse{}
+	err = json.Unmarshal(respBody, resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the slack %s api response - %v. Response Body: %v", method, err, string(respBody))
+	}
+	if !resp.Ok {
+		return resp, fmt.Errorf("slack %s api call failed. Error: %s", method, resp.Error)
+	}
+	return resp, nil
+}
+
+func (c SlackConfiguration) post(ctx context.Context, url string, bodyBytes []byte, idempotent bool) (int, []byte, error) {
+	req, err := retryablehttp.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if c.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.Token))
+	}
+
+	// the retry client honours the Retry-After header slack returns with 429 responses. Requests that are not idempotent
+	// are only retried when they were rate limited
+	retryClient := http.NewHttpRetryClient(c.Log, c.MaxRetries)
+	if !idempotent {
+		retryClient.CheckRetry = http.RateLimitRetryPolicy
+	}
+	response, err := retryClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+
+	respBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return response.StatusCode, nil, fmt.Errorf("failed to read the response body: %v", err)
+	}
+	return response.StatusCode, respBody, nil
+}
@@ -0,0 +1,184 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSlackConfiguration(t *testing.T) {
+	var rateLimited, failed int32
+	requests := make(chan MessageRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body := MessageRequest{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		if req.URL.Path == "/ratelimited/chat.postMessage" && atomic.AddInt32(&rateLimited, 1) == 1 {
+			rw.Header().Set("Retry-After", "0")
+			rw.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if req.URL.Path == "/failing/chat.postMessage" {
+			atomic.AddInt32(&failed, 1)
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		requests <- body
+		switch req.URL.Path {
+		case "/webhook":
+			_, _ = rw.Write([]byte("ok"))
+		case "/api/chat.postMessage", "/ratelimited/chat.postMessage", "/api/chat.update":
+			if req.Header.Get("Authorization") != "Bearer token" {
+				_ = json.NewEncoder(rw).Encode(ApiResponse{Ok: false, Error: "invalid_auth"})
+				return
+			}
+			ts := body.Ts
+			if ts == "" {
+				ts = "1700000000.000100"
+			}
+			_ = json.NewEncoder(rw).Encode(ApiResponse{Ok: true, Channel: "C123", Ts: ts})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	blocks := `{"blocks":[{"type":"section","text":{"type":"mrkdwn","text":"*deploy*"}}]}`
+
+	t.Run("post message", func(t *testing.T) {
+		c := SlackConfiguration{Log: zaptest.NewLogger(t), ApiUrl: server.URL + "/api", Token: "token", Channel: "#deploys", Text: "deploy started", Blocks: blocks, ThreadTs: "1.2"}
+		resp, err := c.PostMessage(ctx)
+		if err != nil {
+			t.Fatalf("SlackConfiguration.PostMessage() error = %v", err)
+		}
+		if resp.Channel != "C123" || resp.Ts != "1700000000.000100" {
+			t.Errorf("SlackConfiguration.PostMessage() = %+v", resp)
+		}
+		got := <-requests
+		if got.Channel != "#deploys" || got.Text != "deploy started" || got.ThreadTs != "1.2" || string(got.Blocks) != `[{"type":"section","text":{"type":"mrkdwn","text":"*deploy*"}}]` {
+			t.Errorf("SlackConfiguration.PostMessage() request = %+v", got)
+		}
+	})
+
+	t.Run("update message", func(t *testing.T) {
+		c := SlackConfiguration{Log: zaptest.NewLogger(t), ApiUrl: server.URL + "/api", Token: "token", Text: "deploy finished"}
+		resp, err := c.UpdateMessage(ctx, "C123", "1.5")
+		if err != nil {
+			t.Fatalf("SlackConfiguration.UpdateMessage() error = %v", err)
+		}
+		if resp.Ts != "1.5" {
+			t.Errorf("SlackConfiguration.UpdateMessage() ts = %v, want 1.5", resp.Ts)
+		}
+		got := <-requests
+		if got.Channel != "C123" || got.Ts != "1.5" || got.Text != "deploy finished" {
+			t.Errorf("SlackConfiguration.UpdateMessage() request = %+v", got)
+		}
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		c := SlackConfiguration{Log: zaptest.NewLogger(t), ApiUrl: server.URL + "/api", Token: "bad", Channel: "#deploys", Text: "text"}
+		_, err := c.PostMessage(ctx)
+		if err == nil || err.Error() != "slack chat.postMessage api call failed. Error: invalid_auth" {
+			t.Errorf("SlackConfiguration.PostMessage() error = %v", err)
+		}
+		<-requests
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		c := SlackConfiguration{Log: zaptest.NewLogger(t), ApiUrl: server.URL + "/ratelimited", Token: "token", Channel: "#deploys", Text: "text", MaxRetries: 2}
+		_, err := c.PostMessage(ctx)
+		if err != nil {
+			t.Fatalf("SlackConfiguration.PostMessage() error = %v", err)
+		}
+		if atomic.LoadInt32(&rateLimited) != 2 {
+			t.Errorf("SlackConfiguration.PostMessage() attempts = %v, want 2", rateLimited)
+		}
+		<-requests
+	})
+
+	t.Run("server error is not retried", func(t *testing.T) {
+		c := SlackConfiguration{Log: zaptest.NewLogger(t), ApiUrl: server.URL + "/failing", Token: "token", Channel: "#deploys", Text: "text", MaxRetries: 2}
+		_, err := c.PostMessage(ctx)
+		if err == nil {
+			t.Fatalf("SlackConfiguration.PostMessage() error = nil, want the bad gateway error")
+		}
+		if atomic.LoadInt32(&failed) != 1 {
+			t.Errorf("SlackConfiguration.PostMessage() attempts = %v, want 1 so the message is not posted twice", failed)
+		}
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		c := SlackConfiguration{Log: zaptest.NewLogger(t), WebhookUrl: server.URL + "/webhook", Text: "hello"}
+		err := c.SendWebhook(ctx)
+		if err != nil {
+			t.Fatalf("SlackConfiguration.SendWebhook() error = %v", err)
+		}
+		got := <-requests
+		if got.Text != "hello" || got.Channel != "" {
+			t.Errorf("SlackConfiguration.SendWebhook() request = %+v", got)
+		}
+	})
+
+	t.Run("webhook failure", func(t *testing.T) {
+		c := SlackConfiguration{Log: zaptest.NewLogger(t), WebhookUrl: server.URL + "/missing", Text: "hello"}
+		err := c.SendWebhook(ctx)
+		if err == nil {
+			t.Errorf("SlackConfiguration.SendWebhook() expected an error")
+		}
+		<-requests
+	})
+
+	t.Run("empty message", func(t *testing.T) {
+		c := SlackConfiguration{Log: zaptest.NewLogger(t), ApiUrl: server.URL + "/api", Token: "token", Channel: "#deploys"}
+		_, err := c.PostMessage(ctx)
+		if err == nil || err.Error() != "either the text or the blocks of the slack message must be supplied" {
+			t.Errorf("SlackConfiguration.PostMessage() error = %v", err)
+		}
+	})
+}
+
+func TestParseBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		blocks  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "array",
+			blocks: ` [{"type":"divider"}] `,
+			want:   `[{"type":"divider"}]`,
+		},
+		{
+			name:   "block kit builder object",
+			blocks: `{"blocks":[{"type":"divider"}]}`,
+			want:   `[{"type":"divider"}]`,
+		},
+		{
+			name:    "object without blocks",
+			blocks:  `{"type":"divider"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			blocks:  `dude`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBlocks(tt.blocks)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBlocks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ParseBlocks() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
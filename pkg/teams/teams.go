@@ -0,0 +1,169 @@
+package teams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/kcloutie/event-reactor/pkg/http"
+	"github.com/kcloutie/event-reactor/pkg/webex"
+	"go.uber.org/zap"
+)
+
+// TeamsConfiguration is used to post adaptive cards to Microsoft Teams incoming webhooks or Workflows urls. The same card json
+// used by the webex reactor can be used
+type TeamsConfiguration struct {
+	Log        *zap.Logger
+	WebhookUrl string
+	// Message is used as the fallback text of the card. When no card is supplied, a card containing the message is sent
+	Message string
+	Card    string
+	// Mentions maps the display name used in the card, as <at>name</at>, to the user principal name or id of the user
+	Mentions   map[string]string
+	MaxRetries int
+}
+
+type MessageRequest struct {
+	Type        string                      `json:"type"`
+	Attachments []webex.WebexCardAttachment `json:"attachments"`
+}
+
+type Mention struct {
+	Type      string           `json:"type"`
+	Text      string           `json:"text"`
+	Mentioned MentionedAccount `json:"mentioned"`
+}
+
+type MentionedAccount struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Send posts the card to the webhook url. Only rate limited requests are retried, a 5xx or a network error may happen after
+// teams accepted the card and retrying it would post the card twice
+func (c TeamsConfiguration) Send(ctx context.Context) error {
+	body, err := c.NewMessageRequest()
+	if err != nil {
+		return err
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the teams request body into json - %v", err)
+	}
+
+	httpReq, err := retryablehttp.NewRequestWithContext(ctx, "POST", c.WebhookUrl, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	retryClient := http.NewHttpRetryClient(c.Log, c.MaxRetries)
+	retryClient.CheckRetry = http.RateLimitRetryPolicy
+	response, err := retryClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("unable to send the teams message - %v", err)
+	}
+	defer response.Body.Close()
+
+	respBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read the response body: %v", err)
+	}
+	if response.StatusCode <= 199 || response.StatusCode >= 400 {
+		c.Log.Info("Failed teams call body contents", zap.String("bodyContents", string(bodyBytes)))
+		return fmt.Errorf("teams webhook call failed. Status Code: %v. Response Body: %v", response.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// NewMessageRequest creates the message containing the adaptive card. The message is used as the fallback text of the card
+// and the mentions are added as msteams entities
+func (c TeamsConfiguration) NewMessageRequest() (*MessageRequest, error) {
+	var attachment webex.WebexCardAttachment
+	var err error
+	if strings.TrimSpace(c.Card) != "" {
+		attachment, err = webex.NewAdaptiveCardAttachment(c.Card)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := attachment.Content["fallbackText"]; !exists && c.Message != "" {
+			attachment.Content["fallbackText"] = c.Message
+		}
+	} else {
+		if c.Message == "" {
+			return nil, fmt.Errorf("either the message or the card of the teams message must be supplied")
+		}
+		attachment = webex.WebexCardAttachment{
+			ContentType: webex.AdaptiveCardContentType,
+			Content:     c.newTextCard(),
+		}
+	}
+
+	if len(c.Mentions) > 0 {
+		msteams, _ := attachment.Content["msteams"].(map[string]interface{})
+		if msteams == nil {
+			msteams = map[string]interface{}{}
+		}
+		entities, _ := msteams["entities"].([]interface{})
+		for _, mention := range c.getMentions() {
+			entities = append(entities, mention)
+		}
+		msteams["entities"] = entities
+		attachment.Content["msteams"] = msteams
+	}
+
+	return &MessageRequest{
+		Type:        "message",
+		Attachments: []webex.WebexCardAttachment{attachment},
+	}, nil
+}
+
+// newTextCard creates a card containing the message. Mentions that are not in the message are appended to it so the users
+// are notified
+func (c TeamsConfiguration) newTextCard() map[string]interface{} {
+	text := c.Message
+	for _, mention := range c.getMentions() {
+		if !strings.Contains(text, mention.Text) {
+			text = fmt.Sprintf("%s %s", text, mention.Text)
+		}
+	}
+	return map[string]interface{}{
+		"$schema":      "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":         "AdaptiveCard",
+		"version":      "1.4",
+		"fallbackText": c.Message,
+		"body": []interface{}{
+			map[string]interface{}{
+				"type": "TextBlock",
+				"text": text,
+				"wrap": true,
+			},
+		},
+	}
+}
+
+func (c TeamsConfiguration) getMentions() []Mention {
+	names := make([]string, 0, len(c.Mentions))
+	for name := range c.Mentions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mentions := []Mention{}
+	for _, name := range names {
+		mentions = append(mentions, Mention{
+			Type: "mention",
+			Text: fmt.Sprintf("<at>%s</at>", name),
+			Mentioned: MentionedAccount{
+				Id:   c.Mentions[name],
+				Name: name,
+			},
+		})
+	}
+	return mentions
+}
@@ -0,0 +1,141 @@
+package teams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestTeamsConfiguration_NewMessageRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       TeamsConfiguration
+		want    string
+		wantErr string
+	}{
+		{
+			name: "card with fallback text and mentions",
+			c: TeamsConfiguration{
+				Message:  "deploy failed",
+				Card:     `{"type":"AdaptiveCard","body":[{"type":"TextBlock","text":"<at>Jane</at> deploy failed"}],"msteams":{"width":"Full"}}`,
+				Mentions: map[string]string{"Jane": "jane@example.com"},
+			},
+			want: `{"type":"message","attachments":[{"contentType":"application/vnd.microsoft.card.adaptive","content":{"body":[{"text":"<at>Jane</at> deploy failed","type":"TextBlock"}],"fallbackText":"deploy failed","msteams":{"entities":[{"type":"mention","text":"<at>Jane</at>","mentioned":{"id":"jane@example.com","name":"Jane"}}],"width":"Full"},"type":"AdaptiveCard"}}]}`,
+		},
+		{
+			name: "card keeps its own fallback text",
+			c: TeamsConfiguration{
+				Message: "message",
+				Card:    `{"type":"AdaptiveCard","fallbackText":"card fallback"}`,
+			},
+			want: `{"type":"message","attachments":[{"contentType":"application/vnd.microsoft.card.adaptive","content":{"fallbackText":"card fallback","type":"AdaptiveCard"}}]}`,
+		},
+		{
+			name: "plain text",
+			c: TeamsConfiguration{
+				Message:  "deploy failed",
+				Mentions: map[string]string{"Jane": "jane@example.com"},
+			},
+			want: `{"type":"message","attachments":[{"contentType":"application/vnd.microsoft.card.adaptive","content":{"$schema":"http://adaptivecards.io/schemas/adaptive-card.json","body":[{"text":"deploy failed <at>Jane</at>","type":"TextBlock","wrap":true}],"fallbackText":"deploy failed","msteams":{"entities":[{"type":"mention","text":"<at>Jane</at>","mentioned":{"id":"jane@example.com","name":"Jane"}}]},"type":"AdaptiveCard","version":"1.4"}}]}`,
+		},
+		{
+			name:    "empty",
+			c:       TeamsConfiguration{},
+			wantErr: "either the message or the card of the teams message must be supplied",
+		},
+		{
+			name: "invalid card",
+			c: TeamsConfiguration{
+				Card: "card",
+			},
+			wantErr: "failed to unmarshal the webex card into json - invalid character 'c' looking for beginning of value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.c.NewMessageRequest()
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("TeamsConfiguration.NewMessageRequest() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			buf := &bytes.Buffer{}
+			encoder := json.NewEncoder(buf)
+			encoder.SetEscapeHTML(false)
+			_ = encoder.Encode(got)
+			gotJson := strings.TrimSpace(buf.String())
+			if gotJson != tt.want {
+				t.Errorf("TeamsConfiguration.NewMessageRequest() = %s, want %s", gotJson, tt.want)
+			}
+		})
+	}
+}
+
+func TestTeamsConfiguration_Send(t *testing.T) {
+	var attempts int32
+	var failingAttempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		request := MessageRequest{}
+		if json.Unmarshal(body, &request) != nil || request.Type != "message" || len(request.Attachments) != 1 {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch req.URL.Path {
+		case "/retry":
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				rw.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			rw.WriteHeader(http.StatusAccepted)
+		case "/failing":
+			atomic.AddInt32(&failingAttempts, 1)
+			rw.WriteHeader(http.StatusBadGateway)
+		case "/success":
+			_, _ = rw.Write([]byte("1"))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "success", url: server.URL + "/success"},
+		{name: "retried", url: server.URL + "/retry"},
+		{name: "failure", url: server.URL + "/missing", wantErr: true},
+		{name: "server error is not retried", url: server.URL + "/failing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := TeamsConfiguration{
+				Log:        zaptest.NewLogger(t),
+				WebhookUrl: tt.url,
+				Message:    "message",
+				MaxRetries: 2,
+			}
+			err := c.Send(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TeamsConfiguration.Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if attempts != 2 {
+		t.Errorf("TeamsConfiguration.Send() attempts = %v, want 2", attempts)
+	}
+	if failingAttempts != 1 {
+		t.Errorf("TeamsConfiguration.Send() attempts of a server error = %v, want 1", failingAttempts)
+	}
+}
@@ -0,0 +1,350 @@
+package smtp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	TLSNone     = "none"
+	TLSStartTLS = "starttls"
+	TLSImplicit = "implicit"
+)
+
+// Message is an email captured by the server
+type Message struct {
+	From string
+	To   []string
+	Data []byte
+	// TLS is true when the message was received over a tls connection
+	TLS bool
+	// Mechanism is the authentication mechanism used by the client, empty when the client did not authenticate
+	Mechanism string
+	Username  string
+	// Password is the password supplied using the PLAIN or LOGIN mechanisms
+	Password string
+	// Token is the bearer token supplied using the XOAUTH2 mechanism
+	Token string
+}
+
+// Options configures the server
+type Options struct {
+	// TLS is none, starttls or implicit. Defaults to none
+	TLS string
+	// CramMD5Secret is the secret used to verify CRAM-MD5 responses
+	CramMD5Secret string
+	// FailQuit makes the server reply to QUIT with an error, after the messages were accepted
+	FailQuit bool
+}
+
+// Server is an in-process smtp server that captures the messages it receives so tests can assert on them
+type Server struct {
+	Host string
+	Port int
+	// Certificate is the PEM encoded self-signed certificate of the server when tls is enabled
+	Certificate []byte
+
+	options   Options
+	tlsConfig *tls.Config
+	listener  net.Listener
+	mutex     sync.Mutex
+	messages  []Message
+	wg        sync.WaitGroup
+}
+
+// NewServer starts a server listening on a random port of the loopback interface, the server is closed when the test completes
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+	return NewServerWithOptions(t, Options{})
+}
+
+// NewServerWithOptions starts a server using the supplied options, the server is closed when the test completes
+func NewServerWithOptions(t *testing.T, options Options) *Server {
+	t.Helper()
+	s := &Server{options: options}
+	if options.TLS != "" && options.TLS != TLSNone {
+		certificate, key, err := newCertificate()
+		if err != nil {
+			t.Fatalf("failed to create the certificate of the smtp server - %v", err)
+		}
+		s.Certificate = certificate
+		pair, err := tls.X509KeyPair(certificate, key)
+		if err != nil {
+			t.Fatalf("failed to load the certificate of the smtp server - %v", err)
+		}
+		s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{pair}}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start the smtp server - %v", err)
+	}
+	if options.TLS == TLSImplicit {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	s.Host, s.Port, s.listener = addr.IP.String(), addr.Port, listener
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Addr returns the host:port of the server
+func (s *Server) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// Messages returns the messages received by the server
+func (s *Server) Messages() []Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Message{}, s.messages...)
+}
+
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(netConn net.Conn) {
+	_, isTLS := netConn.(*tls.Conn)
+	conn := textproto.NewConn(netConn)
+	msg := Message{}
+	auth := Message{}
+	reply := func(format string, args ...interface{}) bool {
+		return conn.PrintfLine(format, args...) == nil
+	}
+	// readResponse sends a 334 challenge and returns the decoded response of the client
+	readResponse := func(challenge string) (string, bool) {
+		if !reply("334 %s", base64.StdEncoding.EncodeToString([]byte(challenge))) {
+			return "", false
+		}
+		line, err := conn.ReadLine()
+		if err != nil {
+			return "", false
+		}
+		decoded, err := base64.StdEncoding.DecodeString(line)
+		return string(decoded), err == nil
+	}
+
+	if !reply("220 localhost ESMTP test server") {
+		return
+	}
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO":
+			extensions := []string{"localhost", "8BITMIME"}
+			if s.options.TLS == TLSStartTLS && !isTLS {
+				extensions = append(extensions, "STARTTLS")
+			}
+			extensions = append(extensions, "AUTH PLAIN LOGIN CRAM-MD5 XOAUTH2")
+			for i, extension := range extensions {
+				separator := "-"
+				if i == len(extensions)-1 {
+					separator = " "
+				}
+				if !reply("250%s%s", separator, extension) {
+					return
+				}
+			}
+		case "HELO", "NOOP":
+			if !reply("250 OK") {
+				return
+			}
+		case "STARTTLS":
+			if s.options.TLS != TLSStartTLS || isTLS {
+				reply("502 STARTTLS not available")
+				continue
+			}
+			if !reply("220 ready to start tls") {
+				return
+			}
+			tlsConn := tls.Server(netConn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			// the client must say EHLO again and authenticate after the tls handshake
+			netConn, isTLS, conn = tlsConn, true, textproto.NewConn(tlsConn)
+			msg, auth = Message{}, Message{}
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			mechanism = strings.ToUpper(mechanism)
+			var ok bool
+			result := Message{Mechanism: mechanism}
+			switch mechanism {
+			case "PLAIN":
+				response := ""
+				if initial == "" {
+					response, ok = readResponse("")
+				} else {
+					decoded, err := base64.StdEncoding.DecodeString(initial)
+					response, ok = string(decoded), err == nil
+				}
+				parts := strings.Split(response, "\x00")
+				ok = ok && len(parts) == 3
+				if ok {
+					result.Username, result.Password = parts[1], parts[2]
+				}
+			case "LOGIN":
+				result.Username, ok = readResponse("Username:")
+				if ok {
+					result.Password, ok = readResponse("Password:")
+				}
+			case "CRAM-MD5":
+				challenge := fmt.Sprintf("<%d.%d@localhost>", time.Now().UnixNano(), s.Port)
+				var response string
+				response, ok = readResponse(challenge)
+				username, digest, _ := strings.Cut(response, " ")
+				h := hmac.New(md5.New, []byte(s.options.CramMD5Secret))
+				h.Write([]byte(challenge))
+				ok = ok && digest == hex.EncodeToString(h.Sum(nil))
+				result.Username = username
+			case "XOAUTH2":
+				decoded, err := base64.StdEncoding.DecodeString(initial)
+				ok = err == nil
+				for _, field := range strings.Split(string(decoded), "\x01") {
+					key, value, _ := strings.Cut(field, "=")
+					switch key {
+					case "user":
+						result.Username = value
+					case "auth":
+						result.Token = strings.TrimPrefix(value, "Bearer ")
+					}
+				}
+				ok = ok && result.Token != ""
+			default:
+				reply("504 unrecognized authentication type")
+				continue
+			}
+			if !ok {
+				if !reply("535 authentication failed") {
+					return
+				}
+				continue
+			}
+			auth = result
+			if !reply("235 authenticated") {
+				return
+			}
+		case "MAIL":
+			msg = Message{From: trimAddress(arg), TLS: isTLS, Mechanism: auth.Mechanism, Username: auth.Username, Password: auth.Password, Token: auth.Token}
+			if !reply("250 OK") {
+				return
+			}
+		case "RCPT":
+			msg.To = append(msg.To, trimAddress(arg))
+			if !reply("250 OK") {
+				return
+			}
+		case "DATA":
+			if !reply("354 end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := io.ReadAll(conn.DotReader())
+			if err != nil {
+				return
+			}
+			// the dot reader converts the line endings to \n
+			msg.Data = []byte(strings.ReplaceAll(string(data), "\n", "\r\n"))
+			s.mutex.Lock()
+			s.messages = append(s.messages, msg)
+			s.mutex.Unlock()
+			if !reply("250 OK") {
+				return
+			}
+		case "RSET":
+			msg = Message{}
+			if !reply("250 OK") {
+				return
+			}
+		case "QUIT":
+			if s.options.FailQuit {
+				reply("421 service not available")
+				return
+			}
+			reply("221 bye")
+			return
+		default:
+			if !reply(fmt.Sprintf("502 %s not implemented", command)) {
+				return
+			}
+		}
+	}
+}
+
+// trimAddress returns the address of a MAIL FROM:<address> or RCPT TO:<address> argument
+func trimAddress(arg string) string {
+	_, address, _ := strings.Cut(arg, ":")
+	address, _, _ = strings.Cut(strings.TrimSpace(address), " ")
+	return strings.Trim(address, "<>")
+}
+
+// newCertificate returns a PEM encoded self-signed certificate and key for 127.0.0.1 and localhost
+func newCertificate() ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "event-reactor test smtp server"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), nil
+}
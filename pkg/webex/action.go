@@ -0,0 +1,115 @@
+package webex
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505 -- webex signs webhooks using HMAC-SHA1
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// SignatureHeader is the header containing the HMAC-SHA1 signature of the webhook body, created using the secret of the
+	// webhook
+	SignatureHeader = "X-Spark-Signature"
+
+	ResourceAttachmentActions = "attachmentActions"
+	EventCreated              = "created"
+)
+
+// WebhookEvent is the body of a webhook notification. The data only contains the ids of the resource, the resource
+// itself must be fetched using the api
+type WebhookEvent struct {
+	ID       string           `json:"id,omitempty"`
+	Name     string           `json:"name,omitempty"`
+	Resource string           `json:"resource,omitempty"`
+	Event    string           `json:"event,omitempty"`
+	OrgID    string           `json:"orgId,omitempty"`
+	AppID    string           `json:"appId,omitempty"`
+	ActorID  string           `json:"actorId,omitempty"`
+	Data     WebhookEventData `json:"data"`
+}
+
+type WebhookEventData struct {
+	ID        string `json:"id,omitempty"`
+	Type      string `json:"type,omitempty"`
+	MessageID string `json:"messageId,omitempty"`
+	PersonID  string `json:"personId,omitempty"`
+	RoomID    string `json:"roomId,omitempty"`
+	Created   string `json:"created,omitempty"`
+}
+
+// AttachmentAction is the submission of an adaptive card, the inputs contain the values of the input elements of the card
+// and the data of the submit action
+type AttachmentAction struct {
+	ID        string                 `json:"id,omitempty"`
+	Type      string                 `json:"type,omitempty"`
+	MessageID string                 `json:"messageId,omitempty"`
+	PersonID  string                 `json:"personId,omitempty"`
+	RoomID    string                 `json:"roomId,omitempty"`
+	Inputs    map[string]interface{} `json:"inputs,omitempty"`
+	Created   string                 `json:"created,omitempty"`
+}
+
+type Person struct {
+	ID          string   `json:"id,omitempty"`
+	Emails      []string `json:"emails,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+}
+
+// VerifySignature returns true when the signature is the hex encoded HMAC-SHA1 of the body created using the secret
+func VerifySignature(secret string, body []byte, signature string) bool {
+	got, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return false
+	}
+	h := hmac.New(sha1.New, []byte(secret))
+	h.Write(body)
+	return hmac.Equal(got, h.Sum(nil))
+}
+
+// ApiClient reads resources from the webex api, for example the attachment action of a webhook notification
+type ApiClient struct {
+	Log        *zap.Logger
+	ApiUrl     string
+	ApiToken   string
+	MaxRetries int
+}
+
+// GetAttachmentAction returns the attachment action, including the submitted inputs
+func (c ApiClient) GetAttachmentAction(ctx context.Context, id string) (*AttachmentAction, error) {
+	action := &AttachmentAction{}
+	err := c.get(ctx, fmt.Sprintf("attachment/actions/%s", url.PathEscape(id)), action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the webex attachment action '%s' - %v", id, err)
+	}
+	return action, nil
+}
+
+// GetPerson returns the details of the person
+func (c ApiClient) GetPerson(ctx context.Context, id string) (*Person, error) {
+	person := &Person{}
+	err := c.get(ctx, fmt.Sprintf("people/%s", url.PathEscape(id)), person)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the webex person '%s' - %v", id, err)
+	}
+	return person, nil
+}
+
+func (c ApiClient) get(ctx context.Context, path string, out interface{}) error {
+	statusCode, respBody, err := doRequest(ctx, c.Log, c.ApiToken, c.MaxRetries, "GET", fmt.Sprintf("%s/%s", strings.TrimSuffix(c.ApiUrl, "/"), path), "", nil)
+	err = checkWebexHttpResponse(statusCode, respBody, err)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(respBody, out)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal the response body - %v", err)
+	}
+	return nil
+}
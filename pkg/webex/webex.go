@@ -2,21 +2,57 @@ package webex
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
 	"strings"
 
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/kcloutie/event-reactor/pkg/http"
 	"go.uber.org/zap"
 )
 
+// AdaptiveCardContentType is the content type of adaptive card attachments. Adaptive cards are supported by both Webex and
+// Microsoft Teams
+const AdaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+
+const jsonContentType = "application/json"
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// WebexConfiguration is used to send messages using the webex messages api. Requests that are rate limited are retried
+// after the Retry-After duration returned by webex
 type WebexConfiguration struct {
 	Log      *zap.Logger
 	ApiUrl   string
 	ApiToken string
 	SpaceId  string
+	// ToPersonId or ToPersonEmail send a direct message to the person instead of posting the message to the space
+	ToPersonId    string
+	ToPersonEmail string
+	// ParentId posts the message as a reply in the thread of the parent message
+	ParentId string
 	Message  string
+	// Markdown is the markdown of the message. The Message is shown by clients that cannot render markdown
+	Markdown string
 	Card     string
+	// File is uploaded with the message. Webex supports a single file per message, which cannot be combined with a card.
+	// The content type is detected using the extension of the file name when it is not supplied
+	File       *FileAttachment
+	MaxRetries int
+}
+
+// FileAttachment is a file uploaded with a message
+type FileAttachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
 }
 
 type MessageCreateRequest struct {
@@ -29,90 +65,220 @@ type MessageCreateRequest struct {
 	Attachments   []WebexCardAttachment `json:"attachments,omitempty"`
 }
 
+// MessageEditRequest replaces the text and markdown of a message. The attachments of a message cannot be edited
+type MessageEditRequest struct {
+	RoomID   string `json:"roomId"`
+	Text     string `json:"text,omitempty"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+// MessageResponse contains the fields of the message returned by the messages api that are used to edit or reply to it
+type MessageResponse struct {
+	ID       string `json:"id,omitempty"`
+	RoomID   string `json:"roomId,omitempty"`
+	RoomType string `json:"roomType,omitempty"`
+	ParentID string `json:"parentId,omitempty"`
+}
+
 type WebexCardAttachment struct {
 	ContentType string                 `json:"contentType,omitempty"`
 	Content     map[string]interface{} `json:"content,omitempty"`
 }
 
-func checkWebexHttpResponse(resp *http.Response, err error) error {
-	respBodyString := ""
-	if resp != nil && resp.Body != nil {
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(resp.Body)
-		respBodyString = buf.String()
-	}
+// NewAdaptiveCardAttachment unmarshals the card json into an adaptive card attachment
+func NewAdaptiveCardAttachment(card string) (WebexCardAttachment, error) {
+	cardObject := map[string]interface{}{}
+	err := json.Unmarshal([]byte(card), &cardObject)
 	if err != nil {
+		return WebexCardAttachment{}, fmt.Errorf("failed to unmarshal the webex card into json - %v", err)
+	}
+	return WebexCardAttachment{
+		ContentType: AdaptiveCardContentType,
+		Content:     cardObject,
+	}, nil
+}
 
-		respBodyString = fmt.Sprintf("%s - %v", respBodyString, err)
-
-		return fmt.Errorf("unable to send webex message: Response Body: %v", respBodyString)
+func checkWebexHttpResponse(statusCode int, respBody []byte, err error) error {
+	if err != nil {
+		return fmt.Errorf("unable to send webex message: Response Body: %v", fmt.Sprintf("%s - %v", string(respBody), err))
 	}
 
-	if resp.StatusCode <= 199 || resp.StatusCode >= 400 {
-		return fmt.Errorf("webex teams API call failed. Status Code: %v. Response Body: %v", resp.StatusCode, respBodyString)
+	if statusCode <= 199 || statusCode >= 400 {
+		return fmt.Errorf("webex teams API call failed. Status Code: %v. Response Body: %v", statusCode, string(respBody))
 	}
 
 	return nil
 }
 
 func (c WebexConfiguration) SendWithCard() error {
+	_, err := c.Create(context.Background())
+	return err
+}
 
-	log := c.Log.With(zap.String("apiUrl", c.ApiUrl), zap.String("spaceId", c.SpaceId)).Sugar()
+func (c WebexConfiguration) SendMessage() error {
+	c.Card = ""
+	_, err := c.Create(context.Background())
+	return err
+}
 
-	cardObject := map[string]interface{}{}
-	err := json.Unmarshal([]byte(c.Card), &cardObject)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal the webex card into json - %v", err)
+// Create posts the message to the space, or to the person for a direct message, as a reply when the ParentId is set. The
+// card is attached to the message when supplied
+func (c WebexConfiguration) Create(ctx context.Context) (*MessageResponse, error) {
+	log := c.Log.With(zap.String("apiUrl", c.ApiUrl), zap.String("spaceId", c.SpaceId), zap.String("toPersonEmail", c.ToPersonEmail), zap.String("parentId", c.ParentId)).Sugar()
+
+	body := MessageCreateRequest{
+		RoomID:        c.SpaceId,
+		ParentID:      c.ParentId,
+		ToPersonID:    c.ToPersonId,
+		ToPersonEmail: c.ToPersonEmail,
+		Text:          c.Message,
+		Markdown:      c.Markdown,
 	}
-	var cardAttach []WebexCardAttachment
-	cardData := WebexCardAttachment{
-		ContentType: "application/vnd.microsoft.card.adaptive",
-		Content:     cardObject,
+	if c.File != nil {
+		if c.Card != "" {
+			return nil, fmt.Errorf("a card and a file cannot be sent in the same webex message")
+		}
+		bodyBytes, contentType, err := body.multipart(c.File)
+		if err != nil {
+			return nil, err
+		}
+		return c.callApi(ctx, "POST", c.ApiUrl, contentType, bodyBytes, log)
 	}
-	cardAttach = append(cardAttach, cardData)
-	body := MessageCreateRequest{
-		RoomID:      c.SpaceId,
-		Text:        c.Message,
-		Attachments: cardAttach,
+	if c.Card != "" {
+		cardData, err := NewAdaptiveCardAttachment(c.Card)
+		if err != nil {
+			return nil, err
+		}
+		body.Attachments = []WebexCardAttachment{cardData}
 	}
 
 	bodyBytes, err := json.Marshal(body)
 	if err != nil {
-		return fmt.Errorf("failed to marshal the webex request body into json - %v", err)
+		return nil, fmt.Errorf("failed to marshal the webex request body into json - %v", err)
 	}
+	return c.callApi(ctx, "POST", c.ApiUrl, jsonContentType, bodyBytes, log)
+}
 
-	return c.MakeApiCall(bodyBytes, log)
+// multipart returns the multipart/form-data body used to upload the file with the message
+func (r MessageCreateRequest) multipart(file *FileAttachment) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"roomId", r.RoomID},
+		{"parentId", r.ParentID},
+		{"toPersonId", r.ToPersonID},
+		{"toPersonEmail", r.ToPersonEmail},
+		{"text", r.Text},
+		{"markdown", r.Markdown},
+	} {
+		if field.value == "" {
+			continue
+		}
+		err := w.WriteField(field.name, field.value)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create the webex file upload body - %v", err)
+		}
+	}
 
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(file.Name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename="%s"`, quoteEscaper.Replace(file.Name)))
+	header.Set("Content-Type", contentType)
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create the webex file upload body - %v", err)
+	}
+	_, err = part.Write(file.Content)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create the webex file upload body - %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create the webex file upload body - %v", err)
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
 }
 
-func (c WebexConfiguration) SendMessage() error {
-	log := c.Log.With(zap.String("apiUrl", c.ApiUrl), zap.String("spaceId", c.SpaceId)).Sugar()
+// Edit replaces the text and markdown of a message sent earlier. Webex does not support editing the card of a message
+func (c WebexConfiguration) Edit(ctx context.Context, messageId string, roomId string) (*MessageResponse, error) {
+	log := c.Log.With(zap.String("apiUrl", c.ApiUrl), zap.String("messageId", messageId), zap.String("roomId", roomId)).Sugar()
 
-	body := MessageCreateRequest{
-		RoomID: c.SpaceId,
-		Text:   c.Message,
+	body := MessageEditRequest{
+		RoomID:   roomId,
+		Text:     c.Message,
+		Markdown: c.Markdown,
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the webex request body into json - %v", err)
 	}
+	return c.callApi(ctx, "PUT", fmt.Sprintf("%s/%s", strings.TrimSuffix(c.ApiUrl, "/"), url.PathEscape(messageId)), jsonContentType, bodyBytes, log)
+}
 
-	bodyBytes, _ := json.Marshal(body)
+func (c WebexConfiguration) MakeApiCall(bodyBytes []byte, log *zap.SugaredLogger) error {
+	_, err := c.callApi(context.Background(), "POST", c.ApiUrl, jsonContentType, bodyBytes, log)
+	return err
+}
 
-	return c.MakeApiCall(bodyBytes, log)
+func (c WebexConfiguration) callApi(ctx context.Context, method string, apiUrl string, contentType string, bodyBytes []byte, log *zap.SugaredLogger) (*MessageResponse, error) {
+	statusCode, respBody, err := doRequest(ctx, c.Log, c.ApiToken, c.MaxRetries, method, apiUrl, contentType, bodyBytes)
+	err = checkWebexHttpResponse(statusCode, respBody, err)
+	if err != nil {
+		if contentType == jsonContentType {
+			log.Infow("Failed webex call body contents", "bodyContents", string(bodyBytes))
+		}
+		return nil, err
+	}
 
+	// the message was sent, so a response that is not a message is logged instead of failing the call. The message cannot
+	// be edited or replied to as its id is unknown
+	resp := &MessageResponse{}
+	err = json.Unmarshal(respBody, resp)
+	if err != nil {
+		log.Warnw("The webex api response is not a message", "responseBody", string(respBody), "error", err)
+		return &MessageResponse{}, nil
+	}
+	return resp, nil
 }
 
-func (c WebexConfiguration) MakeApiCall(bodyBytes []byte, log *zap.SugaredLogger) error {
-	client := &http.Client{}
-	req, _ := http.NewRequest("POST", c.ApiUrl, bytes.NewBuffer(bodyBytes))
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %v", c.ApiToken))
-	req.Header.Add("Content-Type", "application/json")
-
-	response, err := client.Do(req)
+func doRequest(ctx context.Context, log *zap.Logger, apiToken string, maxRetries int, method string, apiUrl string, contentType string, bodyBytes []byte) (int, []byte, error) {
+	var body io.Reader
+	if bodyBytes != nil {
+		body = bytes.NewBuffer(bodyBytes)
+	}
+	req, err := retryablehttp.NewRequestWithContext(ctx, method, apiUrl, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %v", apiToken))
+	if contentType != "" {
+		req.Header.Add("Content-Type", contentType)
+	}
 
-	err = checkWebexHttpResponse(response, err)
+	// the retry client honours the Retry-After header webex returns with 429 responses. A POST creates a message, so it is
+	// only retried when it was rate limited as retrying a 5xx or a network error could create the message twice
+	retryClient := http.NewHttpRetryClient(log, maxRetries)
+	if method == "POST" {
+		retryClient.CheckRetry = http.RateLimitRetryPolicy
+	}
+	response, err := retryClient.Do(req)
 	if err != nil {
-		log.Infof("Failed webex call body contents", "bodyContents", string(bodyBytes))
+		return 0, nil, err
 	}
-	if err != nil && strings.Contains(err.Error(), "invalid character") {
-		log.Infof("Failed webex call body contents", "bodyContents", string(bodyBytes))
+	defer response.Body.Close()
+
+	respBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return response.StatusCode, nil, fmt.Errorf("failed to read the response body: %v", err)
 	}
-	return err
+	return response.StatusCode, respBody, nil
 }
@@ -2,10 +2,14 @@ package webex
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 
 	"go.uber.org/zap/zaptest"
@@ -192,3 +196,188 @@ func TestSendMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestWebexConfiguration_CreateAndEdit(t *testing.T) {
+	type call struct {
+		Method string
+		Path   string
+		Body   string
+	}
+	calls := []call{}
+	rateLimited := false
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		buf := new(bytes.Buffer)
+		_, _ = buf.ReadFrom(req.Body)
+		calls = append(calls, call{Method: req.Method, Path: req.URL.Path, Body: buf.String()})
+		if !rateLimited {
+			rateLimited = true
+			rw.Header().Set("Retry-After", "0")
+			rw.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = rw.Write([]byte(`{"id":"msg1","roomId":"room1","roomType":"direct"}`))
+	}))
+	defer server.Close()
+
+	c := WebexConfiguration{
+		Log:           zaptest.NewLogger(t),
+		ApiUrl:        server.URL + "/v1/messages",
+		ApiToken:      "token",
+		ToPersonEmail: "user@example.com",
+		ParentId:      "parent1",
+		Message:       "deploy started",
+		Markdown:      "deploy **started**",
+		MaxRetries:    2,
+	}
+	got, err := c.Create(context.Background())
+	if err != nil {
+		t.Fatalf("WebexConfiguration.Create() error = %v", err)
+	}
+	if got.ID != "msg1" || got.RoomID != "room1" {
+		t.Errorf("WebexConfiguration.Create() = %+v, want the id msg1 and roomId room1", got)
+	}
+
+	c.Message, c.Markdown = "deploy finished", "deploy **finished**"
+	_, err = c.Edit(context.Background(), got.ID, got.RoomID)
+	if err != nil {
+		t.Fatalf("WebexConfiguration.Edit() error = %v", err)
+	}
+
+	createBody := `{"parentId":"parent1","toPersonEmail":"user@example.com","text":"deploy started","markdown":"deploy **started**"}`
+	wantCalls := []call{
+		{Method: "POST", Path: "/v1/messages", Body: createBody},
+		{Method: "POST", Path: "/v1/messages", Body: createBody},
+		{Method: "PUT", Path: "/v1/messages/msg1", Body: `{"roomId":"room1","text":"deploy finished","markdown":"deploy **finished**"}`},
+	}
+	if len(calls) != len(wantCalls) {
+		t.Fatalf("calls = %+v, want %+v", calls, wantCalls)
+	}
+	for i := range calls {
+		if calls[i] != wantCalls[i] {
+			t.Errorf("call %d = %+v, want %+v", i, calls[i], wantCalls[i])
+		}
+	}
+}
+
+func TestWebexConfiguration_CreateWithFile(t *testing.T) {
+	type upload struct {
+		ContentType string
+		Fields      map[string]string
+		FileName    string
+		FileType    string
+		FileContent string
+	}
+	uploads := []upload{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		err := req.ParseMultipartForm(1 << 20)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(err.Error()))
+			return
+		}
+		u := upload{ContentType: strings.Split(req.Header.Get("Content-Type"), ";")[0], Fields: map[string]string{}}
+		for k, v := range req.MultipartForm.Value {
+			u.Fields[k] = v[0]
+		}
+		file, header, err := req.FormFile("files")
+		if err == nil {
+			content, _ := io.ReadAll(file)
+			u.FileName, u.FileType, u.FileContent = header.Filename, header.Header.Get("Content-Type"), string(content)
+		}
+		uploads = append(uploads, u)
+		_, _ = rw.Write([]byte(`{"id":"msg1","roomId":"room1"}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		config  WebexConfiguration
+		want    []upload
+		wantErr string
+	}{
+		{
+			name: "file",
+			config: WebexConfiguration{
+				SpaceId:  "room1",
+				Markdown: "release notes **attached**",
+				File:     &FileAttachment{Name: "notes.json", Content: []byte(`{"notes":[]}`)},
+			},
+			want: []upload{{
+				ContentType: "multipart/form-data",
+				Fields:      map[string]string{"roomId": "room1", "markdown": "release notes **attached**"},
+				FileName:    "notes.json",
+				FileType:    "application/json",
+				FileContent: `{"notes":[]}`,
+			}},
+		},
+		{
+			name: "file with content type",
+			config: WebexConfiguration{
+				ToPersonEmail: "user@example.com",
+				ParentId:      "parent1",
+				File:          &FileAttachment{Name: "report", ContentType: "application/pdf", Content: []byte("pdf")},
+			},
+			want: []upload{{
+				ContentType: "multipart/form-data",
+				Fields:      map[string]string{"toPersonEmail": "user@example.com", "parentId": "parent1"},
+				FileName:    "report",
+				FileType:    "application/pdf",
+				FileContent: "pdf",
+			}},
+		},
+		{
+			name: "file and card",
+			config: WebexConfiguration{
+				SpaceId: "room1",
+				Card:    `{"type":"AdaptiveCard","body":[]}`,
+				File:    &FileAttachment{Name: "notes.md", Content: []byte("# notes")},
+			},
+			want:    []upload{},
+			wantErr: "a card and a file cannot be sent in the same webex message",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploads = []upload{}
+			tt.config.Log = zaptest.NewLogger(t)
+			tt.config.ApiUrl = server.URL
+			tt.config.ApiToken = "token"
+			_, err := tt.config.Create(context.Background())
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("WebexConfiguration.Create() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			} else if tt.wantErr != "" {
+				t.Errorf("WebexConfiguration.Create() error = nil, wantErr %v", tt.wantErr)
+			}
+			if !reflect.DeepEqual(uploads, tt.want) {
+				t.Errorf("WebexConfiguration.Create() uploads = %+v, want %+v", uploads, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebexConfiguration_CreateIsNotRetried(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		rw.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	c := WebexConfiguration{
+		Log:        zaptest.NewLogger(t),
+		ApiUrl:     server.URL + "/v1/messages",
+		ApiToken:   "token",
+		SpaceId:    "room1",
+		Message:    "deploy started",
+		MaxRetries: 2,
+	}
+	_, err := c.Create(context.Background())
+	if err == nil {
+		t.Fatalf("WebexConfiguration.Create() error = nil, want the bad gateway error")
+	}
+	if calls != 1 {
+		t.Errorf("WebexConfiguration.Create() calls = %v, want 1 so the message is not created twice", calls)
+	}
+}